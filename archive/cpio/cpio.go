@@ -1,7 +1,6 @@
 package cpio
 
 import (
-	"errors"
 	"io"
 	"io/ioutil"
 )
@@ -28,8 +27,12 @@ func (s *streamReader) Next() (*Header, io.Reader,  error) {
 			return nil, nil, err
 		}
 	}
+	start := s.r.n
 	h, err := ReadNewcHeader(s)
 	if err != nil {
+		if err != io.EOF {
+			err = &FormatError{Offset: start, Err: err}
+		}
 		return nil, nil, err
 	}
 	f, err := newFileStreamLimitReader(s.r, h.Size)
@@ -67,7 +70,7 @@ func (r *readSeekCounter) Read(d []byte) (n int, err error) {
 
 func (r *readSeekCounter) Seek(offset int64, whence int) (n int64, err error) {
 	if whence != 1 {
-		return 0, errors.New("error can only seek from current position")
+		return 0, ErrSeek
 	}
 	if offset == 0 {
 		return r.n, nil
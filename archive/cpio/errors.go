@@ -0,0 +1,28 @@
+package cpio
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadMagic       = errors.New("cpio: bad header magic")
+	ErrBadHeader      = errors.New("cpio: malformed header")
+	ErrOutOfOrderRead = errors.New("cpio: out of order read")
+	ErrSeek           = errors.New("cpio: can only seek from current position")
+)
+
+// FormatError reports malformed archive entry header at Offset
+// from the start of the archive
+type FormatError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("cpio: malformed entry at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
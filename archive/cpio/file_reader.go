@@ -1,7 +1,6 @@
 package cpio
 
 import (
-	"errors"
 	"io"
 )
 
@@ -35,7 +34,7 @@ func (f *fileStreamLimitReader) Read(d []byte) (n int, err error) {
 		return 0, err
 	}
 	if f.start+f.n != p {
-		return 0, errors.New("error out of order read")
+		return 0, ErrOutOfOrderRead
 	}
 
 	l := int64(len(d))
@@ -19,6 +19,9 @@ type Header struct {
 	Links int
 	Mtime time.Time
 	Size int64
+	// device number of character and block devices
+	RdevMajor int
+	RdevMinor int
 	Name string
 	NameSize int
 	Linkname string
@@ -1,7 +1,7 @@
 package cpio
 
 import (
-	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"strconv"
@@ -11,6 +11,7 @@ import (
 const (
 	newcMagic = "070701"
 	newcHeaderLen = 110
+	maxNameSize = 64 * 1024
 )
 
 type newcReader struct {
@@ -30,7 +31,7 @@ func (r newcReader) Read16() (int, error) {
 	}
 	i16, err := strconv.ParseInt(string(buf), 16, 0)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("%w: invalid field %q", ErrBadHeader, buf)
 	}
 	return int(i16), nil
 }
@@ -44,7 +45,7 @@ func ReadNewcHeader(r io.Reader) (*Header, error) {
 	}
 
 	if string(magic) != newcMagic {
-		return nil, errors.New("Bad Header Magic")
+		return nil, ErrBadMagic
 	}
 
 	h := &Header{}
@@ -88,8 +89,18 @@ func ReadNewcHeader(r io.Reader) (*Header, error) {
 	}
 	h.Size = int64(i)
 
-	// skip dev and rdev
-	if _, err = io.CopyN(ioutil.Discard, cr, int64(32)); err != nil {
+	// skip dev
+	if _, err = io.CopyN(ioutil.Discard, cr, int64(16)); err != nil {
+		return nil, err
+	}
+
+	h.RdevMajor, err = cr.Read16()
+	if err != nil {
+		return nil, err
+	}
+
+	h.RdevMinor, err = cr.Read16()
+	if err != nil {
 		return nil, err
 	}
 
@@ -104,6 +115,9 @@ func ReadNewcHeader(r io.Reader) (*Header, error) {
 		return nil, err
 	}
 	h.Checksum = uint32(i)
+	if h.NameSize < 1 || h.NameSize > maxNameSize {
+		return nil, fmt.Errorf("%w: name size %d", ErrBadHeader, h.NameSize)
+	}
 	name := make([]byte, h.NameSize)
 	if _, err = io.ReadFull(cr, name); err != nil {
 		return nil, err
@@ -0,0 +1,94 @@
+package applydelta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/deltarpm"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Delta string
+	Old   string
+	// Output is path of new package, NEVR.rpm in current directory
+	// by default
+	Output string
+	Info   bool
+}
+
+func NewApplydeltaCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "applydelta DELTA.drpm [OLD.rpm]",
+		Short: "Rebuild package from delta rpm",
+		Long: "Rebuild new package from rpm-only delta rpm and old package " +
+			"file and verify its digest",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if o.Info && len(args) != 1 {
+				return errors.New("--info requires delta only")
+			}
+			if !o.Info && len(args) != 2 {
+				return errors.New("command requires delta and old package")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Delta = args[0]
+			if len(args) == 2 {
+				o.Old = args[1]
+			}
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "write new package into file")
+	cmd.Flags().BoolVarP(&o.Info, "info", "i", false, "print information about delta")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	d, err := deltarpm.ReadFile(o.Delta)
+	if err != nil {
+		return err
+	}
+	if o.Info {
+		fmt.Printf("version:  %d\n", d.Version)
+		fmt.Printf("rpm-only: %t\n", d.RPMOnly)
+		fmt.Printf("new:      %s\n", d.NEVR)
+		fmt.Printf("old:      %s\n", d.OldNEVR)
+		fmt.Printf("sequence: %s\n", d.SequenceID())
+		fmt.Printf("size:     %d\n", d.Size)
+		fmt.Printf("md5:      %x\n", d.MD5)
+		return nil
+	}
+
+	if len(o.Output) == 0 {
+		o.Output = d.NEVR + ".rpm"
+	}
+	old, err := os.Open(o.Old)
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+	tmp := o.Output + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = d.Apply(old, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, o.Output)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
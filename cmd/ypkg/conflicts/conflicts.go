@@ -0,0 +1,99 @@
+package conflicts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/conflicts"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Paths []string
+	JSON  bool
+}
+
+func NewConflictsCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "conflicts PACKAGE.rpm|DIR...",
+		Short: "Find files packages ship with different content without declaring conflicts",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("command requires at least one argument")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Paths = args
+			found, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+			}
+			if err != nil || found {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print conflicts as JSON")
+	return cmd
+}
+
+// Run reports whether any conflict was found
+func (o *Options) Run() (bool, error) {
+	ix := conflicts.NewIndex()
+	add := func(fname string) error {
+		pkg, err := rpmutil.OpenFile(fname)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+		if pkg.Lead.IsSource() {
+			return nil
+		}
+		if err := ix.Add(pkg.Header); err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+		return nil
+	}
+	for _, p := range o.Paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return false, err
+		}
+		if !fi.IsDir() {
+			if err := add(p); err != nil {
+				return false, err
+			}
+			continue
+		}
+		err = filepath.Walk(p, func(fname string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || !strings.HasSuffix(fname, ".rpm") {
+				return err
+			}
+			return add(fname)
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	res := ix.Conflicts()
+	if o.JSON {
+		if res == nil {
+			res = []conflicts.Conflict{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return len(res) > 0, enc.Encode(res)
+	}
+	for _, c := range res {
+		fmt.Printf("%s %s: %s, %s\n", c.Differ, c.Path, c.Packages[0], c.Packages[1])
+	}
+	return len(res) > 0, nil
+}
@@ -0,0 +1,56 @@
+package createrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Dir string
+	repo.CreateOptions
+	Quiet bool
+}
+
+func NewCreaterepoCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "createrepo DIR",
+		Short: "Generate repository metadata for packages in directory",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("command requires repository directory")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Dir = args[0]
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.Update, "update", false, "reuse metadata of unchanged packages")
+	cmd.Flags().IntVarP(&o.Workers, "workers", "j", 0, "number of packages read in parallel")
+	cmd.Flags().StringVar(&o.Checksum, "checksum", "sha256", "checksum type of packages and metadata")
+	cmd.Flags().IntVar(&o.ChangelogLimit, "changelog-limit", 0, "keep only that many newest changelog entries")
+	cmd.Flags().BoolVar(&o.KeepMetadata, "keep-all-metadata", false, "keep metadata of other types, like updateinfo")
+	cmd.Flags().BoolVarP(&o.Quiet, "quiet", "q", false, "don't print summary")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	res, err := repo.Create(o.Dir, &o.CreateOptions)
+	if err != nil {
+		return err
+	}
+	if !o.Quiet {
+		fmt.Printf("%d packages, %d reused, revision %s\n", res.Packages, res.Reused, res.MD.Revision)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/rpmdiff"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Old, New string
+	JSON     bool
+	Contents bool
+	MTime    bool
+	// Repro compares rebuilds of package, Run reports failure when
+	// they are not reproducible
+	Repro bool
+}
+
+func NewDiffCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "diff OLD.rpm NEW.rpm",
+		Short: "Show differences of two packages",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("command requires exactly two arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Old, o.New = args[0], args[1]
+			failed, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+			}
+			if err != nil || failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print differences as JSON")
+	cmd.Flags().BoolVarP(&o.Contents, "contents", "c", false, "show diffs of changed text files")
+	cmd.Flags().BoolVar(&o.MTime, "mtime", false, "compare modification times of files")
+	cmd.Flags().BoolVar(&o.Repro, "reproducible", false, "check whether packages are bit-for-bit rebuilds of each other")
+	return cmd
+}
+
+// Run prints differences of packages, with Repro it reports whether
+// packages are not reproducible
+func (o *Options) Run() (bool, error) {
+	old, err := rpmutil.OpenFile(o.Old)
+	if err != nil {
+		return false, err
+	}
+	new, err := rpmutil.OpenFile(o.New)
+	if err != nil {
+		return false, err
+	}
+	opts := rpmdiff.DefaultOptions
+	opts.Contents, opts.MTime = o.Contents, o.MTime
+	compare := rpmdiff.Compare
+	if o.Repro {
+		compare = rpmdiff.Reproducible
+	}
+	res, err := compare(old, new, &opts)
+	if err != nil {
+		return false, err
+	}
+	if o.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	} else {
+		err = res.WriteText(os.Stdout)
+	}
+	return o.Repro && !res.Empty(), err
+}
@@ -0,0 +1,127 @@
+package elfdeps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/elfdeps"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	elfdeps.Options
+	Root  string
+	Files []string
+	// PerFile prints dependencies of every file separately
+	PerFile bool
+	Audit   bool
+}
+
+func NewElfdepsCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "elfdeps [PACKAGE.rpm...]",
+		Short: "Generate soname dependencies of ELF files in packages or build root",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 && len(o.Root) == 0 {
+				return errors.New("command requires packages or --root")
+			}
+			if len(args) > 0 && len(o.Root) > 0 {
+				return errors.New("packages and --root are mutually exclusive")
+			}
+			if o.Audit && len(o.Root) > 0 {
+				return errors.New("--audit works with packages only")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Files = args
+			ok, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+			}
+			if err != nil || !ok {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Root, "root", "", "look at files in build root instead of packages")
+	cmd.Flags().BoolVar(&o.PerFile, "files", false, "print dependencies of every file")
+	cmd.Flags().BoolVar(&o.Audit, "audit", false, "compare generated dependencies with ones packages declare")
+	cmd.Flags().BoolVar(&o.SonameOnly, "soname-only", false, "leave out symbol versions")
+	cmd.Flags().BoolVar(&o.NoFakeSoname, "no-fake-soname", false, "don't provide basename of libraries without soname")
+	cmd.Flags().BoolVar(&o.RequireInterp, "require-interp", false, "require program interpreter")
+	return cmd
+}
+
+// Run prints dependencies, with Audit it reports whether declared
+// dependencies match generated ones
+func (o *Options) Run() (bool, error) {
+	if len(o.Root) > 0 {
+		files, err := elfdeps.FromDir(o.Root, &o.Options)
+		if err != nil {
+			return false, err
+		}
+		o.print("", files)
+		return true, nil
+	}
+
+	ok := true
+	for _, fname := range o.Files {
+		pkg, err := rpmutil.OpenFile(fname)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		files, err := elfdeps.FromPayload(pkg, &o.Options)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		if !o.Audit {
+			o.print(fname+": ", files)
+			continue
+		}
+		m, err := elfdeps.Audit(pkg.Header, elfdeps.Merge(files))
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		for _, d := range []struct {
+			msg  string
+			deps []string
+		}{
+			{"missing provides", m.MissingProvides},
+			{"extra provides", m.ExtraProvides},
+			{"missing requires", m.MissingRequires},
+			{"extra requires", m.ExtraRequires},
+		} {
+			for _, dep := range d.deps {
+				fmt.Printf("%s: %s: %s\n", fname, d.msg, dep)
+			}
+		}
+		ok = ok && m.Empty()
+	}
+	return ok, nil
+}
+
+func (o *Options) print(prefix string, files []elfdeps.FileDeps) {
+	if o.PerFile {
+		for _, f := range files {
+			for _, p := range f.Provides {
+				fmt.Printf("%s%s: Provides: %s\n", prefix, f.Path, p)
+			}
+			for _, r := range f.Requires {
+				fmt.Printf("%s%s: Requires: %s\n", prefix, f.Path, r)
+			}
+		}
+		return
+	}
+	deps := elfdeps.Merge(files)
+	for _, p := range deps.Provides {
+		fmt.Printf("%sProvides: %s\n", prefix, p)
+	}
+	for _, r := range deps.Requires {
+		fmt.Printf("%sRequires: %s\n", prefix, r)
+	}
+}
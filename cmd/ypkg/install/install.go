@@ -0,0 +1,135 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/cmd/ypkg/resolve"
+	"code.pikelabs.net/go/rpm/install"
+	"code.pikelabs.net/go/rpm/solve"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Root    string
+	Repos   []string
+	Specs   []string
+	Arch    string
+	Scripts string
+	Runner  []string
+	NoOwner bool
+	DryRun  bool
+	solve.Options
+	JSON bool
+}
+
+func NewInstallCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "install --root DIR -r REPO... SPEC...",
+		Short: "Install packages into alternate root",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("command requires package specs")
+			}
+			if len(o.Root) == 0 {
+				return errors.New("command requires root directory")
+			}
+			if len(o.Repos) == 0 {
+				return errors.New("command requires at least one repository")
+			}
+			switch install.ScriptMode(o.Scripts) {
+			case install.ScriptsSkip, install.ScriptsRun, install.ScriptsReport:
+			default:
+				return fmt.Errorf("unknown scripts mode %q", o.Scripts)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Specs = args
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Root, "root", "", "directory to install into")
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository base URL or directory")
+	cmd.Flags().StringVar(&o.Arch, "arch", solve.DefaultArch(), "architecture to install for")
+	cmd.Flags().BoolVar(&o.IgnoreWeak, "no-weak", false, "don't install weak dependencies")
+	cmd.Flags().StringVar(&o.Scripts, "scripts", string(install.ScriptsSkip), "what to do with scriptlets, skip, run or report")
+	cmd.Flags().StringArrayVar(&o.Runner, "runner", []string{"chroot"}, "command running scriptlets in root, repeat for its arguments")
+	cmd.Flags().BoolVar(&o.NoOwner, "no-owner", false, "don't set owners of files")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "print packages to install without installing them")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print result as JSON")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	db, err := install.OpenDB(o.Root)
+	if err != nil {
+		return err
+	}
+	pool, sources, err := resolve.LoadRepos(o.Arch, o.Repos)
+	if err != nil {
+		return err
+	}
+	pool.AddInstalled(db.Packages()...)
+	t, err := pool.Resolve(o.Specs, &o.Options)
+	if err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		for _, s := range t.Install {
+			fmt.Printf("%s (%s)\n", s.Package.NEVRA(), s.Reason)
+		}
+		return nil
+	}
+	items := make([]install.Item, len(t.Install))
+	for i, s := range t.Install {
+		items[i] = install.Item{Package: s.Package, Source: sources[s.Package]}
+	}
+	in := install.NewInstaller(o.Root)
+	in.Scripts = install.ScriptMode(o.Scripts)
+	in.Runner = o.Runner
+	in.NoOwner = o.NoOwner
+	if o.JSON {
+		// output of scriptlets would mangle JSON
+		in.Stdout = os.Stderr
+	}
+	res, err := in.Install(items)
+	if res != nil {
+		o.print(res)
+	}
+	return err
+}
+
+func (o *Options) print(res *install.Result) {
+	if o.JSON {
+		if res.Installed == nil {
+			res.Installed = []*install.Record{}
+		}
+		if res.Scripts == nil {
+			res.Scripts = []install.ScriptReport{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+		return
+	}
+	for _, r := range res.Installed {
+		fmt.Printf("installed %s\n", r.Package.NEVRA())
+	}
+	for _, s := range res.Scripts {
+		switch {
+		case len(s.Error) > 0:
+			fmt.Printf("%s %s (%s): %s\n", s.Package, s.Phase, s.Interpreter, s.Error)
+		case !s.Ran:
+			fmt.Printf("%s %s (%s):\n%s\n", s.Package, s.Phase, s.Interpreter, s.Body)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package license
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/license"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Files []string
+	Repos []string
+	license.Policy
+	JSON bool
+}
+
+func NewLicenseCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "license [FILE... | -r REPO...]",
+		Short: "Check licenses of packages",
+		Long: "Print licenses of packages as SPDX expressions and check them " +
+			"against SPDX license list and allow and deny lists",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) > 0) == (len(o.Repos) > 0) {
+				return errors.New("command requires either package files or repositories")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Files = args
+			failed, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+			}
+			if err != nil || failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository base URL or directory")
+	cmd.Flags().StringSliceVar(&o.Allow, "allow", nil, "licenses allowed, glob patterns like GPL-*")
+	cmd.Flags().StringSliceVar(&o.Deny, "deny", nil, "licenses denied, glob patterns like AGPL-*")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print report as JSON")
+	return cmd
+}
+
+type report struct {
+	Package string   `json:"package"`
+	License string   `json:"license"`
+	SPDX    string   `json:"spdx,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Unknown []string `json:"unknown,omitempty"`
+	// Ambiguous are legacy names which cover several SPDX licenses
+	Ambiguous []string `json:"ambiguous,omitempty"`
+	Denied    []string `json:"denied,omitempty"`
+}
+
+func (r *report) failed() bool {
+	return len(r.Error) > 0 || len(r.Unknown) > 0 || len(r.Denied) > 0
+}
+
+// Run reports whether any package has invalid or unacceptable license
+func (o *Options) Run() (bool, error) {
+	var reports []*report
+	add := func(nevra, lic string) {
+		r := &report{Package: nevra, License: lic}
+		e, err := license.Parse(lic)
+		if len(lic) == 0 {
+			r.Error = "no license"
+		} else if err != nil {
+			r.Error = err.Error()
+		} else {
+			e = license.Normalize(e)
+			r.SPDX = e.String()
+			r.Unknown = e.Unknown()
+			r.Ambiguous = e.Ambiguous()
+			r.Denied = o.Violations(e)
+		}
+		reports = append(reports, r)
+	}
+	for _, fname := range o.Files {
+		pkg, err := rpmutil.OpenFile(fname)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		p, err := repo.PackageFromHeader(pkg, 0)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		add(p.NEVRA(), p.License)
+	}
+	for _, base := range o.Repos {
+		r, err := repo.Open(base)
+		if err != nil {
+			return false, err
+		}
+		pkgs, err := r.Load(nil)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", base, err)
+		}
+		for _, p := range pkgs {
+			add(p.NEVRA(), p.License)
+		}
+	}
+
+	failed := false
+	for _, r := range reports {
+		failed = failed || r.failed()
+	}
+	if o.JSON {
+		if reports == nil {
+			reports = []*report{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return failed, enc.Encode(reports)
+	}
+	for _, r := range reports {
+		var notes []string
+		if len(r.Error) > 0 {
+			notes = append(notes, r.Error)
+		}
+		if len(r.Unknown) > 0 {
+			notes = append(notes, "unknown "+strings.Join(r.Unknown, ", "))
+		}
+		if len(r.Ambiguous) > 0 {
+			notes = append(notes, "ambiguous "+strings.Join(r.Ambiguous, ", "))
+		}
+		if len(r.Denied) > 0 {
+			notes = append(notes, "denied "+strings.Join(r.Denied, ", "))
+		}
+		lic := r.SPDX
+		if len(lic) == 0 {
+			lic = r.License
+		}
+		switch {
+		case len(notes) == 0:
+			fmt.Printf("%s: %s\n", r.Package, lic)
+		case len(lic) == 0:
+			fmt.Printf("%s: (%s)\n", r.Package, strings.Join(notes, "; "))
+		default:
+			fmt.Printf("%s: %s (%s)\n", r.Package, lic, strings.Join(notes, "; "))
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,103 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/rpmlint"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Files    []string
+	JSON     bool
+	List     bool
+	Suppress []string
+	Checks   []string
+}
+
+func NewLintCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "lint PACKAGE.rpm...",
+		Short: "Check packages for common problems",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 && !o.List {
+				return errors.New("command requires at least one argument")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Files = args
+			failed, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+			}
+			if err != nil || failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print findings as JSON")
+	cmd.Flags().BoolVar(&o.List, "list", false, "list available checks")
+	cmd.Flags().StringArrayVarP(&o.Suppress, "suppress", "s", nil, "suppress findings of check, optionally for paths matching glob (check[:glob])")
+	cmd.Flags().StringSliceVarP(&o.Checks, "check", "c", nil, "run only given checks")
+	return cmd
+}
+
+// Run lints packages and reports whether any error was found
+func (o *Options) Run() (bool, error) {
+	if o.List {
+		for _, c := range rpmlint.Checks() {
+			fmt.Printf("%-32s %-8s %s\n", c.Name, c.Severity, c.Description)
+		}
+		return false, nil
+	}
+
+	var l rpmlint.Linter
+	for _, s := range o.Suppress {
+		l.Suppress = append(l.Suppress, rpmlint.ParseSuppression(s))
+	}
+	if len(o.Checks) > 0 {
+		all := map[string]*rpmlint.Check{}
+		for _, c := range rpmlint.Checks() {
+			all[c.Name] = c
+		}
+		for _, name := range o.Checks {
+			c, ok := all[name]
+			if !ok {
+				return false, fmt.Errorf("unknown check %s", name)
+			}
+			l.Checks = append(l.Checks, c)
+		}
+	}
+
+	var findings []rpmlint.Finding
+	for _, fname := range o.Files {
+		pkg, err := rpmutil.OpenFile(fname)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		res, err := l.Lint(pkg)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", fname, err)
+		}
+		findings = append(findings, res...)
+	}
+
+	var err error
+	if o.JSON {
+		err = rpmlint.WriteJSON(os.Stdout, findings)
+	} else {
+		err = rpmlint.WriteText(os.Stdout, findings)
+	}
+	for _, f := range findings {
+		if f.Severity == rpmlint.Error {
+			return true, err
+		}
+	}
+	return false, err
+}
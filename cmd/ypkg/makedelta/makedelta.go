@@ -0,0 +1,103 @@
+package makedelta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/deltarpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Old, New string
+	// Output is path of delta, named after both packages in current
+	// directory by default
+	Output string
+	Quiet  bool
+}
+
+func NewMakedeltaCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "makedelta OLD.rpm NEW.rpm",
+		Short: "Make delta rpm between two builds of package",
+		Long: "Make rpm-only delta rpm in format of deltarpm, which " +
+			"rebuilds new package from old package file",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("command requires exactly two arguments")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Old, o.New = args[0], args[1]
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "write delta into file")
+	cmd.Flags().BoolVarP(&o.Quiet, "quiet", "q", false, "don't print size of delta")
+	return cmd
+}
+
+// deltaName returns name deltarpm gives to delta of old and new
+// package, like foo-1.0-1_1.1-1.x86_64.drpm
+func deltaName(d *deltarpm.Delta, arch string) string {
+	new := d.NEVR
+	// new is the same package, keep its version-release only
+	if i := strings.LastIndexByte(new, '-'); i > 0 {
+		if j := strings.LastIndexByte(new[:i], '-'); j > 0 {
+			new = new[j+1:]
+		}
+	}
+	return d.OldNEVR + "_" + new + "." + arch + ".drpm"
+}
+
+func (o *Options) Run() error {
+	old, err := os.Open(o.Old)
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+	new, err := os.Open(o.New)
+	if err != nil {
+		return err
+	}
+	defer new.Close()
+	d, err := deltarpm.Make(old, new)
+	if err != nil {
+		return fmt.Errorf("%s: %w", o.New, err)
+	}
+	if len(o.Output) == 0 {
+		pkg, err := rpmutil.OpenFile(o.New)
+		if err != nil {
+			return err
+		}
+		arch, _ := pkg.Header.GetString(rpm.TagArch)
+		o.Output = deltaName(d, arch)
+	}
+	f, err := os.Create(o.Output)
+	if err != nil {
+		return err
+	}
+	n, err := d.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(o.Output)
+		return err
+	}
+	if !o.Quiet {
+		fmt.Printf("%s: %d bytes, %.1f%% of %s\n", o.Output, n, float64(n)*100/float64(d.Size), filepath.Base(o.New))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package modifyrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	File, Dir string
+	// Type is metadata type, taken from name of File by default,
+	// updateinfo for updateinfo.xml
+	Type     string
+	Checksum string
+	// Replace replaces advisories of repository instead of merging
+	// them with those of File
+	Replace bool
+	Quiet   bool
+}
+
+func NewModifyrepoCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "modifyrepo FILE DIR",
+		Short: "Add metadata file to repository",
+		Long: "Add metadata file, like updateinfo.xml, to repository in directory. " +
+			"Advisories of updateinfo are merged with those repository has.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("command requires metadata file and repository directory")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.File, o.Dir = args[0], args[1]
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Type, "mdtype", "", "metadata type, by default name of file up to the first dot")
+	cmd.Flags().StringVar(&o.Checksum, "checksum", "sha256", "checksum type of metadata")
+	cmd.Flags().BoolVar(&o.Replace, "replace", false, "replace advisories of repository instead of merging them")
+	cmd.Flags().BoolVarP(&o.Quiet, "quiet", "q", false, "don't print summary")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	if len(o.Type) == 0 {
+		o.Type = strings.SplitN(filepath.Base(o.File), ".", 2)[0]
+	}
+	d, err := ioutil.ReadFile(o.File)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("%s added", o.Type)
+	if o.Type == repo.UpdateInfo {
+		advisories, err := repo.ParseUpdateInfo(bytes.NewReader(d))
+		if err != nil {
+			return fmt.Errorf("%s: %w", o.File, err)
+		}
+		if !o.Replace {
+			r, err := repo.Open(o.Dir)
+			if err != nil {
+				return err
+			}
+			old, err := r.Advisories()
+			if err != nil {
+				return err
+			}
+			advisories = repo.MergeAdvisories(old, advisories)
+		}
+		var b bytes.Buffer
+		if err := repo.WriteUpdateInfo(&b, advisories); err != nil {
+			return err
+		}
+		d = b.Bytes()
+		summary = fmt.Sprintf("%s added, %d advisories", o.Type, len(advisories))
+	}
+	if _, err := repo.AddMetadata(o.Dir, o.Type, d, o.Checksum); err != nil {
+		return err
+	}
+	if !o.Quiet {
+		fmt.Println(summary)
+	}
+	return nil
+}
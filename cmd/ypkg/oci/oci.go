@@ -0,0 +1,131 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"code.pikelabs.net/go/cmd/ypkg/resolve"
+	"code.pikelabs.net/go/rpm/oci"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/solve"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Output    string
+	Repos     []string
+	Args      []string
+	Timestamp int64
+	Image     oci.Options
+	Solve     solve.Options
+	Quiet     bool
+}
+
+func NewOciCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "oci -o DIR [-r REPO... SPEC... | FILE...]",
+		Short: "Build OCI image out of packages",
+		Long: "Build OCI image layout out of package files given in order they are laid, " +
+			"or out of packages resolved from repositories",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("command requires package files or specs")
+			}
+			if len(o.Output) == 0 {
+				return errors.New("command requires output directory")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Args = args
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "OCI layout directory to write image to")
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository to resolve specs from")
+	cmd.Flags().StringVar(&o.Image.Arch, "arch", solve.DefaultArch(), "architecture of image")
+	cmd.Flags().BoolVar(&o.Solve.IgnoreWeak, "no-weak", false, "don't install weak dependencies")
+	cmd.Flags().StringVar(&o.Image.Tag, "tag", "latest", "reference name of image")
+	cmd.Flags().BoolVar(&o.Image.Squash, "squash", false, "put all packages into single layer")
+	cmd.Flags().Int64Var(&o.Timestamp, "timestamp", sourceDateEpoch(), "creation time of image as unix time, times of files are clamped to it")
+	cmd.Flags().StringArrayVar(&o.Image.Env, "env", []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}, "environment of image")
+	cmd.Flags().StringArrayVar(&o.Image.Entrypoint, "entrypoint", nil, "entrypoint of image, repeat for its arguments")
+	cmd.Flags().StringArrayVar(&o.Image.Cmd, "cmd", nil, "command of image, repeat for its arguments")
+	cmd.Flags().BoolVarP(&o.Quiet, "quiet", "q", false, "don't print digest of manifest")
+	return cmd
+}
+
+// sourceDateEpoch returns SOURCE_DATE_EPOCH of environment, 0 if it's
+// not set
+func sourceDateEpoch() int64 {
+	n, _ := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	return n
+}
+
+func (o *Options) Run() error {
+	o.Image.Time = time.Unix(o.Timestamp, 0)
+	files := o.Args
+	if len(o.Repos) > 0 {
+		tmp, err := ioutil.TempDir("", "ypkg-oci-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		if files, err = o.fetch(tmp); err != nil {
+			return err
+		}
+	}
+	desc, err := oci.Build(o.Output, files, &o.Image)
+	if err != nil {
+		return err
+	}
+	if !o.Quiet {
+		fmt.Println(desc.Digest)
+	}
+	return nil
+}
+
+// fetch resolves specs and fetches packages into dir, in order they
+// are installed
+func (o *Options) fetch(dir string) ([]string, error) {
+	pool, sources, err := resolve.LoadRepos(o.Image.Arch, o.Repos)
+	if err != nil {
+		return nil, err
+	}
+	t, err := pool.Resolve(o.Args, &o.Solve)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for i, s := range t.Install {
+		fname := filepath.Join(dir, fmt.Sprintf("%04d-%s", i, path.Base(s.Package.Location.Href)))
+		if err := fetchFile(sources[s.Package], s.Package, fname); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Package.NEVRA(), err)
+		}
+		files = append(files, fname)
+	}
+	return files, nil
+}
+
+func fetchFile(src repo.Source, p *repo.Package, fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	err = repo.Fetch(src, p, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
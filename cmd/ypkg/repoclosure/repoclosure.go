@@ -0,0 +1,129 @@
+package repoclosure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/solve"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Repos  []string
+	Check  []string
+	Arch   string
+	Newest bool
+	JSON   bool
+}
+
+func NewRepoclosureCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "repoclosure -r REPO... [--check REPO...]",
+		Short: "Report packages with unresolved dependencies",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("command takes no arguments")
+			}
+			if len(o.Repos) == 0 {
+				return errors.New("command requires at least one repository")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			broken, err := o.Run()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+			if broken {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository dependencies are resolved against")
+	cmd.Flags().StringArrayVar(&o.Check, "check", nil, "repository to check, all are checked if none is given")
+	cmd.Flags().StringVar(&o.Arch, "arch", solve.DefaultArch(), "architecture to check packages of")
+	cmd.Flags().BoolVar(&o.Newest, "newest", false, "check only the newest version of packages")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print report as JSON")
+	return cmd
+}
+
+type report struct {
+	Package    string   `json:"package"`
+	Repo       string   `json:"repo"`
+	Location   string   `json:"location"`
+	Unresolved []string `json:"unresolved"`
+}
+
+// Run reports whether there are packages with unresolved dependencies
+func (o *Options) Run() (bool, error) {
+	reports, err := o.closure()
+	if err != nil {
+		return false, err
+	}
+	if o.JSON {
+		if reports == nil {
+			reports = []report{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return len(reports) > 0, enc.Encode(reports)
+	}
+	for _, r := range reports {
+		fmt.Printf("package: %s from %s\n  unresolved deps (%d):\n", r.Package, r.Repo, len(r.Unresolved))
+		for _, d := range r.Unresolved {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+	return len(reports) > 0, nil
+}
+
+// closure returns packages of checked repositories with unresolved
+// dependencies
+func (o *Options) closure() ([]report, error) {
+	check := map[string]bool{}
+	for _, base := range o.Check {
+		check[base] = true
+	}
+	pool := solve.NewPool(o.Arch)
+	var reports []report
+	type checked struct {
+		base string
+		pkgs []*repo.Package
+	}
+	var todo []checked
+	loaded := map[string]bool{}
+	for _, base := range append(o.Repos, o.Check...) {
+		if loaded[base] {
+			continue
+		}
+		loaded[base] = true
+		r, err := repo.Open(base)
+		if err != nil {
+			return nil, err
+		}
+		pkgs, err := r.Load(&repo.LoadOptions{Filelists: true})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", base, err)
+		}
+		pool.Add(pkgs...)
+		if len(check) == 0 || check[base] {
+			todo = append(todo, checked{base, pkgs})
+		}
+	}
+	for _, c := range todo {
+		for _, b := range pool.Closure(c.pkgs, o.Newest) {
+			r := report{Package: b.Package.NEVRA(), Repo: c.base, Location: b.Package.Location.Href}
+			for _, d := range b.Unresolved {
+				r.Unresolved = append(r.Unresolved, d.String())
+			}
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,95 @@
+package repoclosure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+const primary = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="1">
+<package type="rpm">
+  <name>%[1]s</name>
+  <arch>noarch</arch>
+  <version epoch="0" ver="1" rel="1"/>
+  <checksum type="sha256" pkgid="YES">%[1]s</checksum>
+  <location href="%[1]s-1-1.noarch.rpm"/>
+  <format>
+    <rpm:requires>
+      <rpm:entry name="%[1]s-missing"/>
+    </rpm:requires>
+  </format>
+</package>
+</metadata>
+`
+
+const filelists = `<?xml version="1.0" encoding="UTF-8"?>
+<filelists xmlns="http://linux.duke.edu/metadata/filelists" packages="0">
+</filelists>
+`
+
+// newRepo creates repository in dir with package name requiring
+// name-missing, which no repository provides
+func newRepo(t *testing.T, dir, name string) string {
+	t.Helper()
+	base := filepath.Join(dir, name)
+	if err := os.Mkdir(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(base, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddMetadata(base, repo.Primary, []byte(fmt.Sprintf(primary, name)), "sha256"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddMetadata(base, repo.Filelists, []byte(filelists), "sha256"); err != nil {
+		t.Fatal(err)
+	}
+	return base
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repoclosure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := newRepo(t, dir, "a"), newRepo(t, dir, "b")
+
+	tests := []struct {
+		name    string
+		repos   []string
+		check   []string
+		reports []string
+	}{
+		{"all", []string{a, b}, nil, []string{"a", "b"}},
+		{"checked first", []string{b, a}, []string{b}, []string{"b"}},
+		{"checked last", []string{a, b}, []string{b}, []string{"b"}},
+		{"checked only", []string{a}, []string{b}, []string{"b"}},
+		{"both checked", []string{b, a}, []string{a, b}, []string{"b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := Options{Repos: tt.repos, Check: tt.check, Arch: "x86_64"}
+			reports, err := o.closure()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range reports {
+				got = append(got, filepath.Base(r.Repo))
+				if expected := []string{r.Package[:1] + "-missing"}; !reflect.DeepEqual(r.Unresolved, expected) {
+					t.Errorf("%s: got %v, expected %v", r.Package, r.Unresolved, expected)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.reports) {
+				t.Errorf("got reports of %v, expected %v", got, tt.reports)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package repoquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Base  string
+	Names []string
+	repo.LoadOptions
+	JSON bool
+}
+
+func NewRepoqueryCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "repoquery BASE [NAME...]",
+		Short: "List packages of repository at URL or directory",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("command requires repository base")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Base, o.Names = args[0], args[1:]
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print packages as JSON")
+	cmd.Flags().BoolVar(&o.SQLite, "sqlite", false, "prefer sqlite metadata")
+	cmd.Flags().BoolVar(&o.Filelists, "filelists", false, "load complete file lists")
+	cmd.Flags().BoolVar(&o.Other, "changelogs", false, "load changelogs")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	r, err := repo.Open(o.Base)
+	if err != nil {
+		return err
+	}
+	pkgs, err := r.Load(&o.LoadOptions)
+	if err != nil {
+		return err
+	}
+	if len(o.Names) > 0 {
+		names := map[string]bool{}
+		for _, n := range o.Names {
+			names[n] = true
+		}
+		var matched []*repo.Package
+		for _, p := range pkgs {
+			if names[p.Name] {
+				matched = append(matched, p)
+			}
+		}
+		pkgs = matched
+	}
+	if o.JSON {
+		if pkgs == nil {
+			pkgs = []*repo.Package{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pkgs)
+	}
+	for _, p := range pkgs {
+		fmt.Println(p.NEVRA())
+	}
+	return nil
+}
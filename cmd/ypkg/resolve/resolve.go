@@ -0,0 +1,108 @@
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/solve"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Repos []string
+	Specs []string
+	Arch  string
+	solve.Options
+	JSON bool
+}
+
+func NewResolveCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "resolve -r REPO... SPEC...",
+		Short: "Resolve packages to install from repositories",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("command requires package specs")
+			}
+			if len(o.Repos) == 0 {
+				return errors.New("command requires at least one repository")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Specs = args
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository base URL or directory")
+	cmd.Flags().StringVar(&o.Arch, "arch", solve.DefaultArch(), "architecture to resolve for")
+	cmd.Flags().BoolVar(&o.IgnoreWeak, "no-weak", false, "don't install weak dependencies")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print transaction as JSON")
+	return cmd
+}
+
+// LoadPool reads packages of repositories into pool, file lists are
+// loaded for file dependencies
+func LoadPool(arch string, repos []string) (*solve.Pool, error) {
+	pool, _, err := LoadRepos(arch, repos)
+	return pool, err
+}
+
+// LoadRepos is LoadPool which also returns repository every package
+// comes from, for fetching them
+func LoadRepos(arch string, repos []string) (*solve.Pool, map[*repo.Package]repo.Source, error) {
+	pool := solve.NewPool(arch)
+	sources := map[*repo.Package]repo.Source{}
+	for _, base := range repos {
+		r, err := repo.Open(base)
+		if err != nil {
+			return nil, nil, err
+		}
+		pkgs, err := r.Load(&repo.LoadOptions{Filelists: true})
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", base, err)
+		}
+		for _, p := range pkgs {
+			sources[p] = r.Source
+		}
+		pool.Add(pkgs...)
+	}
+	return pool, sources, nil
+}
+
+func (o *Options) Run() error {
+	pool, err := LoadPool(o.Arch, o.Repos)
+	if err != nil {
+		return err
+	}
+	t, err := pool.Resolve(o.Specs, &o.Options)
+	if err != nil {
+		return err
+	}
+	if o.JSON {
+		type step struct {
+			Package  string       `json:"package"`
+			Location string       `json:"location"`
+			Reason   solve.Reason `json:"reason"`
+		}
+		steps := []step{}
+		for _, s := range t.Install {
+			steps = append(steps, step{s.Package.NEVRA(), s.Package.Location.Href, s.Reason})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(steps)
+	}
+	for _, s := range t.Install {
+		fmt.Printf("%s (%s)\n", s.Package.NEVRA(), s.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package sbom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"code.pikelabs.net/go/rpm/install"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"code.pikelabs.net/go/rpm/sbom"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Files     []string
+	Repos     []string
+	Root      string
+	Format    string
+	Name      string
+	Namespace string
+	NoFiles   bool
+	Output    string
+}
+
+func NewSbomCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "sbom [FILE... | -r REPO... | --root DIR]",
+		Short: "Generate SBOM of packages",
+		Long: "Generate SPDX or CycloneDX SBOM of package files, packages of " +
+			"repositories or packages installed in root",
+		Args: func(cmd *cobra.Command, args []string) error {
+			sources := 0
+			for _, set := range []bool{len(args) > 0, len(o.Repos) > 0, len(o.Root) > 0} {
+				if set {
+					sources++
+				}
+			}
+			if sources != 1 {
+				return errors.New("command requires either package files, repositories or root")
+			}
+			if o.Format != "spdx" && o.Format != "cyclonedx" {
+				return fmt.Errorf("unknown format %q", o.Format)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Files = args
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository base URL or directory")
+	cmd.Flags().StringVar(&o.Root, "root", "", "describe packages installed in root")
+	cmd.Flags().StringVarP(&o.Format, "format", "f", "spdx", "format of SBOM, spdx or cyclonedx")
+	cmd.Flags().StringVar(&o.Name, "name", "", "name of SBOM document")
+	cmd.Flags().StringVar(&o.Namespace, "namespace", "", "vendor or distribution part of package URLs")
+	cmd.Flags().BoolVar(&o.NoFiles, "no-files", false, "don't list files of packages")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "file to write SBOM to instead of stdout")
+	return cmd
+}
+
+func (o *Options) Run() error {
+	doc := &sbom.Document{
+		Name:      o.Name,
+		Namespace: o.Namespace,
+		Created:   time.Now(),
+		Files:     !o.NoFiles,
+	}
+	// SOURCE_DATE_EPOCH keeps documents reproducible
+	if n, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		doc.Created = time.Unix(n, 0)
+	}
+	var err error
+	switch {
+	case len(o.Repos) > 0:
+		err = o.readRepos(doc)
+	case len(o.Root) > 0:
+		err = o.readRoot(doc)
+	default:
+		err = o.readFiles(doc)
+	}
+	if err != nil {
+		return err
+	}
+	if len(doc.Name) == 0 {
+		doc.Name = "ypkg-sbom"
+	}
+
+	w := io.Writer(os.Stdout)
+	if len(o.Output) > 0 {
+		f, err := os.Create(o.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if o.Format == "cyclonedx" {
+		err = doc.WriteCycloneDX(w)
+	} else {
+		err = doc.WriteSPDX(w)
+	}
+	return err
+}
+
+func (o *Options) readFiles(doc *sbom.Document) error {
+	for _, fname := range o.Files {
+		c, err := sbom.ReadFile(fname)
+		if err != nil {
+			return err
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return nil
+}
+
+func (o *Options) readRepos(doc *sbom.Document) error {
+	for _, base := range o.Repos {
+		r, err := repo.Open(base)
+		if err != nil {
+			return err
+		}
+		pkgs, err := r.Load(&repo.LoadOptions{Filelists: !o.NoFiles})
+		if err != nil {
+			return fmt.Errorf("%s: %w", base, err)
+		}
+		for _, p := range pkgs {
+			doc.Components = append(doc.Components, &sbom.Component{Package: p})
+		}
+	}
+	return nil
+}
+
+// readRoot reads packages installed in root, file digests are known
+// only for packages of rpmdb
+func (o *Options) readRoot(doc *sbom.Document) error {
+	if len(doc.Name) == 0 {
+		doc.Name = o.Root
+	}
+	return install.Walk(o.Root, func(p *repo.Package, pkg *rpmutil.Package) error {
+		if pkg == nil {
+			doc.Components = append(doc.Components, &sbom.Component{Package: p})
+			return nil
+		}
+		c, err := sbom.FromHeader(pkg)
+		if err != nil {
+			return err
+		}
+		doc.Components = append(doc.Components, c)
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package updateinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/install"
+	"code.pikelabs.net/go/rpm/repo"
+	"github.com/spf13/cobra"
+)
+
+type Options struct {
+	Repos []string
+	// Root selects advisories which apply to packages installed
+	// under it, all advisories are listed without it
+	Root     string
+	Types    []string
+	Severity []string
+	JSON     bool
+}
+
+func NewUpdateinfoCmd() *cobra.Command {
+	var o Options
+	cmd := &cobra.Command{
+		Use:   "updateinfo -r REPO...",
+		Short: "List advisories of repositories",
+		Long: "List advisories of repositories updateinfo metadata describes, " +
+			"with --root only those which apply to packages installed under root",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("command takes no arguments")
+			}
+			if len(o.Repos) == 0 {
+				return errors.New("command requires at least one repository")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "err: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&o.Repos, "repo", "r", nil, "repository base URL or directory")
+	cmd.Flags().StringVar(&o.Root, "root", "", "list advisories which apply to packages installed under root")
+	cmd.Flags().StringSliceVar(&o.Types, "type", nil, "list advisories of types, like security or bugfix")
+	cmd.Flags().StringSliceVar(&o.Severity, "severity", nil, "list advisories of severities, like Critical or Important")
+	cmd.Flags().BoolVar(&o.JSON, "json", false, "print advisories as JSON")
+	return cmd
+}
+
+// match reports whether value is one of values, any value matches if
+// there are none
+func match(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonPackage struct {
+	Package   string `json:"package"`
+	Installed string `json:"installed,omitempty"`
+}
+
+type jsonAdvisory struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	Severity string        `json:"severity,omitempty"`
+	Title    string        `json:"title,omitempty"`
+	Issued   string        `json:"issued,omitempty"`
+	CVEs     []string      `json:"cves,omitempty"`
+	Reboot   bool          `json:"reboot_suggested,omitempty"`
+	Packages []jsonPackage `json:"packages"`
+}
+
+func (o *Options) Run() error {
+	var advisories []*repo.Advisory
+	for _, base := range o.Repos {
+		r, err := repo.Open(base)
+		if err != nil {
+			return err
+		}
+		as, err := r.Advisories()
+		if err != nil {
+			return fmt.Errorf("%s: %w", base, err)
+		}
+		advisories = repo.MergeAdvisories(advisories, as)
+	}
+	var selected []*repo.Advisory
+	for _, a := range advisories {
+		if match(o.Types, a.Type) && match(o.Severity, a.Severity) {
+			selected = append(selected, a)
+		}
+	}
+
+	var applicable []*repo.ApplicableAdvisory
+	if len(o.Root) > 0 {
+		installed, err := install.Installed(o.Root)
+		if err != nil {
+			return err
+		}
+		applicable = repo.Applicable(selected, installed)
+	} else {
+		for _, a := range selected {
+			aa := &repo.ApplicableAdvisory{Advisory: a}
+			for _, p := range a.Packages() {
+				if p.Arch == "src" || p.Arch == "nosrc" {
+					continue
+				}
+				aa.Updates = append(aa.Updates, repo.PackageUpdate{Update: p})
+			}
+			applicable = append(applicable, aa)
+		}
+	}
+
+	if o.JSON {
+		res := []jsonAdvisory{}
+		for _, aa := range applicable {
+			a := aa.Advisory
+			ja := jsonAdvisory{
+				ID:       a.ID,
+				Type:     a.Type,
+				Severity: a.Severity,
+				Title:    a.Title,
+				CVEs:     a.CVEs(),
+				Reboot:   a.Reboot(),
+				Packages: []jsonPackage{},
+			}
+			if !a.Issued.IsZero() {
+				ja.Issued = a.Issued.UTC().Format("2006-01-02")
+			}
+			for _, u := range aa.Updates {
+				jp := jsonPackage{Package: u.Update.NEVRA()}
+				if u.Installed != nil {
+					jp.Installed = u.Installed.NEVRA()
+				}
+				ja.Packages = append(ja.Packages, jp)
+			}
+			res = append(res, ja)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	for _, aa := range applicable {
+		a := aa.Advisory
+		kind := a.Type
+		if len(a.Severity) > 0 {
+			kind += "/" + a.Severity
+		}
+		for _, u := range aa.Updates {
+			fmt.Printf("%s %s %s\n", a.ID, kind, u.Update.NEVRA())
+		}
+	}
+	return nil
+}
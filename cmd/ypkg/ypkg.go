@@ -1,5 +1,55 @@
 package main
 
-func main() {
+import (
+	"os"
+
+	"code.pikelabs.net/go/cmd/ypkg/applydelta"
+	"code.pikelabs.net/go/cmd/ypkg/conflicts"
+	"code.pikelabs.net/go/cmd/ypkg/createrepo"
+	"code.pikelabs.net/go/cmd/ypkg/diff"
+	"code.pikelabs.net/go/cmd/ypkg/elfdeps"
+	"code.pikelabs.net/go/cmd/ypkg/install"
+	"code.pikelabs.net/go/cmd/ypkg/license"
+	"code.pikelabs.net/go/cmd/ypkg/lint"
+	"code.pikelabs.net/go/cmd/ypkg/makedelta"
+	"code.pikelabs.net/go/cmd/ypkg/modifyrepo"
+	"code.pikelabs.net/go/cmd/ypkg/oci"
+	"code.pikelabs.net/go/cmd/ypkg/repoclosure"
+	"code.pikelabs.net/go/cmd/ypkg/repoquery"
+	"code.pikelabs.net/go/cmd/ypkg/resolve"
+	"code.pikelabs.net/go/cmd/ypkg/sbom"
+	"code.pikelabs.net/go/cmd/ypkg/updateinfo"
+	"github.com/spf13/cobra"
+)
+
+func NewYpkgCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ypkg",
+		Short: "RPM package tool",
+	}
 
+	cmd.AddCommand(applydelta.NewApplydeltaCmd())
+	cmd.AddCommand(conflicts.NewConflictsCmd())
+	cmd.AddCommand(createrepo.NewCreaterepoCmd())
+	cmd.AddCommand(diff.NewDiffCmd())
+	cmd.AddCommand(elfdeps.NewElfdepsCmd())
+	cmd.AddCommand(install.NewInstallCmd())
+	cmd.AddCommand(license.NewLicenseCmd())
+	cmd.AddCommand(lint.NewLintCmd())
+	cmd.AddCommand(makedelta.NewMakedeltaCmd())
+	cmd.AddCommand(modifyrepo.NewModifyrepoCmd())
+	cmd.AddCommand(oci.NewOciCmd())
+	cmd.AddCommand(repoclosure.NewRepoclosureCmd())
+	cmd.AddCommand(repoquery.NewRepoqueryCmd())
+	cmd.AddCommand(resolve.NewResolveCmd())
+	cmd.AddCommand(sbom.NewSbomCmd())
+	cmd.AddCommand(updateinfo.NewUpdateinfoCmd())
+	return cmd
+}
+
+func main() {
+	root := NewYpkgCommand()
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
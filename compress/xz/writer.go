@@ -0,0 +1,37 @@
+package xz
+
+import (
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+type writer struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close flushes compressed data and waits for xz to exit
+func (w *writer) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func NewWriter(w io.Writer, level int) io.WriteCloser {
+	rpipe, wpipe := io.Pipe()
+
+	cmd := exec.Command("xz", "--compress", "--stdout", "--threads=1", "--check=sha256", "-"+strconv.Itoa(level))
+	cmd.Stdin = rpipe
+	cmd.Stdout = w
+
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Run()
+		rpipe.CloseWithError(err)
+		done <- err
+	}()
+
+	return &writer{PipeWriter: wpipe, done: done}
+}
@@ -0,0 +1,21 @@
+package zstd
+
+import (
+	"io"
+	"os/exec"
+)
+
+func NewReader(r io.Reader) io.ReadCloser {
+	rpipe, wpipe := io.Pipe()
+
+	cmd := exec.Command("zstd", "--decompress", "--stdout")
+	cmd.Stdin = r
+	cmd.Stdout = wpipe
+
+	go func() {
+		err := cmd.Run()
+		wpipe.CloseWithError(err)
+	}()
+
+	return rpipe
+}
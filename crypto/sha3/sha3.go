@@ -0,0 +1,160 @@
+// Package sha3 implements SHA3-256 and SHA3-512 hash functions as
+// defined in FIPS 202, as used by rpm v6 package digests
+package sha3
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// domain separation byte of SHA-3 followed by padding start
+	dsbyte = 0x06
+)
+
+type state struct {
+	a       [25]uint64
+	buf     []byte
+	rate    int
+	size    int
+	storage [200]byte
+}
+
+// New256 returns new hash.Hash computing SHA3-256 checksum
+func New256() hash.Hash {
+	return newState(136, 32)
+}
+
+// New512 returns new hash.Hash computing SHA3-512 checksum
+func New512() hash.Hash {
+	return newState(72, 64)
+}
+
+// Sum256 returns SHA3-256 checksum of data
+func Sum256(data []byte) (sum [32]byte) {
+	h := New256()
+	h.Write(data)
+	h.Sum(sum[:0])
+	return
+}
+
+// Sum512 returns SHA3-512 checksum of data
+func Sum512(data []byte) (sum [64]byte) {
+	h := New512()
+	h.Write(data)
+	h.Sum(sum[:0])
+	return
+}
+
+func newState(rate, size int) *state {
+	s := &state{rate: rate, size: size}
+	s.buf = s.storage[:0]
+	return s
+}
+
+func (s *state) Size() int      { return s.size }
+func (s *state) BlockSize() int { return s.rate }
+
+func (s *state) Reset() {
+	s.a = [25]uint64{}
+	s.buf = s.storage[:0]
+}
+
+func (s *state) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(s.buf) == 0 && len(p) >= s.rate {
+			s.absorb(p[:s.rate])
+			p = p[s.rate:]
+			continue
+		}
+		todo := s.rate - len(s.buf)
+		if todo > len(p) {
+			todo = len(p)
+		}
+		s.buf = append(s.buf, p[:todo]...)
+		p = p[todo:]
+		if len(s.buf) == s.rate {
+			s.absorb(s.buf)
+			s.buf = s.storage[:0]
+		}
+	}
+	return n, nil
+}
+
+// Sum appends checksum to b, state of the hash is not changed
+func (s *state) Sum(b []byte) []byte {
+	dup := *s
+	dup.buf = dup.storage[:len(s.buf)]
+
+	block := make([]byte, dup.rate)
+	copy(block, dup.buf)
+	block[len(dup.buf)] ^= dsbyte
+	block[dup.rate-1] ^= 0x80
+	dup.absorb(block)
+
+	out := make([]byte, dup.rate)
+	for i := 0; i < dup.rate/8; i++ {
+		binary.LittleEndian.PutUint64(out[8*i:], dup.a[i])
+	}
+	return append(b, out[:dup.size]...)
+}
+
+func (s *state) absorb(block []byte) {
+	for i := 0; i < s.rate/8; i++ {
+		s.a[i] ^= binary.LittleEndian.Uint64(block[8*i:])
+	}
+	keccakF1600(&s.a)
+}
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var rotations = [25]uint{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+func rotl(x uint64, n uint) uint64 {
+	return x<<n | x>>(64-n)
+}
+
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ rotl(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = rotl(a[x+5*y], rotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+		// iota
+		a[0] ^= roundConstants[round]
+	}
+}
@@ -0,0 +1,98 @@
+package sha3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"testing"
+)
+
+// NIST FIPS 202 example values and SHA3 short/long message vectors
+var tests = []struct {
+	msg    string
+	sum256 string
+	sum512 string
+}{
+	{
+		"",
+		"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+		"a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26",
+	},
+	{
+		"abc",
+		"3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+		"b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0",
+	},
+	{
+		"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq",
+		"41c0dba2a9d6240849100376a8235e2c82e1b9998a999e21db32dd97496d3376",
+		"04a371e84ecfb5b8b77cb48610fca8182dd457ce6f326a0fd3d7ec2f1e91636dee691fbe0c985302ba1b0d8dc78c086346b533b49c030d99a27daf1139d6e75e",
+	},
+	{
+		"abcdefghbcdefghicdefghijdefghijkefghijklfghijklmghijklmnhijklmnoijklmnopjklmnopqklmnopqrlmnopqrsmnopqrstnopqrstu",
+		"916f6061fe879741ca6469b43971dfdb28b1a32dc36cb3254e812be27aad1d18",
+		"afebb2ef542e6579c50cad06d2e578f9f8dd6881d7dc824d26360feebf18a4fa73e3261122948efcfd492e74e82e2189ed0fb440d187f382270cb455f21dd185",
+	},
+	{
+		strings.Repeat("\xa3", 200),
+		"79f38adec5c20307a98ef76e8324afbfd46cfd81b22e3973c65fa1bd9de31787",
+		"e76dfad22084a8b1467fcf2ffa58361bec7628edf5f3fdc0e4805dc48caeeca81b7c13c30adf52a3659584739a2df46be589c51ca1a4a8416df6545a1ce8ba00",
+	},
+	{
+		strings.Repeat("a", 1000000),
+		"5c8875ae474a3634ba4fd55ec85bffd661f32aca75c6d699d0cdcb6c115891c1",
+		"3c3a876da14034ab60627c077bb98f7e120a2a5370212dffb3385a18d4f38859ed311d0a9d5141ce9cc5c66ee689b266a8aa18ace8282a0e0db596c90b0a7b87",
+	},
+}
+
+func TestSum(t *testing.T) {
+	for _, tt := range tests {
+		if sum := Sum256([]byte(tt.msg)); hex.EncodeToString(sum[:]) != tt.sum256 {
+			t.Errorf("SHA3-256 of %d bytes: got %x, expected %s", len(tt.msg), sum, tt.sum256)
+		}
+		if sum := Sum512([]byte(tt.msg)); hex.EncodeToString(sum[:]) != tt.sum512 {
+			t.Errorf("SHA3-512 of %d bytes: got %x, expected %s", len(tt.msg), sum, tt.sum512)
+		}
+	}
+}
+
+// TestWrite feeds messages in pieces not aligned to rate of the
+// sponge, and checks Sum doesn't change state
+func TestWrite(t *testing.T) {
+	for _, tt := range tests {
+		for _, f := range []struct {
+			new      func() hash.Hash
+			expected string
+		}{{New256, tt.sum256}, {New512, tt.sum512}} {
+			h := f.new()
+			for _, n := range []int{1, 7, 71, 72, 135, 136, 137} {
+				h.Reset()
+				for msg := tt.msg; len(msg) > 0; {
+					k := n
+					if k > len(msg) {
+						k = len(msg)
+					}
+					h.Write([]byte(msg[:k]))
+					msg = msg[k:]
+				}
+				first := h.Sum(nil)
+				if sum := h.Sum(nil); !bytes.Equal(first, sum) {
+					t.Errorf("%d bytes: Sum changed state", len(tt.msg))
+				}
+				if sum := hex.EncodeToString(first); sum != f.expected {
+					t.Errorf("%d bytes in pieces of %d: got %s, expected %s", len(tt.msg), n, sum, f.expected)
+				}
+			}
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	if h := New256(); h.Size() != 32 || h.BlockSize() != 136 {
+		t.Errorf("SHA3-256: size %d, block size %d", h.Size(), h.BlockSize())
+	}
+	if h := New512(); h.Size() != 64 || h.BlockSize() != 72 {
+		t.Errorf("SHA3-512: size %d, block size %d", h.Size(), h.BlockSize())
+	}
+}
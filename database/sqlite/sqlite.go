@@ -0,0 +1,481 @@
+// Package sqlite is minimal read-only reader of SQLite database file
+// format, just enough to walk rowid tables. Committed frames of
+// write-ahead log are taken into account, so database which wasn't
+// checkpointed yet reads the same as with sqlite itself.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	ErrCorrupt     = errors.New("corrupt SQLite database")
+	ErrUnsupported = errors.New("unsupported SQLite database")
+	ErrNoTable     = errors.New("no such table")
+)
+
+// maxPayloadSize bounds rows read from database
+const maxPayloadSize = 1 << 30
+
+const (
+	sqliteMagic      = "SQLite format 3\x00"
+	sqliteHeaderSize = 100
+
+	sqlitePageInteriorTable = 0x05
+	sqlitePageLeafTable     = 0x0d
+
+	walMagic       = 0x377f0682
+	walHeaderSize  = 32
+	walFrameHeader = 24
+)
+
+// DB is read-only handle of SQLite database file
+type DB struct {
+	f, wal   *os.File
+	pageSize int64
+	usable   int64
+	// pages found in write-ahead log and their offsets there
+	walPages map[uint32]int64
+}
+
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{f: f}
+	if err := db.init(path); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) init(path string) error {
+	var hdr [sqliteHeaderSize]byte
+	if _, err := db.f.ReadAt(hdr[:], 0); err != nil {
+		return readError(err, 0)
+	}
+	if string(hdr[:16]) != sqliteMagic {
+		return fmt.Errorf("%w: bad magic", ErrCorrupt)
+	}
+	db.pageSize = int64(binary.BigEndian.Uint16(hdr[16:]))
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	if db.pageSize < 512 || db.pageSize&(db.pageSize-1) != 0 {
+		return fmt.Errorf("%w: page size %d", ErrCorrupt, db.pageSize)
+	}
+	db.usable = db.pageSize - int64(hdr[20])
+	if db.usable < 480 {
+		return fmt.Errorf("%w: usable page size %d", ErrCorrupt, db.usable)
+	}
+	if enc := binary.BigEndian.Uint32(hdr[56:]); enc > 1 {
+		return fmt.Errorf("%w: text encoding %d", ErrUnsupported, enc)
+	}
+
+	wal, err := os.Open(path + "-wal")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	db.wal = wal
+	return db.readWAL()
+}
+
+// readWAL indexes pages of committed frames in write-ahead log,
+// log ends at the first frame with stale salt or bad checksum
+func (db *DB) readWAL() error {
+	var hdr [walHeaderSize]byte
+	if _, err := db.wal.ReadAt(hdr[:], 0); err != nil {
+		// empty or truncated log has nothing to offer
+		return nil
+	}
+	magic := binary.BigEndian.Uint32(hdr[0:])
+	if magic&^1 != walMagic || int64(binary.BigEndian.Uint32(hdr[8:])) != db.pageSize {
+		return nil
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if magic&1 != 0 {
+		order = binary.BigEndian
+	}
+	s0, s1 := walChecksum(order, 0, 0, hdr[:24])
+	if s0 != binary.BigEndian.Uint32(hdr[24:]) || s1 != binary.BigEndian.Uint32(hdr[28:]) {
+		return nil
+	}
+
+	db.walPages = map[uint32]int64{}
+	pending := map[uint32]int64{}
+	frame := make([]byte, walFrameHeader+db.pageSize)
+	for off := int64(walHeaderSize); ; off += int64(len(frame)) {
+		if _, err := db.wal.ReadAt(frame, off); err != nil {
+			break
+		}
+		if !bytes.Equal(frame[8:16], hdr[16:24]) {
+			break
+		}
+		s0, s1 = walChecksum(order, s0, s1, frame[:8])
+		s0, s1 = walChecksum(order, s0, s1, frame[walFrameHeader:])
+		if s0 != binary.BigEndian.Uint32(frame[16:]) || s1 != binary.BigEndian.Uint32(frame[20:]) {
+			break
+		}
+		pending[binary.BigEndian.Uint32(frame[0:])] = off + walFrameHeader
+		// non-zero database size marks commit frame
+		if binary.BigEndian.Uint32(frame[4:]) != 0 {
+			for pgno, o := range pending {
+				db.walPages[pgno] = o
+			}
+			pending = map[uint32]int64{}
+		}
+	}
+	return nil
+}
+
+func walChecksum(order binary.ByteOrder, s0, s1 uint32, d []byte) (uint32, uint32) {
+	for i := 0; i+8 <= len(d); i += 8 {
+		s0 += order.Uint32(d[i:]) + s1
+		s1 += order.Uint32(d[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+func (db *DB) Close() error {
+	if db.wal != nil {
+		db.wal.Close()
+	}
+	return db.f.Close()
+}
+
+func (db *DB) page(pgno uint32) ([]byte, error) {
+	if pgno < 1 {
+		return nil, fmt.Errorf("%w: page %d", ErrCorrupt, pgno)
+	}
+	pg := make([]byte, db.pageSize)
+	if off, ok := db.walPages[pgno]; ok {
+		if _, err := db.wal.ReadAt(pg, off); err != nil {
+			return nil, readError(err, off)
+		}
+		return pg, nil
+	}
+	off := int64(pgno-1) * db.pageSize
+	if _, err := db.f.ReadAt(pg, off); err != nil {
+		return nil, readError(err, off)
+	}
+	return pg, nil
+}
+
+// Table describes table of database
+type Table struct {
+	Name    string
+	Columns []string
+	root    uint32
+	// column aliasing rowid, -1 if none
+	rowidColumn int
+}
+
+// Table looks up table in schema of database
+func (db *DB) Table(name string) (*Table, error) {
+	var t *Table
+	err := db.scanTable(1, func(rowid int64, rec []interface{}) error {
+		// type, name, tbl_name, rootpage, sql
+		if len(rec) < 5 || rec[0] != "table" || rec[1] != name {
+			return nil
+		}
+		root, _ := rec[3].(int64)
+		if root < 1 || root > 0xffffffff {
+			return fmt.Errorf("%w: %s table root page %d", ErrCorrupt, name, root)
+		}
+		sql, _ := rec[4].(string)
+		t = &Table{Name: name, root: uint32(root), rowidColumn: -1}
+		t.Columns, t.rowidColumn = parseColumns(sql)
+		return io.EOF
+	})
+	if err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("%w: %s", ErrNoTable, name)
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// Scan calls fn for every row of table in rowid order, record holds
+// int64, float64, string, []byte or nil value of every column
+func (db *DB) Scan(t *Table, fn func(rowid int64, rec []interface{}) error) error {
+	return db.scanTable(t.root, func(rowid int64, rec []interface{}) error {
+		// columns added by ALTER TABLE are missing in older rows
+		for len(rec) < len(t.Columns) {
+			rec = append(rec, nil)
+		}
+		if t.rowidColumn >= 0 && t.rowidColumn < len(rec) {
+			rec[t.rowidColumn] = rowid
+		}
+		return fn(rowid, rec)
+	})
+}
+
+// column constraints which aren't columns
+var tableConstraints = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN"}
+
+// parseColumns returns column names of CREATE TABLE statement along
+// with index of INTEGER PRIMARY KEY column aliasing rowid
+func parseColumns(sql string) ([]string, int) {
+	rowid := -1
+	start, end := strings.IndexByte(sql, '('), strings.LastIndexByte(sql, ')')
+	if start < 0 || end < start {
+		return nil, rowid
+	}
+	var defs []string
+	depth, from := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[from:i])
+				from = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[from:end])
+
+	var cols []string
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		constraint := false
+		for _, c := range tableConstraints {
+			if strings.EqualFold(fields[0], c) {
+				constraint = true
+			}
+		}
+		if constraint {
+			continue
+		}
+		upper := strings.ToUpper(strings.Join(fields[1:], " "))
+		if strings.HasPrefix(upper, "INTEGER PRIMARY KEY") {
+			rowid = len(cols)
+		}
+		cols = append(cols, strings.Trim(fields[0], "\"`[]"))
+	}
+	return cols, rowid
+}
+
+// scanTable calls fn for every row of table b-tree rooted at root
+// in rowid order
+func (db *DB) scanTable(root uint32, fn func(rowid int64, rec []interface{}) error) error {
+	return db.scanPage(root, map[uint32]bool{}, fn)
+}
+
+func (db *DB) scanPage(pgno uint32, seen map[uint32]bool, fn func(rowid int64, rec []interface{}) error) error {
+	if seen[pgno] {
+		return fmt.Errorf("%w: page %d referenced twice", ErrCorrupt, pgno)
+	}
+	seen[pgno] = true
+	pg, err := db.page(pgno)
+	if err != nil {
+		return err
+	}
+	// b-tree header follows database header on the first page
+	hdr := pg
+	if pgno == 1 {
+		hdr = pg[sqliteHeaderSize:]
+	}
+	corrupt := func(what string) error {
+		return fmt.Errorf("%w: page %d: %s", ErrCorrupt, pgno, what)
+	}
+
+	ncells := int(binary.BigEndian.Uint16(hdr[3:]))
+	hdrSize := 8
+	if hdr[0] == sqlitePageInteriorTable {
+		hdrSize = 12
+	}
+	ptrs := hdr[hdrSize:]
+	if 2*ncells > len(ptrs) {
+		return corrupt("too many cells")
+	}
+	for i := 0; i < ncells; i++ {
+		p := int64(binary.BigEndian.Uint16(ptrs[2*i:]))
+		if p >= db.usable {
+			return corrupt("cell out of page")
+		}
+		cell := pg[p:db.usable]
+		switch hdr[0] {
+		case sqlitePageInteriorTable:
+			if len(cell) < 4 {
+				return corrupt("truncated cell")
+			}
+			if err := db.scanPage(binary.BigEndian.Uint32(cell), seen, fn); err != nil {
+				return err
+			}
+		case sqlitePageLeafTable:
+			size, n := sqliteVarint(cell)
+			if n == 0 || size < 0 || size > maxPayloadSize {
+				return corrupt("bad payload size")
+			}
+			rowid, m := sqliteVarint(cell[n:])
+			if m == 0 {
+				return corrupt("bad rowid")
+			}
+			payload, err := db.payload(cell[n+m:], size)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pgno, err)
+			}
+			rec, err := parseRecord(payload)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pgno, err)
+			}
+			if err := fn(rowid, rec); err != nil {
+				return err
+			}
+		default:
+			return corrupt(fmt.Sprintf("not a table page (type %d)", hdr[0]))
+		}
+	}
+	if hdr[0] == sqlitePageInteriorTable {
+		return db.scanPage(binary.BigEndian.Uint32(hdr[8:]), seen, fn)
+	}
+	return nil
+}
+
+// payload assembles cell payload of size bytes, spilling into
+// overflow pages when it doesn't fit into the page
+func (db *DB) payload(cell []byte, size int64) ([]byte, error) {
+	u := db.usable
+	local := size
+	if max := u - 35; size > max {
+		min := (u-12)*32/255 - 23
+		local = min + (size-min)%(u-4)
+		if local > max {
+			local = min
+		}
+	}
+	if int64(len(cell)) < local {
+		return nil, fmt.Errorf("%w: truncated cell", ErrCorrupt)
+	}
+	res := append([]byte(nil), cell[:local]...)
+	if local == size {
+		return res, nil
+	}
+	if int64(len(cell)) < local+4 {
+		return nil, fmt.Errorf("%w: truncated cell", ErrCorrupt)
+	}
+	next := binary.BigEndian.Uint32(cell[local:])
+	seen := map[uint32]bool{}
+	for int64(len(res)) < size {
+		if next == 0 || seen[next] {
+			return nil, fmt.Errorf("%w: broken overflow chain", ErrCorrupt)
+		}
+		seen[next] = true
+		pg, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		n := size - int64(len(res))
+		if n > u-4 {
+			n = u - 4
+		}
+		res = append(res, pg[4:4+n]...)
+		next = binary.BigEndian.Uint32(pg)
+	}
+	return res, nil
+}
+
+// parseRecord decodes record of row into int64, float64, string,
+// []byte or nil values
+func parseRecord(d []byte) ([]interface{}, error) {
+	hlen, n := sqliteVarint(d)
+	if n == 0 || hlen < int64(n) || hlen > int64(len(d)) {
+		return nil, fmt.Errorf("%w: bad record header", ErrCorrupt)
+	}
+	types, body := d[n:hlen], d[hlen:]
+	var rec []interface{}
+	for len(types) > 0 {
+		st, n := sqliteVarint(types)
+		if n == 0 || st < 0 {
+			return nil, fmt.Errorf("%w: bad serial type", ErrCorrupt)
+		}
+		types = types[n:]
+
+		var size int64
+		switch {
+		case st >= 12:
+			size = (st - 12) / 2
+		case st <= 4:
+			size = st
+		case st == 5:
+			size = 6
+		case st == 6, st == 7:
+			size = 8
+		}
+		if size > int64(len(body)) {
+			return nil, fmt.Errorf("%w: truncated record", ErrCorrupt)
+		}
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case st == 0:
+			rec = append(rec, nil)
+		case st <= 6:
+			var i int64
+			for _, b := range v {
+				i = i<<8 | int64(b)
+			}
+			// sign extend
+			if size > 0 && size < 8 {
+				shift := uint(64 - 8*size)
+				i = i << shift >> shift
+			}
+			rec = append(rec, i)
+		case st == 7:
+			rec = append(rec, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case st == 8, st == 9:
+			rec = append(rec, st-8)
+		case st >= 12 && st%2 == 0:
+			rec = append(rec, append([]byte(nil), v...))
+		case st >= 13:
+			rec = append(rec, string(v))
+		default:
+			return nil, fmt.Errorf("%w: serial type %d", ErrCorrupt, st)
+		}
+	}
+	return rec, nil
+}
+
+// sqliteVarint decodes big-endian variable length integer, returns
+// 0 length for truncated input
+func sqliteVarint(d []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(d); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(d[i])), 9
+		}
+		v = v<<7 | uint64(d[i]&0x7f)
+		if d[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return 0, 0
+}
+
+func readError(err error, offset int64) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated at offset %d", ErrCorrupt, offset)
+	}
+	return err
+}
@@ -0,0 +1,324 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/test.sqlite has page size of 1024 to spread tables over
+// interior and overflow pages, wal.sqlite has both of its commits
+// in the write-ahead log only
+
+var testValues = []interface{}{
+	nil, int64(0), int64(1), int64(-1), int64(127), int64(128), int64(-32768), int64(1 << 23),
+	int64(-1 << 31), int64(1 << 40), int64(-1 << 47), int64(-1 << 63), 1.5, "text", []byte("\x00blob"),
+	[]byte(strings.Repeat("x", 5000)), strings.Repeat("y", 3000),
+}
+
+// scan returns rows of table name
+func scan(db *DB, name string) ([][]interface{}, error) {
+	t, err := db.Table(name)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]interface{}
+	err = db.Scan(t, func(rowid int64, rec []interface{}) error {
+		rows = append(rows, rec)
+		return nil
+	})
+	return rows, err
+}
+
+// copyDB copies files of database name in testdata to a temporary
+// directory
+func copyDB(t *testing.T, name string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"", "-wal"} {
+		d, err := ioutil.ReadFile(filepath.Join("testdata", name+suffix))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+suffix), d, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestScan(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	table, err := db.Table("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"id", "v", "note"}; !reflect.DeepEqual(table.Columns, expected) {
+		t.Errorf("got columns %v, expected %v", table.Columns, expected)
+	}
+
+	rows, err := scan(db, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(testValues)+300 {
+		t.Fatalf("got %d rows, expected %d", len(rows), len(testValues)+300)
+	}
+	for i, v := range testValues {
+		expected := []interface{}{int64(i + 1), v, fmt.Sprintf("value %d", i)}
+		if !reflect.DeepEqual(rows[i], expected) {
+			t.Errorf("row %d: got %.40v, expected %.40v", i+1, rows[i], expected)
+		}
+	}
+	for i, row := range rows[len(testValues):] {
+		id := int64(100 + i)
+		if expected := []interface{}{id, id * id, fmt.Sprintf("row %d", id)}; !reflect.DeepEqual(row, expected) {
+			t.Errorf("got %v, expected %v", row, expected)
+		}
+	}
+
+	// scan stops at the first error
+	stop := errors.New("stop")
+	n := 0
+	err = db.Scan(table, func(rowid int64, rec []interface{}) error {
+		if n++; n == 200 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 200 {
+		t.Errorf("got %v after %d rows, expected to stop at 200", err, n)
+	}
+}
+
+func TestTable(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, c := range []struct {
+		name    string
+		columns []string
+		rows    [][]interface{}
+	}{
+		// column added later is missing in old rows
+		{"a", []string{"x", "y"}, [][]interface{}{{"old", nil}, {"new", int64(2)}}},
+		// quoted names, table constraints, no rowid alias
+		{"w", []string{"k", "v"}, [][]interface{}{{"one", int64(1)}}},
+	} {
+		table, err := db.Table(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(table.Columns, c.columns) {
+			t.Errorf("%s: got columns %v, expected %v", c.name, table.Columns, c.columns)
+		}
+		rows, err := scan(db, c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, c.rows) {
+			t.Errorf("%s: got %v, expected %v", c.name, rows, c.rows)
+		}
+	}
+	if _, err := db.Table("missing"); !errors.Is(err, ErrNoTable) {
+		t.Errorf("got %v, expected %v", err, ErrNoTable)
+	}
+}
+
+func TestWAL(t *testing.T) {
+	both := [][]interface{}{{int64(1), "first"}, {int64(2), "second"}}
+	for _, c := range []struct {
+		name    string
+		corrupt func(dir string) error
+		rows    [][]interface{}
+		err     error
+	}{
+		{"committed", nil, both, nil},
+		{"no log", func(dir string) error { return os.Remove(filepath.Join(dir, "wal.sqlite-wal")) }, nil, ErrNoTable},
+		{"empty log", func(dir string) error { return os.Truncate(filepath.Join(dir, "wal.sqlite-wal"), 0) }, nil, ErrNoTable},
+		// log ends at frame with bad checksum
+		{"bad frame", func(dir string) error { return flip(filepath.Join(dir, "wal.sqlite-wal"), -1) }, both[:1], nil},
+		{"partial frame", func(dir string) error {
+			path := filepath.Join(dir, "wal.sqlite-wal")
+			st, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			return os.Truncate(path, st.Size()-1)
+		}, both[:1], nil},
+		// bad header makes the whole log stale
+		{"bad header", func(dir string) error { return flip(filepath.Join(dir, "wal.sqlite-wal"), 20) }, nil, ErrNoTable},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := copyDB(t, "wal.sqlite")
+			defer os.RemoveAll(dir)
+			if c.corrupt != nil {
+				if err := c.corrupt(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db, err := Open(filepath.Join(dir, "wal.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows, err := scan(db, "t")
+			if !errors.Is(err, c.err) || !reflect.DeepEqual(rows, c.rows) {
+				t.Errorf("got %v, %v, expected %v, %v", rows, err, c.rows, c.err)
+			}
+		})
+	}
+}
+
+// flip inverts byte of file at offset, negative offsets count from
+// the end
+func flip(path string, off int64) error {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if off < 0 {
+		off += int64(len(d))
+	}
+	d[off] ^= 0xff
+	return ioutil.WriteFile(path, d, 0644)
+}
+
+// scanAll opens database at path and reads all of its tables
+func scanAll(path string) error {
+	db, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, name := range []string{"t", "a", "w"} {
+		if _, err := scan(db, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCorrupt(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		off     int
+		b       []byte
+		err     error
+		message string
+	}{
+		{"magic", 0, []byte("X"), ErrCorrupt, "bad magic"},
+		{"page size", 16, []byte{0x03, 0x00}, ErrCorrupt, "page size 768"},
+		{"reserved space", 16, []byte{0x02, 0x00, 0x01, 0x01, 0xff}, ErrCorrupt, "usable page size 257"},
+		{"encoding", 56, []byte{0, 0, 0, 2}, ErrUnsupported, "text encoding 2"},
+		{"page type", 1024, []byte{0x0a}, ErrCorrupt, "not a table page"},
+		{"cells", 1024 + 3, []byte{0xff, 0xff}, ErrCorrupt, "too many cells"},
+		// page 2 is interior page of t
+		{"cell pointer", 1024 + 12, []byte{0xff, 0xff}, ErrCorrupt, "cell out of page"},
+		{"right child", 1024 + 8, []byte{0xff, 0xff}, ErrCorrupt, "truncated"},
+		{"child loop", 1024 + 8, []byte{0, 0, 0, 2}, ErrCorrupt, "page 2 referenced twice"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := copyDB(t, "test.sqlite")
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "test.sqlite")
+			d, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			copy(d[c.off:], c.b)
+			if err := ioutil.WriteFile(path, d, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := scanAll(path); !errors.Is(err, c.err) || !strings.Contains(err.Error(), c.message) {
+				t.Errorf("got %v, expected %v: %s", err, c.err, c.message)
+			}
+		})
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join("testdata", "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// every page belongs to some table but the last two holding
+	// indexes of w
+	for n := 0; n < len(d)-2*1024; n += 97 {
+		path := filepath.Join(dir, fmt.Sprintf("%d.sqlite", n))
+		if err := ioutil.WriteFile(path, d[:n], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := scanAll(path); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("truncated at %d: got %v, expected %v", n, err, ErrCorrupt)
+		}
+	}
+}
+
+// TestGarbage damages database at random, it must fail or read fine
+// but never panic
+func TestGarbage(t *testing.T) {
+	orig, err := ioutil.ReadFile(filepath.Join("testdata", "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := rand.New(rand.NewSource(1))
+	d := make([]byte, len(orig))
+	for i := 0; i < 500; i++ {
+		copy(d, orig)
+		for n := r.Intn(8); n >= 0; n-- {
+			d[r.Intn(len(d))] = byte(r.Intn(256))
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d.sqlite", i))
+		if err := ioutil.WriteFile(path, d, 0644); err != nil {
+			t.Fatal(err)
+		}
+		scanAll(path)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, c := range []struct {
+		d []byte
+		v int64
+		n int
+	}{
+		{[]byte{0x7f}, 0x7f, 1},
+		{[]byte{0x81, 0x00}, 0x80, 2},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1, 9},
+		{[]byte{0x81}, 0, 0},
+		{nil, 0, 0},
+	} {
+		if v, n := sqliteVarint(c.d); v != c.v || n != c.n {
+			t.Errorf("%x: got %d, %d, expected %d, %d", c.d, v, n, c.v, c.n)
+		}
+	}
+}
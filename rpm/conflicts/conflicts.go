@@ -0,0 +1,225 @@
+// Package conflicts finds files shipped by more than one package of
+// a set which can't be installed together, the same way rpm detects
+// file conflicts in transaction. Only headers are looked at.
+package conflicts
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// colorMask are colors taking part in multilib resolution, ELF32 and
+// ELF64 ones
+const colorMask = 0x3
+
+const (
+	modeTypeMask = 0xf000
+	modeReg      = 0x8000
+	modeDir      = 0x4000
+	modeLink     = 0xa000
+)
+
+// Conflict is path packages ship with different attributes
+type Conflict struct {
+	Path     string
+	Packages [2]string
+	// Differ are attributes which differ, VerifyMode for file type too
+	Differ rpm.VerifyFlags
+}
+
+func (c Conflict) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path     string    `json:"path"`
+		Packages [2]string `json:"packages"`
+		Differ   []string  `json:"differ"`
+	}{c.Path, c.Packages, c.Differ.Names()})
+}
+
+type pkgInfo struct {
+	name, arch, nvra string
+	evr              rpm.EVR
+	provides         []rpm.Dependency
+	conflicts        []rpm.Dependency
+	obsoletes        []rpm.Dependency
+}
+
+type fileEntry struct {
+	pkg    int
+	mode   uint16
+	size   int64
+	digest string
+	algo   int32
+	linkTo string
+	user   string
+	group  string
+	flags  rpm.FileFlags
+	color  uint32
+}
+
+// Index collects file tables of packages
+type Index struct {
+	pkgs  []*pkgInfo
+	files map[string][]fileEntry
+}
+
+func NewIndex() *Index {
+	return &Index{files: map[string][]fileEntry{}}
+}
+
+// Add adds files of package described by header to index
+func (ix *Index) Add(h *rpm.Header) error {
+	p := &pkgInfo{nvra: rpmutil.HeaderNVRA(h)}
+	p.name, _ = h.GetString(rpm.TagName)
+	p.arch, _ = h.GetString(rpm.TagArch)
+	p.evr.Version, _ = h.GetString(rpm.TagVersion)
+	p.evr.Release, _ = h.GetString(rpm.TagRelease)
+	if e, err := h.GetInt(rpm.TagEpoch); err == nil {
+		p.evr.Epoch = int(e)
+	}
+	var err error
+	if p.provides, err = h.Provides(); err != nil {
+		return err
+	}
+	if p.conflicts, err = h.Conflicts(); err != nil {
+		return err
+	}
+	if p.obsoletes, err = h.Obsoletes(); err != nil {
+		return err
+	}
+	files, err := rpmutil.HeaderFiles(h)
+	if err != nil {
+		return err
+	}
+	algo := int32(rpm.HashMD5)
+	if n, err := h.GetInt(rpm.TagFileDigestAlgo); err == nil {
+		algo = int32(n)
+	} else if !errors.Is(err, rpm.ErrTagNotFound) {
+		return err
+	}
+
+	idx := len(ix.pkgs)
+	ix.pkgs = append(ix.pkgs, p)
+	for _, f := range files {
+		ix.files[f.Name] = append(ix.files[f.Name], fileEntry{
+			pkg:    idx,
+			mode:   f.Mode,
+			size:   f.Size,
+			digest: f.Digest,
+			algo:   algo,
+			linkTo: f.LinkTo,
+			user:   f.User,
+			group:  f.Group,
+			flags:  f.Flags,
+			color:  f.Color,
+		})
+	}
+	return nil
+}
+
+// Conflicts returns conflicting files sorted by path. Packages which
+// declare conflict with or obsolete one another, and versions of the
+// same package, are never installed together and don't conflict.
+func (ix *Index) Conflicts() []Conflict {
+	var res []Conflict
+	for path, entries := range ix.files {
+		for i := range entries {
+			for j := i + 1; j < len(entries); j++ {
+				a, b := &entries[i], &entries[j]
+				if a.pkg == b.pkg || !ix.coinstallable(a.pkg, b.pkg) {
+					continue
+				}
+				if differ := compareFiles(a, b); differ != rpm.VerifyNone {
+					res = append(res, Conflict{
+						Path:     path,
+						Packages: [2]string{ix.pkgs[a.pkg].nvra, ix.pkgs[b.pkg].nvra},
+						Differ:   differ,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Path != res[j].Path {
+			return res[i].Path < res[j].Path
+		}
+		if res[i].Packages[0] != res[j].Packages[0] {
+			return res[i].Packages[0] < res[j].Packages[0]
+		}
+		return res[i].Packages[1] < res[j].Packages[1]
+	})
+	return res
+}
+
+func (ix *Index) coinstallable(a, b int) bool {
+	pa, pb := ix.pkgs[a], ix.pkgs[b]
+	if pa.name == pb.name && pa.arch == pb.arch {
+		return false
+	}
+	return !excludes(pa, pb) && !excludes(pb, pa)
+}
+
+// excludes reports whether package a conflicts with or obsoletes b
+func excludes(a, b *pkgInfo) bool {
+	self := rpm.Dependency{Name: b.name, Flags: rpm.SenseEqual, Version: b.evr.String()}
+	for _, c := range a.conflicts {
+		if c.Overlaps(self) {
+			return true
+		}
+		for _, p := range b.provides {
+			if c.Overlaps(p) {
+				return true
+			}
+		}
+	}
+	// obsoletes match package names only
+	for _, o := range a.obsoletes {
+		if o.Overlaps(self) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareFiles returns attributes which make files conflict, it
+// follows rpmfilesCompare of rpm
+func compareFiles(a, b *fileEntry) rpm.VerifyFlags {
+	if (a.flags|b.flags)&rpm.FileGhost != 0 {
+		return rpm.VerifyNone
+	}
+	aType, bType := a.mode&modeTypeMask, b.mode&modeTypeMask
+	// directories are shared by packages freely
+	if aType == modeDir && bType == modeDir {
+		return rpm.VerifyNone
+	}
+
+	var differ rpm.VerifyFlags
+	if !(aType == modeLink && bType == modeLink) && a.mode != b.mode {
+		differ |= rpm.VerifyMode
+	}
+	if (aType == modeReg || aType == modeLink) && a.size != b.size {
+		differ |= rpm.VerifySize
+	}
+	if a.user != b.user {
+		differ |= rpm.VerifyUser
+	}
+	if a.group != b.group {
+		differ |= rpm.VerifyGroup
+	}
+	switch {
+	case aType == modeLink && a.linkTo != b.linkTo:
+		differ |= rpm.VerifyLinkTo
+	case aType == modeReg && (a.algo != b.algo || a.digest != b.digest):
+		differ |= rpm.VerifyDigest
+	}
+
+	// files of different multilib colors replace each other
+	ac, bc := a.color&colorMask, b.color&colorMask
+	if differ != rpm.VerifyNone && ac != 0 && bc != 0 && ac != bc {
+		return rpm.VerifyNone
+	}
+	return differ
+}
@@ -0,0 +1,185 @@
+package conflicts
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+)
+
+type pkg struct {
+	name, version, arch string
+	files               []rpmtest.File
+	colors              []int32
+	conflicts           string
+	obsoletes           string
+}
+
+func (p *pkg) header() *rpm.Header {
+	h := rpmtest.Header(p.name, p.files...)
+	if p.version != "" {
+		h.SetString(rpm.TagVersion, p.version)
+	}
+	if p.arch != "" {
+		h.SetString(rpm.TagArch, p.arch)
+	}
+	if p.colors != nil {
+		h.SetInt32s(rpm.TagFileColors, p.colors)
+	}
+	if p.conflicts != "" {
+		h.SetStrings(rpm.TagConflictName, []string{p.conflicts})
+		h.SetInt32s(rpm.TagConflictFlags, []int32{0})
+		h.SetStrings(rpm.TagConflictVersion, []string{""})
+	}
+	if p.obsoletes != "" {
+		h.SetStrings(rpm.TagObsoleteName, []string{p.obsoletes})
+		h.SetInt32s(rpm.TagObsoleteFlags, []int32{0})
+		h.SetStrings(rpm.TagObsoleteVersion, []string{""})
+	}
+	return h
+}
+
+func TestConflicts(t *testing.T) {
+	readme := rpmtest.File{Name: "/usr/share/doc/README", Mode: 0100644, Data: "readme"}
+	lib := func(data string) rpmtest.File {
+		return rpmtest.File{Name: "/usr/bin/tool", Mode: 0100755, Data: data}
+	}
+
+	tests := []struct {
+		name   string
+		a, b   pkg
+		differ rpm.VerifyFlags
+	}{
+		{
+			name: "identical files",
+			a:    pkg{name: "a", files: []rpmtest.File{readme}},
+			b:    pkg{name: "b", files: []rpmtest.File{readme}},
+		},
+		{
+			name:   "different contents",
+			a:      pkg{name: "a", files: []rpmtest.File{readme}},
+			b:      pkg{name: "b", files: []rpmtest.File{{Name: "/usr/share/doc/README", Mode: 0100644, Data: "README"}}},
+			differ: rpm.VerifyDigest,
+		},
+		{
+			name:   "different size and mode",
+			a:      pkg{name: "a", files: []rpmtest.File{readme}},
+			b:      pkg{name: "b", files: []rpmtest.File{{Name: "/usr/share/doc/README", Mode: 0100600, Data: "readme!"}}},
+			differ: rpm.VerifyMode | rpm.VerifySize | rpm.VerifyDigest,
+		},
+		{
+			name:   "file and directory",
+			a:      pkg{name: "a", files: []rpmtest.File{{Name: "/etc/hello", Mode: 040755}}},
+			b:      pkg{name: "b", files: []rpmtest.File{{Name: "/etc/hello", Mode: 0100644}}},
+			differ: rpm.VerifyMode,
+		},
+		{
+			name: "directories",
+			a:    pkg{name: "a", files: []rpmtest.File{{Name: "/etc/hello", Mode: 040755}}},
+			b:    pkg{name: "b", files: []rpmtest.File{{Name: "/etc/hello", Mode: 040700}}},
+		},
+		{
+			name:   "symlinks",
+			a:      pkg{name: "a", files: []rpmtest.File{{Name: "/usr/bin/hi", Mode: 0120777, Data: "hello"}}},
+			b:      pkg{name: "b", files: []rpmtest.File{{Name: "/usr/bin/hi", Mode: 0120777, Data: "howdy"}}},
+			differ: rpm.VerifyLinkTo,
+		},
+		{
+			name: "ghost",
+			a:    pkg{name: "a", files: []rpmtest.File{readme}},
+			b:    pkg{name: "b", files: []rpmtest.File{{Name: "/usr/share/doc/README", Mode: 0100644, Flags: rpm.FileGhost}}},
+		},
+		{
+			name: "versions of package",
+			a:    pkg{name: "a", files: []rpmtest.File{lib("1")}},
+			b:    pkg{name: "a", version: "2.0", files: []rpmtest.File{lib("2")}},
+		},
+		{
+			name:   "different arch",
+			a:      pkg{name: "a", arch: "x86_64", files: []rpmtest.File{lib("1")}},
+			b:      pkg{name: "a", arch: "i686", files: []rpmtest.File{lib("2")}},
+			differ: rpm.VerifyDigest,
+		},
+		{
+			name: "multilib colors",
+			a:    pkg{name: "a", arch: "x86_64", files: []rpmtest.File{lib("64")}, colors: []int32{2}},
+			b:    pkg{name: "a", arch: "i686", files: []rpmtest.File{lib("32")}, colors: []int32{1}},
+		},
+		{
+			name:   "same color",
+			a:      pkg{name: "a", arch: "x86_64", files: []rpmtest.File{lib("64")}, colors: []int32{2}},
+			b:      pkg{name: "a", arch: "i686", files: []rpmtest.File{lib("32")}, colors: []int32{2}},
+			differ: rpm.VerifyDigest,
+		},
+		{
+			name: "declared conflict",
+			a:    pkg{name: "a", files: []rpmtest.File{lib("1")}, conflicts: "b"},
+			b:    pkg{name: "b", files: []rpmtest.File{lib("2")}},
+		},
+		{
+			name: "obsoletes",
+			a:    pkg{name: "a", files: []rpmtest.File{lib("1")}},
+			b:    pkg{name: "b", files: []rpmtest.File{lib("2")}, obsoletes: "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := NewIndex()
+			for _, p := range []*pkg{&tt.a, &tt.b} {
+				if err := ix.Add(p.header()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			res := ix.Conflicts()
+			if tt.differ == rpm.VerifyNone {
+				if len(res) > 0 {
+					t.Errorf("got %+v, expected no conflicts", res)
+				}
+				return
+			}
+			if len(res) != 1 || res[0].Differ != tt.differ {
+				t.Errorf("got %+v, expected conflict of %s", res, tt.differ)
+			}
+		})
+	}
+}
+
+func TestConflictsOrder(t *testing.T) {
+	ix := NewIndex()
+	for _, p := range []pkg{
+		{name: "c", files: []rpmtest.File{{Name: "/b", Mode: 0100644, Data: "c"}, {Name: "/a", Mode: 0100644, Data: "c"}}},
+		{name: "a", files: []rpmtest.File{{Name: "/b", Mode: 0100644, Data: "a"}}},
+		{name: "b", files: []rpmtest.File{{Name: "/a", Mode: 0100644, Data: "b"}, {Name: "/b", Mode: 0100644, Data: "b"}}},
+	} {
+		if err := ix.Add(p.header()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got [][3]string
+	for _, c := range ix.Conflicts() {
+		got = append(got, [3]string{c.Path, c.Packages[0], c.Packages[1]})
+	}
+	expected := [][3]string{
+		{"/a", "c-1.0-1.noarch", "b-1.0-1.noarch"},
+		{"/b", "a-1.0-1.noarch", "b-1.0-1.noarch"},
+		{"/b", "c-1.0-1.noarch", "a-1.0-1.noarch"},
+		{"/b", "c-1.0-1.noarch", "b-1.0-1.noarch"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestConflictJSON(t *testing.T) {
+	c := Conflict{Path: "/a", Packages: [2]string{"a-1.0-1.noarch", "b-1.0-1.noarch"}, Differ: rpm.VerifySize | rpm.VerifyDigest}
+	d, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"path":"/a","packages":["a-1.0-1.noarch","b-1.0-1.noarch"],"differ":["size","digest"]}`
+	if string(d) != expected {
+		t.Errorf("got %s, expected %s", d, expected)
+	}
+}
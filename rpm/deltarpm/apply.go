@@ -0,0 +1,125 @@
+package deltarpm
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+)
+
+// Apply rebuilds new package from old package file read from r and
+// writes it into w. What's been written is not a valid package if
+// Apply fails.
+func (d *Delta) Apply(r io.Reader, w io.Writer) error {
+	if !d.RPMOnly {
+		return fmt.Errorf("%w: applying standard delta", ErrUnsupported)
+	}
+	if len(d.add) > 0 || len(d.offAdjust) > 0 {
+		return fmt.Errorf("%w: add data", ErrUnsupported)
+	}
+	o, err := readPackage(r)
+	if err != nil {
+		return err
+	}
+	if n := nevr(o.pkg.Header); n != d.OldNEVR {
+		return fmt.Errorf("%w: %s is not %s", ErrOldMismatch, n, d.OldNEVR)
+	}
+	if !bytes.Equal(o.sequence(), d.Sequence[:md5Size]) {
+		return fmt.Errorf("%w: sequence differs", ErrOldMismatch)
+	}
+	old, err := o.oldData()
+	if err != nil {
+		return err
+	}
+	if int64(len(old)) != d.extSize {
+		return fmt.Errorf("%w: size of old data differs", ErrOldMismatch)
+	}
+
+	h := md5.New()
+	cw := &countWriter{w: io.MultiWriter(w, h)}
+	if _, err := cw.Write(d.Lead); err != nil {
+		return err
+	}
+	cr, err := compressor(cw, d.Compression)
+	if err != nil {
+		return err
+	}
+	out := &headerWriter{n: int(d.HeaderSize), header: cw, rest: cr}
+	if err := d.copy(old, out); err != nil {
+		cr.Close()
+		return err
+	}
+	if err := cr.Close(); err != nil {
+		return err
+	}
+	sum := h.Sum(nil)
+	if cw.n != d.Size || !bytes.Equal(sum, d.MD5) {
+		return fmt.Errorf("%w: expected %x, got %x", ErrDigestMismatch, d.MD5, sum)
+	}
+	return nil
+}
+
+// copy writes new data made by copies from old data and internal data
+func (d *Delta) copy(old []byte, w io.Writer) error {
+	ext := d.extCopies
+	internal := d.internal
+	pos := int64(0)
+	for i := 0; i < len(d.intCopies); i += 2 {
+		n, size := d.intCopies[i], d.intCopies[i+1]
+		if uint64(n) > uint64(len(ext)/2) {
+			return fmt.Errorf("%w: copy %d out of range", ErrMalformed, i/2)
+		}
+		for ; n > 0; n-- {
+			pos += decodeAdjust(ext[0])
+			end := pos + int64(ext[1])
+			if pos < 0 || end > int64(len(old)) {
+				return fmt.Errorf("%w: copy from %d out of range", ErrMalformed, pos)
+			}
+			if _, err := w.Write(old[pos:end]); err != nil {
+				return err
+			}
+			ext = ext[2:]
+			pos = end
+		}
+		if uint64(size) > uint64(len(internal)) {
+			return fmt.Errorf("%w: copy %d out of range", ErrMalformed, i/2)
+		}
+		if _, err := w.Write(internal[:size]); err != nil {
+			return err
+		}
+		internal = internal[size:]
+	}
+	if len(ext) > 0 || len(internal) > 0 {
+		return fmt.Errorf("%w: data left over", ErrMalformed)
+	}
+	return nil
+}
+
+// headerWriter writes the first n bytes into header and the rest into
+// rest, new header of rpm-only delta isn't compressed
+type headerWriter struct {
+	n            int
+	header, rest io.Writer
+}
+
+func (h *headerWriter) Write(d []byte) (int, error) {
+	written := 0
+	if h.n > 0 {
+		k := len(d)
+		if k > h.n {
+			k = h.n
+		}
+		n, err := h.header.Write(d[:k])
+		h.n -= n
+		written += n
+		if err != nil {
+			return written, err
+		}
+		d = d[k:]
+	}
+	if len(d) == 0 {
+		return written, nil
+	}
+	n, err := h.rest.Write(d)
+	return written + n, err
+}
@@ -0,0 +1,414 @@
+// Package deltarpm reads, makes and applies delta rpms in format of
+// deltarpm. Delta is the difference between uncompressed headers and
+// payloads of two builds of a package, new package is rebuilt by
+// compressing the result again the way deltarpm does it, and checked
+// against MD5 digest of the original.
+//
+// Make makes rpm-only deltas, the kind makedeltarpm -r makes, which
+// apply to the old package file. Standard deltas, which carry header
+// of new package in front of the diff and apply to installed files
+// too, are read but not applied.
+package deltarpm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/compress/zstd"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var (
+	ErrNotDelta    = errors.New("not a delta rpm")
+	ErrMalformed   = errors.New("malformed delta rpm")
+	ErrUnsupported = errors.New("unsupported delta rpm")
+	ErrOldMismatch = errors.New("old package doesn't match delta")
+	// ErrDigestMismatch is returned when rebuilt package differs
+	// from the original, usually because it compresses differently
+	ErrDigestMismatch   = errors.New("digest of result doesn't match")
+	ErrDifferentPackage = errors.New("packages differ in name or arch")
+)
+
+const (
+	// rpmOnlyMagic starts rpm-only deltas, standard ones are rpm
+	// packages themselves
+	rpmOnlyMagic = "drpm"
+	// versions of delta data are DLT1 to DLT3
+	versionMagic = 'D'<<24 | 'L'<<16 | 'T'<<8
+	version3     = versionMagic | '3'
+
+	md5Size = 16
+)
+
+// compression algorithms of deltarpm, level is kept in the second
+// byte of compression
+const (
+	compNone  = 0
+	compGzip  = 1
+	compBzip2 = 2
+	compLZMA  = 5
+	compXZ    = 6
+	compZstd  = 7
+)
+
+// Delta is delta rpm
+type Delta struct {
+	// Version of delta data, 1 to 3
+	Version int
+	// RPMOnly is set for deltas which apply to old package file
+	// only, their diff starts with header of new package
+	RPMOnly bool
+	// Header is header of new package standard deltas carry
+	Header *rpm.Header
+	// NEVR and OldNEVR are name-[epoch:]version-release of new and
+	// old package
+	NEVR    string
+	OldNEVR string
+	// Sequence identifies old package delta applies to, it starts
+	// with MD5 digest, which is the digest of header and payload of
+	// old package file for rpm-only deltas
+	Sequence []byte
+	// MD5 and Size are digest and size of new package file
+	MD5  []byte
+	Size int64
+	// Compression is deltarpm algorithm new payload is compressed
+	// with, level in the second byte
+	Compression      uint32
+	CompressionParam []byte
+	// HeaderSize is size of new header diff of rpm-only delta
+	// starts with
+	HeaderSize uint32
+	// Lead is lead and signature header of new package
+	Lead []byte
+	// PayloadFormatOffset is offset of payload format in header of
+	// standard delta, which says drpm instead of cpio
+	PayloadFormatOffset uint32
+
+	offAdjust []uint32
+	// internal copies are pairs of number of external copies to
+	// make first and length of internal data to copy
+	intCopies []uint32
+	// external copies are pairs of offset adjustment and length,
+	// offset of a copy is relative to the end of previous one
+	extCopies []uint32
+	extSize   int64
+	add       []byte
+	internal  []byte
+}
+
+// SequenceID returns sequence the way prestodelta lists it, old
+// name-[epoch:]version-release followed by the sequence in hex
+func (d *Delta) SequenceID() string {
+	return d.OldNEVR + "-" + hex.EncodeToString(d.Sequence)
+}
+
+// nevr returns name-[epoch:]version-release of package header
+// describes
+func nevr(h *rpm.Header) string {
+	name, _ := h.GetString(rpm.TagName)
+	evr := rpm.EVR{}
+	evr.Version, _ = h.GetString(rpm.TagVersion)
+	evr.Release, _ = h.GetString(rpm.TagRelease)
+	if e, err := h.GetInt(rpm.TagEpoch); err == nil {
+		evr.Epoch = int(e)
+	}
+	return name + "-" + evr.String()
+}
+
+// Read reads delta file
+func Read(r io.Reader) (*Delta, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrNotDelta
+		}
+		return nil, err
+	}
+	d := &Delta{}
+	switch {
+	case string(magic) == rpmOnlyMagic:
+		d.RPMOnly = true
+		dec := &decoder{r: br}
+		dec.uint32()
+		if v := dec.uint32(); dec.err == nil && v != version3 {
+			return nil, fmt.Errorf("%w: rpm-only delta of version %x", ErrMalformed, v)
+		}
+		d.NEVR = string(dec.bytes32())
+		// deltarpm has no use for it either
+		dec.bytes32()
+		if dec.err != nil {
+			return nil, dec.error()
+		}
+	case rpm.IsRPM(magic):
+		pkg, err := rpmutil.ReadPackage(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+		}
+		d.Header = pkg.Header
+		d.NEVR = nevr(pkg.Header)
+	default:
+		return nil, ErrNotDelta
+	}
+
+	body, err := decompress(br)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.readData(&decoder{r: bufio.NewReader(body)}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readData reads delta data which follows header of delta
+func (d *Delta) readData(dec *decoder) error {
+	v := dec.uint32()
+	if dec.err == nil && (v&^0xff != versionMagic || v&0xff < '1' || v&0xff > '3') {
+		return fmt.Errorf("%w: version %x", ErrMalformed, v)
+	}
+	d.Version = int(v&0xff - '0')
+	d.OldNEVR = string(dec.bytes32())
+	d.Sequence = dec.bytes32()
+	if dec.err == nil && len(d.Sequence) < md5Size {
+		return fmt.Errorf("%w: short sequence", ErrMalformed)
+	}
+	d.MD5 = dec.bytes(md5Size)
+	if d.Version > 1 {
+		d.Size = int64(dec.uint32())
+		d.Compression = dec.uint32()
+		d.CompressionParam = dec.bytes32()
+	}
+	if d.Version > 2 {
+		d.HeaderSize = dec.uint32()
+		d.offAdjust = dec.pairs(dec.uint32())
+	}
+	d.Lead = dec.bytes32()
+	if dec.err == nil && len(d.Lead) < rpm.LeadSize {
+		return fmt.Errorf("%w: short lead", ErrMalformed)
+	}
+	d.PayloadFormatOffset = dec.uint32()
+	nint, next := dec.uint32(), dec.uint32()
+	d.intCopies = dec.pairs(nint)
+	d.extCopies = dec.pairs(next)
+	if d.Version > 2 {
+		d.extSize = int64(dec.uint64())
+	} else {
+		d.extSize = int64(dec.uint32())
+	}
+	d.add = dec.bytes32()
+	if d.Version > 2 {
+		d.internal = dec.bytes(dec.uint64())
+	} else {
+		d.internal = dec.bytes32()
+	}
+	return dec.error()
+}
+
+// WriteTo writes delta file, only rpm-only deltas can be written
+func (d *Delta) WriteTo(w io.Writer) (int64, error) {
+	if !d.RPMOnly {
+		return 0, fmt.Errorf("%w: writing standard delta", ErrUnsupported)
+	}
+	cw := &countWriter{w: w}
+	e := &encoder{w: cw}
+	e.write([]byte(rpmOnlyMagic))
+	e.uint32(version3)
+	e.bytes32([]byte(d.NEVR))
+	e.bytes32(nil)
+	if e.err != nil {
+		return cw.n, e.err
+	}
+
+	gz, _ := gzip.NewWriterLevel(cw, gzip.BestCompression)
+	bw := bufio.NewWriter(gz)
+	e = &encoder{w: bw}
+	e.uint32(version3)
+	e.bytes32([]byte(d.OldNEVR))
+	e.bytes32(d.Sequence)
+	e.write(d.MD5)
+	e.uint32(uint32(d.Size))
+	e.uint32(d.Compression)
+	e.bytes32(d.CompressionParam)
+	e.uint32(d.HeaderSize)
+	e.uint32(uint32(len(d.offAdjust) / 2))
+	e.pairs(d.offAdjust)
+	e.bytes32(d.Lead)
+	e.uint32(d.PayloadFormatOffset)
+	e.uint32(uint32(len(d.intCopies) / 2))
+	e.uint32(uint32(len(d.extCopies) / 2))
+	e.pairs(d.intCopies)
+	e.pairs(d.extCopies)
+	e.uint64(uint64(d.extSize))
+	e.bytes32(d.add)
+	e.uint64(uint64(len(d.internal)))
+	e.write(d.internal)
+	if e.err == nil {
+		e.err = bw.Flush()
+	}
+	if e.err == nil {
+		e.err = gz.Close()
+	}
+	return cw.n, e.err
+}
+
+// ReadFile reads delta file fname
+func ReadFile(fname string) (*Delta, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return d, nil
+}
+
+// decompress returns delta data, deltarpm tells its compression by
+// magic
+func decompress(br *bufio.Reader) (io.Reader, error) {
+	magic, _ := br.Peek(6)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+		}
+		return r, nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte("\xfd7zXZ\x00")):
+		return xz.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return zstd.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte("DLT")):
+		return br, nil
+	}
+	return nil, fmt.Errorf("%w: unknown compression of delta data", ErrUnsupported)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(d []byte) (int, error) {
+	n, err := c.w.Write(d)
+	c.n += int64(n)
+	return n, err
+}
+
+// encoder writes big-endian numbers and data prefixed by length, the
+// first error sticks
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) write(d []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(d)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.write(b[:])
+}
+
+func (e *encoder) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.write(b[:])
+}
+
+func (e *encoder) bytes32(d []byte) {
+	e.uint32(uint32(len(d)))
+	e.write(d)
+}
+
+// pairs writes first numbers of pairs and then the second ones
+func (e *encoder) pairs(p []uint32) {
+	for i := 0; i < len(p); i += 2 {
+		e.uint32(p[i])
+	}
+	for i := 1; i < len(p); i += 2 {
+		e.uint32(p[i])
+	}
+}
+
+type decoder struct {
+	r   io.Reader
+	err error
+}
+
+func (d *decoder) error() error {
+	if d.err == io.EOF || d.err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated", ErrMalformed)
+	}
+	return d.err
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.bytes(4)
+	if d.err != nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.bytes(8)
+	if d.err != nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// bytes reads n bytes, n isn't trusted to allocate the buffer up
+// front
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(d.r, int64(n&(1<<63-1))))
+	if err == nil && uint64(len(b)) != n {
+		err = io.ErrUnexpectedEOF
+	}
+	d.err = err
+	return b
+}
+
+func (d *decoder) bytes32() []byte {
+	return d.bytes(uint64(d.uint32()))
+}
+
+// pairs reads n pairs, stored as first numbers of pairs followed by
+// the second ones
+func (d *decoder) pairs(n uint32) []uint32 {
+	var p []uint32
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		p = append(p, d.uint32(), 0)
+	}
+	for i := uint32(0); i < n && d.err == nil; i++ {
+		p[2*i+1] = d.uint32()
+	}
+	if d.err != nil {
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,260 @@
+package deltarpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// testFiles returns files of version of hello package, large data is
+// random to keep it from compressing and is the same in every version
+func testFiles(version string) []rpmtest.File {
+	r := rand.New(rand.NewSource(1))
+	large := make([]byte, 64<<10)
+	r.Read(large)
+	return []rpmtest.File{
+		{Name: "/usr/bin/hello", Mode: 0100755, Data: "#!/bin/sh\necho hello " + version + "\n"},
+		{Name: "/usr/share/hello/data", Mode: 0100644, Data: string(large)},
+		{Name: "/usr/share/hello/" + version, Mode: 0100644, Data: strings.Repeat(version, 100)},
+	}
+}
+
+// buildPackage returns hello package of version with payload
+// compressed by compress, whose header says compressor and flags
+func buildPackage(t *testing.T, name, version, compressor, flags string, compress func([]byte) []byte) []byte {
+	t.Helper()
+	files := testFiles(version)
+	h := rpmtest.Header(name, files...)
+	h.SetString(rpm.TagVersion, version)
+	h.SetString(rpm.TagSourceRPM, name+"-"+version+"-1.src.rpm")
+	h.SetString(rpm.TagPayloadCompressor, compressor)
+	if flags != "" {
+		h.SetString(rpm.TagPayloadFlags, flags)
+	}
+	payload := rpmtest.Payload(files...)
+	if compress != nil {
+		payload = compress(payload)
+	}
+	var b bytes.Buffer
+	lead := rpm.NewLead(name+"-"+version+"-1", "noarch", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func gzipLevel(level int) func([]byte) []byte {
+	return func(d []byte) []byte {
+		var b bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&b, level)
+		w.Write(d)
+		w.Close()
+		return b.Bytes()
+	}
+}
+
+// makeDelta makes delta of old and new and returns it written
+func makeDelta(t *testing.T, old, new []byte) []byte {
+	t.Helper()
+	d, err := Make(bytes.NewReader(old), bytes.NewReader(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := d.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		name              string
+		compressor, flags string
+		compress          func([]byte) []byte
+		tool              string
+		compression       uint32
+	}{
+		{"uncompressed", "uncompressed", "", nil, "", compNone},
+		{"gzip", "gzip", "9", gzipLevel(9), "", compGzip | 9<<8},
+		// rpm's gzip doesn't compress the way Go does, payload is
+		// diffed compressed
+		{"gzip elsewhere", "gzip", "9", gzipLevel(1), "", compNone},
+		{"xz", "xz", "2", func(d []byte) []byte {
+			var b bytes.Buffer
+			w := xz.NewWriter(&b, 2)
+			w.Write(d)
+			w.Close()
+			return b.Bytes()
+		}, "xz", compXZ | 2<<8},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if c.tool != "" {
+				if _, err := exec.LookPath(c.tool); err != nil {
+					t.Skipf("%s not installed", c.tool)
+				}
+			}
+			old := buildPackage(t, "hello", "1.0", c.compressor, c.flags, c.compress)
+			new := buildPackage(t, "hello", "1.1", c.compressor, c.flags, c.compress)
+			data := makeDelta(t, old, new)
+			if !bytes.HasPrefix(data, []byte("drpmDLT3")) {
+				t.Errorf("got magic %q, expected drpmDLT3", data[:8])
+			}
+
+			d, err := Read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != 3 || !d.RPMOnly || d.NEVR != "hello-1.1-1" || d.OldNEVR != "hello-1.0-1" || d.Size != int64(len(new)) {
+				t.Errorf("got version %d, rpm-only %t, %s from %s, size %d", d.Version, d.RPMOnly, d.NEVR, d.OldNEVR, d.Size)
+			}
+			if d.Compression != c.compression {
+				t.Errorf("got compression %#x, expected %#x", d.Compression, c.compression)
+			}
+			if !strings.HasPrefix(d.SequenceID(), "hello-1.0-1-") || len(d.SequenceID()) != len("hello-1.0-1-")+32 {
+				t.Errorf("got sequence %s", d.SequenceID())
+			}
+			if c.compression != compNone && len(data) > len(new)/4 {
+				t.Errorf("delta of %d bytes is too large for package of %d bytes", len(data), len(new))
+			}
+
+			var b bytes.Buffer
+			if err := d.Apply(bytes.NewReader(old), &b); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b.Bytes(), new) {
+				t.Errorf("rebuilt package of %d bytes differs from new package of %d bytes", b.Len(), len(new))
+			}
+		})
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	old := buildPackage(t, "hello", "1.0", "uncompressed", "", nil)
+	new := buildPackage(t, "hello", "1.1", "uncompressed", "", nil)
+	d, err := Read(bytes.NewReader(makeDelta(t, old, new)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// same name-version-release, different contents
+	other := buildPackage(t, "hello", "1.0", "gzip", "9", gzipLevel(9))
+	for _, pkg := range [][]byte{new, other} {
+		if err := d.Apply(bytes.NewReader(pkg), ioutil.Discard); !errors.Is(err, ErrOldMismatch) {
+			t.Errorf("got %v, expected %v", err, ErrOldMismatch)
+		}
+	}
+
+	// new package changed since delta was made
+	d.MD5[0] ^= 0xff
+	if err := d.Apply(bytes.NewReader(old), ioutil.Discard); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("got %v, expected %v", err, ErrDigestMismatch)
+	}
+
+	bye := buildPackage(t, "bye", "1.1", "uncompressed", "", nil)
+	if _, err := Make(bytes.NewReader(old), bytes.NewReader(bye)); !errors.Is(err, ErrDifferentPackage) {
+		t.Errorf("got %v, expected %v", err, ErrDifferentPackage)
+	}
+}
+
+// rawDelta returns delta with delta data left uncompressed, along
+// with offset of the data
+func rawDelta(t *testing.T, data []byte) ([]byte, int) {
+	t.Helper()
+	n := 12 + len("hello-1.1-1") + 4
+	r, err := gzip.NewReader(bytes.NewReader(data[n:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data[:n:n], body...), n
+}
+
+func TestStandard(t *testing.T) {
+	old := buildPackage(t, "hello", "1.0", "uncompressed", "", nil)
+	new := buildPackage(t, "hello", "1.1", "uncompressed", "", nil)
+	_, n := rawDelta(t, makeDelta(t, old, new))
+
+	// standard delta is the new header with delta data for payload
+	pkg, err := rpmutil.ReadPackage(bytes.NewReader(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := rpmutil.WritePackage(&b, rpm.NewLead("hello-1.1-1", "noarch", 0), pkg.SigHeader, pkg.Header, nil); err != nil {
+		t.Fatal(err)
+	}
+	b.Write(makeDelta(t, old, new)[n:])
+
+	d, err := Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.RPMOnly || d.Header == nil || d.NEVR != "hello-1.1-1" || d.OldNEVR != "hello-1.0-1" {
+		t.Errorf("got rpm-only %t, %s from %s", d.RPMOnly, d.NEVR, d.OldNEVR)
+	}
+	if err := d.Apply(bytes.NewReader(old), ioutil.Discard); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got %v, expected %v", err, ErrUnsupported)
+	}
+	if _, err := d.WriteTo(ioutil.Discard); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got %v, expected %v", err, ErrUnsupported)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	old := buildPackage(t, "hello", "1.0", "uncompressed", "", nil)
+	new := buildPackage(t, "hello", "1.1", "uncompressed", "", nil)
+	raw, _ := rawDelta(t, makeDelta(t, old, new))
+	// data left uncompressed reads the same
+	if _, err := Read(bytes.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range [][]byte{nil, []byte("drp"), []byte("not a delta"), new[:rpm.LeadSize]} {
+		if _, err := Read(bytes.NewReader(d)); !errors.Is(err, ErrNotDelta) && !errors.Is(err, ErrMalformed) {
+			t.Errorf("%.10q: got %v, expected not a delta", d, err)
+		}
+	}
+	for n := 4; n < len(raw); n += 97 {
+		if _, err := Read(bytes.NewReader(raw[:n])); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("truncated at %d: got %v, expected %v", n, err, ErrMalformed)
+		}
+	}
+}
+
+// TestGarbage damages delta data at random, reading and applying
+// must fail or rebuild the package but never panic
+func TestGarbage(t *testing.T) {
+	old := buildPackage(t, "hello", "1.0", "uncompressed", "", nil)
+	new := buildPackage(t, "hello", "1.1", "uncompressed", "", nil)
+	orig, n := rawDelta(t, makeDelta(t, old, new))
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, len(orig))
+	for i := 0; i < 300; i++ {
+		copy(data, orig)
+		for k := r.Intn(4); k >= 0; k-- {
+			// mostly the numbers in front of the data
+			off := n + r.Intn(len(orig)-n)
+			if r.Intn(2) == 0 {
+				off = n + r.Intn(300)
+			}
+			data[off] = byte(r.Intn(256))
+		}
+		d, err := Read(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		d.Apply(bytes.NewReader(old), ioutil.Discard)
+	}
+}
@@ -0,0 +1,122 @@
+package deltarpm
+
+import (
+	"bytes"
+)
+
+// op appends Add bytes of add data to the result and then copies Copy
+// bytes of old data starting at Offset
+type op struct {
+	Add    int
+	Copy   int
+	Offset int
+}
+
+const (
+	blockSize = 32
+	// hashMul is multiplier of rolling polynomial hash
+	hashMul = 16777619
+)
+
+// hashBlock returns rolling hash of block and hashMul to the power of
+// its length, which removes leading byte from the hash
+func hashBlock(b []byte) (h, pow uint32) {
+	pow = 1
+	for _, c := range b {
+		h = h*hashMul + uint32(c)
+		pow *= hashMul
+	}
+	return h, pow
+}
+
+// diff returns ops and add data which turn old into new. Blocks of old
+// are indexed by hash, matches found in new are extended both ways.
+func diff(old, new []byte) ([]op, []byte) {
+	index := map[uint32]int{}
+	for i := 0; i+blockSize <= len(old); i += blockSize {
+		h, _ := hashBlock(old[i : i+blockSize])
+		if _, ok := index[h]; !ok {
+			index[h] = i
+		}
+	}
+
+	var ops []op
+	var add []byte
+	// start is where data not covered by ops begins
+	start := 0
+	i := 0
+	var h, pow uint32
+	if len(new) >= blockSize {
+		h, pow = hashBlock(new[:blockSize])
+	}
+	for i+blockSize <= len(new) {
+		if j, ok := index[h]; ok && bytes.Equal(old[j:j+blockSize], new[i:i+blockSize]) {
+			// extend backwards over data not covered yet, then forwards
+			for j > 0 && i > start && old[j-1] == new[i-1] {
+				i--
+				j--
+			}
+			n := 0
+			for j+n < len(old) && i+n < len(new) && old[j+n] == new[i+n] {
+				n++
+			}
+			add = append(add, new[start:i]...)
+			ops = append(ops, op{Add: i - start, Copy: n, Offset: j})
+			i += n
+			start = i
+			if i+blockSize <= len(new) {
+				h, _ = hashBlock(new[i : i+blockSize])
+			}
+			continue
+		}
+		if i+blockSize < len(new) {
+			h = h*hashMul + uint32(new[i+blockSize]) - pow*uint32(new[i])
+		}
+		i++
+	}
+	if start < len(new) {
+		add = append(add, new[start:]...)
+		ops = append(ops, op{Add: len(new) - start})
+	}
+	return ops, add
+}
+
+// copies turns ops into internal and external copies of deltarpm,
+// each internal copy makes pending external copies first and then
+// copies internal data
+func copies(ops []op) (intCopies, extCopies []uint32) {
+	pos := 0
+	pending := uint32(0)
+	for _, o := range ops {
+		if o.Add > 0 {
+			intCopies = append(intCopies, pending, uint32(o.Add))
+			pending = 0
+		}
+		if o.Copy > 0 {
+			extCopies = append(extCopies, encodeAdjust(o.Offset-pos), uint32(o.Copy))
+			pos = o.Offset + o.Copy
+			pending++
+		}
+	}
+	if pending > 0 {
+		intCopies = append(intCopies, pending, 0)
+	}
+	return intCopies, extCopies
+}
+
+// offset adjustments keep sign in the top bit
+const adjustNegative = 1 << 31
+
+func encodeAdjust(adj int) uint32 {
+	if adj < 0 {
+		return uint32(-adj) | adjustNegative
+	}
+	return uint32(adj)
+}
+
+func decodeAdjust(v uint32) int64 {
+	if v&adjustNegative != 0 {
+		return -int64(v &^ adjustNegative)
+	}
+	return int64(v)
+}
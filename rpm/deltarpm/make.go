@@ -0,0 +1,224 @@
+package deltarpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/compress/zstd"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// pkgFile is package file read into memory
+type pkgFile struct {
+	data []byte
+	pkg  *rpmutil.Package
+}
+
+func readPackage(r io.Reader) (*pkgFile, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := rpmutil.ReadPackage(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	return &pkgFile{data: d, pkg: pkg}, nil
+}
+
+// header returns header of package as stored in the file
+func (p *pkgFile) header() []byte {
+	start, end := p.pkg.HeaderRange()
+	return p.data[start:end]
+}
+
+func (p *pkgFile) payload() []byte {
+	_, end := p.pkg.HeaderRange()
+	return p.data[end:]
+}
+
+// sequence returns MD5 digest of header and payload, which is what
+// rpm-only deltas identify old package by
+func (p *pkgFile) sequence() []byte {
+	start, _ := p.pkg.HeaderRange()
+	sum := md5.Sum(p.data[start:])
+	return sum[:]
+}
+
+// oldData returns header followed by uncompressed payload, the data
+// rpm-only delta copies from
+func (p *pkgFile) oldData() ([]byte, error) {
+	r, err := p.pkg.PayloadReader()
+	if err != nil {
+		return nil, err
+	}
+	b := bytes.NewBuffer(append([]byte(nil), p.header()...))
+	if _, err := io.Copy(b, r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Make makes rpm-only delta which turns package file old into package
+// file new
+func Make(old, new io.Reader) (*Delta, error) {
+	o, err := readPackage(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := readPackage(new)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range []rpm.HeaderTag{rpm.TagName, rpm.TagArch} {
+		a, _ := o.pkg.Header.GetString(t)
+		b, _ := n.pkg.Header.GetString(t)
+		if a != b {
+			return nil, ErrDifferentPackage
+		}
+	}
+	oldData, err := o.oldData()
+	if err != nil {
+		return nil, err
+	}
+
+	start, _ := n.pkg.HeaderRange()
+	sum := md5.Sum(n.data)
+	d := &Delta{
+		Version:    3,
+		RPMOnly:    true,
+		NEVR:       nevr(n.pkg.Header),
+		OldNEVR:    nevr(o.pkg.Header),
+		Sequence:   o.sequence(),
+		MD5:        sum[:],
+		Size:       int64(len(n.data)),
+		HeaderSize: uint32(len(n.header())),
+		Lead:       n.data[:start],
+	}
+	newData := append([]byte(nil), n.header()...)
+	comp, cpio := reproduce(n)
+	if cpio != nil {
+		d.Compression = comp
+		newData = append(newData, cpio...)
+	} else {
+		// payload is taken as it is, diff of compressed data is
+		// poor but rebuilds the package exactly
+		d.Compression = compNone
+		newData = append(newData, n.payload()...)
+	}
+
+	ops, internal := diff(oldData, newData)
+	d.intCopies, d.extCopies = copies(ops)
+	d.internal = internal
+	d.extSize = int64(len(oldData))
+	return d, nil
+}
+
+// reproduce returns compression of payload and the payload
+// uncompressed if compressing it again gives the same payload, nil
+// if it doesn't
+func reproduce(p *pkgFile) (uint32, []byte) {
+	comp, err := payloadCompression(p.pkg.Header)
+	if err != nil {
+		return 0, nil
+	}
+	r, err := p.pkg.PayloadReader()
+	if err != nil {
+		return 0, nil
+	}
+	cpio, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, nil
+	}
+	var b bytes.Buffer
+	w, err := compressor(&b, comp)
+	if err != nil {
+		return 0, nil
+	}
+	if _, err := w.Write(cpio); err != nil {
+		return 0, nil
+	}
+	if err := w.Close(); err != nil || !bytes.Equal(b.Bytes(), p.payload()) {
+		return 0, nil
+	}
+	return comp, cpio
+}
+
+// payloadCompression returns deltarpm compression of payload header
+// describes
+func payloadCompression(h *rpm.Header) (uint32, error) {
+	name, err := h.GetString(rpm.TagPayloadCompressor)
+	if err != nil {
+		return 0, err
+	}
+	var comp uint32
+	switch name {
+	case "uncompressed":
+		return compNone, nil
+	case "gzip":
+		comp = compGzip
+	case "bzip2":
+		comp = compBzip2
+	case "lzma":
+		comp = compLZMA
+	case "xz":
+		comp = compXZ
+	case "zstd":
+		comp = compZstd
+	default:
+		return 0, fmt.Errorf("%w: payload compressor %s", ErrUnsupported, name)
+	}
+	// flags start with level, xz and zstd may follow it by
+	// options
+	flags, _ := h.GetString(rpm.TagPayloadFlags)
+	i := strings.IndexFunc(flags, func(r rune) bool { return r < '0' || r > '9' })
+	if i >= 0 {
+		flags = flags[:i]
+	}
+	if level, err := strconv.Atoi(flags); err == nil && level < 256 {
+		comp |= uint32(level) << 8
+	}
+	return comp, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// compressor returns writer which compresses by deltarpm compression
+// comp, level 0 is default level of the compressor
+func compressor(w io.Writer, comp uint32) (io.WriteCloser, error) {
+	level := int(comp >> 8 & 0xff)
+	switch comp & 0xff {
+	case compNone:
+		return nopCloser{w}, nil
+	case compGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case compXZ:
+		if level == 0 {
+			level = 6
+		}
+		return xz.NewWriter(w, level), nil
+	case compZstd:
+		if level == 0 {
+			level = 3
+		}
+		return zstd.NewWriter(w, level), nil
+	}
+	return nil, fmt.Errorf("%w: compression %d", ErrUnsupported, comp&0xff)
+}
@@ -0,0 +1,171 @@
+package rpm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Weak dependency tags
+const (
+	TagRecommendName HeaderTag = 5046 + iota
+	TagRecommendVersion
+	TagRecommendFlags
+	TagSuggestName
+	TagSuggestVersion
+	TagSuggestFlags
+	TagSupplementName
+	TagSupplementVersion
+	TagSupplementFlags
+	TagEnhanceName
+	TagEnhanceVersion
+	TagEnhanceFlags
+)
+
+// Dependency is capability with optional version constraint
+type Dependency struct {
+	Name    string
+	Flags   SenseFlags
+	Version string
+}
+
+// String formats dependency the way it's written in spec file
+func (d Dependency) String() string {
+	if op := d.Flags.Operator(); len(op) > 0 {
+		return d.Name + " " + op + " " + d.Version
+	}
+	return d.Name
+}
+
+// DependencyKind is kind of relation dependency expresses
+type DependencyKind string
+
+const (
+	DepRequires    DependencyKind = "requires"
+	DepProvides    DependencyKind = "provides"
+	DepConflicts   DependencyKind = "conflicts"
+	DepObsoletes   DependencyKind = "obsoletes"
+	DepRecommends  DependencyKind = "recommends"
+	DepSuggests    DependencyKind = "suggests"
+	DepSupplements DependencyKind = "supplements"
+	DepEnhances    DependencyKind = "enhances"
+)
+
+// DependencyKinds lists all kinds of dependencies
+var DependencyKinds = []DependencyKind{
+	DepRequires, DepProvides, DepConflicts, DepObsoletes,
+	DepRecommends, DepSuggests, DepSupplements, DepEnhances,
+}
+
+// name, flags and version tags of dependency kinds
+var dependencyTags = map[DependencyKind][3]HeaderTag{
+	DepRequires:    {TagRequireName, TagRequireFlags, TagRequireVersion},
+	DepProvides:    {TagProvideName, TagProvideFlags, TagProvideVersion},
+	DepConflicts:   {TagConflictName, TagConflictFlags, TagConflictVersion},
+	DepObsoletes:   {TagObsoleteName, TagObsoleteFlags, TagObsoleteVersion},
+	DepRecommends:  {TagRecommendName, TagRecommendFlags, TagRecommendVersion},
+	DepSuggests:    {TagSuggestName, TagSuggestFlags, TagSuggestVersion},
+	DepSupplements: {TagSupplementName, TagSupplementFlags, TagSupplementVersion},
+	DepEnhances:    {TagEnhanceName, TagEnhanceFlags, TagEnhanceVersion},
+}
+
+// DependencyTags returns name, flags and version tags of kind
+func DependencyTags(kind DependencyKind) (name, flags, version HeaderTag) {
+	t := dependencyTags[kind]
+	return t[0], t[1], t[2]
+}
+
+// Dependencies returns dependencies of kind, nil if package has none
+func (h Header) Dependencies(kind DependencyKind) ([]Dependency, error) {
+	tags, ok := dependencyTags[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown dependency kind %q", kind)
+	}
+	names, err := h.GetStrings(tags[0])
+	if errors.Is(err, ErrTagNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// old packages may lack flags and versions
+	flags, err := h.GetInt32s(tags[1])
+	if err != nil && !errors.Is(err, ErrTagNotFound) {
+		return nil, err
+	}
+	versions, err := h.GetStrings(tags[2])
+	if err != nil && !errors.Is(err, ErrTagNotFound) {
+		return nil, err
+	}
+	if (flags != nil && len(flags) != len(names)) || (versions != nil && len(versions) != len(names)) {
+		return nil, fmt.Errorf("%w: %s", ErrCountMismatch, kind)
+	}
+
+	deps := make([]Dependency, len(names))
+	for i, name := range names {
+		deps[i].Name = name
+		if flags != nil {
+			deps[i].Flags = SenseFlags(flags[i])
+		}
+		if versions != nil {
+			deps[i].Version = versions[i]
+		}
+	}
+	return deps, nil
+}
+
+func (h Header) Requires() ([]Dependency, error) {
+	return h.Dependencies(DepRequires)
+}
+
+func (h Header) Provides() ([]Dependency, error) {
+	return h.Dependencies(DepProvides)
+}
+
+func (h Header) Conflicts() ([]Dependency, error) {
+	return h.Dependencies(DepConflicts)
+}
+
+func (h Header) Obsoletes() ([]Dependency, error) {
+	return h.Dependencies(DepObsoletes)
+}
+
+// Overlaps reports whether ranges of versions of dependencies with the
+// same name overlap, the way rpm matches requires against provides.
+// Dependency without version matches any version, release missing on
+// either side isn't compared.
+func (d Dependency) Overlaps(other Dependency) bool {
+	if d.Name != other.Name {
+		return false
+	}
+	a, b := d.Flags&SenseCompareMask, other.Flags&SenseCompareMask
+	if a == 0 || b == 0 || len(d.Version) == 0 || len(other.Version) == 0 {
+		return true
+	}
+	ae, be := ParseEVR(d.Version), ParseEVR(other.Version)
+	if len(ae.Release) == 0 || len(be.Release) == 0 {
+		ae.Release, be.Release = "", ""
+	}
+	switch sense := CompareEVR(ae, be); {
+	case sense < 0:
+		return a&SenseGreater != 0 || b&SenseLess != 0
+	case sense > 0:
+		return a&SenseLess != 0 || b&SenseGreater != 0
+	}
+	return a&b != 0
+}
+
+// ParseDependency parses dependency written the way String formats
+// it, "name" or "name op version"
+func ParseDependency(s string) (Dependency, error) {
+	f := strings.Fields(s)
+	switch len(f) {
+	case 1:
+		return Dependency{Name: f[0]}, nil
+	case 3:
+		if flags, ok := ParseOperator(f[1]); ok {
+			return Dependency{Name: f[0], Flags: flags, Version: f[2]}, nil
+		}
+	}
+	return Dependency{}, fmt.Errorf("malformed dependency %q", s)
+}
@@ -0,0 +1,310 @@
+// Package elfdeps generates soname dependencies of ELF files the same
+// way rpm's elfdeps does, "libfoo.so.1()(64bit)" for sonames and
+// "libfoo.so.1(FOO_1.0)(64bit)" for symbol versions.
+package elfdeps
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotELF is returned for files which aren't ELF at all
+var ErrNotELF = errors.New("not an ELF file")
+
+// Options mirror switches of rpm's elfdeps
+type Options struct {
+	// SonameOnly leaves out symbol versions and rtld(GNU_HASH)
+	SonameOnly bool
+	// NoFakeSoname disables providing basename of libraries without
+	// DT_SONAME
+	NoFakeSoname bool
+	// RequireInterp adds dependency on program interpreter
+	RequireInterp bool
+}
+
+// Deps are dependencies of single file
+type Deps struct {
+	Provides []string
+	Requires []string
+}
+
+const (
+	dtNeeded  = 1
+	dtHash    = 4
+	dtSoname  = 14
+	dtDebug   = 21
+	dtGNUHash = 0x6ffffef5
+
+	verFlagBase = 0x1
+)
+
+type elfInfo struct {
+	f                             *elf.File
+	opts                          *Options
+	marker                        string
+	isDSO                         bool
+	isExec                        bool
+	interp                        string
+	soname                        string
+	gotDebug, gotHash, gotGNUHash bool
+
+	provides, requires []string
+}
+
+// IsELF reports whether data starts with ELF magic
+func IsELF(d []byte) bool {
+	return bytes.HasPrefix(d, []byte(elf.ELFMAG))
+}
+
+// Analyze returns dependencies of ELF file. name is used for fake
+// soname of libraries without one, executable tells whether file has
+// any executable bit set. Files other than executables and shared
+// objects have no dependencies.
+func Analyze(r io.ReaderAt, name string, executable bool, opts *Options) (*Deps, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := r.ReadAt(magic, 0); err != nil || !IsELF(magic) {
+		return nil, ErrNotELF
+	}
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	ei := &elfInfo{f: f, opts: opts, isExec: executable}
+	if f.Type != elf.ET_DYN && f.Type != elf.ET_EXEC {
+		return &Deps{}, nil
+	}
+	ei.marker = marker(f)
+	ei.isDSO = f.Type == elf.ET_DYN
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		d := make([]byte, p.Filesz)
+		if _, err := p.ReadAt(d, 0); err != nil {
+			return nil, fmt.Errorf("interpreter: %w", err)
+		}
+		ei.interp = string(bytes.TrimRight(d, "\x00"))
+	}
+	for _, s := range f.Sections {
+		switch s.Type {
+		case elf.SHT_GNU_VERDEF:
+			err = ei.processVerdef(s)
+		case elf.SHT_GNU_VERNEED:
+			err = ei.processVerneed(s)
+		case elf.SHT_DYNAMIC:
+			err = ei.processDynamic(s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+
+	// glibc too old for .gnu.hash can't run files lacking .hash
+	if ei.genRequires() && ei.gotGNUHash && !ei.gotHash && !opts.SonameOnly {
+		ei.requires = append(ei.requires, "rtld(GNU_HASH)")
+	}
+	// DT_DEBUG tells PIE executables apart from libraries
+	if ei.isDSO && !ei.gotDebug {
+		if len(ei.soname) == 0 && !opts.NoFakeSoname {
+			ei.soname = path.Base(name)
+		}
+		if len(ei.soname) > 0 {
+			ei.addDep(&ei.provides, ei.soname, "")
+		}
+	}
+	if len(ei.interp) > 0 && opts.RequireInterp {
+		ei.requires = append(ei.requires, ei.interp)
+	}
+	return &Deps{Provides: ei.provides, Requires: ei.requires}, nil
+}
+
+// marker returns suffix distinguishing 64-bit dependencies
+func marker(f *elf.File) string {
+	if f.Class != elf.ELFCLASS64 {
+		return ""
+	}
+	switch f.Machine {
+	case elf.EM_ALPHA, elf.EM_ALPHA_STD:
+		return ""
+	}
+	return "(64bit)"
+}
+
+func (ei *elfInfo) addDep(deps *[]string, soname, ver string) {
+	// only shared objects make dependencies
+	if !strings.Contains(soname, ".so") {
+		return
+	}
+	if len(ver) > 0 || len(ei.marker) > 0 {
+		*deps = append(*deps, soname+"("+ver+")"+ei.marker)
+	} else {
+		*deps = append(*deps, soname)
+	}
+}
+
+// genRequires tells whether file makes requires, programs without
+// executable bit don't
+func (ei *elfInfo) genRequires() bool {
+	return len(ei.interp) == 0 || ei.isExec
+}
+
+// sectionData returns data of section and of string table it links to
+func (ei *elfInfo) sectionData(s *elf.Section) ([]byte, []byte, error) {
+	d, err := s.Data()
+	if err != nil {
+		return nil, nil, err
+	}
+	if int(s.Link) >= len(ei.f.Sections) {
+		return nil, nil, fmt.Errorf("string table %d out of range", s.Link)
+	}
+	strtab, err := ei.f.Sections[s.Link].Data()
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, strtab, nil
+}
+
+func cstring(strtab []byte, off uint32) (string, error) {
+	if int64(off) >= int64(len(strtab)) {
+		return "", fmt.Errorf("string offset %d out of range", off)
+	}
+	s := strtab[off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s), nil
+}
+
+// maxVerEntries bounds walks of version chains which may loop
+const maxVerEntries = 1 << 16
+
+func (ei *elfInfo) processVerdef(s *elf.Section) error {
+	d, strtab, err := ei.sectionData(s)
+	if err != nil {
+		return err
+	}
+	bo := ei.f.ByteOrder
+	var soname string
+	off := uint64(0)
+	for n := 0; n < maxVerEntries; n++ {
+		if off+20 > uint64(len(d)) {
+			return errors.New("verdef out of range")
+		}
+		flags := bo.Uint16(d[off+2:])
+		cnt := bo.Uint16(d[off+6:])
+		aux := uint64(bo.Uint32(d[off+12:]))
+		next := uint64(bo.Uint32(d[off+16:]))
+		// first aux entry names the version, others its parents
+		if cnt > 0 {
+			if off+aux+8 > uint64(len(d)) {
+				return errors.New("verdaux out of range")
+			}
+			name, err := cstring(strtab, bo.Uint32(d[off+aux:]))
+			if err != nil {
+				return err
+			}
+			if flags&verFlagBase != 0 {
+				soname = name
+			} else if len(soname) > 0 && !ei.opts.SonameOnly {
+				ei.addDep(&ei.provides, soname, name)
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		off += next
+	}
+	return errors.New("verdef chain too long")
+}
+
+func (ei *elfInfo) processVerneed(s *elf.Section) error {
+	d, strtab, err := ei.sectionData(s)
+	if err != nil {
+		return err
+	}
+	bo := ei.f.ByteOrder
+	off := uint64(0)
+	for n := 0; n < maxVerEntries; n++ {
+		if off+16 > uint64(len(d)) {
+			return errors.New("verneed out of range")
+		}
+		cnt := int(bo.Uint16(d[off+2:]))
+		file, err := cstring(strtab, bo.Uint32(d[off+4:]))
+		if err != nil {
+			return err
+		}
+		aux := off + uint64(bo.Uint32(d[off+8:]))
+		next := uint64(bo.Uint32(d[off+12:]))
+		for i := 0; i < cnt; i++ {
+			if aux+16 > uint64(len(d)) {
+				return errors.New("vernaux out of range")
+			}
+			name, err := cstring(strtab, bo.Uint32(d[aux+8:]))
+			if err != nil {
+				return err
+			}
+			if ei.genRequires() && !ei.opts.SonameOnly {
+				ei.addDep(&ei.requires, file, name)
+			}
+			auxNext := uint64(bo.Uint32(d[aux+12:]))
+			if auxNext == 0 {
+				break
+			}
+			aux += auxNext
+		}
+		if next == 0 {
+			return nil
+		}
+		off += next
+	}
+	return errors.New("verneed chain too long")
+}
+
+func (ei *elfInfo) processDynamic(s *elf.Section) error {
+	d, strtab, err := ei.sectionData(s)
+	if err != nil {
+		return err
+	}
+	bo := ei.f.ByteOrder
+	entSize := 8
+	if ei.f.Class == elf.ELFCLASS64 {
+		entSize = 16
+	}
+	for off := 0; off+entSize <= len(d); off += entSize {
+		var tag, val uint64
+		if entSize == 16 {
+			tag, val = bo.Uint64(d[off:]), bo.Uint64(d[off+8:])
+		} else {
+			tag, val = uint64(bo.Uint32(d[off:])), uint64(bo.Uint32(d[off+4:]))
+		}
+		switch tag {
+		case 0:
+			return nil
+		case dtHash:
+			ei.gotHash = true
+		case dtGNUHash:
+			ei.gotGNUHash = true
+		case dtDebug:
+			ei.gotDebug = true
+		case dtSoname, dtNeeded:
+			s, err := cstring(strtab, uint32(val))
+			if err != nil {
+				return err
+			}
+			if tag == dtSoname {
+				ei.soname = s
+			} else if ei.genRequires() {
+				ei.addDep(&ei.requires, s, "")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package elfdeps
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testdata/libgnuhash.so.1 is x86_64 library with .gnu.hash only,
+// built with
+//
+//	gcc -shared -fPIC -nostdlib -s -Wl,--hash-style=gnu \
+//	    -Wl,-z,max-page-size=16 -Wl,-z,noseparate-code \
+//	    -Wl,--build-id=none -Wl,-soname,libgnuhash.so.1
+func TestGNUHash(t *testing.T) {
+	f, err := os.Open("testdata/libgnuhash.so.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name       string
+		executable bool
+		opts       Options
+		requires   []string
+	}{
+		{"executable", true, Options{}, []string{"rtld(GNU_HASH)"}},
+		{"not executable", false, Options{}, []string{"rtld(GNU_HASH)"}},
+		{"soname only", true, Options{SonameOnly: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, err := Analyze(f, "/usr/lib64/libgnuhash.so.1", tt.executable, &tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"libgnuhash.so.1()(64bit)"}; !reflect.DeepEqual(deps.Provides, expected) {
+				t.Errorf("provides: got %v, expected %v", deps.Provides, expected)
+			}
+			if !reflect.DeepEqual(deps.Requires, tt.requires) {
+				t.Errorf("requires: got %v, expected %v", deps.Requires, tt.requires)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package elfdeps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// FileDeps are dependencies of file at Path
+type FileDeps struct {
+	Path string
+	Deps
+}
+
+const (
+	modeTypeMask = 0xf000
+	modeReg      = 0x8000
+	modeExec     = 0111
+)
+
+// excluded are paths rpm doesn't generate ELF dependencies for
+func excluded(name string) bool {
+	return strings.HasPrefix(name, "/lib/modules/") || strings.HasPrefix(name, "/usr/lib/modules/")
+}
+
+// FromPayload returns dependencies of ELF files in payload of package.
+// Like rpm, only files with executable bit are looked at. Payload is
+// read, so package can't be read again.
+func FromPayload(pkg *rpmutil.Package, opts *Options) ([]FileDeps, error) {
+	pl, err := pkg.Payload()
+	if err != nil {
+		return nil, err
+	}
+	var res []FileDeps
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(h.Name, ".")
+		if h.Mode&modeTypeMask != modeReg || h.Mode&modeExec == 0 || excluded(name) {
+			continue
+		}
+		br := bufio.NewReader(r)
+		if magic, _ := br.Peek(4); !IsELF(magic) {
+			continue
+		}
+		d, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		deps, err := Analyze(bytes.NewReader(d), name, true, opts)
+		if err != nil {
+			return nil, &os.PathError{Op: "elfdeps", Path: name, Err: err}
+		}
+		res = append(res, FileDeps{Path: name, Deps: *deps})
+	}
+	return res, nil
+}
+
+// FromDir returns dependencies of ELF files in build root, paths are
+// relative to root
+func FromDir(root string, opts *Options) ([]FileDeps, error) {
+	var res []FileDeps
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := "/" + filepath.ToSlash(strings.TrimPrefix(p, root))
+		name = filepath.Clean(name)
+		if !fi.Mode().IsRegular() || fi.Mode()&modeExec == 0 || excluded(name) {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		deps, err := Analyze(f, name, true, opts)
+		if errors.Is(err, ErrNotELF) {
+			return nil
+		}
+		if err != nil {
+			return &os.PathError{Op: "elfdeps", Path: p, Err: err}
+		}
+		res = append(res, FileDeps{Path: name, Deps: *deps})
+		return nil
+	})
+	return res, err
+}
+
+// Merge returns sorted unique dependencies of all files
+func Merge(files []FileDeps) *Deps {
+	var res Deps
+	for _, f := range files {
+		res.Provides = append(res.Provides, f.Provides...)
+		res.Requires = append(res.Requires, f.Requires...)
+	}
+	res.Provides = uniq(res.Provides)
+	res.Requires = uniq(res.Requires)
+	return &res
+}
+
+func uniq(s []string) []string {
+	sort.Strings(s)
+	res := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Mismatch lists soname dependencies generated from files but not
+// declared by package (Missing) and declared but not generated (Extra)
+type Mismatch struct {
+	MissingProvides []string
+	ExtraProvides   []string
+	MissingRequires []string
+	ExtraRequires   []string
+}
+
+// Empty reports whether declared dependencies match generated ones
+func (m *Mismatch) Empty() bool {
+	return len(m.MissingProvides) == 0 && len(m.ExtraProvides) == 0 &&
+		len(m.MissingRequires) == 0 && len(m.ExtraRequires) == 0
+}
+
+// Audit compares soname dependencies declared in header with ones
+// generated from files. Only declared dependencies which look like
+// sonames are taken into account.
+func Audit(h *rpm.Header, found *Deps) (*Mismatch, error) {
+	provides, err := h.Provides()
+	if err != nil {
+		return nil, err
+	}
+	requires, err := h.Requires()
+	if err != nil {
+		return nil, err
+	}
+	var m Mismatch
+	m.MissingProvides, m.ExtraProvides = compare(found.Provides, provides)
+	m.MissingRequires, m.ExtraRequires = compare(found.Requires, requires)
+	return &m, nil
+}
+
+func compare(found []string, declared []rpm.Dependency) (missing, extra []string) {
+	decl := map[string]bool{}
+	for _, d := range declared {
+		if isSonameDep(d.Name) {
+			decl[d.Name] = true
+		}
+	}
+	gen := map[string]bool{}
+	for _, f := range found {
+		gen[f] = true
+		if !decl[f] {
+			missing = append(missing, f)
+		}
+	}
+	for d := range decl {
+		if !gen[d] {
+			extra = append(extra, d)
+		}
+	}
+	return uniq(missing), uniq(extra)
+}
+
+// isSonameDep tells dependencies elfdeps could have generated apart
+// from others
+func isSonameDep(name string) bool {
+	return name == "rtld(GNU_HASH)" || (!strings.HasPrefix(name, "/") && strings.Contains(name, ".so"))
+}
@@ -0,0 +1,43 @@
+package rpm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTagNotFound   = errors.New("tag not found")
+	ErrBadMagic      = errors.New("bad magic")
+	ErrTruncated     = errors.New("truncated data")
+	ErrCountMismatch = errors.New("tag count mismatch")
+	ErrOutOfRange    = errors.New("value out of range")
+)
+
+// FormatError reports malformed input, Offset is relative to the start
+// of Section unless the error was returned by rpmutil.ReadPackage,
+// which makes it relative to the start of the package
+type FormatError struct {
+	Section string
+	Offset  int64
+	Err     error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("malformed %s at offset %d: %v", e.Section, e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// TypeMismatchError is returned when tag is read as different data
+// type than it is stored in header
+type TypeMismatchError struct {
+	Tag      HeaderTag
+	Expected HeaderDataType
+	Actual   HeaderDataType
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("tag %d has data type %d, expected %d", e.Tag, e.Actual, e.Expected)
+}
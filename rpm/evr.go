@@ -0,0 +1,168 @@
+package rpm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EVR is epoch, version and release triplet of package or dependency
+type EVR struct {
+	Epoch   int
+	Version string
+	Release string
+}
+
+// ParseEVR parses string in form of [epoch:]version[-release]
+func ParseEVR(s string) EVR {
+	var evr EVR
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		if e, err := strconv.Atoi(s[:i]); err == nil {
+			evr.Epoch = e
+			s = s[i+1:]
+		}
+	}
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		evr.Release = s[i+1:]
+		s = s[:i]
+	}
+	evr.Version = s
+	return evr
+}
+
+func (evr EVR) String() string {
+	s := evr.Version
+	if evr.Epoch > 0 {
+		s = strconv.Itoa(evr.Epoch) + ":" + s
+	}
+	if len(evr.Release) > 0 {
+		s += "-" + evr.Release
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if evr is older, same or newer than other
+func (evr EVR) Compare(other EVR) int {
+	return CompareEVR(evr, other)
+}
+
+// CompareEVR compares two EVRs the same way rpm does
+func CompareEVR(a, b EVR) int {
+	if a.Epoch < b.Epoch {
+		return -1
+	}
+	if a.Epoch > b.Epoch {
+		return 1
+	}
+	if rc := Vercmp(a.Version, b.Version); rc != 0 {
+		return rc
+	}
+	return Vercmp(a.Release, b.Release)
+}
+
+// Vercmp is port of rpmvercmp, it compares version or release strings
+// segment by segment, honoring '~' (sorts before anything) and
+// '^' (sorts after base version, but before any other suffix)
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := a, b
+	for len(one) > 0 || len(two) > 0 {
+		one = strings.TrimLeftFunc(one, isVerSeparator)
+		two = strings.TrimLeftFunc(two, isVerSeparator)
+
+		if strings.HasPrefix(one, "~") || strings.HasPrefix(two, "~") {
+			if !strings.HasPrefix(one, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(two, "~") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		if strings.HasPrefix(one, "^") || strings.HasPrefix(two, "^") {
+			if len(one) == 0 {
+				return -1
+			}
+			if len(two) == 0 {
+				return 1
+			}
+			if !strings.HasPrefix(one, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(two, "^") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		if len(one) == 0 || len(two) == 0 {
+			break
+		}
+
+		isnum := isDigit(one[0])
+		pred := isAlpha
+		if isnum {
+			pred = isDigit
+		}
+		seg1, seg2 := takeWhile(one, pred), takeWhile(two, pred)
+		one, two = one[len(seg1):], two[len(seg2):]
+
+		if len(seg2) == 0 {
+			// numeric segments are always newer than alpha
+			if isnum {
+				return 1
+			}
+			return -1
+		}
+
+		if isnum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+			if len(seg1) > len(seg2) {
+				return 1
+			}
+			if len(seg2) > len(seg1) {
+				return -1
+			}
+		}
+		if rc := strings.Compare(seg1, seg2); rc != 0 {
+			return rc
+		}
+	}
+
+	if len(one) == 0 && len(two) == 0 {
+		return 0
+	}
+	if len(one) == 0 {
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVerSeparator(r rune) bool {
+	if r >= 0x80 {
+		return true
+	}
+	return !isDigit(byte(r)) && !isAlpha(byte(r)) && r != '~' && r != '^'
+}
+
+func takeWhile(s string, pred func(byte) bool) string {
+	i := 0
+	for i < len(s) && pred(s[i]) {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,154 @@
+package rpm
+
+import "testing"
+
+// cases of rpmvercmp.at from rpm test suite
+var vercmpTests = []struct {
+	a, b string
+	rc   int
+}{
+	{"1.0", "1.0", 0},
+	{"1.0", "2.0", -1},
+	{"2.0", "1.0", 1},
+	{"2.0.1", "2.0.1", 0},
+	{"2.0", "2.0.1", -1},
+	{"2.0.1", "2.0", 1},
+	{"2.0.1a", "2.0.1a", 0},
+	{"2.0.1a", "2.0.1", 1},
+	{"2.0.1", "2.0.1a", -1},
+	{"5.5p1", "5.5p1", 0},
+	{"5.5p1", "5.5p2", -1},
+	{"5.5p2", "5.5p1", 1},
+	{"5.5p10", "5.5p10", 0},
+	{"5.5p1", "5.5p10", -1},
+	{"5.5p10", "5.5p1", 1},
+	{"10xyz", "10.1xyz", -1},
+	{"10.1xyz", "10xyz", 1},
+	{"xyz10", "xyz10", 0},
+	{"xyz10", "xyz10.1", -1},
+	{"xyz10.1", "xyz10", 1},
+	{"xyz.4", "xyz.4", 0},
+	{"xyz.4", "8", -1},
+	{"8", "xyz.4", 1},
+	{"xyz.4", "2", -1},
+	{"2", "xyz.4", 1},
+	{"5.5p2", "5.6p1", -1},
+	{"5.6p1", "5.5p2", 1},
+	{"5.6p1", "6.5p1", -1},
+	{"6.5p1", "5.6p1", 1},
+	{"6.0.rc1", "6.0", 1},
+	{"6.0", "6.0.rc1", -1},
+	{"10b2", "10a1", 1},
+	{"10a2", "10b2", -1},
+	{"1.0aa", "1.0aa", 0},
+	{"1.0a", "1.0aa", -1},
+	{"1.0aa", "1.0a", 1},
+	{"10.0001", "10.0001", 0},
+	{"10.0001", "10.1", 0},
+	{"10.1", "10.0001", 0},
+	{"10.0001", "10.0039", -1},
+	{"10.0039", "10.0001", 1},
+	{"4.999.9", "5.0", -1},
+	{"5.0", "4.999.9", 1},
+	{"20101121", "20101121", 0},
+	{"20101121", "20101122", -1},
+	{"20101122", "20101121", 1},
+	{"2_0", "2_0", 0},
+	{"2.0", "2_0", 0},
+	{"2_0", "2.0", 0},
+	{"a", "a", 0},
+	{"a+", "a+", 0},
+	{"a+", "a_", 0},
+	{"a_", "a+", 0},
+	{"+a", "+a", 0},
+	{"+a", "_a", 0},
+	{"_a", "+a", 0},
+	{"+_", "+_", 0},
+	{"_+", "+_", 0},
+	{"_+", "_+", 0},
+	{"+", "_", 0},
+	{"_", "+", 0},
+	{"1.0~rc1", "1.0~rc1", 0},
+	{"1.0~rc1", "1.0", -1},
+	{"1.0", "1.0~rc1", 1},
+	{"1.0~rc1", "1.0~rc2", -1},
+	{"1.0~rc2", "1.0~rc1", 1},
+	{"1.0~rc1~git123", "1.0~rc1~git123", 0},
+	{"1.0~rc1~git123", "1.0~rc1", -1},
+	{"1.0~rc1", "1.0~rc1~git123", 1},
+	{"1.0^", "1.0^", 0},
+	{"1.0^", "1.0", 1},
+	{"1.0", "1.0^", -1},
+	{"1.0^git1", "1.0^git1", 0},
+	{"1.0^git1", "1.0", 1},
+	{"1.0", "1.0^git1", -1},
+	{"1.0^git1", "1.0^git2", -1},
+	{"1.0^git2", "1.0^git1", 1},
+	{"1.0^git1", "1.01", -1},
+	{"1.01", "1.0^git1", 1},
+	{"1.0^20160101", "1.0^20160101", 0},
+	{"1.0^20160101", "1.0.1", -1},
+	{"1.0.1", "1.0^20160101", 1},
+	{"1.0^20160101^git1", "1.0^20160101^git1", 0},
+	{"1.0^20160102", "1.0^20160101^git1", 1},
+	{"1.0^20160101^git1", "1.0^20160102", -1},
+	{"1.0~rc1^git1", "1.0~rc1^git1", 0},
+	{"1.0~rc1^git1", "1.0~rc1", 1},
+	{"1.0~rc1", "1.0~rc1^git1", -1},
+	{"1.0^git1~pre", "1.0^git1~pre", 0},
+	{"1.0^git1", "1.0^git1~pre", 1},
+	{"1.0^git1~pre", "1.0^git1", -1},
+}
+
+func TestVercmp(t *testing.T) {
+	for _, tt := range vercmpTests {
+		if rc := Vercmp(tt.a, tt.b); rc != tt.rc {
+			t.Errorf("Vercmp(%q, %q) = %d, expected %d", tt.a, tt.b, rc, tt.rc)
+		}
+	}
+}
+
+func TestParseEVR(t *testing.T) {
+	tests := []struct {
+		s   string
+		evr EVR
+		str string
+	}{
+		{"1.0", EVR{Version: "1.0"}, "1.0"},
+		{"1.0-1.fc33", EVR{Version: "1.0", Release: "1.fc33"}, "1.0-1.fc33"},
+		{"2:1.0-1", EVR{Epoch: 2, Version: "1.0", Release: "1"}, "2:1.0-1"},
+		{"0:1.0-1", EVR{Version: "1.0", Release: "1"}, "1.0-1"},
+		{"1.0-rc-1", EVR{Version: "1.0-rc", Release: "1"}, "1.0-rc-1"},
+		// epoch which isn't number stays part of version
+		{"x:1.0", EVR{Version: "x:1.0"}, "x:1.0"},
+	}
+	for _, tt := range tests {
+		evr := ParseEVR(tt.s)
+		if evr != tt.evr {
+			t.Errorf("ParseEVR(%q) = %+v, expected %+v", tt.s, evr, tt.evr)
+		}
+		if s := evr.String(); s != tt.str {
+			t.Errorf("%+v formats as %q, expected %q", evr, s, tt.str)
+		}
+	}
+}
+
+func TestCompareEVR(t *testing.T) {
+	tests := []struct {
+		a, b string
+		rc   int
+	}{
+		{"1:1.0-1", "2.0-1", 1},
+		{"2.0-1", "1:1.0-1", -1},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-10", "1.0-9", 1},
+		{"1.0-1", "1.0-1", 0},
+		{"1.1-1", "1.0-9", 1},
+		{"1.0~rc1-5", "1.0-1", -1},
+	}
+	for _, tt := range tests {
+		if rc := ParseEVR(tt.a).Compare(ParseEVR(tt.b)); rc != tt.rc {
+			t.Errorf("%s vs %s: got %d, expected %d", tt.a, tt.b, rc, tt.rc)
+		}
+	}
+}
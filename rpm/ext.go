@@ -1,5 +1,12 @@
 package rpm
 
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type getTagFn func(h *Header, tag HeaderTag) (*HeaderIndexEntry, []byte, error)
 
 const (
@@ -10,6 +17,54 @@ var extTagFunc = map[HeaderTag]getTagFn{
 	TagFilenames: getTagFilenames,
 }
 
+// getTagFilenames joins BASENAMES with their DIRNAMES, packages
+// without compressed file list carry full paths in OLDFILENAMES
 func getTagFilenames(h *Header, tag HeaderTag) (*HeaderIndexEntry, []byte, error) {
-	return getTag(h, TagBaseNames)
+	baseNames, err := getStrings(h, TagBaseNames)
+	if errors.Is(err, ErrTagNotFound) {
+		return getTag(h, TagOldFilenames)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dirNames, err := getStrings(h, TagDirNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	idx, dirIndexes, err := getTag(h, TagDirIndexes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if idx.DataType != DataTypeInt32 {
+		return nil, nil, &TypeMismatchError{Tag: TagDirIndexes, Expected: DataTypeInt32, Actual: idx.DataType}
+	}
+	if int(idx.Count) != len(baseNames) {
+		return nil, nil, fmt.Errorf("%w: %d dir indexes for %d files", ErrCountMismatch, idx.Count, len(baseNames))
+	}
+
+	var b strings.Builder
+	for i, name := range baseNames {
+		di := int32(binary.BigEndian.Uint32(dirIndexes[4*i:]))
+		if di < 0 || int(di) >= len(dirNames) {
+			return nil, nil, fmt.Errorf("%w: dir index %d", ErrOutOfRange, di)
+		}
+		b.WriteString(dirNames[di])
+		b.WriteString(name)
+		b.WriteByte(0)
+	}
+	idx = &HeaderIndexEntry{Tag: tag, DataType: DataTypeStringArray, Count: int32(len(baseNames))}
+	return idx, []byte(b.String()), nil
+}
+
+// getStrings is GetStrings bypassing extension tags, which can't
+// refer to it
+func getStrings(h *Header, t HeaderTag) ([]string, error) {
+	idx, d, err := getTag(h, t)
+	if err != nil {
+		return nil, err
+	}
+	if idx.DataType != DataTypeStringArray {
+		return nil, &TypeMismatchError{Tag: t, Expected: DataTypeStringArray, Actual: idx.DataType}
+	}
+	return CStringArrayToSlice(d), nil
 }
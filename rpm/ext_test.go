@@ -0,0 +1,59 @@
+package rpm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFilenames(t *testing.T) {
+	h := NewHeader(TagHeaderImmutable)
+	h.SetStrings(TagBaseNames, []string{"foo", "foo.conf", "lib"})
+	h.SetStrings(TagDirNames, []string{"/usr/bin/", "/etc/", "/usr/"})
+	h.SetInt32s(TagDirIndexes, []int32{0, 1, 2})
+
+	names, err := h.GetStrings(TagFilenames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"/usr/bin/foo", "/etc/foo.conf", "/usr/lib"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Errorf("got %q, expected %q", names, exp)
+	}
+}
+
+func TestFilenamesOld(t *testing.T) {
+	h := NewHeader(TagHeaderImmutable)
+	h.SetStrings(TagOldFilenames, []string{"/usr/bin/foo", "/etc/foo.conf"})
+
+	names, err := h.GetStrings(TagFilenames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"/usr/bin/foo", "/etc/foo.conf"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Errorf("got %q, expected %q", names, exp)
+	}
+}
+
+func TestFilenamesBad(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int32
+		err     error
+	}{
+		{"out of range", []int32{0, 2}, ErrOutOfRange},
+		{"negative", []int32{0, -1}, ErrOutOfRange},
+		{"count", []int32{0}, ErrCountMismatch},
+	}
+	for _, tt := range tests {
+		h := NewHeader(TagHeaderImmutable)
+		h.SetStrings(TagBaseNames, []string{"foo", "bar"})
+		h.SetStrings(TagDirNames, []string{"/usr/bin/", "/etc/"})
+		h.SetInt32s(TagDirIndexes, tt.indexes)
+
+		if _, err := h.GetStrings(TagFilenames); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, expected %v", tt.name, err, tt.err)
+		}
+	}
+}
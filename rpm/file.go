@@ -0,0 +1,137 @@
+package rpm
+
+// File tags not covered by the main tag list
+const (
+	TagFileCaps       HeaderTag = 5010
+	TagFileDigestAlgo HeaderTag = 5011
+)
+
+// FileFlags are attributes of files stored in FILEFLAGS (rpmfileAttrs)
+type FileFlags uint32
+
+const (
+	FileConfig    FileFlags = 1 << 0
+	FileDoc       FileFlags = 1 << 1
+	FileIcon      FileFlags = 1 << 2
+	FileMissingOK FileFlags = 1 << 3
+	FileNoReplace FileFlags = 1 << 4
+	FileSpecfile  FileFlags = 1 << 5
+	FileGhost     FileFlags = 1 << 6
+	FileLicense   FileFlags = 1 << 7
+	FileReadme    FileFlags = 1 << 8
+	FilePubkey    FileFlags = 1 << 11
+	FileArtifact  FileFlags = 1 << 12
+)
+
+var fileFlagChars = []struct {
+	f FileFlags
+	c byte
+}{
+	{FileDoc, 'd'},
+	{FileConfig, 'c'},
+	{FileSpecfile, 's'},
+	{FileMissingOK, 'm'},
+	{FileNoReplace, 'n'},
+	{FileGhost, 'g'},
+	{FileLicense, 'l'},
+	{FileReadme, 'r'},
+	{FileArtifact, 'a'},
+}
+
+// String returns flags the way rpm prints them, one letter per flag
+func (f FileFlags) String() string {
+	var s []byte
+	for _, fc := range fileFlagChars {
+		if f&fc.f != 0 {
+			s = append(s, fc.c)
+		}
+	}
+	return string(s)
+}
+
+// VerifyFlags select attributes of file rpm -V checks, stored in
+// FILEVERIFYFLAGS, and report the ones which failed (rpmVerifyAttrs)
+type VerifyFlags uint32
+
+const (
+	VerifyNone     VerifyFlags = 0
+	VerifyDigest   VerifyFlags = 1 << 0
+	VerifySize     VerifyFlags = 1 << 1
+	VerifyLinkTo   VerifyFlags = 1 << 2
+	VerifyUser     VerifyFlags = 1 << 3
+	VerifyGroup    VerifyFlags = 1 << 4
+	VerifyMTime    VerifyFlags = 1 << 5
+	VerifyMode     VerifyFlags = 1 << 6
+	VerifyRdev     VerifyFlags = 1 << 7
+	VerifyCaps     VerifyFlags = 1 << 8
+	VerifyAll      VerifyFlags = 0x0000ffff
+	VerifyReadLink VerifyFlags = 1 << 28
+	VerifyRead     VerifyFlags = 1 << 29
+	VerifyLstat    VerifyFlags = 1 << 30
+)
+
+// String formats result of verification the way rpm -V does,
+// "S.5....T." with '?' for tests which couldn't be done
+func (f VerifyFlags) String() string {
+	res := []byte(".........")
+	for i, c := range []struct {
+		f, fail VerifyFlags
+		c       byte
+	}{
+		{VerifySize, 0, 'S'},
+		{VerifyMode, 0, 'M'},
+		{VerifyDigest, VerifyRead, '5'},
+		{VerifyRdev, 0, 'D'},
+		{VerifyLinkTo, VerifyReadLink, 'L'},
+		{VerifyUser, 0, 'U'},
+		{VerifyGroup, 0, 'G'},
+		{VerifyMTime, 0, 'T'},
+		{VerifyCaps, 0, 'P'},
+	} {
+		switch {
+		case f&c.fail != 0:
+			res[i] = '?'
+		case f&c.f != 0:
+			res[i] = c.c
+		}
+	}
+	return string(res)
+}
+
+var verifyNames = []struct {
+	f    VerifyFlags
+	name string
+}{
+	{VerifySize, "size"},
+	{VerifyMode, "mode"},
+	{VerifyDigest, "digest"},
+	{VerifyRdev, "rdev"},
+	{VerifyLinkTo, "linkto"},
+	{VerifyUser, "user"},
+	{VerifyGroup, "group"},
+	{VerifyMTime, "mtime"},
+	{VerifyCaps, "caps"},
+}
+
+// Names returns names of attributes flags select, in order of String
+func (f VerifyFlags) Names() []string {
+	names := []string{}
+	for _, vn := range verifyNames {
+		if f&vn.f != 0 {
+			names = append(names, vn.name)
+		}
+	}
+	return names
+}
+
+// FileState is state of installed file in FILESTATES of rpmdb headers
+type FileState int8
+
+const (
+	FileStateMissing      FileState = -1
+	FileStateNormal       FileState = 0
+	FileStateReplaced     FileState = 1
+	FileStateNotInstalled FileState = 2
+	FileStateNetShared    FileState = 3
+	FileStateWrongColor   FileState = 4
+)
@@ -0,0 +1,26 @@
+//go:build gofuzz
+// +build gofuzz
+
+package rpm
+
+import (
+	"bytes"
+)
+
+// Fuzz is go-fuzz entry point exercising ReadHeader and accessors
+func Fuzz(data []byte) int {
+	h, err := ReadHeader(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	for _, t := range h.AvailableTags() {
+		h.GetTag(t)
+		h.GetString(t)
+		h.GetStrings(t)
+		h.GetInt16s(t)
+		h.GetInt32s(t)
+		h.GetInt64s(t)
+		h.GetInt(t)
+	}
+	return 1
+}
@@ -0,0 +1,71 @@
+package rpm
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultLocale is the locale every localized string has a variant
+// for, it's the first entry of HEADERI18NTABLE
+const DefaultLocale = "C"
+
+// I18NTable returns locales localized strings of the header are
+// stored for, headers without the table have just C locale
+func (h Header) I18NTable() ([]string, error) {
+	locales, err := h.GetStrings(TagHeaderI18NTable)
+	if err != nil {
+		if errors.Is(err, ErrTagNotFound) {
+			return []string{DefaultLocale}, nil
+		}
+		return nil, err
+	}
+	return locales, nil
+}
+
+// GetI18NString returns string of tag for locale, falling back to C
+// when there is no translation. locale can be colon separated list
+// of locales in order of preference, as in LANGUAGE environment
+// variable. Plain string tags are returned as they are.
+func (h Header) GetI18NString(t HeaderTag, locale string) (string, error) {
+	idx, v, err := h.getTag(t)
+	if err != nil {
+		return "", err
+	}
+	if idx.DataType == DataTypeString {
+		return h.GetString(t)
+	}
+	if idx.DataType != DataTypeI18NString {
+		return "", &TypeMismatchError{Tag: t, Expected: DataTypeI18NString, Actual: idx.DataType}
+	}
+	translations := CStringArrayToSlice(v)
+	locales, err := h.I18NTable()
+	if err != nil {
+		return "", err
+	}
+
+	for _, l := range strings.Split(locale, ":") {
+		if len(l) == 0 {
+			continue
+		}
+		for i, td := range locales {
+			if i < len(translations) && len(translations[i]) > 0 && matchLocale(td, l) {
+				return translations[i], nil
+			}
+		}
+	}
+	return translations[0], nil
+}
+
+// matchLocale matches table locale against requested locale the way
+// rpm does, dropping modifier, codeset and territory of requested one
+func matchLocale(td, l string) bool {
+	if td == l {
+		return true
+	}
+	for _, sep := range []string{"@", ".", "_"} {
+		if i := strings.Index(l, sep); i >= 0 && td == l[:i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmdb"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// DBPath is location of state database relative to root
+const DBPath = "var/lib/ypkg/installed.json"
+
+// Record is package installed into root
+type Record struct {
+	Package     *repo.Package `json:"package"`
+	InstallTime time.Time     `json:"installtime"`
+	// Files are paths written, relative to root
+	Files []string `json:"files"`
+}
+
+// DB is state database of packages installed into root, JSON file
+// rewritten as a whole on every change
+type DB struct {
+	path    string
+	Records []*Record `json:"records"`
+}
+
+// OpenDB reads state database of root, it's empty if root has none
+func OpenDB(root string) (*DB, error) {
+	db := &DB{path: filepath.Join(root, filepath.FromSlash(DBPath))}
+	d, err := ioutil.ReadFile(db.path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(d, db); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.path, err)
+	}
+	return db, nil
+}
+
+// Packages returns packages installed
+func (db *DB) Packages() []*repo.Package {
+	pkgs := make([]*repo.Package, len(db.Records))
+	for i, r := range db.Records {
+		pkgs[i] = r.Package
+	}
+	return pkgs
+}
+
+// Installed returns packages installed under root, read from rpmdb
+// or from state database if root has no rpmdb
+func Installed(root string) ([]*repo.Package, error) {
+	var pkgs []*repo.Package
+	err := Walk(root, func(p *repo.Package, _ *rpmutil.Package) error {
+		pkgs = append(pkgs, p)
+		return nil
+	})
+	return pkgs, err
+}
+
+// Walk calls fn for every package installed under root, the way
+// Installed reads them, along with its header if it comes from rpmdb
+func Walk(root string, fn func(p *repo.Package, pkg *rpmutil.Package) error) error {
+	db, err := rpmdb.Open(root)
+	if errors.Is(err, rpmdb.ErrNoDatabase) {
+		state, serr := OpenDB(root)
+		if serr != nil {
+			return serr
+		}
+		if len(state.Records) == 0 {
+			return err
+		}
+		for _, p := range state.Packages() {
+			if err := fn(p, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Walk(func(h *rpmdb.Package) error {
+		// imported keys are kept as fake packages
+		if name, _ := h.Header.GetString(rpm.TagName); name == "gpg-pubkey" {
+			return nil
+		}
+		pkg := &rpmutil.Package{Header: h.Header}
+		p, err := repo.PackageFromHeader(pkg, 0)
+		if err != nil {
+			return err
+		}
+		return fn(p, pkg)
+	})
+}
+
+// Add records installed package, record of package with the same
+// name and architecture is replaced
+func (db *DB) Add(rec *Record) {
+	for i, r := range db.Records {
+		if r.Package.Name == rec.Package.Name && r.Package.Arch == rec.Package.Arch {
+			db.Records[i] = rec
+			return
+		}
+	}
+	db.Records = append(db.Records, rec)
+}
+
+// Save writes database, it's replaced atomically
+func (db *DB) Save() error {
+	d, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(db.path), 0755); err != nil {
+		return err
+	}
+	tmp := db.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, db.path)
+}
@@ -0,0 +1,49 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmdb"
+)
+
+func TestInstalled(t *testing.T) {
+	root, err := ioutil.TempDir("", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if _, err := Installed(root); !errors.Is(err, rpmdb.ErrNoDatabase) {
+		t.Errorf("empty root: got %v, expected no database", err)
+	}
+
+	db, err := OpenDB(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &repo.Package{Name: "hello", Arch: "x86_64", EVR: rpm.EVR{Version: "2.10", Release: "1"}}
+	db.Add(&Record{Package: p})
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := Installed(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].NEVRA() != p.NEVRA() {
+		t.Errorf("got %v, expected %s", pkgs, p.NEVRA())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(DBPath)), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pkgs, err := Installed(root); err == nil || errors.Is(err, rpmdb.ErrNoDatabase) {
+		t.Errorf("broken state database: got %v, %v", pkgs, err)
+	}
+}
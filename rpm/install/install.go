@@ -0,0 +1,273 @@
+// Package install installs packages from repositories into alternate
+// root without rpm, the way minimal container roots are built
+package install
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var (
+	ErrScriptFailed = errors.New("scriptlet failed")
+	ErrLua          = errors.New("lua scriptlets are not supported")
+)
+
+// cacheDir is where packages are fetched to, relative to root
+const cacheDir = "var/cache/ypkg/packages"
+
+// ScriptMode selects what is done with scriptlets
+type ScriptMode string
+
+const (
+	ScriptsSkip   ScriptMode = "skip"
+	ScriptsRun    ScriptMode = "run"
+	ScriptsReport ScriptMode = "report"
+)
+
+// Item is package to install along with repository it comes from
+type Item struct {
+	Package *repo.Package
+	Source  repo.Source
+}
+
+// ScriptReport is scriptlet met during installation
+type ScriptReport struct {
+	Package     string              `json:"package"`
+	Phase       rpmutil.ScriptPhase `json:"phase"`
+	Interpreter string              `json:"interpreter"`
+	Body        string              `json:"body,omitempty"`
+	Ran         bool                `json:"ran"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// Result is outcome of installation
+type Result struct {
+	Installed []*Record      `json:"installed"`
+	Scripts   []ScriptReport `json:"scripts"`
+}
+
+// Installer installs packages into Root
+type Installer struct {
+	Root    string
+	Scripts ScriptMode
+	// Runner is command scriptlets run with, root, interpreter with
+	// its arguments, path of script within root and its argument are
+	// appended to it
+	Runner         []string
+	Stdout, Stderr io.Writer
+	NoOwner        bool
+}
+
+func NewInstaller(root string) *Installer {
+	return &Installer{
+		Root:    root,
+		Scripts: ScriptsSkip,
+		Runner:  []string{"chroot"},
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+type pending struct {
+	*repo.Package
+	// file is where package was fetched to, it's read again when
+	// installed rather than kept in memory
+	file    string
+	scripts map[rpmutil.ScriptPhase]rpmutil.Scriptlet
+	// count is number of instances installed once package is
+	// installed, argument of its scriptlets
+	count int
+}
+
+// Install installs packages in order given, dependencies first, and
+// records them in state database of root. Packages are fetched and
+// verified before anything is written. Failure of pretrans or prein
+// scriptlet stops installation, failures of the others are reported.
+// Files of replaced versions which the new ones lack are left behind.
+func (in *Installer) Install(items []Item) (*Result, error) {
+	db, err := OpenDB(in.Root)
+	if err != nil {
+		return nil, err
+	}
+	cache := filepath.Join(in.Root, filepath.FromSlash(cacheDir))
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(cache)
+
+	pkgs := make([]*pending, len(items))
+	for i, it := range items {
+		p, err := in.fetch(cache, it)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", it.Package.NEVRA(), err)
+		}
+		p.count = 1
+		for _, r := range db.Records {
+			if r.Package.Name == p.Name {
+				p.count = 2
+				break
+			}
+		}
+		pkgs[i] = p
+	}
+
+	res := &Result{}
+	for _, p := range pkgs {
+		if err := in.script(res, p, rpmutil.PhasePretrans); err != nil {
+			return res, err
+		}
+	}
+	for _, p := range pkgs {
+		if err := in.script(res, p, rpmutil.PhasePrein); err != nil {
+			return res, err
+		}
+		files, err := in.extract(p)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", p.NEVRA(), err)
+		}
+		rec := &Record{Package: p.Package, InstallTime: time.Now().UTC(), Files: files}
+		db.Add(rec)
+		if err := db.Save(); err != nil {
+			return res, err
+		}
+		res.Installed = append(res.Installed, rec)
+		in.script(res, p, rpmutil.PhasePostin)
+	}
+	for _, p := range pkgs {
+		in.script(res, p, rpmutil.PhasePosttrans)
+	}
+	return res, nil
+}
+
+func (in *Installer) fetch(cache string, it Item) (*pending, error) {
+	fname := filepath.Join(cache, path.Base(it.Package.Location.Href))
+	f, err := os.Create(fname)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.Fetch(it.Source, it.Package, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err = os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := rpmutil.ReadPackage(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if err := pkg.VerifyHeader(); err != nil {
+		return nil, err
+	}
+	scripts, err := pkg.Scriptlets()
+	if err != nil {
+		return nil, err
+	}
+	p := &pending{Package: it.Package, file: fname, scripts: map[rpmutil.ScriptPhase]rpmutil.Scriptlet{}}
+	for _, s := range scripts {
+		p.scripts[s.Phase] = s
+	}
+	return p, nil
+}
+
+// extract writes files of fetched package into root, payload is
+// streamed from the file
+func (in *Installer) extract(p *pending) ([]string, error) {
+	f, err := os.Open(p.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := rpmutil.ReadPackage(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	return pkg.Extract(in.Root, &rpmutil.ExtractOptions{NoOwner: in.NoOwner})
+}
+
+// script handles scriptlet of package for phase according to mode,
+// error is returned only if it runs and fails, lua scriptlets are
+// reported as not run
+func (in *Installer) script(res *Result, p *pending, phase rpmutil.ScriptPhase) error {
+	s, ok := p.scripts[phase]
+	if !ok || in.Scripts == ScriptsSkip {
+		return nil
+	}
+	rep := ScriptReport{Package: p.NEVRA(), Phase: phase, Interpreter: s.Interpreter}
+	var err error
+	switch {
+	case in.Scripts == ScriptsReport:
+		rep.Body = s.Body
+	case s.Interpreter == "<lua>":
+		// not being able to run them is no reason to fail
+		rep.Error = ErrLua.Error()
+	default:
+		rep.Ran = true
+		arg := ""
+		if phase != rpmutil.PhasePretrans && phase != rpmutil.PhasePosttrans {
+			arg = strconv.Itoa(p.count)
+		}
+		err = in.run(s.Script, arg)
+	}
+	if err != nil {
+		rep.Error = err.Error()
+		err = fmt.Errorf("%s: %s: %w", p.NEVRA(), phase, err)
+	}
+	res.Scripts = append(res.Scripts, rep)
+	return err
+}
+
+// run runs script within root, script body is written to file under
+// var/tmp of root
+func (in *Installer) run(s rpmutil.Script, arg string) error {
+	if len(in.Runner) == 0 {
+		return fmt.Errorf("%w: no runner", ErrScriptFailed)
+	}
+	args := append(append(append([]string{}, in.Runner[1:]...), in.Root, s.Interpreter), s.Args...)
+	if len(s.Body) > 0 {
+		tmp := filepath.Join(in.Root, "var", "tmp")
+		if err := os.MkdirAll(tmp, 0755); err != nil {
+			return err
+		}
+		f, err := ioutil.TempFile(tmp, "ypkg-script-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(s.Body)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		args = append(args, "/var/tmp/"+filepath.Base(f.Name()))
+	}
+	if len(arg) > 0 {
+		args = append(args, arg)
+	}
+	cmd := exec.Command(in.Runner[0], args...)
+	cmd.Env = []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin", "HOME=/"}
+	cmd.Stdout, cmd.Stderr = in.Stdout, in.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", ErrScriptFailed, err)
+	}
+	return nil
+}
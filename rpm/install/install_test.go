@@ -0,0 +1,323 @@
+package install
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// memSource is repository of package files kept in memory
+type memSource map[string][]byte
+
+func (s memSource) Open(path string) (io.ReadCloser, error) {
+	d, ok := s[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(d)), nil
+}
+
+// scripts maps scriptlet tags to their values, bodies run with
+// /bin/sh unless interpreter is set
+type scripts map[rpm.HeaderTag]string
+
+// item builds package name-version-1.noarch owning /usr/share/name
+// and adds it to src
+func item(t *testing.T, src memSource, name, version string, s scripts) Item {
+	t.Helper()
+	files := []rpmtest.File{
+		{Name: "/usr/share/" + name, Mode: 040755},
+		{Name: "/usr/share/" + name + "/VERSION", Mode: 0100644, Data: version},
+	}
+	h := rpmtest.Header(name, files...)
+	h.SetString(rpm.TagVersion, version)
+	h.SetString(rpm.TagSourceRPM, name+"-"+version+"-1.src.rpm")
+	for tag, body := range s {
+		h.SetString(tag, body)
+	}
+
+	var b bytes.Buffer
+	lead := rpm.NewLead(name+"-"+version+"-1", "noarch", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(rpmtest.Payload(files...))); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b.Bytes())
+	p := &repo.Package{
+		Name:     name,
+		Arch:     "noarch",
+		EVR:      rpm.EVR{Version: version, Release: "1"},
+		Checksum: repo.Checksum{Type: "sha256", Value: hex.EncodeToString(sum[:])},
+		Location: repo.Location{Href: "Packages/" + name + "-" + version + "-1.noarch.rpm"},
+	}
+	src[p.Location.Href] = b.Bytes()
+	return Item{Package: p, Source: src}
+}
+
+// newInstaller returns installer into new root with fake runner,
+// which runs scripts with sh without chroot, their output goes to
+// returned buffer
+func newInstaller(t *testing.T, mode ScriptMode) (*Installer, *bytes.Buffer) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	root, err := ioutil.TempDir("", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := NewInstaller(root)
+	in.Scripts = mode
+	// runner gets root, interpreter, script path within root and
+	// argument
+	in.Runner = []string{"sh", "-c", `root=$1; shift 2; s=$1; shift; exec sh "$root$s" "$@"`, "runner"}
+	in.Stdout, in.Stderr = &out, &out
+	in.NoOwner = true
+	return in, &out
+}
+
+func readVersion(t *testing.T, root, name string) string {
+	t.Helper()
+	d, err := ioutil.ReadFile(filepath.Join(root, "usr", "share", name, "VERSION"))
+	if err != nil {
+		return ""
+	}
+	return string(d)
+}
+
+func installed(t *testing.T, root string) []string {
+	t.Helper()
+	db, err := OpenDB(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, p := range db.Packages() {
+		res = append(res, p.NEVRA())
+	}
+	return res
+}
+
+func TestInstall(t *testing.T) {
+	in, _ := newInstaller(t, ScriptsSkip)
+	defer os.RemoveAll(in.Root)
+	src := memSource{}
+	items := []Item{item(t, src, "lib", "1.0", nil), item(t, src, "app", "2.0", nil)}
+
+	res, err := in.Install(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range res.Installed {
+		got = append(got, r.Package.NEVRA())
+	}
+	expected := []string{"lib-1.0-1.noarch", "app-2.0-1.noarch"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	if got := installed(t, in.Root); !reflect.DeepEqual(got, expected) {
+		t.Errorf("state database: got %v, expected %v", got, expected)
+	}
+	files := []string{"/usr/share/app", "/usr/share/app/VERSION"}
+	if r := res.Installed[1]; !reflect.DeepEqual(r.Files, files) || r.InstallTime.IsZero() {
+		t.Errorf("got %+v, expected files %v", r, files)
+	}
+	if v := readVersion(t, in.Root, "app"); v != "2.0" {
+		t.Errorf("got version %q, expected 2.0", v)
+	}
+	if len(res.Scripts) != 0 {
+		t.Errorf("got scripts %+v", res.Scripts)
+	}
+	if _, err := os.Stat(filepath.Join(in.Root, filepath.FromSlash(cacheDir))); !os.IsNotExist(err) {
+		t.Errorf("package cache left behind: %v", err)
+	}
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	in, _ := newInstaller(t, ScriptsSkip)
+	defer os.RemoveAll(in.Root)
+	src := memSource{}
+	items := []Item{item(t, src, "lib", "1.0", nil), item(t, src, "app", "2.0", nil)}
+	items[1].Package.Checksum.Value = strings.Repeat("0", 64)
+
+	var ce *repo.ChecksumError
+	if _, err := in.Install(items); !errors.As(err, &ce) {
+		t.Errorf("got %v, expected checksum mismatch", err)
+	}
+	// nothing is written until all packages are fetched
+	if got := installed(t, in.Root); len(got) != 0 || readVersion(t, in.Root, "lib") != "" {
+		t.Errorf("got %v installed", got)
+	}
+}
+
+// echo prints phase, package and argument of scriptlet
+func echo(phase, name string) string {
+	return "echo " + phase + " " + name + " $#$1"
+}
+
+func TestInstallScripts(t *testing.T) {
+	in, out := newInstaller(t, ScriptsRun)
+	defer os.RemoveAll(in.Root)
+	src := memSource{}
+	all := func(name string) scripts {
+		return scripts{
+			rpm.TagPretrans:  echo("pretrans", name),
+			rpm.TagPrein:     echo("prein", name),
+			rpm.TagPostin:    echo("postin", name),
+			rpm.TagPosttrans: echo("posttrans", name),
+		}
+	}
+	items := []Item{item(t, src, "lib", "1.0", all("lib")), item(t, src, "app", "1.0", all("app"))}
+
+	res, err := in.Install(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// transaction scriptlets get no argument, the others get number
+	// of instances installed
+	expected := `pretrans lib 0
+pretrans app 0
+prein lib 11
+postin lib 11
+prein app 11
+postin app 11
+posttrans lib 0
+posttrans app 0
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out, expected)
+	}
+	if len(res.Scripts) != 8 {
+		t.Fatalf("got %d scripts, expected 8", len(res.Scripts))
+	}
+	if s := res.Scripts[2]; s.Package != "lib-1.0-1.noarch" || s.Phase != rpmutil.PhasePrein ||
+		s.Interpreter != "/bin/sh" || !s.Ran || s.Body != "" || s.Error != "" {
+		t.Errorf("got %+v", s)
+	}
+
+	// upgrade replaces record and its scriptlets see two instances
+	out.Reset()
+	if _, err := in.Install([]Item{item(t, src, "lib", "2.0", all("lib"))}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "pretrans lib 0\nprein lib 12\npostin lib 12\nposttrans lib 0\n"
+	if out.String() != expected {
+		t.Errorf("upgrade: got\n%s\nexpected\n%s", out, expected)
+	}
+	if got := installed(t, in.Root); !reflect.DeepEqual(got, []string{"lib-2.0-1.noarch", "app-1.0-1.noarch"}) {
+		t.Errorf("upgrade: got %v installed", got)
+	}
+	if v := readVersion(t, in.Root, "lib"); v != "2.0" {
+		t.Errorf("upgrade: got version %q, expected 2.0", v)
+	}
+}
+
+func TestInstallScriptModes(t *testing.T) {
+	s := scripts{rpm.TagPostin: "echo postin", rpm.TagPosttrans: "print('hi')"}
+	for _, mode := range []ScriptMode{ScriptsSkip, ScriptsReport} {
+		t.Run(string(mode), func(t *testing.T) {
+			in, out := newInstaller(t, mode)
+			defer os.RemoveAll(in.Root)
+			src := memSource{}
+			res, err := in.Install([]Item{item(t, src, "lib", "1.0", s)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Len() > 0 {
+				t.Errorf("scriptlet ran: %s", out)
+			}
+			var expected []ScriptReport
+			if mode == ScriptsReport {
+				expected = []ScriptReport{
+					{Package: "lib-1.0-1.noarch", Phase: rpmutil.PhasePostin, Interpreter: "/bin/sh", Body: "echo postin"},
+					{Package: "lib-1.0-1.noarch", Phase: rpmutil.PhasePosttrans, Interpreter: "/bin/sh", Body: "print('hi')"},
+				}
+			}
+			if !reflect.DeepEqual(res.Scripts, expected) {
+				t.Errorf("got %+v, expected %+v", res.Scripts, expected)
+			}
+		})
+	}
+}
+
+func TestInstallLua(t *testing.T) {
+	in, out := newInstaller(t, ScriptsRun)
+	defer os.RemoveAll(in.Root)
+	src := memSource{}
+	s := scripts{
+		rpm.TagPretrans:     "print('hi')",
+		rpm.TagPretransProg: "<lua>",
+		rpm.TagPostin:       echo("postin", "lib"),
+	}
+	// lua scriptlet is reported without failing, the rest runs
+	res, err := in.Install([]Item{item(t, src, "lib", "1.0", s)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Scripts) != 2 || res.Scripts[0].Ran || res.Scripts[0].Error != ErrLua.Error() || !res.Scripts[1].Ran {
+		t.Errorf("got %+v", res.Scripts)
+	}
+	if out.String() != "postin lib 11\n" {
+		t.Errorf("got output %q", out)
+	}
+}
+
+func TestInstallScriptFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		tag       rpm.HeaderTag
+		installed []string
+		err       bool
+	}{
+		// pretrans failure stops installation before anything is
+		// written
+		{"pretrans", rpm.TagPretrans, nil, true},
+		// prein failure stops at the package
+		{"prein", rpm.TagPrein, []string{"lib-1.0-1.noarch"}, true},
+		// later failures are only reported
+		{"postin", rpm.TagPostin, []string{"lib-1.0-1.noarch", "app-1.0-1.noarch"}, false},
+		{"posttrans", rpm.TagPosttrans, []string{"lib-1.0-1.noarch", "app-1.0-1.noarch"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, _ := newInstaller(t, ScriptsRun)
+			defer os.RemoveAll(in.Root)
+			src := memSource{}
+			items := []Item{
+				item(t, src, "lib", "1.0", nil),
+				item(t, src, "app", "1.0", scripts{tt.tag: "exit 3"}),
+			}
+			res, err := in.Install(items)
+			if tt.err != (err != nil) {
+				t.Fatalf("got error %v, expected error %v", err, tt.err)
+			}
+			if err != nil && !errors.Is(err, ErrScriptFailed) {
+				t.Errorf("got %v, expected %v", err, ErrScriptFailed)
+			}
+			if got := installed(t, in.Root); !reflect.DeepEqual(got, tt.installed) {
+				t.Errorf("got %v installed, expected %v", got, tt.installed)
+			}
+			if app := readVersion(t, in.Root, "app") != ""; app != (len(tt.installed) == 2) {
+				t.Errorf("files of app written: %v", app)
+			}
+			if len(res.Scripts) != 1 || !res.Scripts[0].Ran || !strings.Contains(res.Scripts[0].Error, "exit status 3") {
+				t.Errorf("got %+v", res.Scripts)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Package rpmtest builds headers and payloads of synthetic packages
+// for tests
+package rpmtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+const (
+	modeTypeMask = 0xf000
+	modeReg      = 0x8000
+	modeLink     = 0xa000
+)
+
+// File is file of synthetic package
+type File struct {
+	Name string
+	// Mode includes file type bits
+	Mode uint32
+	// Data is content of regular file or target of symlink
+	Data  string
+	Flags rpm.FileFlags
+	MTime int32
+	// Ino is shared by hardlinked files, files with zero Ino get
+	// inode of their own
+	Ino int
+	// device number of device files, header keeps only 16 bits
+	// of it, full numbers are in payload
+	RdevMajor, RdevMinor int
+}
+
+func (f *File) isReg() bool {
+	return f.Mode&modeTypeMask == modeReg
+}
+
+// Header returns main header of name-1.0-1.noarch package with
+// uncompressed payload and files. Files are owned by root and
+// regular ones carry SHA256 digest of their data.
+func Header(name string, files ...File) *rpm.Header {
+	h := rpm.NewHeader(rpm.TagHeaderImmutable)
+	h.SetString(rpm.TagName, name)
+	h.SetString(rpm.TagVersion, "1.0")
+	h.SetString(rpm.TagRelease, "1")
+	h.SetString(rpm.TagArch, "noarch")
+	h.SetString(rpm.TagPayloadCompressor, "uncompressed")
+	if len(files) == 0 {
+		return h
+	}
+
+	var dirNames, baseNames, digests, links, owners []string
+	var dirIndexes, sizes, flags, mtimes, inodes []int32
+	var modes, rdevs []int16
+	dirs := map[string]int32{}
+	for i, f := range files {
+		dir, base := path.Split(f.Name)
+		di, ok := dirs[dir]
+		if !ok {
+			di = int32(len(dirNames))
+			dirs[dir] = di
+			dirNames = append(dirNames, dir)
+		}
+		dirIndexes = append(dirIndexes, di)
+		baseNames = append(baseNames, base)
+
+		var digest, link string
+		var size int
+		switch f.Mode & modeTypeMask {
+		case modeReg:
+			sum := sha256.Sum256([]byte(f.Data))
+			digest, size = hex.EncodeToString(sum[:]), len(f.Data)
+		case modeLink:
+			link, size = f.Data, len(f.Data)
+		}
+		digests = append(digests, digest)
+		links = append(links, link)
+		sizes = append(sizes, int32(size))
+		modes = append(modes, int16(f.Mode))
+		rdevs = append(rdevs, int16(f.RdevMajor<<8|f.RdevMinor&0xff))
+		flags = append(flags, int32(f.Flags))
+		mtimes = append(mtimes, f.MTime)
+		ino := f.Ino
+		if ino == 0 {
+			ino = 1000 + i
+		}
+		inodes = append(inodes, int32(ino))
+		owners = append(owners, "root")
+	}
+	h.SetStrings(rpm.TagDirNames, dirNames)
+	h.SetStrings(rpm.TagBaseNames, baseNames)
+	h.SetInt32s(rpm.TagDirIndexes, dirIndexes)
+	h.SetInt32s(rpm.TagFileSizes, sizes)
+	h.SetInt16s(rpm.TagFileModes, modes)
+	h.SetInt16s(rpm.TagFileRDevs, rdevs)
+	h.SetInt32s(rpm.TagFileMTimes, mtimes)
+	h.SetStrings(rpm.TagFileMD5S, digests)
+	h.SetStrings(rpm.TagFileLinkTos, links)
+	h.SetInt32s(rpm.TagFileFlags, flags)
+	h.SetStrings(rpm.TagFileUsername, owners)
+	h.SetStrings(rpm.TagFileGroupname, owners)
+	h.SetInt32s(rpm.TagFileInodes, inodes)
+	h.SetInt32s(rpm.TagFileDigestAlgo, []int32{int32(rpm.HashSHA256)})
+	return h
+}
+
+// Payload returns cpio archive of files the way rpm stores them,
+// data of hardlinked files is stored with the last of them and
+// %ghost files are left out
+func Payload(files ...File) []byte {
+	links := map[int]int{}
+	for _, f := range files {
+		if f.Ino != 0 && f.Flags&rpm.FileGhost == 0 {
+			links[f.Ino]++
+		}
+	}
+	seen := map[int]int{}
+	var entries []Entry
+	for i, f := range files {
+		if f.Flags&rpm.FileGhost != 0 {
+			continue
+		}
+		e := Entry{
+			Name:      "." + f.Name,
+			Mode:      f.Mode,
+			Ino:       f.Ino,
+			Links:     1,
+			RdevMajor: f.RdevMajor,
+			RdevMinor: f.RdevMinor,
+			MTime:     f.MTime,
+		}
+		if e.Ino == 0 {
+			e.Ino = 1000 + i
+		}
+		if f.isReg() || f.Mode&modeTypeMask == modeLink {
+			e.Data = f.Data
+		}
+		if f.Ino != 0 {
+			e.Links = links[f.Ino]
+			if seen[f.Ino]++; seen[f.Ino] < e.Links {
+				e.Data = ""
+			}
+		}
+		entries = append(entries, e)
+	}
+	return Cpio(entries...)
+}
+
+// Entry is entry of newc cpio archive
+type Entry struct {
+	Name                 string
+	Mode                 uint32
+	Ino, Links           int
+	RdevMajor, RdevMinor int
+	MTime                int32
+	Data                 string
+}
+
+// Cpio returns newc archive of entries with trailer
+func Cpio(entries ...Entry) []byte {
+	var b bytes.Buffer
+	pad := func() {
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	for _, e := range append(entries, Entry{Name: "TRAILER!!!", Links: 1}) {
+		fmt.Fprintf(&b, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+			e.Ino, e.Mode, 0, 0, e.Links, e.MTime, len(e.Data), 0, 0, e.RdevMajor, e.RdevMinor, len(e.Name)+1, 0)
+		b.WriteString(e.Name + "\x00")
+		pad()
+		b.WriteString(e.Data)
+		pad()
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,154 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	leadNameSize = 66
+
+	// SigTypeHeaderSig is the only signature type used since rpm 3,
+	// signature is stored in header following the lead
+	SigTypeHeaderSig int16 = 5
+)
+
+// Lead is legacy structure at the start of every package. rpm no
+// longer relies on it (apart from the magic), but it is still written
+// and used by tools like `file` to identify packages.
+type Lead struct {
+	Major         uint8
+	Minor         uint8
+	Type          int16
+	ArchNum       int16
+	Name          string
+	OSNum         int16
+	SignatureType int16
+}
+
+// archNums are lead architecture numbers from rpmrc arch_canon
+var archNums = map[string]int16{
+	"i386":    1,
+	"i486":    1,
+	"i586":    1,
+	"i686":    1,
+	"x86_64":  1,
+	"alpha":   2,
+	"sparc":   3,
+	"sparc64": 3,
+	"mips":    4,
+	"ppc":     5,
+	"m68k":    6,
+	"ia64":    9,
+	"mipsel":  11,
+	"armv7hl": 12,
+	"armv7l":  12,
+	"s390":    14,
+	"s390x":   15,
+	"ppc64":   16,
+	"ppc64le": 16,
+	"aarch64": 19,
+	"riscv64": 22,
+}
+
+// NewLead creates lead for package the way rpmbuild fills it,
+// name is expected to be N-V-R of the package
+func NewLead(name, arch string, pkgType int16) *Lead {
+	return &Lead{
+		Major:         3,
+		Minor:         0,
+		Type:          pkgType,
+		ArchNum:       archNums[arch],
+		Name:          name,
+		OSNum:         1,
+		SignatureType: SigTypeHeaderSig,
+	}
+}
+
+// ReadLead reads and decodes lead of the package
+func ReadLead(r io.Reader) (*Lead, error) {
+	d := make([]byte, LeadSize)
+	if _, err := io.ReadFull(r, d); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = &FormatError{Section: "lead", Err: ErrTruncated}
+		}
+		return nil, err
+	}
+	return SniffPackage(d)
+}
+
+func (l *Lead) UnmarshalBinary(d []byte) error {
+	if len(d) < LeadSize {
+		return &FormatError{Section: "lead", Err: ErrTruncated}
+	}
+	if binary.BigEndian.Uint32(d[0:4]) != LeadMagic {
+		return &FormatError{Section: "lead", Err: ErrBadMagic}
+	}
+	l.Major, l.Minor = d[4], d[5]
+	l.Type = int16(binary.BigEndian.Uint16(d[6:]))
+	l.ArchNum = int16(binary.BigEndian.Uint16(d[8:]))
+	name := d[10 : 10+leadNameSize]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	l.Name = string(name)
+	l.OSNum = int16(binary.BigEndian.Uint16(d[76:]))
+	l.SignatureType = int16(binary.BigEndian.Uint16(d[78:]))
+	return nil
+}
+
+// MarshalBinary encodes lead, name is truncated to fit the lead
+// keeping it NUL terminated
+func (l *Lead) MarshalBinary() ([]byte, error) {
+	d := make([]byte, LeadSize)
+	binary.BigEndian.PutUint32(d[0:], LeadMagic)
+	d[4], d[5] = l.Major, l.Minor
+	binary.BigEndian.PutUint16(d[6:], uint16(l.Type))
+	binary.BigEndian.PutUint16(d[8:], uint16(l.ArchNum))
+	copy(d[10:10+leadNameSize-1], l.Name)
+	binary.BigEndian.PutUint16(d[76:], uint16(l.OSNum))
+	binary.BigEndian.PutUint16(d[78:], uint16(l.SignatureType))
+	return d, nil
+}
+
+// WriteLead writes encoded lead to w
+func WriteLead(w io.Writer, l *Lead) error {
+	d, err := l.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
+
+// IsSource reports whether lead belongs to source package
+func (l *Lead) IsSource() bool {
+	return l.Type == PackageTypeSource
+}
+
+// FormatVersion returns package format version the lead announces.
+// Lead version 3 is written by both v3 and v4 packages, as v3 ones
+// are long extinct it's reported as 4. Lead doesn't tell v6 packages
+// apart, their format is recorded in the header.
+func (l *Lead) FormatVersion() int {
+	if l.Major == 3 {
+		return 4
+	}
+	return int(l.Major)
+}
+
+// IsRPM reports whether d starts with lead magic
+func IsRPM(d []byte) bool {
+	return len(d) >= 4 && binary.BigEndian.Uint32(d) == LeadMagic
+}
+
+// SniffPackage identifies package from its first LeadSize bytes,
+// use IsSource and FormatVersion of returned lead to tell what it is
+func SniffPackage(d []byte) (*Lead, error) {
+	l := &Lead{}
+	if err := l.UnmarshalBinary(d); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
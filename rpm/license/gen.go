@@ -0,0 +1,110 @@
+//go:build ignore
+// +build ignore
+
+// gen writes list.go from licenses.json and exceptions.json of SPDX
+// license list data, https://github.com/spdx/license-list-data
+//
+//	go run gen.go [-data URL or directory]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	data = flag.String("data", "https://raw.githubusercontent.com/spdx/license-list-data/main/json",
+		"URL or directory of license list data in JSON")
+	out = flag.String("o", "list.go", "file to write")
+)
+
+type list struct {
+	Licenses []struct {
+		ID string `json:"licenseId"`
+	} `json:"licenses"`
+	Exceptions []struct {
+		ID string `json:"licenseExceptionId"`
+	} `json:"exceptions"`
+}
+
+func read(name string) (*list, error) {
+	var r io.ReadCloser
+	if strings.HasPrefix(*data, "http://") || strings.HasPrefix(*data, "https://") {
+		resp, err := http.Get(*data + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", name, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(filepath.Join(*data, name))
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	defer r.Close()
+	var l list
+	if err := json.NewDecoder(r).Decode(&l); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &l, nil
+}
+
+func writeIDs(b *bytes.Buffer, doc, name string, ids []string) {
+	sort.Strings(ids)
+	fmt.Fprintf(b, "\n%svar %s = []string{\n", doc, name)
+	for _, id := range ids {
+		fmt.Fprintf(b, "\t%q,\n", id)
+	}
+	b.WriteString("}\n")
+}
+
+func main() {
+	flag.Parse()
+	licenses, err := read("licenses.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	exceptions, err := read("exceptions.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var lids, eids []string
+	for _, l := range licenses.Licenses {
+		lids = append(lids, l.ID)
+	}
+	for _, e := range exceptions.Exceptions {
+		eids = append(eids, e.ID)
+	}
+	if len(lids) == 0 || len(eids) == 0 {
+		log.Fatal("empty license list")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen.go from SPDX license list data. DO NOT EDIT.\n\npackage license\n")
+	writeIDs(&b, "// licenseIDs are identifiers of SPDX license list, deprecated ones\n"+
+		"// included since old packages have them\n", "licenseIDs", lids)
+	writeIDs(&b, "// exceptionIDs are identifiers of SPDX license exception list\n", "exceptionIDs", eids)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
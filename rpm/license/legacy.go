@@ -0,0 +1,80 @@
+package license
+
+// legacyNames map short names Fedora used before switching to SPDX
+// which stand for single SPDX license
+var legacyNames = map[string]string{
+	"AGPLv3":             "AGPL-3.0-only",
+	"AGPLv3+":            "AGPL-3.0-or-later",
+	"ASL 1.0":            "Apache-1.0",
+	"ASL 1.1":            "Apache-1.1",
+	"ASL 2.0":            "Apache-2.0",
+	"Artistic 2.0":       "Artistic-2.0",
+	"Artistic clarified": "ClArtistic",
+	"Artistic":           "Artistic-1.0-Perl",
+	"Boost":              "BSL-1.0",
+	"CC0":                "CC0-1.0",
+	"CeCILL-B":           "CECILL-B",
+	"CeCILL-C":           "CECILL-C",
+	"CPL":                "CPL-1.0",
+	"EPL":                "EPL-1.0",
+	"EUPL 1.1":           "EUPL-1.1",
+	"GPL+":               "GPL-1.0-or-later",
+	"GPLv1":              "GPL-1.0-only",
+	"GPLv2":              "GPL-2.0-only",
+	"GPLv2+":             "GPL-2.0-or-later",
+	"GPLv3":              "GPL-3.0-only",
+	"GPLv3+":             "GPL-3.0-or-later",
+	"IBM":                "IPL-1.0",
+	"JasPer":             "JasPer-2.0",
+	"LGPLv3":             "LGPL-3.0-only",
+	"LGPLv3+":            "LGPL-3.0-or-later",
+	"MPLv1.0":            "MPL-1.0",
+	"MPLv1.1":            "MPL-1.1",
+	"MPLv2.0":            "MPL-2.0",
+	"Netscape":           "NPL-1.1",
+	"OSL 1.0":            "OSL-1.0",
+	"OSL 2.0":            "OSL-2.0",
+	"OSL 2.1":            "OSL-2.1",
+	"OSL 3.0":            "OSL-3.0",
+	"Public Domain":      "LicenseRef-Fedora-Public-Domain",
+	"QPL":                "QPL-1.0",
+	"wxWidgets":          "wxWindows",
+	"zlib":               "Zlib",
+	"ZPLv2.0":            "ZPL-2.0",
+	"ZPLv2.1":            "ZPL-2.1",
+	"Copyright only":     "LicenseRef-Fedora-Copyright-Only",
+	"Redistributable, no modification permitted": "LicenseRef-Fedora-Firmware",
+}
+
+// ambiguousNames are legacy names covering several SPDX licenses, like
+// BSD, which stands for any BSD variant. Which one package has can't
+// be told from the name, so they are mapped to references rather than
+// guessed.
+var ambiguousNames = []string{
+	"AFL",
+	"BSD",
+	"BSD with advertising",
+	"CC-BY",
+	"CC-BY-SA",
+	"CDDL",
+	"CeCILL",
+	"GFDL",
+	"LGPLv2",
+	"LGPLv2+",
+	"LPPL",
+	"MIT with advertising",
+	"OFL",
+	"OpenLDAP",
+	"PHP",
+	"Python",
+	"UCD",
+}
+
+// legacyExceptions map legacy names of exceptions, bare "exceptions"
+// says just that license has some
+var legacyExceptions = map[string]string{
+	"Bison exception":               "Bison-exception-2.2",
+	"Classpath exception":           "Classpath-exception-2.0",
+	"GCC Runtime Library exception": "GCC-exception-3.1",
+	"Linux-syscall-note":            "Linux-syscall-note",
+}
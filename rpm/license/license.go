@@ -0,0 +1,369 @@
+// Package license parses License tags of packages as SPDX license
+// expressions. Legacy Fedora short names are mapped to SPDX.
+package license
+
+//go:generate go run gen.go
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+var ErrMalformed = errors.New("malformed license expression")
+
+// Op is operator of compound expression
+type Op string
+
+const (
+	And Op = "AND"
+	Or  Op = "OR"
+)
+
+// Expr is parsed license expression. Leaf has no Op and carries
+// license with optional exception, AND and OR have two or more Args.
+type Expr struct {
+	Op        Op
+	Args      []*Expr
+	License   string
+	Exception string
+}
+
+// String formats expression, operands of other kind of compound
+// expression are parenthesized
+func (e *Expr) String() string {
+	if len(e.Op) == 0 {
+		if len(e.Exception) > 0 {
+			return e.License + " WITH " + e.Exception
+		}
+		return e.License
+	}
+	s := make([]string, len(e.Args))
+	for i, a := range e.Args {
+		s[i] = a.String()
+		if len(a.Op) > 0 && a.Op != e.Op {
+			s[i] = "(" + s[i] + ")"
+		}
+	}
+	return strings.Join(s, " "+string(e.Op)+" ")
+}
+
+// Leaves returns licenses of expression in order they appear
+func (e *Expr) Leaves() []*Expr {
+	if len(e.Op) == 0 {
+		return []*Expr{e}
+	}
+	var res []*Expr
+	for _, a := range e.Args {
+		res = append(res, a.Leaves()...)
+	}
+	return res
+}
+
+// Parse parses license expression. Operators are matched regardless
+// of case the way legacy tags write them and consecutive words are
+// taken as single identifier, like "ASL 2.0".
+func Parse(s string) (*Expr, error) {
+	p := parser{tokens: tokens(s)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrMalformed)
+	}
+	e, err := p.or()
+	if err == nil && p.pos != len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %s", ErrMalformed, s, err)
+	}
+	return e, nil
+}
+
+// tokens splits expression into parentheses, operators and
+// identifiers
+func tokens(s string) []string {
+	var res, words []string
+	flush := func() {
+		if len(words) > 0 {
+			res = append(res, strings.Join(words, " "))
+			words = nil
+		}
+	}
+	s = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)
+	for _, w := range strings.Fields(s) {
+		if w == "(" || w == ")" || isOp(w) {
+			flush()
+			res = append(res, w)
+			continue
+		}
+		words = append(words, w)
+	}
+	flush()
+	return res
+}
+
+func isOp(t string) bool {
+	for _, op := range []string{"AND", "OR", "WITH"} {
+		if strings.EqualFold(t, op) {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) or() (*Expr, error) {
+	return p.compound(Or, p.and)
+}
+
+func (p *parser) and() (*Expr, error) {
+	return p.compound(And, p.with)
+}
+
+// compound parses operands of op joined by it, single operand is
+// returned as it is
+func (p *parser) compound(op Op, operand func() (*Expr, error)) (*Expr, error) {
+	e, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	res := &Expr{Op: op, Args: []*Expr{e}}
+	for strings.EqualFold(p.peek(), string(op)) {
+		p.next()
+		if e, err = operand(); err != nil {
+			return nil, err
+		}
+		res.Args = append(res.Args, e)
+	}
+	if len(res.Args) == 1 {
+		return res.Args[0], nil
+	}
+	return res, nil
+}
+
+func (p *parser) with() (*Expr, error) {
+	switch t := p.next(); {
+	case t == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing )")
+		}
+		return e, nil
+	case len(t) == 0:
+		return nil, errors.New("unexpected end")
+	case t == ")" || isOp(t):
+		return nil, fmt.Errorf("unexpected %q", t)
+	default:
+		e := &Expr{License: t}
+		if strings.EqualFold(p.peek(), "WITH") {
+			p.next()
+			exc := p.next()
+			if len(exc) == 0 || exc == "(" || exc == ")" || isOp(exc) {
+				return nil, errors.New("missing exception")
+			}
+			e.Exception = exc
+		}
+		return e, nil
+	}
+}
+
+var licenses, exceptions = fold(licenseIDs), fold(exceptionIDs)
+
+// ambiguousRef prefixes references ambiguous legacy names are mapped to
+const ambiguousRef = "LicenseRef-Legacy-"
+
+// ambiguous maps ambiguous legacy names to references and back
+var ambiguous, ambiguousRefs = func() (map[string]string, map[string]string) {
+	r := strings.NewReplacer(" ", "-", "+", "-plus")
+	names, refs := map[string]string{}, map[string]string{}
+	for _, name := range ambiguousNames {
+		ref := ambiguousRef + r.Replace(name)
+		names[name], refs[ref] = ref, name
+	}
+	return names, refs
+}()
+
+// fold maps lowercase identifiers to their canonical form, SPDX
+// identifiers are case insensitive
+func fold(ids []string) map[string]string {
+	m := make(map[string]string, len(ids))
+	for _, id := range ids {
+		m[strings.ToLower(id)] = id
+	}
+	return m
+}
+
+// isRef reports whether identifier references license outside of
+// SPDX list
+func isRef(id string) bool {
+	return strings.HasPrefix(id, "LicenseRef-") || strings.HasPrefix(id, "DocumentRef-")
+}
+
+// Known reports whether license identifier is in SPDX list bundled
+func Known(id string) bool {
+	_, ok := licenses[strings.ToLower(id)]
+	return ok
+}
+
+// KnownException reports whether exception identifier is in SPDX list
+// bundled
+func KnownException(id string) bool {
+	_, ok := exceptions[strings.ToLower(id)]
+	return ok
+}
+
+// Normalize returns copy of expression with identifiers in their
+// canonical case and legacy names mapped to SPDX, ambiguous legacy
+// names are mapped to references
+func Normalize(e *Expr) *Expr {
+	if len(e.Op) > 0 {
+		n := &Expr{Op: e.Op, Args: make([]*Expr, len(e.Args))}
+		for i, a := range e.Args {
+			n.Args[i] = Normalize(a)
+		}
+		return n
+	}
+	n := *e
+	// some legacy names look like exception
+	if len(e.Exception) > 0 {
+		name := e.License + " with " + e.Exception
+		if id, ok := legacyNames[name]; ok {
+			return &Expr{License: id}
+		}
+		if ref, ok := ambiguous[name]; ok {
+			return &Expr{License: ref}
+		}
+	}
+	if id, ok := licenses[strings.ToLower(e.License)]; ok {
+		n.License = id
+	} else if id, ok := legacyNames[e.License]; ok {
+		n.License = id
+	} else if ref, ok := ambiguous[e.License]; ok {
+		n.License = ref
+	}
+	if id, ok := exceptions[strings.ToLower(e.Exception)]; ok {
+		n.Exception = id
+	} else if id, ok := legacyExceptions[e.Exception]; ok {
+		n.Exception = id
+	}
+	return &n
+}
+
+// Unknown returns identifiers of licenses and exceptions which are
+// neither in SPDX list bundled nor references, sorted
+func (e *Expr) Unknown() []string {
+	seen := map[string]bool{}
+	for _, l := range e.Leaves() {
+		if !Known(l.License) && !isRef(l.License) {
+			seen[l.License] = true
+		}
+		if len(l.Exception) > 0 && !KnownException(l.Exception) {
+			seen[l.Exception] = true
+		}
+	}
+	var res []string
+	for id := range seen {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Ambiguous returns legacy names normalized expression had which
+// cover several SPDX licenses, sorted
+func (e *Expr) Ambiguous() []string {
+	seen := map[string]bool{}
+	for _, l := range e.Leaves() {
+		if name, ok := ambiguousRefs[l.License]; ok {
+			seen[name] = true
+		}
+	}
+	var res []string
+	for name := range seen {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// HeaderLicense returns normalized License of package header
+func HeaderLicense(h *rpm.Header) (*Expr, error) {
+	s, err := h.GetString(rpm.TagLicense)
+	if err != nil {
+		return nil, err
+	}
+	e, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return Normalize(e), nil
+}
+
+// Policy decides which licenses are acceptable. Entries are glob
+// patterns matched regardless of case against license identifiers
+// alone and along with their exceptions, like "GPL-*" or
+// "GPL-2.0-only WITH Classpath-exception-2.0".
+type Policy struct {
+	// Allow lists licenses allowed, any license not denied is allowed
+	// if it's empty
+	Allow []string
+	// Deny lists licenses denied, it takes precedence over Allow
+	Deny []string
+}
+
+// Violations returns licenses which make expression unacceptable,
+// none if it's acceptable. All operands of AND have to be acceptable,
+// one alternative of OR is enough.
+func (p *Policy) Violations(e *Expr) []string {
+	switch e.Op {
+	case And, Or:
+		var res []string
+		for _, a := range e.Args {
+			v := p.Violations(a)
+			if e.Op == Or && len(v) == 0 {
+				return nil
+			}
+			res = append(res, v...)
+		}
+		return res
+	}
+	if matchAny(p.Deny, e) || (len(p.Allow) > 0 && !matchAny(p.Allow, e)) {
+		return []string{e.String()}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, leaf *Expr) bool {
+	for _, pat := range patterns {
+		pat = strings.ToLower(pat)
+		for _, s := range []string{leaf.License, leaf.String()} {
+			if ok, _ := path.Match(pat, strings.ToLower(s)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package license
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		license   string
+		spdx      string
+		unknown   []string
+		ambiguous []string
+	}{
+		{"MIT", "MIT", nil, nil},
+		{"mit and apache-2.0", "MIT AND Apache-2.0", nil, nil},
+		{"GPLv2+ and ASL 2.0", "GPL-2.0-or-later AND Apache-2.0", nil, nil},
+		{"GPL+ or Artistic", "GPL-1.0-or-later OR Artistic-1.0-Perl", nil, nil},
+		{"GPL-2.0+", "GPL-2.0+", nil, nil},
+		{"GPLv2 with exceptions", "GPL-2.0-only WITH exceptions", []string{"exceptions"}, nil},
+		{"GPLv2 with Classpath exception", "GPL-2.0-only WITH Classpath-exception-2.0", nil, nil},
+		// not commonly used ones are on the list too
+		{"BSD-3-Clause-HP", "BSD-3-Clause-HP", nil, nil},
+		{"Bitstream-Vera", "Bitstream-Vera", nil, nil},
+		{"BSD", "LicenseRef-Legacy-BSD", nil, []string{"BSD"}},
+		{"BSD with advertising", "LicenseRef-Legacy-BSD-with-advertising", nil, []string{"BSD with advertising"}},
+		// LGPLv2 covers both 2.0 and 2.1
+		{"LGPLv2+ and (BSD or MIT) and LGPLv2+", "LicenseRef-Legacy-LGPLv2-plus AND (LicenseRef-Legacy-BSD OR MIT) AND LicenseRef-Legacy-LGPLv2-plus",
+			nil, []string{"BSD", "LGPLv2+"}},
+		{"Foo and MIT", "Foo AND MIT", []string{"Foo"}, nil},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.license)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := Normalize(e)
+		if n.String() != tt.spdx {
+			t.Errorf("%s: got %q, expected %q", tt.license, n, tt.spdx)
+		}
+		if !reflect.DeepEqual(n.Unknown(), tt.unknown) {
+			t.Errorf("%s: got unknown %v, expected %v", tt.license, n.Unknown(), tt.unknown)
+		}
+		if !reflect.DeepEqual(n.Ambiguous(), tt.ambiguous) {
+			t.Errorf("%s: got ambiguous %v, expected %v", tt.license, n.Ambiguous(), tt.ambiguous)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		license string
+		parsed  string
+		op      Op
+	}{
+		{"MIT", "MIT", ""},
+		{"MIT AND Zlib AND BSD-3-Clause", "MIT AND Zlib AND BSD-3-Clause", And},
+		// AND binds tighter than OR
+		{"MIT OR Zlib AND BSD-3-Clause", "MIT OR (Zlib AND BSD-3-Clause)", Or},
+		{"MIT AND Zlib OR BSD-3-Clause", "(MIT AND Zlib) OR BSD-3-Clause", Or},
+		{"(MIT OR Zlib) AND BSD-3-Clause", "(MIT OR Zlib) AND BSD-3-Clause", And},
+		{"((MIT))", "MIT", ""},
+		{"GPL-2.0-only with Classpath-exception-2.0 or MIT", "GPL-2.0-only WITH Classpath-exception-2.0 OR MIT", Or},
+		{"ASL 2.0 and Public Domain", "ASL 2.0 AND Public Domain", And},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.license)
+		if err != nil {
+			t.Errorf("%s: %v", tt.license, err)
+			continue
+		}
+		if e.String() != tt.parsed || e.Op != tt.op {
+			t.Errorf("%s: got %q op %q, expected %q op %q", tt.license, e, e.Op, tt.parsed, tt.op)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, s := range []string{"", "  ", "MIT AND", "AND MIT", "(MIT", "MIT)", "()", "WITH", "MIT WITH", "MIT WITH (Zlib)", "MIT OR OR Zlib"} {
+		if e, err := Parse(s); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: got %v, %v, expected %v", s, e, err, ErrMalformed)
+		}
+	}
+}
+
+func TestViolations(t *testing.T) {
+	tests := []struct {
+		license    string
+		policy     Policy
+		violations []string
+	}{
+		{"MIT", Policy{}, nil},
+		{"MIT", Policy{Allow: []string{"MIT"}}, nil},
+		{"Zlib", Policy{Allow: []string{"MIT"}}, []string{"Zlib"}},
+		// deny takes precedence over allow
+		{"GPL-3.0-only", Policy{Allow: []string{"GPL-*"}, Deny: []string{"GPL-3.0-*"}}, []string{"GPL-3.0-only"}},
+		{"GPL-2.0-only", Policy{Allow: []string{"GPL-*"}, Deny: []string{"GPL-3.0-*"}}, nil},
+		// patterns are matched regardless of case
+		{"Apache-2.0", Policy{Allow: []string{"apache-*"}}, nil},
+		// one alternative of OR is enough
+		{"GPL-3.0-only OR MIT", Policy{Deny: []string{"GPL-3.0-only"}}, nil},
+		{"GPL-3.0-only OR AGPL-3.0-only", Policy{Deny: []string{"*GPL-3.0-only"}}, []string{"GPL-3.0-only", "AGPL-3.0-only"}},
+		// every operand of AND is reported
+		{"GPL-3.0-only AND MIT AND AGPL-3.0-only", Policy{Deny: []string{"*GPL-3.0-only"}}, []string{"GPL-3.0-only", "AGPL-3.0-only"}},
+		{"(GPL-3.0-only OR MIT) AND (Zlib OR AGPL-3.0-only)", Policy{Allow: []string{"MIT", "*GPL*"}}, nil},
+		{"(GPL-3.0-only OR MIT) AND (Zlib OR AGPL-3.0-only)", Policy{Deny: []string{"MIT", "AGPL-*"}}, nil},
+		{"(GPL-3.0-only OR MIT) AND (Zlib OR AGPL-3.0-only)", Policy{Deny: []string{"MIT", "*GPL*", "Zlib"}},
+			[]string{"GPL-3.0-only", "MIT", "Zlib", "AGPL-3.0-only"}},
+		// license with exception matches license alone and with it
+		{"GPL-2.0-only WITH Classpath-exception-2.0", Policy{Allow: []string{"GPL-2.0-only"}}, nil},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", Policy{Allow: []string{"GPL-2.0-only WITH Classpath-*"}}, nil},
+		{"GPL-2.0-only", Policy{Allow: []string{"GPL-2.0-only WITH Classpath-*"}}, []string{"GPL-2.0-only"}},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", Policy{Deny: []string{"* WITH Classpath-exception-2.0"}},
+			[]string{"GPL-2.0-only WITH Classpath-exception-2.0"}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.license)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := tt.policy.Violations(e)
+		if !reflect.DeepEqual(v, tt.violations) {
+			t.Errorf("%s with %+v: got %q, expected %q", tt.license, tt.policy, v, tt.violations)
+		}
+	}
+}
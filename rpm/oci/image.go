@@ -0,0 +1,324 @@
+// Package oci builds OCI images out of rpm packages without container
+// runtime, payloads are laid into image layout directory
+package oci
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// Media types of image parts
+const (
+	MediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	MediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// AnnotationRefName names manifest in index of layout
+const AnnotationRefName = "org.opencontainers.image.ref.name"
+
+// Descriptor references content of image
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Config is image configuration, only parts set by Build are present
+type Config struct {
+	Created      time.Time `json:"created"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Variant      string    `json:"variant,omitempty"`
+	Config       struct {
+		Env        []string `json:"Env,omitempty"`
+		Entrypoint []string `json:"Entrypoint,omitempty"`
+		Cmd        []string `json:"Cmd,omitempty"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []History `json:"history"`
+}
+
+type History struct {
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// rpm architectures as GOARCH and variant used by OCI
+var platformArches = map[string][2]string{
+	"x86_64":  {"amd64", ""},
+	"i686":    {"386", ""},
+	"aarch64": {"arm64", "v8"},
+	"armv7hl": {"arm", "v7"},
+	"ppc64le": {"ppc64le", ""},
+	"ppc64":   {"ppc64", ""},
+	"s390x":   {"s390x", ""},
+	"riscv64": {"riscv64", ""},
+}
+
+// Options control how image is built
+type Options struct {
+	// Tag is reference name of image in index, manifest with the same
+	// name is replaced
+	Tag string
+	// Squash puts all packages into single layer instead of layer per
+	// package
+	Squash bool
+	// Time is creation time of image, times of files are clamped to
+	// it so that rebuilds produce the same image
+	Time time.Time
+	// Arch is rpm architecture of image, the one of running system if
+	// empty
+	Arch            string
+	Env             []string
+	Entrypoint, Cmd []string
+}
+
+// Build lays payloads of package files into image stored in OCI
+// layout at dir and returns descriptor of its manifest. Packages are
+// laid in order given, dependencies first. Owners are taken from
+// /etc/passwd and /etc/group of packages laid before.
+func Build(dir string, files []string, opts *Options) (*Descriptor, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	created := opts.Time.UTC().Truncate(time.Second)
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644); err != nil {
+		return nil, err
+	}
+
+	c := &Config{Created: created, Architecture: runtime.GOARCH, OS: "linux"}
+	if a, ok := platformArches[opts.Arch]; ok {
+		c.Architecture, c.Variant = a[0], a[1]
+	} else if len(opts.Arch) > 0 {
+		c.Architecture = opts.Arch
+	}
+	c.Config.Env, c.Config.Entrypoint, c.Config.Cmd = opts.Env, opts.Entrypoint, opts.Cmd
+	c.RootFS.Type = "layers"
+	m := &Manifest{SchemaVersion: 2, MediaType: MediaTypeManifest}
+
+	t := newTree(created)
+	for len(files) > 0 {
+		n := 1
+		if opts.Squash {
+			n = len(files)
+		}
+		desc, diffID, nevras, err := writeLayer(blobs, t, files[:n])
+		if err != nil {
+			return nil, err
+		}
+		files = files[n:]
+		m.Layers = append(m.Layers, *desc)
+		c.RootFS.DiffIDs = append(c.RootFS.DiffIDs, diffID)
+		c.History = append(c.History, History{created, "ypkg oci " + strings.Join(nevras, " ")})
+	}
+	if m.Layers == nil {
+		m.Layers = []Descriptor{}
+		c.RootFS.DiffIDs = []string{}
+		c.History = []History{}
+	}
+
+	config, err := writeJSON(blobs, MediaTypeConfig, c)
+	if err != nil {
+		return nil, err
+	}
+	m.Config = *config
+	desc, err := writeJSON(blobs, MediaTypeManifest, m)
+	if err != nil {
+		return nil, err
+	}
+	desc.Platform = &Platform{Architecture: c.Architecture, OS: c.OS, Variant: c.Variant}
+	if len(opts.Tag) > 0 {
+		desc.Annotations = map[string]string{AnnotationRefName: opts.Tag}
+	}
+	return desc, updateIndex(dir, desc)
+}
+
+// writeLayer writes gzipped layer of packages into blobs and returns
+// its descriptor, digest of uncompressed tar and packages it has
+func writeLayer(blobs string, t *tree, files []string) (*Descriptor, string, []string, error) {
+	b, err := newBlob(blobs)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer b.discard()
+	diff := sha256.New()
+	gz := gzip.NewWriter(b)
+	l := t.newLayer(io.MultiWriter(gz, diff))
+	var nevras []string
+	for _, fname := range files {
+		nevra, err := l.addFile(fname)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		nevras = append(nevras, nevra)
+	}
+	if err := l.Close(); err != nil {
+		return nil, "", nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", nil, err
+	}
+	desc, err := b.commit(MediaTypeLayer)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return desc, digest(diff), nevras, nil
+}
+
+// addFile writes payload of package file into layer, payload is
+// streamed from the file
+func (l *layer) addFile(fname string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	pkg, err := rpmutil.ReadPackage(bufio.NewReader(f))
+	if err != nil {
+		return "", &os.PathError{Op: "read", Path: fname, Err: err}
+	}
+	if err := l.add(pkg); err != nil {
+		return "", &os.PathError{Op: "layer", Path: fname, Err: err}
+	}
+	return rpmutil.HeaderNVRA(pkg.Header), nil
+}
+
+func writeJSON(blobs, mediaType string, v interface{}) (*Descriptor, error) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newBlob(blobs)
+	if err != nil {
+		return nil, err
+	}
+	defer b.discard()
+	if _, err := b.Write(d); err != nil {
+		return nil, err
+	}
+	return b.commit(mediaType)
+}
+
+// updateIndex adds manifest to index of layout, manifest of the same
+// name is replaced
+func updateIndex(dir string, desc *Descriptor) error {
+	fname := filepath.Join(dir, "index.json")
+	idx := &Index{SchemaVersion: 2, MediaType: MediaTypeIndex}
+	if d, err := ioutil.ReadFile(fname); err == nil {
+		if err := json.Unmarshal(d, idx); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	ref := desc.Annotations[AnnotationRefName]
+	manifests := []Descriptor{}
+	for _, m := range idx.Manifests {
+		if len(ref) == 0 || m.Annotations[AnnotationRefName] != ref {
+			manifests = append(manifests, m)
+		}
+	}
+	idx.Manifests = append(manifests, *desc)
+	d, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	tmp := fname + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fname)
+}
+
+// blob is content being written to blobs, it's named by its digest
+// once committed
+type blob struct {
+	f    *os.File
+	h    hash.Hash
+	size int64
+}
+
+func newBlob(blobs string) (*blob, error) {
+	f, err := ioutil.TempFile(blobs, ".blob-")
+	if err != nil {
+		return nil, err
+	}
+	return &blob{f: f, h: sha256.New()}, nil
+}
+
+func (b *blob) Write(d []byte) (int, error) {
+	n, err := b.f.Write(d)
+	b.h.Write(d[:n])
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *blob) commit(mediaType string) (*Descriptor, error) {
+	if err := b.f.Chmod(0644); err != nil {
+		return nil, err
+	}
+	if err := b.f.Close(); err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(b.h.Sum(nil))
+	if err := os.Rename(b.f.Name(), filepath.Join(filepath.Dir(b.f.Name()), sum)); err != nil {
+		return nil, err
+	}
+	b.f = nil
+	return &Descriptor{MediaType: mediaType, Digest: "sha256:" + sum, Size: b.size}, nil
+}
+
+// discard removes blob unless it's committed
+func (b *blob) discard() {
+	if b.f != nil {
+		b.f.Close()
+		os.Remove(b.f.Name())
+	}
+}
+
+func digest(h hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,277 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/archive/cpio"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+const (
+	modeTypeMask = 0xf000
+	modeDir      = 0x4000
+	modeReg      = 0x8000
+	modeLink     = 0xa000
+	modeChr      = 0x2000
+	modeBlk      = 0x6000
+	modeFifo     = 0x1000
+	// maxLinks bounds symlinks followed resolving single path
+	maxLinks = 40
+)
+
+// tree tracks directories and symlinks of image across its layers,
+// paths of packages are resolved through symlinks the way they would
+// be when installed
+type tree struct {
+	mtime         time.Time
+	dirs          map[string]bool
+	links         map[string]string
+	users, groups map[string]int
+}
+
+func newTree(mtime time.Time) *tree {
+	return &tree{
+		mtime:  mtime,
+		dirs:   map[string]bool{"/": true},
+		links:  map[string]string{},
+		users:  map[string]int{"root": 0},
+		groups: map[string]int{"root": 0},
+	}
+}
+
+// resolve resolves symlinks of parent directories of absolute name
+func (t *tree) resolve(name string) string {
+	parts := strings.Split(path.Dir(name), "/")
+	cur := "/"
+	for n := 0; len(parts) > 0; {
+		p := parts[0]
+		parts = parts[1:]
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			cur = path.Dir(cur)
+			continue
+		}
+		next := path.Join(cur, p)
+		if target, ok := t.links[next]; ok && n < maxLinks {
+			n++
+			if path.IsAbs(target) {
+				cur = "/"
+			}
+			parts = append(strings.Split(target, "/"), parts...)
+			continue
+		}
+		cur = next
+	}
+	return path.Join(cur, path.Base(name))
+}
+
+// layer writes payloads of packages as single layer tar
+type layer struct {
+	*tree
+	tw *tar.Writer
+	// inodes are names hardlinked inodes were written as, pending
+	// are links seen before data of their inode
+	inodes  map[int64]string
+	pending map[int64][]*tar.Header
+}
+
+func (t *tree) newLayer(w io.Writer) *layer {
+	return &layer{tree: t, tw: tar.NewWriter(w)}
+}
+
+func (l *layer) Close() error {
+	return l.tw.Close()
+}
+
+// add writes payload of package into layer, payload is consumed
+func (l *layer) add(pkg *rpmutil.Package) error {
+	files, err := pkg.Files()
+	if err != nil {
+		return err
+	}
+	byName := map[string]*rpmutil.FileInfo{}
+	for i := range files {
+		byName[files[i].Name] = &files[i]
+	}
+	pl, err := pkg.Payload()
+	if err != nil {
+		return err
+	}
+	l.inodes, l.pending = map[int64]string{}, map[int64][]*tar.Header{}
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + strings.TrimPrefix(h.Name, "."))
+		f := byName[name]
+		if f == nil {
+			f = &rpmutil.FileInfo{Name: name, Mode: uint16(h.Mode), MTime: h.Mtime}
+		}
+		if f.Flags&rpm.FileGhost != 0 || name == "/" {
+			continue
+		}
+		if err := l.entry(l.resolve(name), h, r, f); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *layer) entry(name string, h *cpio.Header, r io.Reader, f *rpmutil.FileInfo) error {
+	if err := l.parents(path.Dir(name)); err != nil {
+		return err
+	}
+	th := &tar.Header{
+		Name:    name[1:],
+		Mode:    int64(h.Mode & 07777),
+		Uid:     l.users[f.User],
+		Gid:     l.groups[f.Group],
+		Uname:   f.User,
+		Gname:   f.Group,
+		ModTime: l.clamp(h.Mtime),
+	}
+	switch h.Mode & modeTypeMask {
+	case modeDir:
+		// directory replaced by symlink stays symlink
+		if _, ok := l.links[name]; ok {
+			return nil
+		}
+		l.dirs[name] = true
+		th.Typeflag = tar.TypeDir
+		th.Name += "/"
+		return l.tw.WriteHeader(th)
+	case modeReg:
+		delete(l.links, name)
+		th.Typeflag = tar.TypeReg
+		// data of hardlinked files comes with the last link, empty
+		// entries before it are written as links once it's written
+		if h.Links > 1 {
+			if first, ok := l.inodes[h.Inode]; ok {
+				th.Typeflag, th.Linkname = tar.TypeLink, first
+				return l.tw.WriteHeader(th)
+			}
+			if h.Size == 0 && f.Size > 0 {
+				l.pending[h.Inode] = append(l.pending[h.Inode], th)
+				return nil
+			}
+		}
+		th.Size = h.Size
+		if name == "/etc/passwd" || name == "/etc/group" {
+			d, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if name == "/etc/passwd" {
+				l.users = parseIDs(d)
+			} else {
+				l.groups = parseIDs(d)
+			}
+			r = bytes.NewReader(d)
+		}
+		if err := l.tw.WriteHeader(th); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(l.tw, r, h.Size); err != nil {
+			return err
+		}
+		if h.Links > 1 {
+			l.inodes[h.Inode] = th.Name
+			for _, p := range l.pending[h.Inode] {
+				p.Typeflag, p.Linkname = tar.TypeLink, th.Name
+				if err := l.tw.WriteHeader(p); err != nil {
+					return err
+				}
+			}
+			delete(l.pending, h.Inode)
+		}
+		return nil
+	case modeLink:
+		target, err := ioutil.ReadAll(io.LimitReader(r, 4096))
+		if err != nil {
+			return err
+		}
+		if len(target) == 0 {
+			target = []byte(f.LinkTo)
+		}
+		delete(l.dirs, name)
+		l.links[name] = string(target)
+		th.Typeflag, th.Linkname = tar.TypeSymlink, string(target)
+		return l.tw.WriteHeader(th)
+	case modeChr, modeBlk:
+		th.Typeflag = tar.TypeChar
+		if h.Mode&modeTypeMask == modeBlk {
+			th.Typeflag = tar.TypeBlock
+		}
+		// header keeps only 16 bits of device number, payload has
+		// all of it
+		th.Devmajor, th.Devminor = int64(h.RdevMajor), int64(h.RdevMinor)
+		return l.tw.WriteHeader(th)
+	case modeFifo:
+		th.Typeflag = tar.TypeFifo
+		return l.tw.WriteHeader(th)
+	}
+	// sockets can't be stored in tar
+	return nil
+}
+
+// parents writes directories leading to dir which no layer has yet
+func (l *layer) parents(dir string) error {
+	if l.dirs[dir] {
+		return nil
+	}
+	if err := l.parents(path.Dir(dir)); err != nil {
+		return err
+	}
+	l.dirs[dir] = true
+	return l.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir[1:] + "/",
+		Mode:     0755,
+		Uname:    "root",
+		Gname:    "root",
+		ModTime:  l.mtime,
+	})
+}
+
+// clamp limits time to mtime of tree, the way SOURCE_DATE_EPOCH
+// does, so that images built at different times are the same
+func (t *tree) clamp(mtime time.Time) time.Time {
+	if mtime.After(t.mtime) {
+		return t.mtime
+	}
+	return mtime
+}
+
+// parseIDs reads ids of names from passwd or group file
+func parseIDs(d []byte) map[string]int {
+	ids := map[string]int{"root": 0}
+	s := bufio.NewScanner(bytes.NewReader(d))
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		if _, ok := ids[fields[0]]; !ok {
+			ids[fields[0]] = int(id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,325 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var buildTime = time.Unix(1700000000, 0).UTC()
+
+// writePackage writes package name-1.0-1.noarch of files into dir
+// and returns its path
+func writePackage(t *testing.T, dir, name string, files ...rpmtest.File) string {
+	t.Helper()
+	h := rpmtest.Header(name, files...)
+	var b bytes.Buffer
+	lead := rpm.NewLead(name+"-1.0-1", "noarch", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(rpmtest.Payload(files...))); err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(dir, name+"-1.0-1.noarch.rpm")
+	if err := ioutil.WriteFile(fname, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+// entry is tar entry of layer, data of regular files is kept
+type entry struct {
+	tar.Header
+	Data string
+}
+
+func readBlob(t *testing.T, dir string, desc Descriptor, v interface{}) []byte {
+	t.Helper()
+	d, err := ioutil.ReadFile(filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(desc.Digest, "sha256:")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(d)) != desc.Size {
+		t.Fatalf("%s: size %d, expected %d", desc.Digest, len(d), desc.Size)
+	}
+	if v != nil {
+		if err := json.Unmarshal(d, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+// readImage reads manifest, config and layers of image in layout at
+// dir
+func readImage(t *testing.T, dir string, desc *Descriptor) (*Manifest, *Config, [][]entry) {
+	t.Helper()
+	var m Manifest
+	readBlob(t, dir, *desc, &m)
+	var c Config
+	readBlob(t, dir, m.Config, &c)
+	var layers [][]entry
+	for _, l := range m.Layers {
+		gz, err := gzip.NewReader(bytes.NewReader(readBlob(t, dir, l, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []entry
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry{*h, string(d)})
+		}
+		layers = append(layers, entries)
+	}
+	return &m, &c, layers
+}
+
+// names lists entries of layer as "name type [linkname]"
+func names(entries []entry) []string {
+	var res []string
+	for _, e := range entries {
+		s := e.Name + " " + string(e.Typeflag)
+		if len(e.Linkname) > 0 {
+			s += " " + e.Linkname
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestBuildLayers(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := []string{
+		writePackage(t, dir, "a", rpmtest.File{Name: "/usr/share/a", Mode: 0100644, Data: "a"}),
+		writePackage(t, dir, "b", rpmtest.File{Name: "/usr/share/b", Mode: 0100644, Data: "b"}),
+		writePackage(t, dir, "c", rpmtest.File{Name: "/etc/c", Mode: 0100600, Data: "c"}),
+	}
+
+	for _, squash := range []bool{false, true} {
+		layout := filepath.Join(dir, "image")
+		desc, err := Build(layout, files, &Options{Squash: squash, Time: buildTime, Arch: "aarch64", Tag: "latest"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Platform.Architecture != "arm64" || desc.Platform.Variant != "v8" || desc.Annotations[AnnotationRefName] != "latest" {
+			t.Errorf("squash %v: got %+v", squash, desc)
+		}
+		m, c, layers := readImage(t, layout, desc)
+		expected := [][]string{
+			{"usr/ 5", "usr/share/ 5", "usr/share/a 0"},
+			{"usr/share/b 0"},
+			{"etc/ 5", "etc/c 0"},
+		}
+		history := []string{"ypkg oci a-1.0-1.noarch", "ypkg oci b-1.0-1.noarch", "ypkg oci c-1.0-1.noarch"}
+		if squash {
+			expected = [][]string{{"usr/ 5", "usr/share/ 5", "usr/share/a 0", "usr/share/b 0", "etc/ 5", "etc/c 0"}}
+			history = []string{"ypkg oci a-1.0-1.noarch b-1.0-1.noarch c-1.0-1.noarch"}
+		}
+		var got [][]string
+		for _, l := range layers {
+			got = append(got, names(l))
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("squash %v: got layers %v, expected %v", squash, got, expected)
+		}
+		var createdBy []string
+		for _, h := range c.History {
+			createdBy = append(createdBy, h.CreatedBy)
+			if !h.Created.Equal(buildTime) {
+				t.Errorf("squash %v: history created %v", squash, h.Created)
+			}
+		}
+		if !reflect.DeepEqual(createdBy, history) {
+			t.Errorf("squash %v: got history %q, expected %q", squash, createdBy, history)
+		}
+		if len(c.RootFS.DiffIDs) != len(m.Layers) || !c.Created.Equal(buildTime) {
+			t.Errorf("squash %v: got config %+v", squash, c)
+		}
+
+		// image of the same tag replaces previous one
+		var idx Index
+		d, err := ioutil.ReadFile(filepath.Join(layout, "index.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(d, &idx); err != nil {
+			t.Fatal(err)
+		}
+		if len(idx.Manifests) != 1 || idx.Manifests[0].Digest != desc.Digest {
+			t.Errorf("squash %v: got index %+v", squash, idx)
+		}
+	}
+}
+
+func TestBuildReproducible(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := []string{
+		writePackage(t, dir, "a",
+			rpmtest.File{Name: "/usr/share/old", Mode: 0100644, Data: "old", MTime: 1600000000},
+			rpmtest.File{Name: "/usr/share/new", Mode: 0100644, Data: "new", MTime: 1800000000}),
+		writePackage(t, dir, "b", rpmtest.File{Name: "/usr/share/b", Mode: 0100644, Data: "b", MTime: 1800000000}),
+	}
+
+	var descs []*Descriptor
+	for _, layout := range []string{"one", "two"} {
+		if len(descs) > 0 {
+			// nothing depends on time of build
+			time.Sleep(1100 * time.Millisecond)
+			now := time.Now()
+			for _, f := range files {
+				os.Chtimes(f, now, now)
+			}
+		}
+		desc, err := Build(filepath.Join(dir, layout), files, &Options{Time: buildTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		descs = append(descs, desc)
+	}
+	if descs[0].Digest != descs[1].Digest {
+		t.Errorf("got manifests %s and %s", descs[0].Digest, descs[1].Digest)
+	}
+
+	_, _, layers := readImage(t, filepath.Join(dir, "one"), descs[0])
+	mtimes := map[string]time.Time{}
+	for _, l := range layers {
+		for _, e := range l {
+			mtimes[e.Name] = e.ModTime
+		}
+	}
+	// times of files are clamped to time of image
+	expected := map[string]time.Time{
+		"usr/":          buildTime,
+		"usr/share/":    buildTime,
+		"usr/share/old": time.Unix(1600000000, 0),
+		"usr/share/new": buildTime,
+		"usr/share/b":   buildTime,
+	}
+	for name, mtime := range expected {
+		if !mtimes[name].Equal(mtime) {
+			t.Errorf("%s: got mtime %v, expected %v", name, mtimes[name], mtime)
+		}
+	}
+}
+
+func TestBuildSymlinkedDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := []string{
+		writePackage(t, dir, "filesystem",
+			rpmtest.File{Name: "/usr/lib", Mode: 040755},
+			rpmtest.File{Name: "/lib", Mode: 0120777, Data: "usr/lib"}),
+		// packages laid later see /lib as symlink
+		writePackage(t, dir, "old",
+			rpmtest.File{Name: "/lib", Mode: 040755},
+			rpmtest.File{Name: "/lib/libold.so", Mode: 0100755, Data: "old"}),
+		// symlink replaces directory of earlier package
+		writePackage(t, dir, "opt",
+			rpmtest.File{Name: "/opt/app", Mode: 040755},
+			rpmtest.File{Name: "/opt/app/bin", Mode: 0100755, Data: "bin"}),
+		writePackage(t, dir, "optlink", rpmtest.File{Name: "/opt/app", Mode: 0120777, Data: "/srv/app"}),
+		writePackage(t, dir, "srv",
+			rpmtest.File{Name: "/srv/app", Mode: 040755},
+			rpmtest.File{Name: "/opt/app/data", Mode: 0100644, Data: "data"}),
+	}
+	desc, err := Build(filepath.Join(dir, "image"), files, &Options{Time: buildTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, layers := readImage(t, filepath.Join(dir, "image"), desc)
+	var got [][]string
+	for _, l := range layers {
+		got = append(got, names(l))
+	}
+	expected := [][]string{
+		{"usr/ 5", "usr/lib/ 5", "lib 2 usr/lib"},
+		{"usr/lib/libold.so 0"},
+		{"opt/ 5", "opt/app/ 5", "opt/app/bin 0"},
+		{"opt/app 2 /srv/app"},
+		{"srv/ 5", "srv/app/ 5", "srv/app/data 0"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got layers %v, expected %v", got, expected)
+	}
+}
+
+func TestBuildSpecialFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := []string{
+		writePackage(t, dir, "a",
+			// data of hardlinks comes with the last of them
+			rpmtest.File{Name: "/usr/bin/a", Mode: 0100755, Data: "tool", Ino: 7},
+			rpmtest.File{Name: "/usr/bin/b", Mode: 0100755, Data: "tool", Ino: 7},
+			rpmtest.File{Name: "/usr/bin/c", Mode: 0100755, Data: "tool", Ino: 7},
+			// numbers don't fit 16 bits header keeps
+			rpmtest.File{Name: "/dev/nvme", Mode: 020600, RdevMajor: 259, RdevMinor: 300},
+			rpmtest.File{Name: "/dev/loop", Mode: 060660, RdevMajor: 7, RdevMinor: 1},
+			rpmtest.File{Name: "/run/fifo", Mode: 010644},
+			rpmtest.File{Name: "/var/log/a.log", Mode: 0100644, Flags: rpm.FileGhost}),
+	}
+	desc, err := Build(filepath.Join(dir, "image"), files, &Options{Time: buildTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, layers := readImage(t, filepath.Join(dir, "image"), desc)
+	if len(layers) != 1 {
+		t.Fatalf("got %d layers", len(layers))
+	}
+	expected := []string{
+		"usr/ 5", "usr/bin/ 5", "usr/bin/c 0", "usr/bin/a 1 usr/bin/c", "usr/bin/b 1 usr/bin/c",
+		"dev/ 5", "dev/nvme 3", "dev/loop 4", "run/ 5", "run/fifo 6",
+	}
+	if got := names(layers[0]); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	for _, e := range layers[0] {
+		switch e.Name {
+		case "usr/bin/c":
+			if e.Data != "tool" || e.Mode != 0755 {
+				t.Errorf("%s: got %q mode %o", e.Name, e.Data, e.Mode)
+			}
+		case "dev/nvme":
+			if e.Devmajor != 259 || e.Devminor != 300 {
+				t.Errorf("%s: got %d:%d, expected 259:300", e.Name, e.Devmajor, e.Devminor)
+			}
+		case "dev/loop":
+			if e.Devmajor != 7 || e.Devminor != 1 {
+				t.Errorf("%s: got %d:%d, expected 7:1", e.Name, e.Devmajor, e.Devminor)
+			}
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// CreateOptions control generation of repository metadata
+type CreateOptions struct {
+	// Workers is number of packages read in parallel, number of CPUs
+	// if not set
+	Workers int
+	// Update reuses entries of existing metadata for packages whose
+	// size and mtime didn't change
+	Update bool
+	// Checksum is type of checksums of packages and metadata,
+	// sha256 if not set
+	Checksum string
+	// ChangelogLimit keeps only that many newest changelog entries,
+	// all are kept if zero
+	ChangelogLimit int
+	// KeepMetadata keeps metadata Create doesn't generate, like
+	// updateinfo, of previous repodata
+	KeepMetadata bool
+}
+
+// CreateResult describes generated metadata
+type CreateResult struct {
+	MD       *RepoMD
+	Packages int
+	// Reused is number of packages taken from previous metadata
+	Reused int
+}
+
+// Create scans dir for packages and writes metadata of repository
+// into its repodata directory, which is replaced as a whole once new
+// metadata are complete
+func Create(dir string, opts *CreateOptions) (*CreateResult, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	sumType := opts.Checksum
+	if len(sumType) == 0 {
+		sumType = "sha256"
+	}
+	if _, ok := checksumAlgos[sumType]; !ok {
+		return nil, fmt.Errorf("%w: checksum %s", ErrUnsupported, sumType)
+	}
+
+	hrefs, err := findPackages(dir)
+	if err != nil {
+		return nil, err
+	}
+	old := map[string]*Package{}
+	if opts.Update {
+		if old, err = previousPackages(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &CreateResult{Packages: len(hrefs)}
+	pkgs := make([]*Package, len(hrefs))
+	var todo []int
+	for i, href := range hrefs {
+		fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(href)))
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := old[href]; ok && p.Checksum.Type == sumType &&
+			p.PackageSize == fi.Size() && p.FileTime.Unix() == fi.ModTime().Unix() {
+			pkgs[i] = p
+			res.Reused++
+			continue
+		}
+		todo = append(todo, i)
+	}
+	if err := readPackages(dir, hrefs, pkgs, todo, sumType, opts); err != nil {
+		return nil, err
+	}
+
+	var keep []Data
+	if opts.KeepMetadata {
+		if keep, err = keptMetadata(dir); err != nil {
+			return nil, err
+		}
+	}
+	res.MD, err = writeMetadata(dir, pkgs, sumType, keep)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// findPackages returns paths of packages in dir relative to it,
+// sorted
+func findPackages(dir string) ([]string, error) {
+	var hrefs []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && (fi.Name() == "repodata" || strings.HasPrefix(fi.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(fi.Name(), ".rpm") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hrefs = append(hrefs, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(hrefs)
+	return hrefs, nil
+}
+
+// previousPackages loads existing metadata of dir keyed by location,
+// there's nothing to reuse when dir has no metadata yet
+func previousPackages(dir string) (map[string]*Package, error) {
+	r, err := OpenSource(DirSource(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*Package{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := r.Load(&LoadOptions{Filelists: true, Other: true})
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]*Package{}
+	for _, p := range pkgs {
+		if len(p.Location.Base) == 0 {
+			res[p.Location.Href] = p
+		}
+	}
+	return res, nil
+}
+
+// readPackages reads packages of hrefs selected by todo into pkgs
+// using worker pool
+func readPackages(dir string, hrefs []string, pkgs []*Package, todo []int, sumType string, opts *CreateOptions) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	errs := make([]error, len(hrefs))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pkgs[i], errs[i] = ReadPackageFile(dir, hrefs[i], sumType, opts.ChangelogLimit)
+			}
+		}()
+	}
+	for _, i := range todo {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPackageFile reads package at href in repository dir into
+// metadata entry, checksum of type sumType becomes its pkgid.
+// Changelog is limited to changelogLimit newest entries unless it's
+// zero.
+func ReadPackageFile(dir, href, sumType string, changelogLimit int) (*Package, error) {
+	algo, ok := checksumAlgos[sumType]
+	if !ok {
+		return nil, fmt.Errorf("%w: checksum %s", ErrUnsupported, sumType)
+	}
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(href)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// header is read through the hash, rest of package is hashed
+	// afterwards
+	h := rpmutil.NewHash(algo)
+	tr := io.TeeReader(f, h)
+	pkg, err := rpmutil.ReadPackage(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", href, err)
+	}
+	if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+		return nil, fmt.Errorf("%s: %w", href, err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	p, err := PackageFromHeader(pkg, changelogLimit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", href, err)
+	}
+	p.PkgID = sum
+	p.Checksum = Checksum{Type: sumType, Value: sum}
+	p.Location = Location{Href: href}
+	p.PackageSize = fi.Size()
+	p.FileTime = time.Unix(fi.ModTime().Unix(), 0).UTC()
+	return p, nil
+}
+
+// PackageFromHeader returns metadata of package read from its header,
+// checksum and location of package file are left to caller
+func PackageFromHeader(pkg *rpmutil.Package, changelogLimit int) (*Package, error) {
+	hdr := pkg.Header
+	str := func(t rpm.HeaderTag) string {
+		s, _ := hdr.GetString(t)
+		return s
+	}
+	p := &Package{
+		Name:      str(rpm.TagName),
+		Arch:      str(rpm.TagArch),
+		Packager:  str(rpm.TagPackager),
+		URL:       str(rpm.TagURL),
+		License:   str(rpm.TagLicense),
+		Vendor:    str(rpm.TagVendor),
+		BuildHost: str(rpm.TagBuildHost),
+		SourceRPM: str(rpm.TagSourceRPM),
+		Deps:      map[rpm.DependencyKind][]rpm.Dependency{},
+	}
+	p.EVR.Version, p.EVR.Release = str(rpm.TagVersion), str(rpm.TagRelease)
+	if e, err := hdr.GetInt(rpm.TagEpoch); err == nil {
+		p.EVR.Epoch = int(e)
+	}
+	// source packages are told apart by missing SOURCERPM
+	if len(p.SourceRPM) == 0 {
+		p.Arch = "src"
+	}
+	p.Summary, _ = pkg.Summary("")
+	p.Description, _ = pkg.Description("")
+	p.Group, _ = pkg.Group("")
+	if t, err := hdr.GetInt(rpm.TagBuildTime); err == nil {
+		p.BuildTime = time.Unix(t, 0).UTC()
+	}
+	p.HeaderStart, p.HeaderEnd = pkg.HeaderRange()
+	var err error
+	if p.InstalledSize, err = pkg.Size(); err != nil && !errors.Is(err, rpm.ErrTagNotFound) {
+		return nil, err
+	}
+	if p.ArchiveSize, err = pkg.ArchiveSize(); err != nil && !errors.Is(err, rpm.ErrTagNotFound) {
+		return nil, err
+	}
+
+	for _, kind := range rpm.DependencyKinds {
+		deps, err := hdr.Dependencies(kind)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[rpm.Dependency]bool{}
+		for _, d := range deps {
+			if kind == rpm.DepRequires && strings.HasPrefix(d.Name, "rpmlib(") {
+				continue
+			}
+			if !seen[d] {
+				seen[d] = true
+				p.Deps[kind] = append(p.Deps[kind], d)
+			}
+		}
+	}
+
+	files, err := rpmutil.HeaderFiles(hdr)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		file := File{Path: f.Name}
+		switch {
+		case f.Mode&0xf000 == 0x4000:
+			file.Type = FileDir
+		case f.Flags&rpm.FileGhost != 0:
+			file.Type = FileGhost
+		}
+		p.Files = append(p.Files, file)
+	}
+
+	if p.Changelogs, err = headerChangelogs(hdr, changelogLimit); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// headerChangelogs returns changelog entries of header, newest
+// first as rpm stores them
+func headerChangelogs(h *rpm.Header, limit int) ([]Changelog, error) {
+	times, err := h.GetInt32s(rpm.TagChangelogTime)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names, err := h.GetStrings(rpm.TagChangelogName)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := h.GetStrings(rpm.TagChangelogText)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) != len(names) || len(times) != len(texts) {
+		return nil, fmt.Errorf("changelog: %w", rpm.ErrCountMismatch)
+	}
+	n := len(times)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	res := make([]Changelog, n)
+	for i := range res {
+		res[i] = Changelog{Author: names[i], Time: time.Unix(int64(uint32(times[i])), 0).UTC(), Text: texts[i]}
+	}
+	return res, nil
+}
+
+// generated are metadata types Create writes, their sqlite and zchunk
+// flavors get out of date with them
+var generated = map[string]bool{Primary: true, Filelists: true, Other: true}
+
+// keptMetadata returns metadata of previous repodata of dir which
+// Create doesn't generate
+func keptMetadata(dir string) ([]Data, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(RepoMDPath)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	md, err := ParseRepoMD(f)
+	if err != nil {
+		return nil, err
+	}
+	var res []Data
+	for _, d := range md.Data {
+		t := strings.TrimSuffix(strings.TrimSuffix(d.Type, "_zck"), "_db")
+		if !generated[t] {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// writeMetadata writes metadata of pkgs into temporary directory
+// which then replaces repodata of dir, metadata of keep are copied
+// over from repodata
+func writeMetadata(dir string, pkgs []*Package, sumType string, keep []Data) (*RepoMD, error) {
+	tmp, err := ioutil.TempDir(dir, ".repodata-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	md := &RepoMD{Revision: strconv.FormatInt(now, 10)}
+	for _, m := range []struct {
+		t     string
+		write func(io.Writer, []*Package) error
+	}{
+		{Primary, WritePrimary},
+		{Filelists, WriteFilelists},
+		{Other, WriteOther},
+	} {
+		var buf bytes.Buffer
+		if err := m.write(&buf, pkgs); err != nil {
+			return nil, err
+		}
+		d, err := writeData(tmp, m.t, buf.Bytes(), sumType)
+		if err != nil {
+			return nil, err
+		}
+		d.Timestamp = now
+		md.Data = append(md.Data, *d)
+	}
+	for _, d := range keep {
+		if len(d.Location.Base) == 0 && path.Dir(d.Location.Href) == "repodata" {
+			src := filepath.Join(dir, filepath.FromSlash(d.Location.Href))
+			if err := copyFile(src, filepath.Join(tmp, path.Base(d.Location.Href))); err != nil {
+				return nil, err
+			}
+		}
+		md.Data = append(md.Data, d)
+	}
+
+	f, err := os.Create(filepath.Join(tmp, "repomd.xml"))
+	if err != nil {
+		return nil, err
+	}
+	err = WriteRepoMD(f, md)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repodata := filepath.Join(dir, "repodata")
+	old := tmp + ".old"
+	if err := os.Rename(repodata, old); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(tmp, repodata); err != nil {
+		os.Rename(old, repodata)
+		return nil, err
+	}
+	os.RemoveAll(old)
+	return md, nil
+}
+
+func copyFile(src, dest string) error {
+	d, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, d, 0644)
+}
+
+// AddMetadata adds metadata of type t to repository in dir the way
+// modifyrepo does, metadata of that type repository has are replaced
+func AddMetadata(dir, t string, d []byte, sumType string) (*RepoMD, error) {
+	if _, ok := checksumAlgos[sumType]; !ok {
+		return nil, fmt.Errorf("%w: checksum %s", ErrUnsupported, sumType)
+	}
+	repodata := filepath.Join(dir, "repodata")
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(RepoMDPath)))
+	if err != nil {
+		return nil, err
+	}
+	md, err := ParseRepoMD(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	nd, err := writeData(repodata, t, d, sumType)
+	if err != nil {
+		return nil, err
+	}
+	nd.Timestamp = time.Now().Unix()
+	var old *Data
+	for i := range md.Data {
+		if md.Data[i].Type == t {
+			prev := md.Data[i]
+			old = &prev
+			md.Data[i] = *nd
+		}
+	}
+	if old == nil {
+		md.Data = append(md.Data, *nd)
+	}
+
+	tmp, err := ioutil.TempFile(repodata, ".repomd-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	err = WriteRepoMD(tmp, md)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(repodata, "repomd.xml"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if old != nil && len(old.Location.Base) == 0 && old.Location.Href != nd.Location.Href &&
+		path.Dir(old.Location.Href) == "repodata" {
+		os.Remove(filepath.Join(dir, filepath.FromSlash(old.Location.Href)))
+	}
+	return md, nil
+}
+
+// writeData stores gzipped metadata named by its checksum
+func writeData(dir, t string, d []byte, sumType string) (*Data, error) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(d); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	sum, err := Sum(sumType, gz.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	openSum, err := Sum(sumType, d)
+	if err != nil {
+		return nil, err
+	}
+	name := sum + "-" + t + ".xml.gz"
+	if err := ioutil.WriteFile(filepath.Join(dir, name), gz.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	return &Data{
+		Type:         t,
+		Checksum:     Checksum{Type: sumType, Value: sum},
+		OpenChecksum: Checksum{Type: sumType, Value: openSum},
+		Location:     Location{Href: "repodata/" + name},
+		Size:         int64(gz.Len()),
+		OpenSize:     int64(len(d)),
+	}, nil
+}
@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// writePackage writes package of files to dir as href
+func writePackage(t *testing.T, dir, href, name string, files ...rpmtest.File) {
+	t.Helper()
+	h := rpmtest.Header(name, files...)
+	h.SetString(rpm.TagArch, "x86_64")
+	h.SetString(rpm.TagSourceRPM, name+"-1.0-1.src.rpm")
+	h.SetString(rpm.TagLicense, "MIT")
+	h.SetString(rpm.TagSummary, "The "+name+" program")
+	h.SetString(rpm.TagDescription, "Program which says "+name+".")
+	h.SetInt32s(rpm.TagBuildTime, []int32{1700000000})
+	nameTag, flagsTag, versionTag := rpm.DependencyTags(rpm.DepProvides)
+	h.SetStrings(nameTag, []string{name, name + "(x86-64)"})
+	h.SetInt32s(flagsTag, []int32{int32(rpm.SenseEqual), int32(rpm.SenseEqual)})
+	h.SetStrings(versionTag, []string{"1.0-1", "1.0-1"})
+	nameTag, flagsTag, versionTag = rpm.DependencyTags(rpm.DepRequires)
+	h.SetStrings(nameTag, []string{"rpmlib(CompressedFileNames)", "/bin/sh"})
+	h.SetInt32s(flagsTag, []int32{int32(rpm.SenseLess | rpm.SenseEqual | rpm.SenseRpmlib), int32(rpm.SensePrereq)})
+	h.SetStrings(versionTag, []string{"3.0.4-1", ""})
+	h.SetInt32s(rpm.TagChangelogTime, []int32{1690000000, 1680000000})
+	h.SetStrings(rpm.TagChangelogName, []string{"Jane Doe - 1.0-1", "Jane Doe - 0.9-1"})
+	h.SetStrings(rpm.TagChangelogText, []string{"- Update to 1.0", "- Initial package"})
+
+	var b bytes.Buffer
+	lead := rpm.NewLead(name+"-1.0-1", "x86_64", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(rpmtest.Payload(files...))); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(href))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newCreateRepo returns directory with packages hello and world
+func newCreateRepo(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePackage(t, dir, "Packages/hello-1.0-1.x86_64.rpm", "hello",
+		rpmtest.File{Name: "/usr/bin/hello", Mode: 0100755, Data: "#!/bin/sh\necho hello\n"},
+		rpmtest.File{Name: "/usr/share/doc/hello", Mode: 040755},
+		rpmtest.File{Name: "/usr/share/doc/hello/README", Mode: 0100644, Data: "hello"},
+		rpmtest.File{Name: "/var/log/hello.log", Mode: 0100644, Flags: rpm.FileGhost})
+	writePackage(t, dir, "world-1.0-1.x86_64.rpm", "world",
+		rpmtest.File{Name: "/etc/world.conf", Mode: 0100644, Data: "round\n", Flags: rpm.FileConfig})
+	return dir
+}
+
+func TestCreate(t *testing.T) {
+	dir := newCreateRepo(t)
+	defer os.RemoveAll(dir)
+	// not packages or not in repository
+	for _, name := range []string{"README", ".hidden/x-1.0-1.noarch.rpm", "repodata/y-1.0-1.noarch.rpm"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte("junk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := Create(dir, &CreateOptions{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Packages != 2 || res.Reused != 0 {
+		t.Errorf("got %d packages, %d reused, expected 2 and none", res.Packages, res.Reused)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repodata", "y-1.0-1.noarch.rpm")); !os.IsNotExist(err) {
+		t.Errorf("old repodata not replaced: %v", err)
+	}
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.MD.Data) != len(res.MD.Data) {
+		t.Errorf("repomd.xml: got %+v, expected %+v", repo.MD, res.MD)
+	}
+	for _, d := range res.MD.Data {
+		if md := repo.MD.Find(d.Type); md == nil || !reflect.DeepEqual(*md, d) {
+			t.Errorf("repomd.xml: got %+v, expected %+v", md, d)
+		}
+	}
+	pkgs, err := repo.Load(&LoadOptions{Filelists: true, Other: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hrefs := []string{"Packages/hello-1.0-1.x86_64.rpm", "world-1.0-1.x86_64.rpm"}
+	if len(pkgs) != len(hrefs) {
+		t.Fatalf("got %d packages, expected %d", len(pkgs), len(hrefs))
+	}
+	for i, href := range hrefs {
+		expected, err := ReadPackageFile(dir, href, "sha256", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pkgs[i], expected) {
+			t.Errorf("%s: got %+v, expected %+v", href, pkgs[i], expected)
+		}
+	}
+
+	p := pkgs[0]
+	if req := p.Dependencies(rpm.DepRequires); len(req) != 1 || req[0].Name != "/bin/sh" || req[0].Flags&rpm.SensePrereq == 0 {
+		t.Errorf("requires: got %v, expected /bin/sh without rpmlib()", req)
+	}
+	files := []File{
+		{Path: "/usr/bin/hello"},
+		{Path: "/usr/share/doc/hello", Type: FileDir},
+		{Path: "/usr/share/doc/hello/README"},
+		{Path: "/var/log/hello.log", Type: FileGhost},
+	}
+	if !reflect.DeepEqual(p.Files, files) {
+		t.Errorf("files: got %v, expected %v", p.Files, files)
+	}
+	if len(p.Changelogs) != 2 || p.Changelogs[1].Text != "- Initial package" {
+		t.Errorf("changelogs: got %v", p.Changelogs)
+	}
+
+	// primary lists only files of bin and etc directories
+	primary, err := repo.Data(Primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err = ParsePrimary(bytes.NewReader(primary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := pkgs[0].Files; len(files) != 1 || files[0].Path != "/usr/bin/hello" {
+		t.Errorf("primary files: got %v", files)
+	}
+}
+
+func TestCreateChangelogLimit(t *testing.T) {
+	dir := newCreateRepo(t)
+	defer os.RemoveAll(dir)
+	if _, err := Create(dir, &CreateOptions{ChangelogLimit: 1, Checksum: "sha512"}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := repo.Load(&LoadOptions{Other: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pkgs[0]
+	if len(p.Changelogs) != 1 || p.Changelogs[0].Text != "- Update to 1.0" {
+		t.Errorf("got %v, expected newest entry", p.Changelogs)
+	}
+	if p.Checksum.Type != "sha512" || len(p.PkgID) != 128 {
+		t.Errorf("got checksum %+v", p.Checksum)
+	}
+
+	if _, err := Create(dir, &CreateOptions{Checksum: "crc32"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("got %v, expected %v", err, ErrUnsupported)
+	}
+}
+
+func TestCreateUpdate(t *testing.T) {
+	dir := newCreateRepo(t)
+	defer os.RemoveAll(dir)
+	create := func(opts *CreateOptions) *CreateResult {
+		t.Helper()
+		res, err := Create(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// nothing to reuse without previous metadata
+	if res := create(&CreateOptions{Update: true}); res.Reused != 0 {
+		t.Errorf("got %d reused, expected none", res.Reused)
+	}
+	if res := create(&CreateOptions{Update: true}); res.Packages != 2 || res.Reused != 2 {
+		t.Errorf("got %d packages, %d reused, expected 2 and 2", res.Packages, res.Reused)
+	}
+	if res := create(nil); res.Reused != 0 {
+		t.Errorf("got %d reused without update, expected none", res.Reused)
+	}
+
+	// changed and new packages are read
+	hello := filepath.Join(dir, "Packages", "hello-1.0-1.x86_64.rpm")
+	mtime := time.Unix(1600000000, 0)
+	if err := os.Chtimes(hello, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	writePackage(t, dir, "Packages/again-1.0-1.x86_64.rpm", "again",
+		rpmtest.File{Name: "/usr/bin/again", Mode: 0100755, Data: "again"})
+	res := create(&CreateOptions{Update: true})
+	if res.Packages != 3 || res.Reused != 1 {
+		t.Errorf("got %d packages, %d reused, expected 3 and 1", res.Packages, res.Reused)
+	}
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := repo.Load(&LoadOptions{Filelists: true, Other: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range pkgs {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"again", "hello", "world"}) {
+		t.Errorf("got %v", names)
+	}
+	// reused package keeps files and changelogs of previous metadata
+	if w := pkgs[2]; len(w.Files) != 1 || len(w.Changelogs) != 2 {
+		t.Errorf("reused %s: files %v, changelogs %v", w.NEVRA(), w.Files, w.Changelogs)
+	}
+	if !pkgs[1].FileTime.Equal(mtime) {
+		t.Errorf("got mtime %v, expected %v", pkgs[1].FileTime, mtime)
+	}
+
+	// different checksum type invalidates all of them
+	if res := create(&CreateOptions{Update: true, Checksum: "sha1"}); res.Reused != 0 {
+		t.Errorf("got %d reused with other checksum, expected none", res.Reused)
+	}
+}
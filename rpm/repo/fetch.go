@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// Fetch copies package file from repository into w, checksum
+// metadata lists for it is verified. Location base of package
+// overrides src.
+func Fetch(src Source, p *Package, w io.Writer) error {
+	return fetch(src, p.Location, p.Checksum, w)
+}
+
+// FetchDelta copies delta rpm from repository into w, verifying its
+// checksum
+func FetchDelta(src Source, d *Delta, w io.Writer) error {
+	return fetch(src, d.Location, d.Checksum, w)
+}
+
+func fetch(src Source, loc Location, sum Checksum, w io.Writer) error {
+	algo, ok := checksumAlgos[sum.Type]
+	if !ok {
+		return fmt.Errorf("%w: checksum type %s", ErrUnsupported, sum.Type)
+	}
+	if len(loc.Base) > 0 {
+		src = NewSource(loc.Base)
+	}
+	f, err := src.Open(loc.Href)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := rpmutil.NewHash(algo)
+	if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+		return fmt.Errorf("%s: %w", loc.Href, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, strings.TrimSpace(sum.Value)) {
+		return &ChecksumError{Path: loc.Href, Expected: sum, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package repo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// Package is package of repository as metadata describes it
+type Package struct {
+	// PkgID is checksum of package file, key which ties primary,
+	// filelists and other metadata together
+	PkgID       string
+	Checksum    Checksum
+	Name        string
+	Arch        string
+	EVR         rpm.EVR
+	Summary     string
+	Description string
+	Packager    string
+	URL         string
+	FileTime    time.Time
+	BuildTime   time.Time
+
+	PackageSize   int64
+	InstalledSize int64
+	ArchiveSize   int64
+
+	Location  Location
+	License   string
+	Vendor    string
+	Group     string
+	BuildHost string
+	SourceRPM string
+	// HeaderStart and HeaderEnd are range of header in package file
+	HeaderStart int64
+	HeaderEnd   int64
+
+	Deps map[rpm.DependencyKind][]rpm.Dependency
+	// Files are all files with filelists loaded, primary lists just
+	// some of them
+	Files      []File
+	Changelogs []Changelog
+}
+
+// NEVRA returns name-[epoch:]version-release.arch of package
+func (p *Package) NEVRA() string {
+	return p.Name + "-" + p.EVR.String() + "." + p.Arch
+}
+
+// Dependencies returns dependencies of kind
+func (p *Package) Dependencies(kind rpm.DependencyKind) []rpm.Dependency {
+	return p.Deps[kind]
+}
+
+// File types
+const (
+	FileRegular = ""
+	FileDir     = "dir"
+	FileGhost   = "ghost"
+)
+
+// File is path package owns
+type File struct {
+	Path string
+	Type string
+}
+
+// Changelog is %changelog entry of package
+type Changelog struct {
+	Author string
+	Time   time.Time
+	Text   string
+}
+
+type xmlVersion struct {
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+}
+
+func (v *xmlVersion) evr() rpm.EVR {
+	e, _ := strconv.Atoi(v.Epoch)
+	return rpm.EVR{Epoch: e, Version: v.Version, Release: v.Release}
+}
+
+type xmlEntry struct {
+	Name  string `xml:"name,attr"`
+	Flags string `xml:"flags,attr"`
+	xmlVersion
+	Pre string `xml:"pre,attr"`
+}
+
+type xmlFile struct {
+	Type string `xml:"type,attr"`
+	Path string `xml:",chardata"`
+}
+
+type xmlPackage struct {
+	Type     string     `xml:"type,attr"`
+	Name     string     `xml:"name"`
+	Arch     string     `xml:"arch"`
+	Version  xmlVersion `xml:"version"`
+	Checksum struct {
+		Checksum
+		PkgID string `xml:"pkgid,attr"`
+	} `xml:"checksum"`
+	Summary     string `xml:"summary"`
+	Description string `xml:"description"`
+	Packager    string `xml:"packager"`
+	URL         string `xml:"url"`
+	Time        struct {
+		File  int64 `xml:"file,attr"`
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+	Size struct {
+		Package   int64 `xml:"package,attr"`
+		Installed int64 `xml:"installed,attr"`
+		Archive   int64 `xml:"archive,attr"`
+	} `xml:"size"`
+	Location Location `xml:"location"`
+	Format   struct {
+		License     string `xml:"license"`
+		Vendor      string `xml:"vendor"`
+		Group       string `xml:"group"`
+		BuildHost   string `xml:"buildhost"`
+		SourceRPM   string `xml:"sourcerpm"`
+		HeaderRange struct {
+			Start int64 `xml:"start,attr"`
+			End   int64 `xml:"end,attr"`
+		} `xml:"header-range"`
+		Requires    []xmlEntry `xml:"requires>entry"`
+		Provides    []xmlEntry `xml:"provides>entry"`
+		Conflicts   []xmlEntry `xml:"conflicts>entry"`
+		Obsoletes   []xmlEntry `xml:"obsoletes>entry"`
+		Recommends  []xmlEntry `xml:"recommends>entry"`
+		Suggests    []xmlEntry `xml:"suggests>entry"`
+		Supplements []xmlEntry `xml:"supplements>entry"`
+		Enhances    []xmlEntry `xml:"enhances>entry"`
+		Files       []xmlFile  `xml:"file"`
+	} `xml:"format"`
+}
+
+var senseFlags = map[string]rpm.SenseFlags{
+	"EQ": rpm.SenseEqual,
+	"LT": rpm.SenseLess,
+	"LE": rpm.SenseLess | rpm.SenseEqual,
+	"GT": rpm.SenseGreater,
+	"GE": rpm.SenseGreater | rpm.SenseEqual,
+}
+
+// dependency converts rpm-md dependency entry, version is formatted
+// from epoch, version and release parts
+func dependency(name, flags, epoch, version, release string, pre bool) rpm.Dependency {
+	d := rpm.Dependency{Name: name, Flags: senseFlags[flags]}
+	if len(version) > 0 {
+		v := xmlVersion{Epoch: epoch, Version: version, Release: release}
+		d.Version = v.evr().String()
+	}
+	if pre {
+		d.Flags |= rpm.SensePrereq
+	}
+	return d
+}
+
+func entries(es []xmlEntry) []rpm.Dependency {
+	if len(es) == 0 {
+		return nil
+	}
+	deps := make([]rpm.Dependency, len(es))
+	for i, e := range es {
+		deps[i] = dependency(e.Name, e.Flags, e.Epoch, e.Version, e.Release, e.Pre == "1")
+	}
+	return deps
+}
+
+func files(fs []xmlFile) []File {
+	var res []File
+	for _, f := range fs {
+		res = append(res, File{Path: f.Path, Type: f.Type})
+	}
+	return res
+}
+
+func (x *xmlPackage) pkg() *Package {
+	f := &x.Format
+	p := &Package{
+		PkgID:         x.Checksum.Value,
+		Checksum:      x.Checksum.Checksum,
+		Name:          x.Name,
+		Arch:          x.Arch,
+		EVR:           x.Version.evr(),
+		Summary:       x.Summary,
+		Description:   x.Description,
+		Packager:      x.Packager,
+		URL:           x.URL,
+		FileTime:      time.Unix(x.Time.File, 0).UTC(),
+		BuildTime:     time.Unix(x.Time.Build, 0).UTC(),
+		PackageSize:   x.Size.Package,
+		InstalledSize: x.Size.Installed,
+		ArchiveSize:   x.Size.Archive,
+		Location:      x.Location,
+		License:       f.License,
+		Vendor:        f.Vendor,
+		Group:         f.Group,
+		BuildHost:     f.BuildHost,
+		SourceRPM:     f.SourceRPM,
+		HeaderStart:   f.HeaderRange.Start,
+		HeaderEnd:     f.HeaderRange.End,
+		Deps:          map[rpm.DependencyKind][]rpm.Dependency{},
+		Files:         files(f.Files),
+	}
+	for kind, es := range map[rpm.DependencyKind][]xmlEntry{
+		rpm.DepRequires:    f.Requires,
+		rpm.DepProvides:    f.Provides,
+		rpm.DepConflicts:   f.Conflicts,
+		rpm.DepObsoletes:   f.Obsoletes,
+		rpm.DepRecommends:  f.Recommends,
+		rpm.DepSuggests:    f.Suggests,
+		rpm.DepSupplements: f.Supplements,
+		rpm.DepEnhances:    f.Enhances,
+	} {
+		if deps := entries(es); deps != nil {
+			p.Deps[kind] = deps
+		}
+	}
+	return p
+}
+
+// decodePackages calls fn with every <package> element of metadata
+func decodePackages(r io.Reader, fn func(d *xml.Decoder, start *xml.StartElement) error) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "package" {
+			if err := fn(d, &start); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ParsePrimary parses primary.xml
+func ParsePrimary(r io.Reader) ([]*Package, error) {
+	var res []*Package
+	err := decodePackages(r, func(d *xml.Decoder, start *xml.StartElement) error {
+		var x xmlPackage
+		if err := d.DecodeElement(&x, start); err != nil {
+			return err
+		}
+		res = append(res, x.pkg())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+	return res, nil
+}
+
+type xmlExtra struct {
+	PkgID     string     `xml:"pkgid,attr"`
+	Name      string     `xml:"name,attr"`
+	Arch      string     `xml:"arch,attr"`
+	Version   xmlVersion `xml:"version"`
+	Files     []xmlFile  `xml:"file"`
+	Changelog []struct {
+		Author string `xml:"author,attr"`
+		Date   int64  `xml:"date,attr"`
+		Text   string `xml:",chardata"`
+	} `xml:"changelog"`
+}
+
+// ParseFilelists parses filelists.xml into files of packages keyed
+// by pkgid
+func ParseFilelists(r io.Reader) (map[string][]File, error) {
+	res := map[string][]File{}
+	err := decodePackages(r, func(d *xml.Decoder, start *xml.StartElement) error {
+		var x xmlExtra
+		if err := d.DecodeElement(&x, start); err != nil {
+			return err
+		}
+		res[x.PkgID] = files(x.Files)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filelists: %w", err)
+	}
+	return res, nil
+}
+
+// ParseOther parses other.xml into changelogs of packages keyed by
+// pkgid
+func ParseOther(r io.Reader) (map[string][]Changelog, error) {
+	res := map[string][]Changelog{}
+	err := decodePackages(r, func(d *xml.Decoder, start *xml.StartElement) error {
+		var x xmlExtra
+		if err := d.DecodeElement(&x, start); err != nil {
+			return err
+		}
+		var cl []Changelog
+		for _, c := range x.Changelog {
+			cl = append(cl, Changelog{Author: c.Author, Time: time.Unix(c.Date, 0).UTC(), Text: c.Text})
+		}
+		res[x.PkgID] = cl
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("other: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// Delta is delta rpm prestodelta metadata lists, it turns package of
+// version Old into package Name-EVR.Arch
+type Delta struct {
+	Name string
+	Arch string
+	EVR  rpm.EVR
+	Old  rpm.EVR
+	// Location is location of delta rpm, prestodelta has just its
+	// path relative to repository base
+	Location Location
+	// Sequence identifies files of old package delta rpm is built
+	// against
+	Sequence string
+	Size     int64
+	Checksum Checksum
+}
+
+// NEVRA returns name-[epoch:]version-release.arch of package delta
+// rpm results in
+func (d *Delta) NEVRA() string {
+	return d.Name + "-" + d.EVR.String() + "." + d.Arch
+}
+
+type xmlNewPackage struct {
+	Name    string `xml:"name,attr"`
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"version,attr"`
+	Release string `xml:"release,attr"`
+	Arch    string `xml:"arch,attr"`
+	Deltas  []struct {
+		Epoch    string   `xml:"oldepoch,attr"`
+		Version  string   `xml:"oldversion,attr"`
+		Release  string   `xml:"oldrelease,attr"`
+		Filename string   `xml:"filename"`
+		Sequence string   `xml:"sequence"`
+		Size     int64    `xml:"size"`
+		Checksum Checksum `xml:"checksum"`
+	} `xml:"delta"`
+}
+
+func evr(epoch, version, release string) rpm.EVR {
+	e, _ := strconv.Atoi(epoch)
+	return rpm.EVR{Epoch: e, Version: version, Release: release}
+}
+
+// ParsePrestodelta parses prestodelta.xml
+func ParsePrestodelta(r io.Reader) ([]*Delta, error) {
+	var res []*Delta
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("prestodelta: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "newpackage" {
+			continue
+		}
+		var x xmlNewPackage
+		if err := d.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("prestodelta: %w", err)
+		}
+		for _, xd := range x.Deltas {
+			res = append(res, &Delta{
+				Name:     x.Name,
+				Arch:     x.Arch,
+				EVR:      evr(x.Epoch, x.Version, x.Release),
+				Old:      evr(xd.Epoch, xd.Version, xd.Release),
+				Location: Location{Href: xd.Filename},
+				Sequence: xd.Sequence,
+				Size:     xd.Size,
+				Checksum: xd.Checksum,
+			})
+		}
+	}
+}
+
+// Deltas returns delta rpms of repository, none if it has no
+// prestodelta metadata
+func (r *Repo) Deltas() ([]*Delta, error) {
+	if r.MD.Find(Prestodelta) == nil {
+		return nil, nil
+	}
+	d, err := r.Data(Prestodelta)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrestodelta(bytes.NewReader(d))
+}
+
+// FindDelta returns delta rpm which turns installed version old of
+// package into p, nil if there is none
+func FindDelta(deltas []*Delta, p *Package, old rpm.EVR) *Delta {
+	for _, d := range deltas {
+		if d.Name == p.Name && d.Arch == p.Arch && d.EVR.Compare(p.EVR) == 0 && d.Old.Compare(old) == 0 {
+			return d
+		}
+	}
+	return nil
+}
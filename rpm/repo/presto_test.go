@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+const testPrestodelta = `<?xml version="1.0" encoding="UTF-8"?>
+<prestodelta>
+  <newpackage name="hello" epoch="1" version="2.10" release="3.fc38" arch="x86_64">
+    <delta oldepoch="1" oldversion="2.10" oldrelease="1.fc38">
+      <filename>drpms/hello-2.10-1.fc38_2.10-3.fc38.x86_64.drpm</filename>
+      <sequence>hello-1:2.10-1.fc38-0123456789abcdef0123456789abcdef01</sequence>
+      <size>%d</size>
+      <checksum type="sha256">%s</checksum>
+    </delta>
+    <delta oldepoch="0" oldversion="2.8" oldrelease="2.fc37">
+      <filename>drpms/hello-2.8-2.fc37_2.10-3.fc38.x86_64.drpm</filename>
+      <sequence>hello-2.8-2.fc37-fedcba9876543210fedcba987654321001</sequence>
+      <size>1024</size>
+      <checksum type="sha256">4567</checksum>
+    </delta>
+  </newpackage>
+</prestodelta>
+`
+
+func TestDeltas(t *testing.T) {
+	drpm := []byte("delta rpm")
+	r := newTestRepo()
+	defer r.server.Close()
+	href := "drpms/hello-2.10-1.fc38_2.10-3.fc38.x86_64.drpm"
+	r.files[href] = drpm
+	presto := []byte(fmt.Sprintf(testPrestodelta, len(drpm), sha256Hex(drpm)))
+	r.setRepoMD(t, r.add(t, Primary, ".gz", []byte(testPrimary)), r.add(t, Prestodelta, ".gz", presto))
+
+	repo, err := Open(r.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas, err := repo.Deltas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 {
+		t.Fatalf("got %d deltas, expected 2", len(deltas))
+	}
+	d := deltas[0]
+	if d.NEVRA() != "hello-1:2.10-3.fc38.x86_64" || d.Old.String() != "1:2.10-1.fc38" ||
+		d.Location.Href != href || d.Size != int64(len(drpm)) || d.Sequence != "hello-1:2.10-1.fc38-0123456789abcdef0123456789abcdef01" {
+		t.Errorf("got %+v", d)
+	}
+
+	pkgs, err := repo.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found := FindDelta(deltas, pkgs[0], rpm.EVR{Epoch: 1, Version: "2.10", Release: "1.fc38"}); found != d {
+		t.Errorf("got %+v, expected first delta", found)
+	}
+	if found := FindDelta(deltas, pkgs[0], rpm.EVR{Version: "2.10", Release: "1.fc38"}); found != nil {
+		t.Errorf("found %+v for other epoch", found)
+	}
+	if found := FindDelta(deltas, pkgs[1], rpm.EVR{Version: "2.8", Release: "2.fc37"}); found != nil {
+		t.Errorf("found %+v for other package", found)
+	}
+
+	var b bytes.Buffer
+	if err := FetchDelta(repo.Source, d, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), drpm) {
+		t.Errorf("got %q", b.Bytes())
+	}
+	r.files[href] = []byte("changed")
+	if err := FetchDelta(repo.Source, d, &b); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("got %v, expected checksum mismatch", err)
+	}
+}
+
+func TestNoDeltas(t *testing.T) {
+	r := newTestRepo()
+	defer r.server.Close()
+	r.setRepoMD(t, r.add(t, Primary, ".gz", []byte(testPrimary)))
+	repo, err := Open(r.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deltas, err := repo.Deltas(); err != nil || len(deltas) != 0 {
+		t.Errorf("got %v, %v", deltas, err)
+	}
+}
@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Repo is repository with its repomd.xml read
+type Repo struct {
+	Source Source
+	MD     *RepoMD
+}
+
+// Open opens repository at base, http(s) URL or local directory
+func Open(base string) (*Repo, error) {
+	return OpenSource(NewSource(base))
+}
+
+func OpenSource(src Source) (*Repo, error) {
+	f, err := src.Open(RepoMDPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	md, err := ParseRepoMD(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Source: src, MD: md}, nil
+}
+
+// Data returns metadata of type t decompressed, with its checksums
+// verified
+func (r *Repo) Data(t string) ([]byte, error) {
+	d := r.MD.Find(t)
+	if d == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoMetadata, t)
+	}
+	src := r.Source
+	if len(d.Location.Base) > 0 {
+		src = NewSource(d.Location.Base)
+	}
+	return readData(src, d)
+}
+
+// LoadOptions select metadata Load reads
+type LoadOptions struct {
+	// Filelists replaces partial file lists of primary metadata
+	// with complete ones
+	Filelists bool
+	// Other loads changelogs
+	Other bool
+	// SQLite prefers sqlite flavor of metadata over XML
+	SQLite bool
+}
+
+// flavor picks XML or sqlite metadata of kind t, the preferred one if
+// repository has both
+func (r *Repo) flavor(t string, sqlite bool) (string, bool) {
+	db := t + "_db"
+	hasXML, hasDB := r.MD.Find(t) != nil, r.MD.Find(db) != nil
+	if hasDB && (sqlite || !hasXML) {
+		return db, true
+	}
+	return t, false
+}
+
+// Load reads packages of repository
+func (r *Repo) Load(opts *LoadOptions) ([]*Package, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	var pkgs []*Package
+	err := r.load(Primary, opts.SQLite, func(d []byte) (err error) {
+		pkgs, err = ParsePrimary(bytes.NewReader(d))
+		return err
+	}, func(db *sqliteDB) (err error) {
+		pkgs, err = readPrimaryDB(db)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*Package{}
+	for _, p := range pkgs {
+		byID[p.PkgID] = p
+	}
+
+	if opts.Filelists {
+		var files map[string][]File
+		err := r.load(Filelists, opts.SQLite, func(d []byte) (err error) {
+			files, err = ParseFilelists(bytes.NewReader(d))
+			return err
+		}, func(db *sqliteDB) (err error) {
+			files, err = readFilelistsDB(db)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for id, fs := range files {
+			if p, ok := byID[id]; ok {
+				p.Files = fs
+			}
+		}
+	}
+	if opts.Other {
+		var changelogs map[string][]Changelog
+		err := r.load(Other, opts.SQLite, func(d []byte) (err error) {
+			changelogs, err = ParseOther(bytes.NewReader(d))
+			return err
+		}, func(db *sqliteDB) (err error) {
+			changelogs, err = readOtherDB(db)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for id, cl := range changelogs {
+			if p, ok := byID[id]; ok {
+				p.Changelogs = cl
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// load reads metadata of kind t in XML or sqlite flavor
+func (r *Repo) load(t string, sqlite bool, parseXML func(d []byte) error, readDB func(db *sqliteDB) error) error {
+	name, isDB := r.flavor(t, sqlite)
+	d, err := r.Data(name)
+	if err != nil {
+		return err
+	}
+	if !isDB {
+		return parseXML(d)
+	}
+	db, err := openSqlite(d)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer db.Close()
+	if err := readDB(db); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/database/sqlite"
+)
+
+// testFilelists and testOther describe packages of testPrimary, so do
+// sqlite databases of testdata
+const testFilelists = `<?xml version="1.0" encoding="UTF-8"?>
+<filelists xmlns="http://linux.duke.edu/metadata/filelists" packages="2">
+<package pkgid="0123" name="hello" arch="x86_64">
+  <version epoch="1" ver="2.10" rel="3.fc38"/>
+  <file>/usr/bin/hello</file>
+  <file type="dir">/usr/share/doc/hello</file>
+  <file>/usr/share/doc/hello/NEWS</file>
+  <file>/usr/share/doc/hello/README</file>
+  <file type="ghost">/var/log/hello.log</file>
+</package>
+<package pkgid="4567" name="glibc" arch="x86_64">
+  <version epoch="0" ver="2.37" rel="1.fc38"/>
+  <file type="dir">/lib64</file>
+  <file>/lib64/libc.so.6</file>
+</package>
+</filelists>
+`
+
+const testOther = `<?xml version="1.0" encoding="UTF-8"?>
+<otherdata xmlns="http://linux.duke.edu/metadata/other" packages="2">
+<package pkgid="0123" name="hello" arch="x86_64">
+  <version epoch="1" ver="2.10" rel="3.fc38"/>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 2.10-3" date="1690000000">- Rebuilt</changelog>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 2.10-1" date="1680000000">- Update to 2.10
+- Drop patch</changelog>
+</package>
+<package pkgid="4567" name="glibc" arch="x86_64">
+  <version epoch="0" ver="2.37" rel="1.fc38"/>
+</package>
+</otherdata>
+`
+
+var (
+	testHelloFiles = []File{
+		{Path: "/usr/bin/hello"},
+		{Path: "/usr/share/doc/hello", Type: FileDir},
+		{Path: "/usr/share/doc/hello/NEWS"},
+		{Path: "/usr/share/doc/hello/README"},
+		{Path: "/var/log/hello.log", Type: FileGhost},
+	}
+	testHelloChangelogs = []Changelog{
+		{Author: "Jane Doe <jane@example.com> - 2.10-3", Time: time.Unix(1690000000, 0).UTC(), Text: "- Rebuilt"},
+		{Author: "Jane Doe <jane@example.com> - 2.10-1", Time: time.Unix(1680000000, 0).UTC(), Text: "- Update to 2.10\n- Drop patch"},
+	}
+)
+
+func TestParseFilelists(t *testing.T) {
+	files, err := ParseFilelists(strings.NewReader(testFilelists))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files["0123"], testHelloFiles) {
+		t.Errorf("got %v, expected %v", files["0123"], testHelloFiles)
+	}
+	if len(files["4567"]) != 2 {
+		t.Errorf("got %v, expected 2 files of glibc", files["4567"])
+	}
+	if _, err := ParseFilelists(strings.NewReader(`<filelists><package pkgid="0123"><file>`)); err == nil {
+		t.Error("truncated filelists parsed")
+	}
+}
+
+func TestParseOther(t *testing.T) {
+	changelogs, err := ParseOther(strings.NewReader(testOther))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changelogs["0123"], testHelloChangelogs) {
+		t.Errorf("got %v, expected %v", changelogs["0123"], testHelloChangelogs)
+	}
+	if cl, ok := changelogs["4567"]; !ok || len(cl) != 0 {
+		t.Errorf("got %v, expected no changelog of glibc", cl)
+	}
+}
+
+// sqliteRepo serves testPrimary and friends in both XML and sqlite
+// flavors
+func sqliteRepo(t *testing.T, xml bool) *testRepo {
+	t.Helper()
+	r := newTestRepo()
+	var data []*Data
+	for _, m := range []struct {
+		typ, db string
+		open    string
+	}{
+		{Primary, PrimaryDB, testPrimary},
+		{Filelists, FilelistsDB, testFilelists},
+		{Other, OtherDB, testOther},
+	} {
+		if xml {
+			data = append(data, r.add(t, m.typ, ".gz", []byte(m.open)))
+		}
+		db, err := ioutil.ReadFile(filepath.Join("testdata", strings.TrimSuffix(m.db, "_db")+".sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, r.add(t, m.db, ".gz", db))
+	}
+	r.setRepoMD(t, data...)
+	return r
+}
+
+func TestLoadSQLite(t *testing.T) {
+	r := sqliteRepo(t, true)
+	defer r.server.Close()
+	repo, err := Open(r.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []*LoadOptions{nil, {Filelists: true, Other: true}} {
+		var flavors [2][]*Package
+		for i, sqlite := range []bool{false, true} {
+			o := &LoadOptions{SQLite: sqlite}
+			if opts != nil {
+				o.Filelists, o.Other = opts.Filelists, opts.Other
+			}
+			if flavors[i], err = repo.Load(o); err != nil {
+				t.Fatalf("sqlite %v: %v", sqlite, err)
+			}
+		}
+		xmlPkgs, dbPkgs := flavors[0], flavors[1]
+		if len(dbPkgs) != 2 {
+			t.Fatalf("got %d packages, expected 2", len(dbPkgs))
+		}
+		for i := range xmlPkgs {
+			if !reflect.DeepEqual(dbPkgs[i], xmlPkgs[i]) {
+				t.Errorf("%s: sqlite flavor %+v, XML flavor %+v", xmlPkgs[i].NEVRA(), dbPkgs[i], xmlPkgs[i])
+			}
+		}
+		if opts == nil {
+			continue
+		}
+		if p := dbPkgs[0]; !reflect.DeepEqual(p.Files, testHelloFiles) || !reflect.DeepEqual(p.Changelogs, testHelloChangelogs) {
+			t.Errorf("got files %v, changelogs %v", p.Files, p.Changelogs)
+		}
+	}
+}
+
+func TestLoadSQLiteOnly(t *testing.T) {
+	r := sqliteRepo(t, false)
+	defer r.server.Close()
+	repo, err := Open(r.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := repo.Load(&LoadOptions{Filelists: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 || pkgs[0].NEVRA() != "hello-1:2.10-3.fc38.x86_64" || !reflect.DeepEqual(pkgs[0].Files, testHelloFiles) {
+		t.Errorf("got %+v", pkgs)
+	}
+}
+
+func TestLoadSQLiteCorrupt(t *testing.T) {
+	db, err := ioutil.ReadFile(filepath.Join("testdata", "primary.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, d := range map[string][]byte{
+		"truncated":  db[:len(db)/2],
+		"not sqlite": []byte(testPrimary),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newTestRepo()
+			defer r.server.Close()
+			r.setRepoMD(t, r.add(t, PrimaryDB, "", d))
+			repo, err := Open(r.server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = repo.Load(nil)
+			if !errors.Is(err, sqlite.ErrCorrupt) || !strings.HasPrefix(err.Error(), PrimaryDB+": ") {
+				t.Errorf("got %v, expected corrupt %s", err, PrimaryDB)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Package repo reads metadata of yum/dnf (rpm-md) repositories, both
+// XML and sqlite flavors, from local directories or over HTTP.
+// Checksums repomd.xml lists are verified as metadata is read.
+package repo
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/compress/zstd"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrSizeMismatch     = errors.New("size mismatch")
+	ErrUnsupported      = errors.New("unsupported metadata")
+	ErrNoMetadata       = errors.New("metadata not in repository")
+	ErrTooLarge         = errors.New("metadata too large")
+)
+
+// RepoMDPath is path of repomd.xml relative to repository base
+const RepoMDPath = "repodata/repomd.xml"
+
+// Metadata types
+const (
+	Primary     = "primary"
+	Filelists   = "filelists"
+	Other       = "other"
+	PrimaryDB   = "primary_db"
+	FilelistsDB = "filelists_db"
+	OtherDB     = "other_db"
+	Prestodelta = "prestodelta"
+	UpdateInfo  = "updateinfo"
+)
+
+// Checksum is digest of metadata file or package, Type is named the
+// way rpm-md does, "sha256" for example
+type Checksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ChecksumError reports metadata or package file which doesn't match
+// checksum listed for it
+type ChecksumError struct {
+	Path     string
+	Expected Checksum
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s: %s: expected %s %s, got %s", e.Path, ErrChecksumMismatch, e.Expected.Type, e.Expected.Value, e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+var checksumAlgos = map[string]rpm.HashAlgo{
+	"md5":    rpm.HashMD5,
+	"sha":    rpm.HashSHA1,
+	"sha1":   rpm.HashSHA1,
+	"sha224": rpm.HashSHA224,
+	"sha256": rpm.HashSHA256,
+	"sha384": rpm.HashSHA384,
+	"sha512": rpm.HashSHA512,
+}
+
+// ChecksumAlgo returns hash algorithm of checksum type
+func ChecksumAlgo(t string) (rpm.HashAlgo, bool) {
+	algo, ok := checksumAlgos[t]
+	return algo, ok
+}
+
+// Sum computes checksum of d of type t
+func Sum(t string, d []byte) (string, error) {
+	algo, ok := checksumAlgos[t]
+	if !ok {
+		return "", fmt.Errorf("%w: checksum type %s", ErrUnsupported, t)
+	}
+	h := rpmutil.NewHash(algo)
+	h.Write(d)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Location is path of file relative to repository base, Base
+// overrides base of repository when set
+type Location struct {
+	Href string `xml:"href,attr"`
+	Base string `xml:"base,attr,omitempty"`
+}
+
+// Data is metadata file repomd.xml lists
+type Data struct {
+	Type            string   `xml:"type,attr"`
+	Checksum        Checksum `xml:"checksum"`
+	OpenChecksum    Checksum `xml:"open-checksum"`
+	Location        Location `xml:"location"`
+	Timestamp       int64    `xml:"timestamp"`
+	Size            int64    `xml:"size"`
+	OpenSize        int64    `xml:"open-size"`
+	DatabaseVersion int      `xml:"database_version,omitempty"`
+}
+
+// RepoMD is index of repository metadata, repomd.xml
+type RepoMD struct {
+	Revision string   `xml:"revision"`
+	Tags     []string `xml:"tags>content"`
+	Data     []Data   `xml:"data"`
+}
+
+// ParseRepoMD parses repomd.xml
+func ParseRepoMD(r io.Reader) (*RepoMD, error) {
+	var md RepoMD
+	if err := xml.NewDecoder(r).Decode(&md); err != nil {
+		return nil, fmt.Errorf("repomd.xml: %w", err)
+	}
+	return &md, nil
+}
+
+// Find returns metadata of type t, nil if repository has none
+func (md *RepoMD) Find(t string) *Data {
+	for i := range md.Data {
+		if md.Data[i].Type == t {
+			return &md.Data[i]
+		}
+	}
+	return nil
+}
+
+// maxMetadataSize bounds size of metadata files read into memory,
+// both compressed and decompressed
+var maxMetadataSize int64 = 4 << 30
+
+// readAll reads r refusing data larger than maxMetadataSize
+func readAll(r io.Reader) ([]byte, error) {
+	d, err := ioutil.ReadAll(io.LimitReader(r, maxMetadataSize+1))
+	if err == nil && int64(len(d)) > maxMetadataSize {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrTooLarge, maxMetadataSize)
+	}
+	return d, err
+}
+
+// readData reads metadata file, verifies its checksums and returns
+// it decompressed
+func readData(src Source, d *Data) ([]byte, error) {
+	f, err := src.Open(d.Location.Href)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	raw, err := readAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Location.Href, err)
+	}
+	if err := verify(d.Location.Href, raw, d.Size, d.Checksum); err != nil {
+		return nil, err
+	}
+	data, err := decompress(d.Location.Href, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Location.Href, err)
+	}
+	if err := verify(d.Location.Href, data, d.OpenSize, d.OpenChecksum); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// verify checks size and checksum of data, either of them may be
+// missing in repomd.xml
+func verify(path string, d []byte, size int64, sum Checksum) error {
+	if size > 0 && int64(len(d)) != size {
+		return fmt.Errorf("%s: %w: expected %d, got %d", path, ErrSizeMismatch, size, len(d))
+	}
+	if len(sum.Value) == 0 {
+		return nil
+	}
+	actual, err := Sum(sum.Type, d)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !strings.EqualFold(actual, strings.TrimSpace(sum.Value)) {
+		return &ChecksumError{Path: path, Expected: sum, Actual: actual}
+	}
+	return nil
+}
+
+func decompress(path string, d []byte) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(d))
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	case strings.HasSuffix(path, ".xz"):
+		r = xz.NewReader(bytes.NewReader(d))
+	case strings.HasSuffix(path, ".zst"):
+		r = zstd.NewReader(bytes.NewReader(d))
+	case strings.HasSuffix(path, ".bz2"):
+		r = bzip2.NewReader(bytes.NewReader(d))
+	case strings.HasSuffix(path, ".zck"):
+		return nil, fmt.Errorf("%w: zchunk compression", ErrUnsupported)
+	default:
+		return d, nil
+	}
+	return readAll(r)
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source gives access to files of repository by paths relative to
+// its base, like "repodata/repomd.xml"
+type Source interface {
+	Open(path string) (io.ReadCloser, error)
+}
+
+// DirSource is repository in local directory
+type DirSource string
+
+func (d DirSource) Open(path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(path)))
+}
+
+// HTTPSource is repository served over HTTP
+type HTTPSource struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (s *HTTPSource) Open(path string) (io.ReadCloser, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := strings.TrimSuffix(s.BaseURL, "/") + "/" + path
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// NewSource returns source for base, which is either http(s) URL or
+// local directory
+func NewSource(base string) Source {
+	if u, err := url.Parse(base); err == nil {
+		switch u.Scheme {
+		case "http", "https":
+			return &HTTPSource{BaseURL: base}
+		case "file":
+			return DirSource(u.Path)
+		}
+	}
+	return DirSource(base)
+}
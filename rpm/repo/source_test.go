@@ -0,0 +1,295 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/compress/zstd"
+	"code.pikelabs.net/go/rpm"
+)
+
+const testPrimary = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="2">
+<package type="rpm">
+  <name>hello</name>
+  <arch>x86_64</arch>
+  <version epoch="1" ver="2.10" rel="3.fc38"/>
+  <checksum type="sha256" pkgid="YES">0123</checksum>
+  <summary>Prints a familiar, friendly greeting</summary>
+  <location href="Packages/h/hello-2.10-3.fc38.x86_64.rpm"/>
+  <format>
+    <rpm:license>GPL-3.0-or-later</rpm:license>
+    <rpm:provides>
+      <rpm:entry name="hello" flags="EQ" epoch="1" ver="2.10" rel="3.fc38"/>
+    </rpm:provides>
+    <rpm:requires>
+      <rpm:entry name="libc.so.6(GLIBC_2.34)(64bit)"/>
+    </rpm:requires>
+    <file>/usr/bin/hello</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>glibc</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="2.37" rel="1.fc38"/>
+  <checksum type="sha256" pkgid="YES">4567</checksum>
+  <location href="Packages/g/glibc-2.37-1.fc38.x86_64.rpm"/>
+  <format>
+    <rpm:provides>
+      <rpm:entry name="libc.so.6(GLIBC_2.34)(64bit)"/>
+    </rpm:provides>
+  </format>
+</package>
+</metadata>
+`
+
+func sha256Hex(d []byte) string {
+	sum := sha256.Sum256(d)
+	return hex.EncodeToString(sum[:])
+}
+
+// compress compresses d the way file extension ext says, tests are
+// skipped if compressor isn't installed
+func compress(t *testing.T, ext string, d []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch ext {
+	case "":
+		return d
+	case ".gz":
+		w = gzip.NewWriter(&b)
+	case ".xz", ".zst":
+		tool := map[string]string{".xz": "xz", ".zst": "zstd"}[ext]
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+		if ext == ".xz" {
+			w = xz.NewWriter(&b, 6)
+		} else {
+			w = zstd.NewWriter(&b, 3)
+		}
+	default:
+		t.Fatalf("unknown extension %s", ext)
+	}
+	if _, err := w.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// testRepo is repository served by httptest server, files are keyed
+// by path relative to base
+type testRepo struct {
+	files  map[string][]byte
+	server *httptest.Server
+}
+
+func newTestRepo() *testRepo {
+	r := &testRepo{files: map[string][]byte{}}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		d, ok := r.files[strings.TrimPrefix(req.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(d)
+	}))
+	return r
+}
+
+// add adds metadata of type t compressed the way ext says and lists it
+// in repomd.xml with correct checksums, sqlite flavors of metadata
+// are named .sqlite
+func (r *testRepo) add(t *testing.T, typ, ext string, open []byte) *Data {
+	t.Helper()
+	d := compress(t, ext, open)
+	name := typ + ".xml"
+	if strings.HasSuffix(typ, "_db") {
+		name = strings.TrimSuffix(typ, "_db") + ".sqlite"
+	}
+	href := fmt.Sprintf("repodata/%s-%s%s", sha256Hex(d), name, ext)
+	r.files[href] = d
+	data := Data{
+		Type:     typ,
+		Checksum: Checksum{Type: "sha256", Value: sha256Hex(d)},
+		Location: Location{Href: href},
+		Size:     int64(len(d)),
+	}
+	if ext != "" {
+		data.OpenChecksum = Checksum{Type: "sha256", Value: sha256Hex(open)}
+		data.OpenSize = int64(len(open))
+	}
+	return &data
+}
+
+func (r *testRepo) setRepoMD(t *testing.T, data ...*Data) {
+	t.Helper()
+	md := &RepoMD{Revision: "1700000000"}
+	for _, d := range data {
+		md.Data = append(md.Data, *d)
+	}
+	var b bytes.Buffer
+	if err := WriteRepoMD(&b, md); err != nil {
+		t.Fatal(err)
+	}
+	r.files[RepoMDPath] = b.Bytes()
+}
+
+func TestHTTPRepoMD(t *testing.T) {
+	r := newTestRepo()
+	defer r.server.Close()
+	r.setRepoMD(t, r.add(t, Primary, ".gz", []byte(testPrimary)))
+
+	repo, err := Open(r.server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.Source.(*HTTPSource); !ok {
+		t.Errorf("got source %T, expected HTTP one", repo.Source)
+	}
+	if repo.MD.Revision != "1700000000" {
+		t.Errorf("revision: got %q", repo.MD.Revision)
+	}
+	d := repo.MD.Find(Primary)
+	if d == nil {
+		t.Fatal("primary not listed")
+	}
+	if d.OpenSize != int64(len(testPrimary)) || d.OpenChecksum.Value != sha256Hex([]byte(testPrimary)) {
+		t.Errorf("got %+v", d)
+	}
+	if repo.MD.Find(Filelists) != nil {
+		t.Error("filelists listed")
+	}
+}
+
+func TestHTTPPrimary(t *testing.T) {
+	for _, ext := range []string{"", ".gz", ".xz", ".zst"} {
+		t.Run("primary.xml"+ext, func(t *testing.T) {
+			r := newTestRepo()
+			defer r.server.Close()
+			r.setRepoMD(t, r.add(t, Primary, ext, []byte(testPrimary)))
+
+			repo, err := Open(r.server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgs, err := repo.Load(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pkgs) != 2 {
+				t.Fatalf("got %d packages, expected 2", len(pkgs))
+			}
+			p := pkgs[0]
+			if p.NEVRA() != "hello-1:2.10-3.fc38.x86_64" {
+				t.Errorf("got %s", p.NEVRA())
+			}
+			if p.EVR.Compare(rpm.EVR{Epoch: 1, Version: "2.10", Release: "3.fc38"}) != 0 {
+				t.Errorf("evr: got %+v", p.EVR)
+			}
+			req := p.Dependencies(rpm.DepRequires)
+			if len(req) != 1 || req[0].Name != "libc.so.6(GLIBC_2.34)(64bit)" {
+				t.Errorf("requires: got %v", req)
+			}
+			prov := p.Dependencies(rpm.DepProvides)
+			if len(prov) != 1 || prov[0].String() != "hello = 1:2.10-3.fc38" {
+				t.Errorf("provides: got %v", prov)
+			}
+			if len(p.Files) != 1 || p.Files[0].Path != "/usr/bin/hello" {
+				t.Errorf("files: got %v", p.Files)
+			}
+		})
+	}
+}
+
+func TestHTTPChecksumMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(d *Data)
+		err    error
+	}{
+		{"checksum", func(d *Data) { d.Checksum.Value = sha256Hex(nil) }, ErrChecksumMismatch},
+		{"open checksum", func(d *Data) { d.OpenChecksum.Value = sha256Hex(nil) }, ErrChecksumMismatch},
+		{"size", func(d *Data) { d.Size++ }, ErrSizeMismatch},
+		{"checksum type", func(d *Data) { d.Checksum.Type = "crc32" }, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo()
+			defer r.server.Close()
+			d := r.add(t, Primary, ".gz", []byte(testPrimary))
+			tt.change(d)
+			r.setRepoMD(t, d)
+
+			repo, err := Open(r.server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = repo.Load(nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, expected %v", err, tt.err)
+			}
+			var ce *ChecksumError
+			if errors.As(err, &ce) && ce.Path != d.Location.Href {
+				t.Errorf("checksum error of %s, expected %s", ce.Path, d.Location.Href)
+			}
+		})
+	}
+}
+
+func TestHTTPNotFound(t *testing.T) {
+	r := newTestRepo()
+	defer r.server.Close()
+	if _, err := Open(r.server.URL); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, expected 404", err)
+	}
+
+	d := r.add(t, Primary, ".gz", []byte(testPrimary))
+	r.setRepoMD(t, d)
+	delete(r.files, d.Location.Href)
+	repo, err := Open(r.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Load(nil); err == nil {
+		t.Error("missing primary loaded")
+	}
+}
+
+func TestMetadataTooLarge(t *testing.T) {
+	defer func(n int64) { maxMetadataSize = n }(maxMetadataSize)
+	// compresses well below the limit, decompresses above it
+	open := []byte(testPrimary + strings.Repeat(" ", 4096))
+	maxMetadataSize = int64(len(open)) - 1
+
+	for _, ext := range []string{"", ".gz"} {
+		t.Run("primary.xml"+ext, func(t *testing.T) {
+			r := newTestRepo()
+			defer r.server.Close()
+			r.setRepoMD(t, r.add(t, Primary, ext, open))
+
+			repo, err := Open(r.server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.Load(nil); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("got %v, expected metadata too large", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/database/sqlite"
+	"code.pikelabs.net/go/rpm"
+)
+
+// sqliteDB is sqlite flavor of metadata, rows are read as maps of
+// column values
+type sqliteDB struct {
+	db   *sqlite.DB
+	path string
+}
+
+// openSqlite stores database in temporary file, sqlite reader needs
+// random access to it
+func openSqlite(d []byte) (*sqliteDB, error) {
+	f, err := ioutil.TempFile("", "repo-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(d)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	db, err := sqlite.Open(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &sqliteDB{db: db, path: f.Name()}, nil
+}
+
+func (s *sqliteDB) Close() error {
+	err := s.db.Close()
+	os.Remove(s.path)
+	return err
+}
+
+type row map[string]interface{}
+
+func (r row) str(col string) string {
+	switch v := r[col].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func (r row) int(col string) int64 {
+	switch v := r[col].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// scan calls fn with every row of table
+func (s *sqliteDB) scan(table string, fn func(r row) error) error {
+	t, err := s.db.Table(table)
+	if err != nil {
+		return err
+	}
+	return s.db.Scan(t, func(rowid int64, rec []interface{}) error {
+		r := row{}
+		for i, c := range t.Columns {
+			r[c] = rec[i]
+		}
+		return fn(r)
+	})
+}
+
+// pkgKeys maps pkgKey of packages table to pkgid
+func (s *sqliteDB) pkgKeys() (map[int64]string, error) {
+	keys := map[int64]string{}
+	err := s.scan("packages", func(r row) error {
+		keys[r.int("pkgKey")] = r.str("pkgId")
+		return nil
+	})
+	return keys, err
+}
+
+// readPrimaryDB reads packages of primary_db
+func readPrimaryDB(s *sqliteDB) ([]*Package, error) {
+	var res []*Package
+	byKey := map[int64]*Package{}
+	err := s.scan("packages", func(r row) error {
+		e, _ := strconv.Atoi(r.str("epoch"))
+		p := &Package{
+			PkgID:         r.str("pkgId"),
+			Checksum:      Checksum{Type: r.str("checksum_type"), Value: r.str("pkgId")},
+			Name:          r.str("name"),
+			Arch:          r.str("arch"),
+			EVR:           rpm.EVR{Epoch: e, Version: r.str("version"), Release: r.str("release")},
+			Summary:       r.str("summary"),
+			Description:   r.str("description"),
+			Packager:      r.str("rpm_packager"),
+			URL:           r.str("url"),
+			FileTime:      time.Unix(r.int("time_file"), 0).UTC(),
+			BuildTime:     time.Unix(r.int("time_build"), 0).UTC(),
+			PackageSize:   r.int("size_package"),
+			InstalledSize: r.int("size_installed"),
+			ArchiveSize:   r.int("size_archive"),
+			Location:      Location{Href: r.str("location_href"), Base: r.str("location_base")},
+			License:       r.str("rpm_license"),
+			Vendor:        r.str("rpm_vendor"),
+			Group:         r.str("rpm_group"),
+			BuildHost:     r.str("rpm_buildhost"),
+			SourceRPM:     r.str("rpm_sourcerpm"),
+			HeaderStart:   r.int("rpm_header_start"),
+			HeaderEnd:     r.int("rpm_header_end"),
+			Deps:          map[rpm.DependencyKind][]rpm.Dependency{},
+		}
+		res = append(res, p)
+		byKey[r.int("pkgKey")] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range rpm.DependencyKinds {
+		err := s.scan(string(kind), func(r row) error {
+			p, ok := byKey[r.int("pkgKey")]
+			if !ok {
+				return nil
+			}
+			pre := r.str("pre")
+			p.Deps[kind] = append(p.Deps[kind], dependency(r.str("name"), r.str("flags"),
+				r.str("epoch"), r.str("version"), r.str("release"), pre == "1" || strings.EqualFold(pre, "TRUE")))
+			return nil
+		})
+		// old databases lack weak dependency tables
+		if err != nil && !errors.Is(err, sqlite.ErrNoTable) {
+			return nil, err
+		}
+	}
+	err = s.scan("files", func(r row) error {
+		if p, ok := byKey[r.int("pkgKey")]; ok {
+			p.Files = append(p.Files, File{Path: r.str("name"), Type: fileType(r.str("type"))})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// fileType converts file type of sqlite metadata to XML one
+func fileType(t string) string {
+	switch t {
+	case "d", "dir":
+		return FileDir
+	case "g", "ghost":
+		return FileGhost
+	}
+	return FileRegular
+}
+
+// readFilelistsDB reads files of filelists_db, directory entries hold
+// '/' separated names and one type letter per name
+func readFilelistsDB(s *sqliteDB) (map[string][]File, error) {
+	keys, err := s.pkgKeys()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]File{}
+	err = s.scan("filelist", func(r row) error {
+		id, ok := keys[r.int("pkgKey")]
+		if !ok {
+			return nil
+		}
+		dir, types := r.str("dirname"), r.str("filetypes")
+		for i, name := range strings.Split(r.str("filenames"), "/") {
+			t := ""
+			if i < len(types) {
+				t = types[i : i+1]
+			}
+			path := dir + "/" + name
+			if dir == "/" {
+				path = "/" + name
+			}
+			res[id] = append(res[id], File{Path: path, Type: fileType(t)})
+		}
+		return nil
+	})
+	return res, err
+}
+
+// readOtherDB reads changelogs of other_db
+func readOtherDB(s *sqliteDB) (map[string][]Changelog, error) {
+	keys, err := s.pkgKeys()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]Changelog{}
+	err = s.scan("changelog", func(r row) error {
+		if id, ok := keys[r.int("pkgKey")]; ok {
+			res[id] = append(res[id], Changelog{
+				Author: r.str("author"),
+				Time:   time.Unix(r.int("date"), 0).UTC(),
+				Text:   r.str("changelog"),
+			})
+		}
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,360 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// Advisory types
+const (
+	AdvisorySecurity    = "security"
+	AdvisoryBugfix      = "bugfix"
+	AdvisoryEnhancement = "enhancement"
+	AdvisoryNewPackage  = "newpackage"
+)
+
+// Advisory is update, also known as erratum, updateinfo metadata
+// lists
+type Advisory struct {
+	ID       string
+	Type     string
+	Status   string
+	Version  string
+	From     string
+	Title    string
+	Severity string
+	Release  string
+	Rights   string
+	Issued   time.Time
+	Updated  time.Time
+
+	Summary     string
+	Description string
+	Solution    string
+	References  []Reference
+	Collections []Collection
+	// RebootSuggested is set by some publishers for advisory as
+	// whole rather than for its packages
+	RebootSuggested bool
+}
+
+// Reference is bug, CVE or other document advisory refers to
+type Reference struct {
+	Type  string
+	ID    string
+	Href  string
+	Title string
+}
+
+// Collection is set of packages of advisory, usually one for every
+// release advisory is for
+type Collection struct {
+	Short    string
+	Name     string
+	Packages []AdvisoryPackage
+}
+
+// AdvisoryPackage is package advisory updates to
+type AdvisoryPackage struct {
+	Name     string
+	Arch     string
+	EVR      rpm.EVR
+	Src      string
+	Filename string
+	Sum      Checksum
+
+	RebootSuggested  bool
+	RestartSuggested bool
+	ReloginSuggested bool
+}
+
+// NEVRA returns name-[epoch:]version-release.arch of package
+func (p *AdvisoryPackage) NEVRA() string {
+	return p.Name + "-" + p.EVR.String() + "." + p.Arch
+}
+
+// Packages returns packages of all collections of advisory, package
+// collections share is returned once
+func (a *Advisory) Packages() []AdvisoryPackage {
+	var res []AdvisoryPackage
+	seen := map[string]bool{}
+	for _, c := range a.Collections {
+		for _, p := range c.Packages {
+			if !seen[p.NEVRA()] {
+				seen[p.NEVRA()] = true
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
+// CVEs returns ids of CVEs advisory refers to
+func (a *Advisory) CVEs() []string {
+	var res []string
+	for _, r := range a.References {
+		if r.Type == "cve" {
+			res = append(res, r.ID)
+		}
+	}
+	return res
+}
+
+// Reboot reports whether system should be rebooted after advisory is
+// applied
+func (a *Advisory) Reboot() bool {
+	if a.RebootSuggested {
+		return true
+	}
+	for _, p := range a.Packages() {
+		if p.RebootSuggested {
+			return true
+		}
+	}
+	return false
+}
+
+// flag tells whether element is set, it is unless it says false.
+// Publishers write True, true, 1 or leave it empty.
+func flag(f *string) bool {
+	if f == nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(*f)) {
+	case "false", "0", "no":
+		return false
+	}
+	return true
+}
+
+type xmlDate struct {
+	Date string `xml:"date,attr"`
+}
+
+// updateinfoTime is format of dates updateinfo is written with,
+// dates are read in it, date alone or as seconds since epoch
+const updateinfoTime = "2006-01-02 15:04:05"
+
+func (d *xmlDate) time() time.Time {
+	s := strings.TrimSpace(d.Date)
+	for _, layout := range []string{updateinfoTime, "2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC()
+	}
+	return time.Time{}
+}
+
+type xmlUpdate struct {
+	From        string  `xml:"from,attr"`
+	Status      string  `xml:"status,attr"`
+	Type        string  `xml:"type,attr"`
+	Version     string  `xml:"version,attr"`
+	ID          string  `xml:"id"`
+	Title       string  `xml:"title"`
+	Severity    string  `xml:"severity"`
+	Release     string  `xml:"release"`
+	Rights      string  `xml:"rights"`
+	Issued      xmlDate `xml:"issued"`
+	Updated     xmlDate `xml:"updated"`
+	Summary     string  `xml:"summary"`
+	Description string  `xml:"description"`
+	Solution    string  `xml:"solution"`
+	References  []struct {
+		Type  string `xml:"type,attr"`
+		ID    string `xml:"id,attr"`
+		Href  string `xml:"href,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"references>reference"`
+	Collections []struct {
+		Short    string `xml:"short,attr"`
+		Name     string `xml:"name"`
+		Packages []struct {
+			Name     string   `xml:"name,attr"`
+			Epoch    string   `xml:"epoch,attr"`
+			Version  string   `xml:"version,attr"`
+			Release  string   `xml:"release,attr"`
+			Arch     string   `xml:"arch,attr"`
+			Src      string   `xml:"src,attr"`
+			Filename string   `xml:"filename"`
+			Sum      Checksum `xml:"sum"`
+			Reboot   *string  `xml:"reboot_suggested"`
+			Restart  *string  `xml:"restart_suggested"`
+			Relogin  *string  `xml:"relogin_suggested"`
+		} `xml:"package"`
+	} `xml:"pkglist>collection"`
+	Reboot *string `xml:"reboot_suggested"`
+}
+
+func (x *xmlUpdate) advisory() *Advisory {
+	a := &Advisory{
+		ID:              x.ID,
+		Type:            x.Type,
+		Status:          x.Status,
+		Version:         x.Version,
+		From:            x.From,
+		Title:           x.Title,
+		Severity:        x.Severity,
+		Release:         x.Release,
+		Rights:          x.Rights,
+		Issued:          x.Issued.time(),
+		Updated:         x.Updated.time(),
+		Summary:         x.Summary,
+		Description:     x.Description,
+		Solution:        x.Solution,
+		RebootSuggested: flag(x.Reboot),
+	}
+	for _, r := range x.References {
+		a.References = append(a.References, Reference{Type: r.Type, ID: r.ID, Href: r.Href, Title: r.Title})
+	}
+	for _, xc := range x.Collections {
+		c := Collection{Short: xc.Short, Name: xc.Name}
+		for _, p := range xc.Packages {
+			c.Packages = append(c.Packages, AdvisoryPackage{
+				Name:             p.Name,
+				Arch:             p.Arch,
+				EVR:              evr(p.Epoch, p.Version, p.Release),
+				Src:              p.Src,
+				Filename:         p.Filename,
+				Sum:              p.Sum,
+				RebootSuggested:  flag(p.Reboot),
+				RestartSuggested: flag(p.Restart),
+				ReloginSuggested: flag(p.Relogin),
+			})
+		}
+		a.Collections = append(a.Collections, c)
+	}
+	return a
+}
+
+// ParseUpdateInfo parses updateinfo.xml
+func ParseUpdateInfo(r io.Reader) ([]*Advisory, error) {
+	var res []*Advisory
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("updateinfo: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "update" {
+			continue
+		}
+		var x xmlUpdate
+		if err := d.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("updateinfo: %w", err)
+		}
+		res = append(res, x.advisory())
+	}
+}
+
+// Advisories returns advisories of repository, none if it has no
+// updateinfo metadata
+func (r *Repo) Advisories() ([]*Advisory, error) {
+	if r.MD.Find(UpdateInfo) == nil {
+		return nil, nil
+	}
+	d, err := r.Data(UpdateInfo)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUpdateInfo(bytes.NewReader(d))
+}
+
+// MergeAdvisories merges advisories of add into advisories, advisory
+// of the same id is replaced unless it was updated later than the one
+// of add. Result is sorted by id.
+func MergeAdvisories(advisories, add []*Advisory) []*Advisory {
+	byID := map[string]*Advisory{}
+	for _, a := range advisories {
+		byID[a.ID] = a
+	}
+	for _, a := range add {
+		if old, ok := byID[a.ID]; ok && updated(old).After(updated(a)) {
+			continue
+		}
+		byID[a.ID] = a
+	}
+	res := make([]*Advisory, 0, len(byID))
+	for _, a := range byID {
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// updated returns time advisory was last changed
+func updated(a *Advisory) time.Time {
+	if a.Updated.IsZero() {
+		return a.Issued
+	}
+	return a.Updated
+}
+
+// PackageUpdate is package of advisory which updates installed package
+type PackageUpdate struct {
+	Installed *Package
+	Update    AdvisoryPackage
+}
+
+// ApplicableAdvisory is advisory with its packages which update
+// installed ones
+type ApplicableAdvisory struct {
+	Advisory *Advisory
+	Updates  []PackageUpdate
+}
+
+// Applicable returns advisories which apply to installed packages.
+// Package of advisory applies if package of the same name and arch is
+// installed and every installed version of it is older, noarch
+// packages match any arch. Source packages are left out.
+func Applicable(advisories []*Advisory, installed []*Package) []*ApplicableAdvisory {
+	byName := map[string][]*Package{}
+	for _, p := range installed {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	var res []*ApplicableAdvisory
+	for _, a := range advisories {
+		var updates []PackageUpdate
+		for _, ap := range a.Packages() {
+			if ap.Arch == "src" || ap.Arch == "nosrc" {
+				continue
+			}
+			var older *Package
+			newer := false
+			for _, p := range byName[ap.Name] {
+				if p.Arch != ap.Arch && p.Arch != "noarch" && ap.Arch != "noarch" {
+					continue
+				}
+				if p.EVR.Compare(ap.EVR) >= 0 {
+					newer = true
+					break
+				}
+				if older == nil || p.EVR.Compare(older.EVR) > 0 {
+					older = p
+				}
+			}
+			if older != nil && !newer {
+				updates = append(updates, PackageUpdate{Installed: older, Update: ap})
+			}
+		}
+		if len(updates) > 0 {
+			res = append(res, &ApplicableAdvisory{Advisory: a, Updates: updates})
+		}
+	}
+	return res
+}
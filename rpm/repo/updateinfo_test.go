@@ -0,0 +1,305 @@
+package repo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+const testUpdateInfo = `<?xml version="1.0" encoding="UTF-8"?>
+<updates>
+  <update from="updates@example.com" status="stable" type="security" version="2.0">
+    <id>FEDORA-2023-0001</id>
+    <title>hello security update</title>
+    <issued date="2023-05-01 10:00:00"/>
+    <updated date="2023-05-03T12:00:00+02:00"/>
+    <severity>Important</severity>
+    <release>Fedora 38</release>
+    <summary>Fixes overflow</summary>
+    <description>Greeting &lt;overflow&gt; fixed.</description>
+    <reboot_suggested>false</reboot_suggested>
+    <references>
+      <reference href="https://example.com/1" id="1" type="bugzilla" title="crash"/>
+      <reference href="https://example.com/cve" id="CVE-2023-0001" type="cve" title="CVE-2023-0001"/>
+    </references>
+    <pkglist>
+      <collection short="F38">
+        <name>Fedora 38</name>
+        <package name="hello" epoch="1" version="2.10" release="4.fc38" arch="x86_64" src="hello-2.10-4.fc38.src.rpm">
+          <filename>hello-2.10-4.fc38.x86_64.rpm</filename>
+          <sum type="sha256">0123</sum>
+          <reboot_suggested>True</reboot_suggested>
+          <restart_suggested>1</restart_suggested>
+          <relogin_suggested/>
+        </package>
+      </collection>
+      <collection short="F38-modular">
+        <package name="hello" epoch="1" version="2.10" release="4.fc38" arch="x86_64" src="hello-2.10-4.fc38.src.rpm"/>
+        <package name="hello" epoch="1" version="2.10" release="4.fc38" arch="src"/>
+      </collection>
+    </pkglist>
+  </update>
+  <update status="final" type="bugfix">
+    <id>FEDORA-2023-0002</id>
+    <issued date="2023-05-02"/>
+    <updated date="1683100000"/>
+    <reboot_suggested>0</reboot_suggested>
+    <references/>
+    <pkglist>
+      <collection short="F38">
+        <package name="glibc" version="2.37" release="2.fc38" arch="i686">
+          <reboot_suggested>no</reboot_suggested>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+</updates>
+`
+
+func TestParseUpdateInfo(t *testing.T) {
+	advisories, err := ParseUpdateInfo(strings.NewReader(testUpdateInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advisories) != 2 {
+		t.Fatalf("got %d advisories, expected 2", len(advisories))
+	}
+
+	a := advisories[0]
+	if a.ID != "FEDORA-2023-0001" || a.Type != AdvisorySecurity || a.Status != "stable" || a.From != "updates@example.com" ||
+		a.Severity != "Important" || a.Description != "Greeting <overflow> fixed." {
+		t.Errorf("got %+v", a)
+	}
+	// dates are written in several formats
+	for _, d := range []struct {
+		got, expected time.Time
+	}{
+		{a.Issued, time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{a.Updated, time.Date(2023, 5, 3, 10, 0, 0, 0, time.UTC)},
+		{advisories[1].Issued, time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{advisories[1].Updated, time.Unix(1683100000, 0)},
+	} {
+		if !d.got.Equal(d.expected) {
+			t.Errorf("got date %v, expected %v", d.got, d.expected)
+		}
+	}
+	if cves := a.CVEs(); !reflect.DeepEqual(cves, []string{"CVE-2023-0001"}) {
+		t.Errorf("got CVEs %v", cves)
+	}
+	// package shared by collections is listed once
+	pkgs := a.Packages()
+	if len(pkgs) != 2 || pkgs[0].NEVRA() != "hello-1:2.10-4.fc38.x86_64" || pkgs[1].Arch != "src" {
+		t.Fatalf("got packages %+v", pkgs)
+	}
+	// flags are set unless they say otherwise
+	if p := pkgs[0]; !p.RebootSuggested || !p.RestartSuggested || !p.ReloginSuggested || p.Sum.Value != "0123" {
+		t.Errorf("got %+v", p)
+	}
+	if a.RebootSuggested || !a.Reboot() {
+		t.Errorf("advisory reboot %v, %v, expected package to suggest it", a.RebootSuggested, a.Reboot())
+	}
+
+	b := advisories[1]
+	if b.RebootSuggested || b.Reboot() || len(b.References) != 0 || b.Packages()[0].EVR.Epoch != 0 {
+		t.Errorf("got %+v", b)
+	}
+}
+
+func TestWriteUpdateInfo(t *testing.T) {
+	advisories, err := ParseUpdateInfo(strings.NewReader(testUpdateInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// dates are written in UTC
+	for _, a := range advisories {
+		a.Issued, a.Updated = a.Issued.UTC(), a.Updated.UTC()
+	}
+	var b bytes.Buffer
+	if err := WriteUpdateInfo(&b, advisories); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<updated date="2023-05-03 10:00:00"/>`) {
+		t.Errorf("dates not written in updateinfo format:\n%s", b.String())
+	}
+	parsed, err := ParseUpdateInfo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, advisories) {
+		for i := range parsed {
+			t.Errorf("got %+v, expected %+v", parsed[i], advisories[i])
+		}
+	}
+}
+
+func TestMergeAdvisories(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2023, 5, d, 0, 0, 0, 0, time.UTC) }
+	old := []*Advisory{
+		{ID: "C", Title: "old C", Updated: day(5)},
+		{ID: "A", Title: "old A", Updated: day(1)},
+		{ID: "B", Title: "old B", Issued: day(3)},
+		{ID: "D", Title: "old D", Issued: day(3)},
+	}
+	add := []*Advisory{
+		// updated later replaces, older is ignored
+		{ID: "A", Title: "new A", Updated: day(2)},
+		{ID: "C", Title: "new C", Updated: day(4)},
+		// issued date stands in for missing update date
+		{ID: "B", Title: "new B", Issued: day(2), Updated: day(4)},
+		// the same time replaces
+		{ID: "D", Title: "new D", Issued: day(3)},
+		{ID: "E", Title: "new E"},
+	}
+	var got []string
+	for _, a := range MergeAdvisories(old, add) {
+		got = append(got, a.Title)
+	}
+	expected := []string{"new A", "new B", "old C", "new D", "new E"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestApplicable(t *testing.T) {
+	pkg := func(name, evr, arch string) *Package {
+		return &Package{Name: name, EVR: rpm.ParseEVR(evr), Arch: arch}
+	}
+	apkg := func(name, evr, arch string) AdvisoryPackage {
+		return AdvisoryPackage{Name: name, EVR: rpm.ParseEVR(evr), Arch: arch}
+	}
+	installed := []*Package{
+		pkg("hello", "2.10-3", "x86_64"),
+		pkg("glibc", "2.37-1", "x86_64"),
+		pkg("glibc", "2.37-1", "i686"),
+		pkg("kernel", "6.1-1", "x86_64"),
+		pkg("kernel", "6.2-1", "x86_64"),
+		pkg("kernel-new", "6.1-1", "x86_64"),
+		pkg("kernel-new", "6.5-1", "x86_64"),
+		pkg("same", "1:1.0-1", "x86_64"),
+		pkg("docs", "1.0-1", "noarch"),
+		pkg("tool", "1.0-1", "x86_64"),
+	}
+	advisories := []*Advisory{
+		{ID: "hello", Collections: []Collection{{Packages: []AdvisoryPackage{
+			apkg("hello", "2.10-4", "x86_64"),
+			apkg("hello", "2.10-4", "src"),
+			apkg("hello", "2.10-4", "aarch64"),
+		}}}},
+		// every arch installed is updated
+		{ID: "glibc", Collections: []Collection{{Packages: []AdvisoryPackage{
+			apkg("glibc", "2.37-2", "x86_64"),
+			apkg("glibc", "2.37-2", "i686"),
+		}}}},
+		// newest of older versions installed is updated
+		{ID: "kernel", Collections: []Collection{{Packages: []AdvisoryPackage{apkg("kernel", "6.3-1", "x86_64")}}}},
+		// not if any installed version is newer
+		{ID: "kernel-new", Collections: []Collection{{Packages: []AdvisoryPackage{apkg("kernel-new", "6.3-1", "x86_64")}}}},
+		// nor the same
+		{ID: "same", Collections: []Collection{{Packages: []AdvisoryPackage{apkg("same", "1:1.0-1", "x86_64")}}}},
+		{ID: "epoch", Collections: []Collection{{Packages: []AdvisoryPackage{apkg("same", "2.0-1", "x86_64")}}}},
+		// noarch matches any arch
+		{ID: "noarch", Collections: []Collection{{Packages: []AdvisoryPackage{
+			apkg("docs", "1.1-1", "x86_64"),
+			apkg("tool", "1.1-1", "noarch"),
+		}}}},
+		{ID: "none", Collections: []Collection{{Packages: []AdvisoryPackage{apkg("missing", "1.0-1", "x86_64")}}}},
+	}
+
+	got := map[string][]string{}
+	for _, a := range Applicable(advisories, installed) {
+		for _, u := range a.Updates {
+			got[a.Advisory.ID] = append(got[a.Advisory.ID], u.Installed.NEVRA()+" -> "+u.Update.NEVRA())
+		}
+	}
+	expected := map[string][]string{
+		"hello":  {"hello-2.10-3.x86_64 -> hello-2.10-4.x86_64"},
+		"glibc":  {"glibc-2.37-1.x86_64 -> glibc-2.37-2.x86_64", "glibc-2.37-1.i686 -> glibc-2.37-2.i686"},
+		"kernel": {"kernel-6.2-1.x86_64 -> kernel-6.3-1.x86_64"},
+		"noarch": {"docs-1.0-1.noarch -> docs-1.1-1.x86_64", "tool-1.0-1.x86_64 -> tool-1.1-1.noarch"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestAddMetadata(t *testing.T) {
+	dir := newCreateRepo(t)
+	defer os.RemoveAll(dir)
+	if _, err := Create(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddMetadata(dir, UpdateInfo, []byte(testUpdateInfo), "crc32"); err == nil {
+		t.Error("unsupported checksum accepted")
+	}
+
+	advisories := func() []*Advisory {
+		t.Helper()
+		repo, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := repo.Advisories()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	if a := advisories(); a != nil {
+		t.Errorf("got %v, expected no advisories", a)
+	}
+	md, err := AddMetadata(dir, UpdateInfo, []byte(testUpdateInfo), "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := md.Find(UpdateInfo)
+	if first == nil || len(md.Data) != 4 {
+		t.Fatalf("got %+v", md)
+	}
+	if a := advisories(); len(a) != 2 {
+		t.Errorf("got %d advisories, expected 2", len(a))
+	}
+
+	// metadata of the same type replace the old ones, which are removed
+	one := strings.Replace(testUpdateInfo, "FEDORA-2023-0002", "FEDORA-2023-0003", 1)
+	md, err = AddMetadata(dir, UpdateInfo, []byte(one), "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Data) != 4 || md.Find(UpdateInfo).Location.Href == first.Location.Href {
+		t.Errorf("got %+v", md)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(first.Location.Href))); !os.IsNotExist(err) {
+		t.Errorf("replaced metadata left behind: %v", err)
+	}
+	if a := advisories(); len(a) != 2 || a[1].ID != "FEDORA-2023-0003" {
+		t.Errorf("got %+v", a)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "repodata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("got %d files in repodata, expected metadata and repomd.xml", len(entries))
+	}
+
+	// regenerated metadata keep updateinfo only if asked to
+	if _, err := Create(dir, &CreateOptions{KeepMetadata: true}); err != nil {
+		t.Fatal(err)
+	}
+	if a := advisories(); len(a) != 2 || a[1].ID != "FEDORA-2023-0003" {
+		t.Errorf("kept: got %+v", a)
+	}
+	if _, err := Create(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if a := advisories(); a != nil {
+		t.Errorf("not kept: got %+v", a)
+	}
+}
@@ -0,0 +1,290 @@
+package repo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+const (
+	nsCommon    = "http://linux.duke.edu/metadata/common"
+	nsRPM       = "http://linux.duke.edu/metadata/rpm"
+	nsFilelists = "http://linux.duke.edu/metadata/filelists"
+	nsOther     = "http://linux.duke.edu/metadata/other"
+	nsRepo      = "http://linux.duke.edu/metadata/repo"
+	xmlHeader   = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+)
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// esc escapes text for element content and attributes, characters
+// XML can't carry at all are dropped
+func esc(s string) string {
+	valid := utf8.ValidString(s)
+	if valid && !strings.ContainsAny(s, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x0b\x0c\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f") {
+		return xmlEscaper.Replace(s)
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if r == utf8.RuneError || (r < 0x20 && r != '\t' && r != '\n' && r != '\r') {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return xmlEscaper.Replace(sb.String())
+}
+
+var senseNames = map[rpm.SenseFlags]string{}
+
+func init() {
+	for name, f := range senseFlags {
+		senseNames[f] = name
+	}
+}
+
+// depKinds in the order createrepo writes them
+var depKinds = []rpm.DependencyKind{
+	rpm.DepProvides, rpm.DepRequires, rpm.DepConflicts, rpm.DepObsoletes,
+	rpm.DepSuggests, rpm.DepEnhances, rpm.DepRecommends, rpm.DepSupplements,
+}
+
+// preFlags mark requires needed already by scriptlets
+const preFlags = rpm.SensePrereq | rpm.SenseScriptPre | rpm.SenseScriptPost | rpm.SensePreTrans | rpm.SensePostTrans
+
+// unix returns time in seconds, zero for time not set
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func writeVersion(w io.Writer, evr rpm.EVR) {
+	fmt.Fprintf(w, `<version epoch="%d" ver="%s" rel="%s"/>`, evr.Epoch, esc(evr.Version), esc(evr.Release))
+}
+
+func writeEntry(w io.Writer, kind rpm.DependencyKind, d rpm.Dependency) {
+	fmt.Fprintf(w, `      <rpm:entry name="%s"`, esc(d.Name))
+	if flags, ok := senseNames[d.Flags&rpm.SenseCompareMask]; ok && len(d.Version) > 0 {
+		evr := rpm.ParseEVR(d.Version)
+		fmt.Fprintf(w, ` flags="%s" epoch="%d" ver="%s"`, flags, evr.Epoch, esc(evr.Version))
+		if len(evr.Release) > 0 {
+			fmt.Fprintf(w, ` rel="%s"`, esc(evr.Release))
+		}
+	}
+	if kind == rpm.DepRequires && d.Flags&preFlags != 0 {
+		io.WriteString(w, ` pre="1"`)
+	}
+	io.WriteString(w, "/>\n")
+}
+
+func writeFile(w io.Writer, f File) {
+	if len(f.Type) > 0 {
+		fmt.Fprintf(w, `<file type="%s">%s</file>`, f.Type, esc(f.Path))
+	} else {
+		fmt.Fprintf(w, `<file>%s</file>`, esc(f.Path))
+	}
+}
+
+func writeLocation(w io.Writer, l Location) {
+	if len(l.Base) > 0 {
+		fmt.Fprintf(w, `<location xml:base="%s" href="%s"/>`, esc(l.Base), esc(l.Href))
+	} else {
+		fmt.Fprintf(w, `<location href="%s"/>`, esc(l.Href))
+	}
+}
+
+// isPrimaryFile tells files listed in primary metadata as well, the
+// ones dependencies commonly point at
+func isPrimaryFile(path string) bool {
+	return strings.HasPrefix(path, "/etc/") || path == "/usr/lib/sendmail" || strings.Contains(path, "bin/")
+}
+
+// WritePrimary writes primary.xml of packages
+func WritePrimary(w io.Writer, pkgs []*Package) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<metadata xmlns=\"%s\" xmlns:rpm=\"%s\" packages=\"%d\">\n", xmlHeader, nsCommon, nsRPM, len(pkgs))
+	for _, p := range pkgs {
+		fmt.Fprintf(bw, "<package type=\"rpm\">\n  <name>%s</name>\n  <arch>%s</arch>\n  ", esc(p.Name), esc(p.Arch))
+		writeVersion(bw, p.EVR)
+		fmt.Fprintf(bw, "\n  <checksum type=\"%s\" pkgid=\"YES\">%s</checksum>\n", esc(p.Checksum.Type), esc(p.PkgID))
+		fmt.Fprintf(bw, "  <summary>%s</summary>\n  <description>%s</description>\n", esc(p.Summary), esc(p.Description))
+		fmt.Fprintf(bw, "  <packager>%s</packager>\n  <url>%s</url>\n", esc(p.Packager), esc(p.URL))
+		fmt.Fprintf(bw, "  <time file=\"%d\" build=\"%d\"/>\n", unix(p.FileTime), unix(p.BuildTime))
+		fmt.Fprintf(bw, "  <size package=\"%d\" installed=\"%d\" archive=\"%d\"/>\n  ", p.PackageSize, p.InstalledSize, p.ArchiveSize)
+		writeLocation(bw, p.Location)
+		fmt.Fprintf(bw, "\n  <format>\n    <rpm:license>%s</rpm:license>\n    <rpm:vendor>%s</rpm:vendor>\n", esc(p.License), esc(p.Vendor))
+		fmt.Fprintf(bw, "    <rpm:group>%s</rpm:group>\n    <rpm:buildhost>%s</rpm:buildhost>\n", esc(p.Group), esc(p.BuildHost))
+		fmt.Fprintf(bw, "    <rpm:sourcerpm>%s</rpm:sourcerpm>\n", esc(p.SourceRPM))
+		fmt.Fprintf(bw, "    <rpm:header-range start=\"%d\" end=\"%d\"/>\n", p.HeaderStart, p.HeaderEnd)
+		for _, kind := range depKinds {
+			deps := p.Deps[kind]
+			if len(deps) == 0 {
+				continue
+			}
+			fmt.Fprintf(bw, "    <rpm:%s>\n", kind)
+			for _, d := range deps {
+				writeEntry(bw, kind, d)
+			}
+			fmt.Fprintf(bw, "    </rpm:%s>\n", kind)
+		}
+		for _, f := range p.Files {
+			if isPrimaryFile(f.Path) {
+				io.WriteString(bw, "    ")
+				writeFile(bw, f)
+				io.WriteString(bw, "\n")
+			}
+		}
+		io.WriteString(bw, "  </format>\n</package>\n")
+	}
+	io.WriteString(bw, "</metadata>\n")
+	return bw.Flush()
+}
+
+func writeExtraHeader(w io.Writer, p *Package) {
+	fmt.Fprintf(w, "<package pkgid=\"%s\" name=\"%s\" arch=\"%s\">\n  ", esc(p.PkgID), esc(p.Name), esc(p.Arch))
+	writeVersion(w, p.EVR)
+	io.WriteString(w, "\n")
+}
+
+// WriteFilelists writes filelists.xml of packages
+func WriteFilelists(w io.Writer, pkgs []*Package) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<filelists xmlns=\"%s\" packages=\"%d\">\n", xmlHeader, nsFilelists, len(pkgs))
+	for _, p := range pkgs {
+		writeExtraHeader(bw, p)
+		for _, f := range p.Files {
+			io.WriteString(bw, "  ")
+			writeFile(bw, f)
+			io.WriteString(bw, "\n")
+		}
+		io.WriteString(bw, "</package>\n")
+	}
+	io.WriteString(bw, "</filelists>\n")
+	return bw.Flush()
+}
+
+// WriteOther writes other.xml of packages
+func WriteOther(w io.Writer, pkgs []*Package) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<otherdata xmlns=\"%s\" packages=\"%d\">\n", xmlHeader, nsOther, len(pkgs))
+	for _, p := range pkgs {
+		writeExtraHeader(bw, p)
+		for _, c := range p.Changelogs {
+			fmt.Fprintf(bw, "  <changelog author=\"%s\" date=\"%d\">%s</changelog>\n", esc(c.Author), unix(c.Time), esc(c.Text))
+		}
+		io.WriteString(bw, "</package>\n")
+	}
+	io.WriteString(bw, "</otherdata>\n")
+	return bw.Flush()
+}
+
+func writeDate(w io.Writer, name string, t time.Time) {
+	if !t.IsZero() {
+		fmt.Fprintf(w, "    <%s date=\"%s\"/>\n", name, t.UTC().Format(updateinfoTime))
+	}
+}
+
+// writeText writes element unless its text is empty
+func writeText(w io.Writer, indent, name, s string) {
+	if len(s) > 0 {
+		fmt.Fprintf(w, "%s<%s>%s</%s>\n", indent, name, esc(s), name)
+	}
+}
+
+func writeFlag(w io.Writer, indent, name string, set bool) {
+	if set {
+		fmt.Fprintf(w, "%s<%s>True</%s>\n", indent, name, name)
+	}
+}
+
+// WriteUpdateInfo writes updateinfo.xml of advisories
+func WriteUpdateInfo(w io.Writer, advisories []*Advisory) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<updates>\n", xmlHeader)
+	for _, a := range advisories {
+		fmt.Fprintf(bw, "  <update from=\"%s\" status=\"%s\" type=\"%s\" version=\"%s\">\n", esc(a.From), esc(a.Status), esc(a.Type), esc(a.Version))
+		fmt.Fprintf(bw, "    <id>%s</id>\n", esc(a.ID))
+		writeText(bw, "    ", "title", a.Title)
+		writeDate(bw, "issued", a.Issued)
+		writeDate(bw, "updated", a.Updated)
+		writeText(bw, "    ", "rights", a.Rights)
+		writeText(bw, "    ", "release", a.Release)
+		writeText(bw, "    ", "severity", a.Severity)
+		writeText(bw, "    ", "summary", a.Summary)
+		writeText(bw, "    ", "description", a.Description)
+		writeText(bw, "    ", "solution", a.Solution)
+		writeFlag(bw, "    ", "reboot_suggested", a.RebootSuggested)
+		if len(a.References) > 0 {
+			io.WriteString(bw, "    <references>\n")
+			for _, r := range a.References {
+				fmt.Fprintf(bw, "      <reference href=\"%s\" id=\"%s\" type=\"%s\" title=\"%s\"/>\n", esc(r.Href), esc(r.ID), esc(r.Type), esc(r.Title))
+			}
+			io.WriteString(bw, "    </references>\n")
+		} else {
+			io.WriteString(bw, "    <references/>\n")
+		}
+		io.WriteString(bw, "    <pkglist>\n")
+		for _, c := range a.Collections {
+			fmt.Fprintf(bw, "      <collection short=\"%s\">\n", esc(c.Short))
+			writeText(bw, "        ", "name", c.Name)
+			for _, p := range c.Packages {
+				fmt.Fprintf(bw, "        <package name=\"%s\" epoch=\"%d\" version=\"%s\" release=\"%s\" arch=\"%s\" src=\"%s\">\n",
+					esc(p.Name), p.EVR.Epoch, esc(p.EVR.Version), esc(p.EVR.Release), esc(p.Arch), esc(p.Src))
+				writeText(bw, "          ", "filename", p.Filename)
+				if len(p.Sum.Value) > 0 {
+					fmt.Fprintf(bw, "          <sum type=\"%s\">%s</sum>\n", esc(p.Sum.Type), esc(p.Sum.Value))
+				}
+				writeFlag(bw, "          ", "reboot_suggested", p.RebootSuggested)
+				writeFlag(bw, "          ", "restart_suggested", p.RestartSuggested)
+				writeFlag(bw, "          ", "relogin_suggested", p.ReloginSuggested)
+				io.WriteString(bw, "        </package>\n")
+			}
+			io.WriteString(bw, "      </collection>\n")
+		}
+		io.WriteString(bw, "    </pkglist>\n  </update>\n")
+	}
+	io.WriteString(bw, "</updates>\n")
+	return bw.Flush()
+}
+
+// WriteRepoMD writes repomd.xml, data are sorted by type
+func WriteRepoMD(w io.Writer, md *RepoMD) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s<repomd xmlns=\"%s\" xmlns:rpm=\"%s\">\n", xmlHeader, nsRepo, nsRPM)
+	fmt.Fprintf(bw, "  <revision>%s</revision>\n", esc(md.Revision))
+	if len(md.Tags) > 0 {
+		io.WriteString(bw, "  <tags>\n")
+		for _, t := range md.Tags {
+			fmt.Fprintf(bw, "    <content>%s</content>\n", esc(t))
+		}
+		io.WriteString(bw, "  </tags>\n")
+	}
+	data := append([]Data(nil), md.Data...)
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Type < data[j].Type })
+	for _, d := range data {
+		fmt.Fprintf(bw, "  <data type=\"%s\">\n", esc(d.Type))
+		fmt.Fprintf(bw, "    <checksum type=\"%s\">%s</checksum>\n", esc(d.Checksum.Type), esc(d.Checksum.Value))
+		if len(d.OpenChecksum.Value) > 0 {
+			fmt.Fprintf(bw, "    <open-checksum type=\"%s\">%s</open-checksum>\n", esc(d.OpenChecksum.Type), esc(d.OpenChecksum.Value))
+		}
+		io.WriteString(bw, "    ")
+		writeLocation(bw, d.Location)
+		fmt.Fprintf(bw, "\n    <timestamp>%d</timestamp>\n    <size>%d</size>\n", d.Timestamp, d.Size)
+		if d.OpenSize > 0 {
+			fmt.Fprintf(bw, "    <open-size>%d</open-size>\n", d.OpenSize)
+		}
+		if d.DatabaseVersion > 0 {
+			fmt.Fprintf(bw, "    <database_version>%d</database_version>\n", d.DatabaseVersion)
+		}
+		io.WriteString(bw, "  </data>\n")
+	}
+	io.WriteString(bw, "</repomd>\n")
+	return bw.Flush()
+}
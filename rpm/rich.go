@@ -0,0 +1,202 @@
+package rpm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMalformedRich is returned for rich dependency which can't be
+// parsed
+var ErrMalformedRich = errors.New("malformed rich dependency")
+
+// RichOp is operator of rich (boolean) dependency
+type RichOp string
+
+const (
+	RichAnd     RichOp = "and"
+	RichOr      RichOp = "or"
+	RichIf      RichOp = "if"
+	RichUnless  RichOp = "unless"
+	RichWith    RichOp = "with"
+	RichWithout RichOp = "without"
+)
+
+// RichDep is parsed rich dependency like "(foo >= 1.0 or bar)". Leaf
+// has no Op and carries simple dependency in Dep. Args of if and
+// unless are condition consequent, condition and optional else
+// branch.
+type RichDep struct {
+	Op   RichOp
+	Args []*RichDep
+	Dep  Dependency
+}
+
+// IsRich reports whether dependency name is rich dependency
+func IsRich(name string) bool {
+	return strings.HasPrefix(name, "(")
+}
+
+// ParseRich parses rich dependency, the whole expression has to be
+// in parentheses
+func ParseRich(s string) (*RichDep, error) {
+	p := richParser{tokens: richTokens(s)}
+	if len(p.tokens) == 0 || p.tokens[0] != "(" {
+		return nil, fmt.Errorf("%w: %q", ErrMalformedRich, s)
+	}
+	d, err := p.expr()
+	if err == nil && p.pos != len(p.tokens) {
+		err = errors.New("trailing input")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %s", ErrMalformedRich, s, err)
+	}
+	return d, nil
+}
+
+func richTokens(s string) []string {
+	var tokens []string
+	start := -1
+	for i, c := range s {
+		switch {
+		case c == '(' || c == ')' || c == ' ' || c == '\t':
+			if start >= 0 {
+				tokens = append(tokens, s[start:i])
+				start = -1
+			}
+			if c == '(' || c == ')' {
+				tokens = append(tokens, string(c))
+			}
+		case start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+type richParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *richParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *richParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func isRichOp(t string) bool {
+	switch RichOp(t) {
+	case RichAnd, RichOr, RichIf, RichUnless, RichWith, RichWithout:
+		return true
+	}
+	return t == "else"
+}
+
+// expr parses parenthesized expression, and, or and with may be
+// chained, the other operators are binary
+func (p *richParser) expr() (*RichDep, error) {
+	if p.next() != "(" {
+		return nil, errors.New("expected (")
+	}
+	first, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() == ")" {
+		p.next()
+		return first, nil
+	}
+	op := RichOp(p.next())
+	d := &RichDep{Op: op, Args: []*RichDep{first}}
+	switch op {
+	case RichAnd, RichOr, RichWith:
+		for {
+			arg, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			d.Args = append(d.Args, arg)
+			if p.peek() != string(op) {
+				break
+			}
+			p.next()
+		}
+	case RichIf, RichUnless, RichWithout:
+		arg, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		d.Args = append(d.Args, arg)
+		if op != RichWithout && p.peek() == "else" {
+			p.next()
+			if arg, err = p.operand(); err != nil {
+				return nil, err
+			}
+			d.Args = append(d.Args, arg)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+	if t := p.next(); t != ")" {
+		return nil, fmt.Errorf("unexpected %q", t)
+	}
+	return d, nil
+}
+
+// operand parses nested expression or simple dependency
+func (p *richParser) operand() (*RichDep, error) {
+	if p.peek() == "(" {
+		return p.expr()
+	}
+	name := p.next()
+	if len(name) == 0 || name == ")" || isRichOp(name) {
+		return nil, fmt.Errorf("expected dependency, got %q", name)
+	}
+	d := &RichDep{Dep: Dependency{Name: name}}
+	if flags, ok := ParseOperator(p.peek()); ok {
+		p.next()
+		v := p.next()
+		if len(v) == 0 || v == ")" || v == "(" {
+			return nil, fmt.Errorf("missing version of %s", name)
+		}
+		d.Dep.Flags, d.Dep.Version = flags, v
+	}
+	return d, nil
+}
+
+// String formats rich dependency back, nested expressions in
+// parentheses
+func (d *RichDep) String() string {
+	if len(d.Op) == 0 {
+		return "(" + d.Dep.String() + ")"
+	}
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for i, arg := range d.Args {
+		if i > 0 {
+			op := string(d.Op)
+			if i == 2 && (d.Op == RichIf || d.Op == RichUnless) {
+				op = "else"
+			}
+			sb.WriteString(" " + op + " ")
+		}
+		if len(arg.Op) == 0 {
+			sb.WriteString(arg.Dep.String())
+		} else {
+			sb.WriteString(arg.String())
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
@@ -174,11 +174,49 @@ const (
 	TagPolicies
 )
 
-var HeaderNames = map[HeaderTag]string{
-	TagBaseNames:    "RPMTAG_BASENAMES",
-	TagOldFilenames: "RPMTAG_OLDFILENAMES",
-	TagFilenames:    "RPMTAG_FILENAMES",
-}
+const (
+	TagPretrans HeaderTag = 1151 + iota
+	TagPosttrans
+	TagPretransProg
+	TagPosttransProg
+)
+
+// 64-bit variants of size tags, used by packages over 4 GiB
+const (
+	TagLongFileSizes HeaderTag = 5008
+	TagLongSize      HeaderTag = 5009
+)
+
+const (
+	TagPreinFlags HeaderTag = 5020 + iota
+	TagPostinFlags
+	TagPreunFlags
+	TagPostunFlags
+	TagPretransFlags
+	TagPosttransFlags
+	TagVerifyScriptFlags
+	TagTriggerScriptFlags
+)
+
+const (
+	TagFileTriggerScripts HeaderTag = 5066 + iota
+	TagFileTriggerScriptProg
+	TagFileTriggerScriptFlags
+	TagFileTriggerName
+	TagFileTriggerIndex
+	TagFileTriggerVersion
+	TagFileTriggerFlags
+	TagTransFileTriggerScripts
+	TagTransFileTriggerScriptProg
+	TagTransFileTriggerScriptFlags
+	TagTransFileTriggerName
+	TagTransFileTriggerIndex
+	TagTransFileTriggerVersion
+	TagTransFileTriggerFlags
+	TagRemovePathPostfixes
+	TagFileTriggerPriorities
+	TagTransFileTriggerPriorities
+)
 
 type HeaderDataType int32
 
@@ -192,6 +230,7 @@ const (
 	DataTypeString                     = 6
 	DataTypeBin                        = 7
 	DataTypeStringArray                = 8
+	DataTypeI18NString                 = 9
 
 	DataTypeNotFound = -1
 	DataTypeUnknown  = -2
@@ -206,6 +245,8 @@ type HeaderIndexEntry struct {
 	Count    int32
 }
 
+const headerInfoSize = 16
+
 type headerInfo struct {
 	Magic    uint32
 	Reserved uint32
@@ -218,39 +259,95 @@ type Header struct {
 	data    []byte
 }
 
+func (e *HeaderIndexEntry) UnmarshalBinary(d []byte) error {
+	if len(d) < HeaderIndexEntrySize {
+		return ErrTruncated
+	}
+	e.Tag = HeaderTag(binary.BigEndian.Uint32(d[0:]))
+	e.DataType = HeaderDataType(binary.BigEndian.Uint32(d[4:]))
+	e.Offset = int32(binary.BigEndian.Uint32(d[8:]))
+	e.Count = int32(binary.BigEndian.Uint32(d[12:]))
+	return nil
+}
+
+// ReadHeader reads header using DefaultHeaderLimits
 func ReadHeader(r io.Reader) (*Header, error) {
+	return ReadHeaderWithLimits(r, DefaultHeaderLimits)
+}
+
+// ReadHeaderWithLimits reads and validates header, refusing headers
+// exceeding limits before allocating memory for them
+func ReadHeaderWithLimits(r io.Reader, limits HeaderLimits) (*Header, error) {
 	var hInfo headerInfo
 	if err := binary.Read(r, binary.BigEndian, &hInfo); err != nil {
-		return nil, fmt.Errorf("error reading RPM header: %s", err.Error())
+		return nil, readError(err, 0)
 	}
 
 	if hInfo.Magic != HeaderMagic {
-		return nil, fmt.Errorf("error reading RPM header: bad header magic or version")
+		return nil, &FormatError{Section: "header", Err: ErrBadMagic}
 	}
 
-	nIdx := int(hInfo.IndexCnt)
+	return readHeaderBody(r, hInfo.IndexCnt, hInfo.Size, headerInfoSize, limits)
+}
+
+// ReadHeaderBlob reads header the way it's stored in rpm database,
+// without magic and reserved bytes in front of index
+func ReadHeaderBlob(r io.Reader, limits HeaderLimits) (*Header, error) {
+	var info [2]uint32
+	if err := binary.Read(r, binary.BigEndian, &info); err != nil {
+		return nil, readError(err, 0)
+	}
+	return readHeaderBody(r, info[0], info[1], 8, limits)
+}
+
+// readHeaderBody reads index and data of header, base is size of
+// the preamble already read
+func readHeaderBody(r io.Reader, il, dl uint32, base int64, limits HeaderLimits) (*Header, error) {
+	if int64(il) > int64(limits.MaxIndexEntries) {
+		return nil, &LimitError{What: "index entries", Value: int64(il), Limit: int64(limits.MaxIndexEntries)}
+	}
+	if int64(dl) > int64(limits.MaxDataSize) {
+		return nil, &LimitError{What: "data size", Value: int64(dl), Limit: int64(limits.MaxDataSize)}
+	}
+
+	nIdx := int(il)
 	indexTable := make([]byte, HeaderIndexEntrySize*nIdx)
-	data := make([]byte, hInfo.Size)
 	if _, err := io.ReadFull(r, indexTable); err != nil {
-		return nil, fmt.Errorf("error reading RPM header index table: %s", err.Error())
+		return nil, readError(err, base)
 	}
-	if _, err := io.ReadFull(r, data); err != nil {
-		return nil, fmt.Errorf("error reading RPM header data: %s", err.Error())
+	// data size is only claimed by the input, let buffer grow as
+	// data actually arrives
+	var data bytes.Buffer
+	if _, err := io.CopyN(&data, r, int64(dl)); err != nil {
+		return nil, readError(err, base+int64(len(indexTable)))
 	}
 
 	header := &Header{}
 	header.indexes = make([]HeaderIndexEntry, nIdx)
-	header.data = data
+	header.data = data.Bytes()
 
-	br := bytes.NewReader(indexTable)
 	for i := 0; i < nIdx; i++ {
-		if err := binary.Read(br, binary.BigEndian, &header.indexes[i]); err != nil {
-			return nil, fmt.Errorf("error parsing index table: %s", err.Error())
+		header.indexes[i].UnmarshalBinary(indexTable[i*HeaderIndexEntrySize:])
+	}
+	if err := header.Validate(); err != nil {
+		var offset int64
+		if ve, ok := err.(*ValidationError); ok && ve.Entry >= 0 {
+			offset = base + int64(ve.Entry*HeaderIndexEntrySize)
 		}
+		return nil, &FormatError{Section: "header", Offset: offset, Err: err}
 	}
 	return header, nil
 }
 
+// readError reports premature end of input as FormatError, other
+// errors are passed through
+func readError(err error, offset int64) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &FormatError{Section: "header", Offset: offset, Err: ErrTruncated}
+	}
+	return fmt.Errorf("error reading RPM header: %w", err)
+}
+
 func (h Header) AvailableTags() []HeaderTag {
 	tags := make([]HeaderTag, len(h.indexes))
 	for i, idx := range h.indexes {
@@ -285,7 +382,7 @@ func getTag(h *Header, t HeaderTag) (*HeaderIndexEntry, []byte, error) {
 		}
 	}
 
-	return nil, nil, fmt.Errorf("error tag not found")
+	return nil, nil, fmt.Errorf("%w: %d", ErrTagNotFound, t)
 }
 
 func (h Header) GetString(t HeaderTag) (string, error) {
@@ -294,7 +391,7 @@ func (h Header) GetString(t HeaderTag) (string, error) {
 		return "", err
 	}
 	if idx.DataType != DataTypeString {
-		return "", fmt.Errorf("error invalid data type %d, %d", idx.DataType, DataTypeStringArray)
+		return "", &TypeMismatchError{Tag: t, Expected: DataTypeString, Actual: idx.DataType}
 	}
 	br := bufio.NewReader(bytes.NewReader(v))
 	cstr, err := br.ReadBytes(0)
@@ -311,51 +408,93 @@ func (h Header) GetStrings(t HeaderTag) ([]string, error) {
 		return nil, err
 	}
 	if idx.DataType != DataTypeStringArray {
-		return nil, fmt.Errorf("error invalid data type %d, %d", idx.DataType, DataTypeStringArray)
+		return nil, &TypeMismatchError{Tag: t, Expected: DataTypeStringArray, Actual: idx.DataType}
 	}
 
 	s := CStringArrayToSlice(v)
 	if len(s) != int(idx.Count) {
-		return nil, fmt.Errorf("error trimmed string array")
+		return nil, fmt.Errorf("%w: %d strings, expected %d", ErrCountMismatch, len(s), idx.Count)
 	}
 	return s, nil
 }
 
-func CStringArrayToSlice(d []byte) []string {
-	res := strings.Split(string(d), "\x00")
-	return res[:len(res)-1]
+func (h Header) GetInt16s(t HeaderTag) ([]int16, error) {
+	idx, v, err := h.getTag(t)
+	if err != nil {
+		return nil, err
+	}
+	if idx.DataType != DataTypeInt16 {
+		return nil, &TypeMismatchError{Tag: t, Expected: DataTypeInt16, Actual: idx.DataType}
+	}
+	res := make([]int16, idx.Count)
+	for i := range res {
+		res[i] = int16(binary.BigEndian.Uint16(v[2*i:]))
+	}
+	return res, nil
 }
 
-func readData(idx *HeaderIndexEntry, d []byte) ([]byte, error) {
-	var end int
+func (h Header) GetInt32s(t HeaderTag) ([]int32, error) {
+	idx, v, err := h.getTag(t)
+	if err != nil {
+		return nil, err
+	}
+	if idx.DataType != DataTypeInt32 {
+		return nil, &TypeMismatchError{Tag: t, Expected: DataTypeInt32, Actual: idx.DataType}
+	}
+	res := make([]int32, idx.Count)
+	for i := range res {
+		res[i] = int32(binary.BigEndian.Uint32(v[4*i:]))
+	}
+	return res, nil
+}
+
+func (h Header) GetInt64s(t HeaderTag) ([]int64, error) {
+	idx, v, err := h.getTag(t)
+	if err != nil {
+		return nil, err
+	}
+	if idx.DataType != DataTypeInt64 {
+		return nil, &TypeMismatchError{Tag: t, Expected: DataTypeInt64, Actual: idx.DataType}
+	}
+	res := make([]int64, idx.Count)
+	for i := range res {
+		res[i] = int64(binary.BigEndian.Uint64(v[8*i:]))
+	}
+	return res, nil
+}
+
+// GetInt returns first value of integer tag regardless of its width,
+// values are treated as unsigned the same way rpm does
+func (h Header) GetInt(t HeaderTag) (int64, error) {
+	idx, v, err := h.getTag(t)
+	if err != nil {
+		return 0, err
+	}
+	if idx.Count < 1 {
+		return 0, fmt.Errorf("%w: empty tag %d", ErrTruncated, t)
+	}
 	switch idx.DataType {
-	case DataTypeNull:
-		return d[idx.Offset:idx.Offset], nil
 	case DataTypeChar, DataTypeInt8:
-		end = int(idx.Offset + idx.Count)
+		return int64(v[0]), nil
 	case DataTypeInt16:
-		end = int(idx.Offset + 2*idx.Count)
+		return int64(binary.BigEndian.Uint16(v)), nil
 	case DataTypeInt32:
-		end = int(idx.Offset + 4*idx.Count)
+		return int64(binary.BigEndian.Uint32(v)), nil
 	case DataTypeInt64:
-		end = int(idx.Offset + 8*idx.Count)
-	case DataTypeString, DataTypeStringArray:
-		cnt := idx.Count
-		if idx.DataType == DataTypeString {
-			cnt = 1
-		}
-		end = int(idx.Offset)
-		for cnt > 0 {
-			next := bytes.IndexByte(d[end:], 0)
-			if next < 0 {
-				return nil, fmt.Errorf("trancuted string")
-			}
-			end += next + 1
-			cnt--
-		}
-	default:
-		return nil, fmt.Errorf("invalid index referense")
+		return int64(binary.BigEndian.Uint64(v)), nil
 	}
+	return 0, &TypeMismatchError{Tag: t, Expected: DataTypeInt32, Actual: idx.DataType}
+}
 
-	return d[idx.Offset:end], nil
+func CStringArrayToSlice(d []byte) []string {
+	res := strings.Split(string(d), "\x00")
+	return res[:len(res)-1]
+}
+
+func readData(idx *HeaderIndexEntry, d []byte) ([]byte, error) {
+	n, err := dataLength(idx, d)
+	if err != nil {
+		return nil, err
+	}
+	return d[idx.Offset : int64(idx.Offset)+n], nil
 }
@@ -0,0 +1,190 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Legacy BerkeleyDB hash database. Pages are scanned in order
+// instead of following hash buckets, header blobs are big enough
+// to always live in overflow pages. Numbers are stored in byte
+// order of the host which created the database.
+
+const (
+	bdbHashMagic        = 0x061561
+	bdbMetaSize         = 72
+	bdbPageHeader       = 26
+	bdbMinPageSize      = 512
+	bdbMaxPageSize      = 64 * 1024
+	bdbPageHashMeta     = 8
+	bdbPageHashUnsorted = 2
+	bdbPageHash         = 13
+	bdbPageOverflow     = 7
+
+	bdbKeyData = 1
+	bdbOffPage = 3
+)
+
+type bdbDB struct {
+	f        *os.File
+	order    binary.ByteOrder
+	pageSize uint32
+	lastPage uint32
+}
+
+func openBDB(path string) (backend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &bdbDB{f: f}
+	if err := db.init(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *bdbDB) init() error {
+	var meta [bdbMetaSize]byte
+	if _, err := db.f.ReadAt(meta[:], 0); err != nil {
+		return readError(err, 0)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(meta[12:]) == bdbHashMagic:
+		db.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(meta[12:]) == bdbHashMagic:
+		db.order = binary.BigEndian
+	default:
+		return fmt.Errorf("%w: not BerkeleyDB hash database", ErrUnsupported)
+	}
+	if meta[25] != bdbPageHashMeta {
+		return fmt.Errorf("%w: bad hash metadata page", ErrCorrupt)
+	}
+	if meta[24] != 0 {
+		return fmt.Errorf("%w: encrypted database", ErrUnsupported)
+	}
+	db.pageSize = db.order.Uint32(meta[20:])
+	if db.pageSize < bdbMinPageSize || db.pageSize > bdbMaxPageSize || db.pageSize&(db.pageSize-1) != 0 {
+		return fmt.Errorf("%w: page size %d", ErrCorrupt, db.pageSize)
+	}
+	db.lastPage = db.order.Uint32(meta[32:])
+	st, err := db.f.Stat()
+	if err != nil {
+		return err
+	}
+	if int64(db.lastPage) >= st.Size()/int64(db.pageSize) {
+		return fmt.Errorf("%w: truncated before page %d", ErrCorrupt, db.lastPage)
+	}
+	return nil
+}
+
+func (db *bdbDB) Close() error {
+	return db.f.Close()
+}
+
+func (db *bdbDB) page(pgno uint32) ([]byte, error) {
+	pg := make([]byte, db.pageSize)
+	off := int64(pgno) * int64(db.pageSize)
+	if _, err := db.f.ReadAt(pg, off); err != nil {
+		return nil, readError(err, off)
+	}
+	return pg, nil
+}
+
+func (db *bdbDB) walk(fn func(instance uint32, blob []byte) error) error {
+	for pgno := uint32(1); pgno <= db.lastPage; pgno++ {
+		pg, err := db.page(pgno)
+		if err != nil {
+			return err
+		}
+		if t := pg[25]; t != bdbPageHash && t != bdbPageHashUnsorted {
+			continue
+		}
+		items, err := db.items(pgno, pg)
+		if err != nil {
+			return err
+		}
+		// entries alternate between keys and data
+		for i := 0; i+1 < len(items); i += 2 {
+			key, data := items[i], items[i+1]
+			if key[0] != bdbKeyData || len(key) != 5 {
+				return fmt.Errorf("%w: page %d: bad key", ErrCorrupt, pgno)
+			}
+			// record 0 holds next instance number
+			instance := db.order.Uint32(key[1:])
+			if instance == 0 {
+				continue
+			}
+			blob, err := db.value(pgno, data)
+			if err != nil {
+				return err
+			}
+			if err := fn(instance, blob); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// items splits hash page into its entries, items are stored from
+// the end of page backwards
+func (db *bdbDB) items(pgno uint32, pg []byte) ([][]byte, error) {
+	n := int(db.order.Uint16(pg[20:]))
+	if bdbPageHeader+2*n > len(pg) {
+		return nil, fmt.Errorf("%w: page %d: too many entries", ErrCorrupt, pgno)
+	}
+	items := make([][]byte, n)
+	end := len(pg)
+	for i := range items {
+		start := int(db.order.Uint16(pg[bdbPageHeader+2*i:]))
+		if start < bdbPageHeader+2*n || start >= end {
+			return nil, fmt.Errorf("%w: page %d: bad entry offset", ErrCorrupt, pgno)
+		}
+		items[i] = pg[start:end]
+		end = start
+	}
+	return items, nil
+}
+
+// value returns data of hash entry, following overflow pages
+func (db *bdbDB) value(pgno uint32, item []byte) ([]byte, error) {
+	switch item[0] {
+	case bdbKeyData:
+		return item[1:], nil
+	case bdbOffPage:
+	default:
+		return nil, fmt.Errorf("%w: page %d: entry type %d", ErrUnsupported, pgno, item[0])
+	}
+	if len(item) < 12 {
+		return nil, fmt.Errorf("%w: page %d: truncated entry", ErrCorrupt, pgno)
+	}
+	next, size := db.order.Uint32(item[4:]), int64(db.order.Uint32(item[8:]))
+	if size > maxBlobSize || size > int64(db.lastPage)*int64(db.pageSize) {
+		return nil, fmt.Errorf("%w: page %d: entry too big", ErrCorrupt, pgno)
+	}
+	var res []byte
+	for pages := uint32(0); int64(len(res)) < size; pages++ {
+		// every page adds at least a byte, so chain ends, and no
+		// chain is longer than the database
+		if next == 0 || next > db.lastPage || pages >= db.lastPage {
+			return nil, fmt.Errorf("%w: page %d: broken overflow chain", ErrCorrupt, pgno)
+		}
+		pg, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		n := int(db.order.Uint16(pg[22:]))
+		if pg[25] != bdbPageOverflow || n < 1 || bdbPageHeader+n > len(pg) {
+			return nil, fmt.Errorf("%w: page %d: bad overflow page", ErrCorrupt, next)
+		}
+		res = append(res, pg[bdbPageHeader:bdbPageHeader+n]...)
+		next = db.order.Uint32(pg[16:])
+	}
+	if int64(len(res)) != size {
+		return nil, fmt.Errorf("%w: page %d: overflow data size mismatch", ErrCorrupt, pgno)
+	}
+	return res, nil
+}
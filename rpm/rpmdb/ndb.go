@@ -0,0 +1,124 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// ndb Packages.db starts with header and slots locating blobs of
+// packages, blobs follow in 16 byte blocks. All numbers are
+// little-endian.
+
+const (
+	ndbMagic         = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic     = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic     = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+	ndbBlobTailMagic = 'B' | 'l'<<8 | 'b'<<16 | 'E'<<24
+	ndbVersion       = 0
+
+	ndbPageSize     = 4096
+	ndbHeaderSize   = 32
+	ndbSlotSize     = 16
+	ndbBlockSize    = 16
+	ndbBlobHeadSize = 16
+	ndbBlobTailSize = 12
+)
+
+type ndbDB struct {
+	f          *os.File
+	size       int64
+	slotNPages uint32
+}
+
+func openNDB(path string) (backend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var hdr [ndbHeaderSize]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil {
+		f.Close()
+		return nil, readError(err, 0)
+	}
+	db := &ndbDB{f: f, slotNPages: binary.LittleEndian.Uint32(hdr[12:])}
+	if binary.LittleEndian.Uint32(hdr[0:]) != ndbMagic {
+		f.Close()
+		return nil, fmt.Errorf("%w: not ndb database", ErrCorrupt)
+	}
+	if v := binary.LittleEndian.Uint32(hdr[4:]); v != ndbVersion {
+		f.Close()
+		return nil, fmt.Errorf("%w: ndb version %d", ErrUnsupported, v)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	db.size = st.Size()
+	if db.slotNPages < 1 || int64(db.slotNPages)*ndbPageSize > db.size {
+		f.Close()
+		return nil, fmt.Errorf("%w: %d slot pages", ErrCorrupt, db.slotNPages)
+	}
+	return db, nil
+}
+
+func (db *ndbDB) Close() error {
+	return db.f.Close()
+}
+
+func (db *ndbDB) walk(fn func(instance uint32, blob []byte) error) error {
+	slots := make([]byte, int64(db.slotNPages)*ndbPageSize)
+	if _, err := db.f.ReadAt(slots, 0); err != nil {
+		return readError(err, 0)
+	}
+	// database header takes place of the first slots
+	for off := ndbHeaderSize; off < len(slots); off += ndbSlotSize {
+		s := slots[off:]
+		if binary.LittleEndian.Uint32(s) != ndbSlotMagic {
+			return fmt.Errorf("%w: bad slot at offset %d", ErrCorrupt, off)
+		}
+		pkgIdx := binary.LittleEndian.Uint32(s[4:])
+		if pkgIdx == 0 {
+			// free slot
+			continue
+		}
+		blob, err := db.readBlob(pkgIdx, binary.LittleEndian.Uint32(s[8:]), binary.LittleEndian.Uint32(s[12:]))
+		if err != nil {
+			return err
+		}
+		if err := fn(pkgIdx, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBlob reads blob of package stored in cnt blocks at block off
+func (db *ndbDB) readBlob(pkgIdx, off, cnt uint32) ([]byte, error) {
+	size := int64(cnt) * ndbBlockSize
+	if off < db.slotNPages*(ndbPageSize/ndbBlockSize) || size < ndbBlobHeadSize+ndbBlobTailSize || size > maxBlobSize {
+		return nil, fmt.Errorf("%w: package %d has bad blob location", ErrCorrupt, pkgIdx)
+	}
+	start := int64(off) * ndbBlockSize
+	if start+size > db.size {
+		return nil, fmt.Errorf("%w: blob of package %d beyond end of file", ErrCorrupt, pkgIdx)
+	}
+	d := make([]byte, size)
+	if _, err := db.f.ReadAt(d, start); err != nil {
+		return nil, readError(err, start)
+	}
+
+	// head: magic, package index, generation, length
+	// tail: checksum, length, magic
+	tail := d[size-ndbBlobTailSize:]
+	n := binary.LittleEndian.Uint32(d[12:])
+	if binary.LittleEndian.Uint32(d) != ndbBlobMagic || binary.LittleEndian.Uint32(d[4:]) != pkgIdx ||
+		binary.LittleEndian.Uint32(tail[8:]) != ndbBlobTailMagic || binary.LittleEndian.Uint32(tail[4:]) != n {
+		return nil, fmt.Errorf("%w: bad blob of package %d at offset %d", ErrCorrupt, pkgIdx, start)
+	}
+	if int64(n) > size-ndbBlobHeadSize-ndbBlobTailSize {
+		return nil, fmt.Errorf("%w: blob of package %d overflows its blocks", ErrCorrupt, pkgIdx)
+	}
+	return d[ndbBlobHeadSize : ndbBlobHeadSize+n], nil
+}
@@ -0,0 +1,173 @@
+// Package rpmdb reads installed package database of rpm without
+// librpm. Packages.sqlite, ndb Packages.db and legacy BerkeleyDB
+// Packages backends are supported, databases are opened read-only.
+package rpmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var (
+	ErrNoDatabase  = errors.New("no rpm database found")
+	ErrCorrupt     = errors.New("corrupt rpm database")
+	ErrUnsupported = errors.New("unsupported rpm database")
+)
+
+// maxBlobSize bounds records read from database files
+const maxBlobSize = 1 << 30
+
+// database directories relative to root, rpm moved to sysimage
+// with /var/lib/rpm kept as symlink which may be absolute
+var dbDirs = []string{"usr/lib/sysimage/rpm", "var/lib/rpm"}
+
+// backend files in the order of preference
+var backends = []struct {
+	name string
+	open func(path string) (backend, error)
+}{
+	{"Packages.sqlite", openSqlite},
+	{"Packages.db", openNDB},
+	{"Packages", openBDB},
+}
+
+type backend interface {
+	// walk calls fn with header blob of each package
+	walk(fn func(instance uint32, blob []byte) error) error
+	Close() error
+}
+
+// DB is read-only handle of rpm database
+type DB struct {
+	// Path of the database file backend reads
+	Path   string
+	Limits rpm.HeaderLimits
+	be     backend
+}
+
+// Package is header of installed package with its database instance
+type Package struct {
+	Instance uint32
+	Header   *rpm.Header
+}
+
+// Open opens rpm database of system installed in root
+func Open(root string) (*DB, error) {
+	for _, dir := range dbDirs {
+		// absolute symlinks are relative to root, not to host
+		path, err := rpmutil.ResolvePath(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		db, err := OpenDir(path)
+		if !errors.Is(err, ErrNoDatabase) {
+			return db, err
+		}
+	}
+	return nil, fmt.Errorf("%w in %s", ErrNoDatabase, root)
+}
+
+// OpenDir opens rpm database stored in dir
+func OpenDir(dir string) (*DB, error) {
+	for _, b := range backends {
+		path := filepath.Join(dir, b.name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		be, err := b.open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return &DB{Path: path, Limits: rpm.DefaultHeaderLimits, be: be}, nil
+	}
+	return nil, fmt.Errorf("%w in %s", ErrNoDatabase, dir)
+}
+
+func (db *DB) Close() error {
+	return db.be.Close()
+}
+
+// Walk calls fn for every installed package, walk stops at the
+// first error fn returns
+func (db *DB) Walk(fn func(pkg *Package) error) error {
+	return db.be.walk(func(instance uint32, blob []byte) error {
+		h, err := rpm.ReadHeaderBlob(bytes.NewReader(blob), db.Limits)
+		if err != nil {
+			return fmt.Errorf("header %d: %w", instance, err)
+		}
+		return fn(&Package{Instance: instance, Header: h})
+	})
+}
+
+// Packages returns all installed packages
+func (db *DB) Packages() ([]*Package, error) {
+	return db.find(func(*Package) (bool, error) { return true, nil })
+}
+
+// FindByName returns installed packages called name
+func (db *DB) FindByName(name string) ([]*Package, error) {
+	return db.find(func(pkg *Package) (bool, error) {
+		n, err := pkg.Header.GetString(rpm.TagName)
+		return n == name, err
+	})
+}
+
+// FindByFile returns packages owning path
+func (db *DB) FindByFile(path string) ([]*Package, error) {
+	path = filepath.Clean(path)
+	return db.find(func(pkg *Package) (bool, error) {
+		return hasString(pkg.Header, rpm.TagFilenames, path)
+	})
+}
+
+// FindByProvide returns packages providing capability name, files
+// aren't considered capabilities here, use FindByFile for them
+func (db *DB) FindByProvide(name string) ([]*Package, error) {
+	return db.find(func(pkg *Package) (bool, error) {
+		return hasString(pkg.Header, rpm.TagProvideName, name)
+	})
+}
+
+func (db *DB) find(match func(pkg *Package) (bool, error)) ([]*Package, error) {
+	var res []*Package
+	err := db.Walk(func(pkg *Package) error {
+		ok, err := match(pkg)
+		if err != nil {
+			return fmt.Errorf("header %d: %w", pkg.Instance, err)
+		}
+		if ok {
+			res = append(res, pkg)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// hasString reports whether string array tag contains s, packages
+// without the tag contain nothing
+func hasString(h *rpm.Header, t rpm.HeaderTag, s string) (bool, error) {
+	values, err := h.GetStrings(t)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return false, nil
+	}
+	for _, v := range values {
+		if v == s {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+func readError(err error, offset int64) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated at offset %d", ErrCorrupt, offset)
+	}
+	return err
+}
@@ -0,0 +1,248 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// testdata holds the same packages in every backend: filesystem,
+// bash and big, whose header spans several pages
+var testBackends = []string{"Packages.sqlite", "Packages.db", "Packages"}
+
+// testRoot returns root with database file name holding d in
+// /usr/lib/sysimage/rpm and /var/lib/rpm linked to it
+func testRoot(t *testing.T, name string, d []byte) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "usr/lib/sysimage/rpm")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "var/lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/lib/sysimage/rpm", filepath.Join(root, "var/lib/rpm")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), d, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// writeDB replaces database file at path with d, the new file is
+// written from scratch
+func writeDB(t *testing.T, path string, d []byte) {
+	t.Helper()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, d, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestDB(t *testing.T, name string) []byte {
+	t.Helper()
+	d, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// readPackages opens database of root and reads all of its packages
+func readPackages(root string) ([]*Package, error) {
+	db, err := Open(root)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.Packages()
+}
+
+func names(t *testing.T, pkgs []*Package) []string {
+	t.Helper()
+	var res []string
+	for _, p := range pkgs {
+		n, err := p.Header.GetString(rpm.TagName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, fmt.Sprintf("%d:%s", p.Instance, n))
+	}
+	return res
+}
+
+func TestOpen(t *testing.T) {
+	for _, name := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			root := testRoot(t, name, readTestDB(t, name))
+			defer os.RemoveAll(root)
+			db, err := Open(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if expected := filepath.Join(root, "usr/lib/sysimage/rpm", name); db.Path != expected {
+				t.Errorf("got path %s, expected %s", db.Path, expected)
+			}
+
+			pkgs, err := db.Packages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, expected := names(t, pkgs), []string{"1:filesystem", "2:bash", "3:big"}; !reflect.DeepEqual(got, expected) {
+				t.Errorf("got %v, expected %v", got, expected)
+			}
+			if files, _ := pkgs[2].Header.GetStrings(rpm.TagFilenames); len(files) != 300 || files[299] != "/usr/share/big/file299" {
+				t.Errorf("big header read wrong: %d files", len(files))
+			}
+
+			for _, f := range []struct {
+				find     func(string) ([]*Package, error)
+				arg      string
+				expected []string
+			}{
+				{db.FindByName, "bash", []string{"2:bash"}},
+				{db.FindByName, "zsh", nil},
+				{db.FindByFile, "/usr/bin/sh", []string{"2:bash"}},
+				{db.FindByFile, "/usr/bin/../bin", []string{"1:filesystem"}},
+				{db.FindByFile, "/usr/share/big/file150", []string{"3:big"}},
+				{db.FindByProvide, "/bin/sh", []string{"2:bash"}},
+				// files aren't capabilities
+				{db.FindByProvide, "/usr/bin/bash", nil},
+			} {
+				pkgs, err := f.find(f.arg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := names(t, pkgs); !reflect.DeepEqual(got, f.expected) {
+					t.Errorf("%s: got %v, expected %v", f.arg, got, f.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenNoDatabase(t *testing.T) {
+	root, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if _, err := Open(root); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("got %v, expected %v", err, ErrNoDatabase)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, name := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			d := readTestDB(t, name)
+			root := testRoot(t, name, nil)
+			defer os.RemoveAll(root)
+			path := filepath.Join(root, "usr/lib/sysimage/rpm", name)
+			for n := 0; n < len(d); n += 61 {
+				writeDB(t, path, d[:n])
+				// sqlite may lose pages of other tables only
+				pkgs, err := readPackages(root)
+				if !errors.Is(err, ErrCorrupt) && (err != nil || len(pkgs) != 3) {
+					t.Fatalf("truncated at %d: got %d packages, error %v, expected corrupt database", n, len(pkgs), err)
+				}
+			}
+		})
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	le := binary.LittleEndian
+	for _, c := range []struct {
+		name, db string
+		corrupt  func(d []byte)
+		err      error
+	}{
+		{"magic", "Packages.sqlite", func(d []byte) { d[0] = 'X' }, ErrCorrupt},
+		{"page size", "Packages.sqlite", func(d []byte) { d[16], d[17] = 0x10, 0x01 }, ErrCorrupt},
+		{"no table", "Packages.sqlite", func(d []byte) { copy(d, bytes.Replace(d[:4096], []byte("Packages"), []byte("Packagez"), -1)) }, ErrCorrupt},
+		{"magic", "Packages.db", func(d []byte) { d[0] = 'X' }, ErrCorrupt},
+		{"version", "Packages.db", func(d []byte) { le.PutUint32(d[4:], 1) }, ErrUnsupported},
+		{"slot pages", "Packages.db", func(d []byte) { le.PutUint32(d[12:], 0xfffff) }, ErrCorrupt},
+		{"slot", "Packages.db", func(d []byte) { d[32+16] = 'X' }, ErrCorrupt},
+		{"blob location", "Packages.db", func(d []byte) { le.PutUint32(d[32+16+8:], 1) }, ErrCorrupt},
+		{"blob beyond end", "Packages.db", func(d []byte) { le.PutUint32(d[32+16+12:], 1<<24) }, ErrCorrupt},
+		{"blob", "Packages.db", func(d []byte) { d[4096] = 'X' }, ErrCorrupt},
+		{"blob length", "Packages.db", func(d []byte) { le.PutUint32(d[4096+12:], 1<<20) }, ErrCorrupt},
+		{"magic", "Packages", func(d []byte) { d[12] = 0 }, ErrUnsupported},
+		{"encrypted", "Packages", func(d []byte) { d[24] = 1 }, ErrUnsupported},
+		{"page size", "Packages", func(d []byte) { le.PutUint32(d[20:], 4095) }, ErrCorrupt},
+		{"last page", "Packages", func(d []byte) { le.PutUint32(d[32:], 100) }, ErrCorrupt},
+		{"entries", "Packages", func(d []byte) { le.PutUint16(d[4096+20:], 4000) }, ErrCorrupt},
+		{"entry offset", "Packages", func(d []byte) { le.PutUint16(d[4096+26:], 10) }, ErrCorrupt},
+		// data of bash
+		{"entry type", "Packages", func(d []byte) { d[4096+4069] = 9 }, ErrUnsupported},
+		// overflow page of big refers to itself
+		{"overflow loop", "Packages", func(d []byte) { le.PutUint32(d[4*4096+16:], 4) }, ErrCorrupt},
+		{"overflow loop of maximum size", "Packages", func(d []byte) {
+			le.PutUint32(d[4*4096+16:], 4)
+			i := bytes.Index(d[4096:8192], []byte{3, 0, 0, 0, 4, 0, 0, 0})
+			le.PutUint32(d[4096+i+8:], maxBlobSize)
+		}, ErrCorrupt},
+		{"overflow size", "Packages", func(d []byte) {
+			i := bytes.Index(d[4096:8192], []byte{3, 0, 0, 0, 4, 0, 0, 0})
+			le.PutUint32(d[4096+i+8:], maxBlobSize)
+		}, ErrCorrupt},
+		{"overflow page", "Packages", func(d []byte) { d[2*4096+25] = bdbPageHash }, ErrCorrupt},
+	} {
+		t.Run(c.db+"/"+c.name, func(t *testing.T) {
+			d := readTestDB(t, c.db)
+			c.corrupt(d)
+			root := testRoot(t, c.db, d)
+			defer os.RemoveAll(root)
+			if _, err := readPackages(root); !errors.Is(err, c.err) {
+				t.Errorf("got %v, expected %v", err, c.err)
+			}
+		})
+	}
+}
+
+// TestGarbage damages databases at random, they must fail or read
+// fine but never panic
+func TestGarbage(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, name := range testBackends {
+		t.Run(name, func(t *testing.T) {
+			orig := readTestDB(t, name)
+			root := testRoot(t, name, nil)
+			defer os.RemoveAll(root)
+			path := filepath.Join(root, "usr/lib/sysimage/rpm", name)
+			d := make([]byte, len(orig))
+			for i := 0; i < 300; i++ {
+				copy(d, orig)
+				for n := r.Intn(8); n >= 0; n-- {
+					// mostly metadata of the first pages
+					off := r.Intn(len(d))
+					if r.Intn(2) == 0 {
+						off = r.Intn(8192)
+					}
+					d[off] = byte(r.Intn(256))
+				}
+				writeDB(t, path, d)
+				readPackages(root)
+			}
+		})
+	}
+}
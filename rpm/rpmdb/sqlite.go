@@ -0,0 +1,56 @@
+package rpmdb
+
+import (
+	"errors"
+	"fmt"
+
+	"code.pikelabs.net/go/database/sqlite"
+)
+
+const packagesTable = "Packages"
+
+type sqliteDB struct {
+	db *sqlite.DB
+}
+
+func openSqlite(path string) (backend, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return &sqliteDB{db: db}, nil
+}
+
+func (db *sqliteDB) Close() error {
+	return db.db.Close()
+}
+
+func (db *sqliteDB) walk(fn func(instance uint32, blob []byte) error) error {
+	t, err := db.db.Table(packagesTable)
+	if err != nil {
+		return sqliteError(err)
+	}
+	err = db.db.Scan(t, func(rowid int64, rec []interface{}) error {
+		// hnum is rowid alias, blob is the second column
+		if len(rec) < 2 {
+			return fmt.Errorf("%w: short %s row %d", ErrCorrupt, packagesTable, rowid)
+		}
+		blob, ok := rec[1].([]byte)
+		if !ok {
+			return fmt.Errorf("%w: %s row %d has no blob", ErrCorrupt, packagesTable, rowid)
+		}
+		return fn(uint32(rowid), blob)
+	})
+	return sqliteError(err)
+}
+
+// sqliteError maps errors of sqlite reader to the ones of rpmdb
+func sqliteError(err error) error {
+	switch {
+	case errors.Is(err, sqlite.ErrCorrupt), errors.Is(err, sqlite.ErrNoTable):
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	case errors.Is(err, sqlite.ErrUnsupported):
+		return fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return err
+}
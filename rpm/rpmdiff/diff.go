@@ -0,0 +1,566 @@
+// Package rpmdiff compares two packages: header tags, dependencies,
+// files, scriptlets and contents of text files in payload.
+package rpmdiff
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// Options tune what Compare looks at
+type Options struct {
+	// Contents enables unified diffs of changed text files, payload
+	// of both packages is read, so it can't be read again
+	Contents bool
+	// MaxContentSize bounds size of files diffed by content
+	MaxContentSize int64
+	// IgnoreTags are left out of comparison of header tags
+	IgnoreTags []rpm.HeaderTag
+	// MTime makes modification time of files part of comparison
+	MTime bool
+}
+
+// DefaultOptions compare everything but contents of files
+var DefaultOptions = Options{MaxContentSize: 1 << 20}
+
+// Change kinds
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// TagChange is header tag which differs, values are formatted for
+// display and empty for missing tag
+type TagChange struct {
+	Tag  rpm.HeaderTag `json:"tag"`
+	Name string        `json:"name,omitempty"`
+	Old  string        `json:"old"`
+	New  string        `json:"new"`
+}
+
+// DepChange is dependency added or removed
+type DepChange struct {
+	Kind       rpm.DependencyKind `json:"kind"`
+	Change     string             `json:"change"`
+	Dependency string             `json:"dependency"`
+}
+
+// FileChange is file added, removed or changed, Attrs are attributes
+// of changed file which differ
+type FileChange struct {
+	Path   string          `json:"path"`
+	Change string          `json:"change"`
+	Attrs  rpm.VerifyFlags `json:"-"`
+	// Diff is unified diff of text file contents
+	Diff string `json:"diff,omitempty"`
+}
+
+func (c FileChange) MarshalJSON() ([]byte, error) {
+	var attrs []string
+	if c.Change == Changed {
+		attrs = c.Attrs.Names()
+	}
+	return json.Marshal(struct {
+		Path   string   `json:"path"`
+		Change string   `json:"change"`
+		Attrs  []string `json:"attrs,omitempty"`
+		Diff   string   `json:"diff,omitempty"`
+	}{c.Path, c.Change, attrs, c.Diff})
+}
+
+// ScriptChange is scriptlet or trigger added, removed or changed
+type ScriptChange struct {
+	Script string `json:"script"`
+	Change string `json:"change"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// Result lists all differences of packages
+type Result struct {
+	Old     string         `json:"old"`
+	New     string         `json:"new"`
+	Tags    []TagChange    `json:"tags"`
+	Deps    []DepChange    `json:"dependencies"`
+	Files   []FileChange   `json:"files"`
+	Scripts []ScriptChange `json:"scripts"`
+}
+
+// Empty reports whether packages don't differ at all
+func (r *Result) Empty() bool {
+	return len(r.Tags) == 0 && len(r.Deps) == 0 && len(r.Files) == 0 && len(r.Scripts) == 0
+}
+
+// tags compared in their own sections
+var sectionTags = map[rpm.HeaderTag]bool{}
+
+func init() {
+	for _, t := range []rpm.HeaderTag{
+		rpm.TagOldFilenames, rpm.TagBaseNames, rpm.TagDirNames, rpm.TagDirIndexes,
+		rpm.TagFileSizes, rpm.TagLongFileSizes, rpm.TagFileStates, rpm.TagFileModes,
+		rpm.TagFileUIDs, rpm.TagFileGIDs, rpm.TagFileRDevs, rpm.TagFileMTimes,
+		rpm.TagFileMD5S, rpm.TagFileLinkTos, rpm.TagFileFlags, rpm.TagFileUsername,
+		rpm.TagFileGroupname, rpm.TagFileVerifyFlags, rpm.TagFileDevices, rpm.TagFileInodes,
+		rpm.TagFileLangs, rpm.TagFileColors, rpm.TagFileClass, rpm.TagClassDict,
+		rpm.TagFileDependsX, rpm.TagFileDependsN, rpm.TagDependsDict,
+		rpm.TagPrein, rpm.TagPostin, rpm.TagPreun, rpm.TagPostun, rpm.TagPretrans,
+		rpm.TagPosttrans, rpm.TagVerifyScript, rpm.TagPreinProg, rpm.TagPostinProg,
+		rpm.TagPreunProg, rpm.TagPostunProg, rpm.TagPretransProg, rpm.TagPosttransProg,
+		rpm.TagVerifyScriptProg, rpm.TagPreinFlags, rpm.TagPostinFlags, rpm.TagPreunFlags,
+		rpm.TagPostunFlags, rpm.TagPretransFlags, rpm.TagPosttransFlags, rpm.TagVerifyScriptFlags,
+		rpm.TagTriggerScripts, rpm.TagTriggerScriptProg, rpm.TagTriggerScriptFlags,
+		rpm.TagTriggerName, rpm.TagTriggerIndex, rpm.TagTriggerVersion, rpm.TagTriggerFlags,
+		rpm.TagFileTriggerScripts, rpm.TagFileTriggerScriptProg, rpm.TagFileTriggerScriptFlags,
+		rpm.TagFileTriggerName, rpm.TagFileTriggerIndex, rpm.TagFileTriggerVersion,
+		rpm.TagFileTriggerFlags, rpm.TagFileTriggerPriorities,
+		rpm.TagTransFileTriggerScripts, rpm.TagTransFileTriggerScriptProg,
+		rpm.TagTransFileTriggerScriptFlags, rpm.TagTransFileTriggerName,
+		rpm.TagTransFileTriggerIndex, rpm.TagTransFileTriggerVersion,
+		rpm.TagTransFileTriggerFlags, rpm.TagTransFileTriggerPriorities,
+	} {
+		sectionTags[t] = true
+	}
+	for _, kind := range rpm.DependencyKinds {
+		name, flags, version := rpm.DependencyTags(kind)
+		sectionTags[name], sectionTags[flags], sectionTags[version] = true, true, true
+	}
+}
+
+// Compare compares old package with the new one
+func Compare(old, new *rpmutil.Package, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	res := &Result{Old: nevra(old.Header), New: nevra(new.Header)}
+	res.Tags = CompareTags(old.Header, new.Header, opts.IgnoreTags)
+
+	var err error
+	if res.Deps, err = compareDeps(old.Header, new.Header); err != nil {
+		return nil, err
+	}
+	if res.Files, err = compareFiles(old, new, opts); err != nil {
+		return nil, err
+	}
+	if res.Scripts, err = compareScripts(old, new); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CompareTags compares header tags of packages apart from files,
+// dependencies and scriptlets, which have sections of their own
+func CompareTags(old, new *rpm.Header, ignore []rpm.HeaderTag) []TagChange {
+	skip := map[rpm.HeaderTag]bool{}
+	for t := range sectionTags {
+		skip[t] = true
+	}
+	for _, t := range ignore {
+		skip[t] = true
+	}
+	return compareTags(old, new, skip)
+}
+
+// regionTags differ whenever set of tags does
+var regionTags = []rpm.HeaderTag{rpm.TagHeaderImmutable, rpm.TagHeaderImage, rpm.TagHeaderSignatures}
+
+func compareTags(old, new *rpm.Header, skip map[rpm.HeaderTag]bool) []TagChange {
+	tags := map[rpm.HeaderTag]bool{}
+	for _, h := range []*rpm.Header{old, new} {
+		for _, t := range h.AvailableTags() {
+			if !skip[t] {
+				tags[t] = true
+			}
+		}
+	}
+	for _, t := range regionTags {
+		delete(tags, t)
+	}
+	sorted := make([]rpm.HeaderTag, 0, len(tags))
+	for t := range tags {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var res []TagChange
+	for _, t := range sorted {
+		ot, od, oerr := old.GetTag(t)
+		nt, nd, nerr := new.GetTag(t)
+		if oerr == nil && nerr == nil && ot == nt && bytes.Equal(od, nd) {
+			continue
+		}
+		res = append(res, TagChange{
+			Tag:  t,
+			Name: rpm.HeaderNames[t],
+			Old:  FormatTag(old, t),
+			New:  FormatTag(new, t),
+		})
+	}
+	return res
+}
+
+// maxBinDisplay bounds length of binary tag data shown
+const maxBinDisplay = 32
+
+// FormatTag formats value of tag for display, empty for missing tag
+func FormatTag(h *rpm.Header, t rpm.HeaderTag) string {
+	dt, d, err := h.GetTag(t)
+	if err != nil {
+		return ""
+	}
+	switch dt {
+	case rpm.DataTypeString, rpm.DataTypeI18NString:
+		s, _ := h.GetI18NString(t, rpm.DefaultLocale)
+		return s
+	case rpm.DataTypeStringArray:
+		return strings.Join(rpm.CStringArrayToSlice(d), ", ")
+	case rpm.DataTypeChar, rpm.DataTypeInt8, rpm.DataTypeInt16, rpm.DataTypeInt32, rpm.DataTypeInt64:
+		return formatInts(h, t, dt)
+	}
+	if len(d) > maxBinDisplay {
+		return hex.EncodeToString(d[:maxBinDisplay]) + "..."
+	}
+	return hex.EncodeToString(d)
+}
+
+func formatInts(h *rpm.Header, t rpm.HeaderTag, dt rpm.HeaderDataType) string {
+	var values []string
+	switch dt {
+	case rpm.DataTypeInt16:
+		v, _ := h.GetInt16s(t)
+		for _, n := range v {
+			values = append(values, strconv.Itoa(int(uint16(n))))
+		}
+	case rpm.DataTypeInt32:
+		v, _ := h.GetInt32s(t)
+		for _, n := range v {
+			values = append(values, strconv.FormatUint(uint64(uint32(n)), 10))
+		}
+	case rpm.DataTypeInt64:
+		v, _ := h.GetInt64s(t)
+		for _, n := range v {
+			values = append(values, strconv.FormatUint(uint64(n), 10))
+		}
+	default:
+		_, d, _ := h.GetTag(t)
+		for _, n := range d {
+			values = append(values, strconv.Itoa(int(n)))
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func compareDeps(old, new *rpm.Header) ([]DepChange, error) {
+	var res []DepChange
+	for _, kind := range rpm.DependencyKinds {
+		od, err := old.Dependencies(kind)
+		if err != nil {
+			return nil, err
+		}
+		nd, err := new.Dependencies(kind)
+		if err != nil {
+			return nil, err
+		}
+		removed, added := diffSets(depStrings(od), depStrings(nd))
+		for _, d := range removed {
+			res = append(res, DepChange{Kind: kind, Change: Removed, Dependency: d})
+		}
+		for _, d := range added {
+			res = append(res, DepChange{Kind: kind, Change: Added, Dependency: d})
+		}
+	}
+	return res, nil
+}
+
+func depStrings(deps []rpm.Dependency) []string {
+	s := make([]string, len(deps))
+	for i, d := range deps {
+		s[i] = d.String()
+	}
+	return s
+}
+
+// diffSets returns sorted strings only in a and only in b
+func diffSets(a, b []string) ([]string, []string) {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, s := range a {
+		inA[s] = true
+	}
+	for _, s := range b {
+		inB[s] = true
+	}
+	var onlyA, onlyB []string
+	for s := range inA {
+		if !inB[s] {
+			onlyA = append(onlyA, s)
+		}
+	}
+	for s := range inB {
+		if !inA[s] {
+			onlyB = append(onlyB, s)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return onlyA, onlyB
+}
+
+func compareFiles(old, new *rpmutil.Package, opts *Options) ([]FileChange, error) {
+	of, err := old.Files()
+	if err != nil {
+		return nil, err
+	}
+	nf, err := new.Files()
+	if err != nil {
+		return nil, err
+	}
+	oldFiles := map[string]*rpmutil.FileInfo{}
+	for i := range of {
+		oldFiles[of[i].Name] = &of[i]
+	}
+	newFiles := map[string]*rpmutil.FileInfo{}
+	for i := range nf {
+		newFiles[nf[i].Name] = &nf[i]
+	}
+
+	var res []FileChange
+	// text files to diff by content
+	diffs := map[string]int{}
+	for _, f := range of {
+		if _, ok := newFiles[f.Name]; !ok {
+			res = append(res, FileChange{Path: f.Name, Change: Removed})
+		}
+	}
+	for _, f := range nf {
+		o, ok := oldFiles[f.Name]
+		if !ok {
+			res = append(res, FileChange{Path: f.Name, Change: Added})
+			continue
+		}
+		attrs := fileAttrs(o, &f, opts.MTime)
+		if attrs == rpm.VerifyNone {
+			continue
+		}
+		if attrs&rpm.VerifyDigest != 0 && o.Size <= opts.MaxContentSize && f.Size <= opts.MaxContentSize {
+			diffs[f.Name] = len(res)
+		}
+		res = append(res, FileChange{Path: f.Name, Change: Changed, Attrs: attrs})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+
+	if !opts.Contents || len(diffs) == 0 {
+		return res, nil
+	}
+	oc, err := readContents(old, diffs, opts.MaxContentSize)
+	if err != nil {
+		return nil, err
+	}
+	nc, err := readContents(new, diffs, opts.MaxContentSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		c := &res[i]
+		if _, ok := diffs[c.Path]; !ok || !isText(oc[c.Path]) || !isText(nc[c.Path]) {
+			continue
+		}
+		c.Diff = Unified("a"+c.Path, "b"+c.Path, string(oc[c.Path]), string(nc[c.Path]))
+	}
+	return res, nil
+}
+
+// fileAttrs returns attributes of file which differ
+func fileAttrs(o, n *rpmutil.FileInfo, mtime bool) rpm.VerifyFlags {
+	var attrs rpm.VerifyFlags
+	if o.Size != n.Size {
+		attrs |= rpm.VerifySize
+	}
+	if o.Mode != n.Mode {
+		attrs |= rpm.VerifyMode
+	}
+	if o.Digest != n.Digest {
+		attrs |= rpm.VerifyDigest
+	}
+	if o.Rdev != n.Rdev {
+		attrs |= rpm.VerifyRdev
+	}
+	if o.LinkTo != n.LinkTo {
+		attrs |= rpm.VerifyLinkTo
+	}
+	if o.User != n.User {
+		attrs |= rpm.VerifyUser
+	}
+	if o.Group != n.Group {
+		attrs |= rpm.VerifyGroup
+	}
+	if mtime && !o.MTime.Equal(n.MTime) {
+		attrs |= rpm.VerifyMTime
+	}
+	return attrs
+}
+
+// readContents reads contents of files listed in paths from payload
+func readContents(pkg *rpmutil.Package, paths map[string]int, max int64) (map[string][]byte, error) {
+	pl, err := pkg.Payload()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]byte{}
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(h.Name, ".")
+		// hardlinked files carry contents with the last link only
+		if _, ok := paths[name]; !ok || h.Size == 0 || h.Size > max {
+			continue
+		}
+		if res[name], err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func isText(d []byte) bool {
+	return bytes.IndexByte(d, 0) < 0 && utf8.Valid(d)
+}
+
+func compareScripts(old, new *rpmutil.Package) ([]ScriptChange, error) {
+	olds, err := scripts(old)
+	if err != nil {
+		return nil, err
+	}
+	news, err := scripts(new)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for name := range olds {
+		names[name] = true
+	}
+	for name := range news {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var res []ScriptChange
+	for _, name := range sorted {
+		o, inOld := olds[name]
+		n, inNew := news[name]
+		switch {
+		case !inOld:
+			res = append(res, ScriptChange{Script: name, Change: Added, Diff: Unified("/dev/null", "b/"+name, "", n)})
+		case !inNew:
+			res = append(res, ScriptChange{Script: name, Change: Removed, Diff: Unified("a/"+name, "/dev/null", o, "")})
+		case o != n:
+			res = append(res, ScriptChange{Script: name, Change: Changed, Diff: Unified("a/"+name, "b/"+name, o, n)})
+		}
+	}
+	return res, nil
+}
+
+// scripts returns scriptlets and triggers of package keyed by their
+// phase or trigger conditions, interpreter is the first line of text
+func scripts(pkg *rpmutil.Package) (map[string]string, error) {
+	res := map[string]string{}
+	scriptlets, err := pkg.Scriptlets()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scriptlets {
+		res[string(s.Phase)] = scriptText(&s.Script)
+	}
+	triggers, err := pkg.Triggers()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range triggers {
+		kind := string(t.Type)
+		if t.Transaction {
+			kind = "transfile" + kind
+		} else if t.File {
+			kind = "file" + kind
+		}
+		conds := make([]string, len(t.Conditions))
+		for i, c := range t.Conditions {
+			conds[i] = rpm.Dependency{Name: c.Name, Flags: c.Flags, Version: c.Version}.String()
+		}
+		res[kind+" "+strings.Join(conds, ", ")] = scriptText(&t.Script)
+	}
+	return res, nil
+}
+
+func scriptText(s *rpmutil.Script) string {
+	interp := strings.Join(append([]string{s.Interpreter}, s.Args...), " ")
+	body := s.Body
+	if len(body) > 0 && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return "#!" + interp + "\n" + body
+}
+
+// nevra returns name-[epoch:]version-release.arch of package
+func nevra(h *rpm.Header) string {
+	name, _ := h.GetString(rpm.TagName)
+	evr := rpm.EVR{}
+	evr.Version, _ = h.GetString(rpm.TagVersion)
+	evr.Release, _ = h.GetString(rpm.TagRelease)
+	if e, err := h.GetInt(rpm.TagEpoch); err == nil {
+		evr.Epoch = int(e)
+	}
+	s := name + "-" + evr.String()
+	if arch, err := h.GetString(rpm.TagArch); err == nil {
+		s += "." + arch
+	}
+	return s
+}
+
+// WriteText writes differences in human readable form, attributes
+// of changed files in rpm -V notation
+func (r *Result) WriteText(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", r.Old, r.New)
+	for _, t := range r.Tags {
+		name := t.Name
+		if len(name) == 0 {
+			name = strconv.Itoa(int(t.Tag))
+		}
+		fmt.Fprintf(&b, "tag %s: %q -> %q\n", name, t.Old, t.New)
+	}
+	for _, d := range r.Deps {
+		fmt.Fprintf(&b, "%-9s %s %s\n", d.Change, strings.ToUpper(string(d.Kind)), d.Dependency)
+	}
+	for _, f := range r.Files {
+		if f.Change == Changed {
+			fmt.Fprintf(&b, "%-9s %s\n", f.Attrs, f.Path)
+		} else {
+			fmt.Fprintf(&b, "%-9s %s\n", f.Change, f.Path)
+		}
+		b.WriteString(f.Diff)
+	}
+	for _, s := range r.Scripts {
+		fmt.Fprintf(&b, "%-9s %%%s\n", s.Change, s.Script)
+		b.WriteString(s.Diff)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
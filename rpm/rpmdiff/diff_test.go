@@ -0,0 +1,166 @@
+package rpmdiff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+func newPackage(t *testing.T, h *rpm.Header, files []rpmtest.File) *rpmutil.Package {
+	t.Helper()
+	var b bytes.Buffer
+	lead := rpm.NewLead(nevra(h), "noarch", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(rpmtest.Payload(files...))); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := rpmutil.ReadPackage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestCompare(t *testing.T) {
+	oldFiles := []rpmtest.File{
+		{Name: "/etc/hello.conf", Mode: 0100644, Data: "a\nb\n", Flags: rpm.FileConfig},
+		{Name: "/usr/bin/hello", Mode: 0100755, Data: "\x7fELF\x00v1", MTime: 1000},
+		{Name: "/usr/share/hello/old", Mode: 0100644},
+		{Name: "/usr/share/hello/same", Mode: 0100644, Data: "same", MTime: 1000},
+	}
+	newFiles := []rpmtest.File{
+		{Name: "/etc/hello.conf", Mode: 0100644, Data: "a\nc\n", Flags: rpm.FileConfig},
+		{Name: "/usr/bin/hello", Mode: 0100711, Data: "\x7fELF\x00v2!!", MTime: 2000},
+		{Name: "/usr/share/hello/new", Mode: 0100644},
+		// modification time is compared only on request
+		{Name: "/usr/share/hello/same", Mode: 0100644, Data: "same", MTime: 2000},
+	}
+	oh := rpmtest.Header("hello", oldFiles...)
+	oh.SetString(rpm.TagSummary, "old summary")
+	oh.SetStrings(rpm.TagRequireName, []string{"bash"})
+	oh.SetInt32s(rpm.TagRequireFlags, []int32{0})
+	oh.SetStrings(rpm.TagRequireVersion, []string{""})
+	oh.SetString(rpm.TagPrein, "echo 1")
+	nh := rpmtest.Header("hello", newFiles...)
+	nh.SetString(rpm.TagVersion, "2.0")
+	nh.SetString(rpm.TagSummary, "new summary")
+	nh.SetStrings(rpm.TagRequireName, []string{"bash", "sed"})
+	nh.SetInt32s(rpm.TagRequireFlags, []int32{0, int32(rpm.SenseGreater | rpm.SenseEqual)})
+	nh.SetStrings(rpm.TagRequireVersion, []string{"", "4.0"})
+	nh.SetString(rpm.TagPrein, "echo 2")
+	nh.SetString(rpm.TagPostin, "ldconfig")
+
+	opts := DefaultOptions
+	opts.Contents = true
+	res, err := Compare(newPackage(t, oh, oldFiles), newPackage(t, nh, newFiles), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Old != "hello-1.0-1.noarch" || res.New != "hello-2.0-1.noarch" {
+		t.Errorf("compared %s to %s", res.Old, res.New)
+	}
+	tags := []TagChange{
+		{Tag: rpm.TagVersion, Name: "RPMTAG_VERSION", Old: "1.0", New: "2.0"},
+		{Tag: rpm.TagSummary, Name: "RPMTAG_SUMMARY", Old: "old summary", New: "new summary"},
+	}
+	if !reflect.DeepEqual(res.Tags, tags) {
+		t.Errorf("got tags %+v, expected %+v", res.Tags, tags)
+	}
+	deps := []DepChange{{Kind: rpm.DepRequires, Change: Added, Dependency: "sed >= 4.0"}}
+	if !reflect.DeepEqual(res.Deps, deps) {
+		t.Errorf("got dependencies %+v, expected %+v", res.Deps, deps)
+	}
+
+	files := []FileChange{
+		{Path: "/etc/hello.conf", Change: Changed, Attrs: rpm.VerifyDigest},
+		{Path: "/usr/bin/hello", Change: Changed, Attrs: rpm.VerifySize | rpm.VerifyMode | rpm.VerifyDigest},
+		{Path: "/usr/share/hello/new", Change: Added},
+		{Path: "/usr/share/hello/old", Change: Removed},
+	}
+	if len(res.Files) != len(files) {
+		t.Fatalf("got files %+v, expected %+v", res.Files, files)
+	}
+	for i, f := range res.Files {
+		if f.Path != files[i].Path || f.Change != files[i].Change || f.Attrs != files[i].Attrs {
+			t.Errorf("got %+v, expected %+v", f, files[i])
+		}
+	}
+	// binary files aren't diffed by content
+	if d := res.Files[0].Diff; !strings.Contains(d, "-b\n") || !strings.Contains(d, "+c\n") {
+		t.Errorf("/etc/hello.conf: got diff %q", d)
+	}
+	if d := res.Files[1].Diff; d != "" {
+		t.Errorf("/usr/bin/hello: got diff %q of binary file", d)
+	}
+
+	if len(res.Scripts) != 2 {
+		t.Fatalf("got scripts %+v", res.Scripts)
+	}
+	if s := res.Scripts[0]; s.Script != "postin" || s.Change != Added {
+		t.Errorf("got %+v, expected added postin", s)
+	}
+	if s := res.Scripts[1]; s.Script != "prein" || s.Change != Changed || !strings.Contains(s.Diff, "+echo 2") {
+		t.Errorf("got %+v, expected changed prein", s)
+	}
+
+	opts = DefaultOptions
+	opts.MTime = true
+	opts.IgnoreTags = []rpm.HeaderTag{rpm.TagSummary}
+	res, err = Compare(newPackage(t, oh, oldFiles), newPackage(t, nh, newFiles), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Tags) != 1 || res.Tags[0].Tag != rpm.TagVersion {
+		t.Errorf("got tags %+v with summary ignored", res.Tags)
+	}
+	var mtime bool
+	for _, f := range res.Files {
+		if f.Path == "/usr/share/hello/same" {
+			mtime = f.Attrs == rpm.VerifyMTime
+		}
+	}
+	if !mtime {
+		t.Errorf("got %+v, expected modification time change", res.Files)
+	}
+}
+
+func TestCompareSame(t *testing.T) {
+	files := []rpmtest.File{{Name: "/usr/bin/hello", Mode: 0100755, Data: "hello"}}
+	h := rpmtest.Header("hello", files...)
+	res, err := Compare(newPackage(t, h, files), newPackage(t, h, files), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Empty() {
+		t.Errorf("got %+v, expected no differences", res)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	r := &Result{
+		Old:   "hello-1.0-1.noarch",
+		New:   "hello-2.0-1.noarch",
+		Tags:  []TagChange{{Tag: rpm.TagVersion, Name: "RPMTAG_VERSION", Old: "1.0", New: "2.0"}},
+		Deps:  []DepChange{{Kind: rpm.DepRequires, Change: Removed, Dependency: "sed"}},
+		Files: []FileChange{{Path: "/usr/bin/hello", Change: Changed, Attrs: rpm.VerifySize | rpm.VerifyDigest}, {Path: "/usr/bin/hi", Change: Added}},
+	}
+	var b bytes.Buffer
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `--- hello-1.0-1.noarch
++++ hello-2.0-1.noarch
+tag RPMTAG_VERSION: "1.0" -> "2.0"
+removed   REQUIRES sed
+S.5...... /usr/bin/hello
+added     /usr/bin/hi
+`
+	if b.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
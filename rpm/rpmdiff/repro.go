@@ -0,0 +1,142 @@
+package rpmdiff
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"code.pikelabs.net/go/archive/cpio"
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// NormalizedTags are expected to differ between rebuilds of the same
+// package: time and host of build and everything which depends on
+// how payload is compressed. Signature header is left out as whole.
+var NormalizedTags = []rpm.HeaderTag{
+	rpm.TagBuildTime,
+	rpm.TagBuildHost,
+	rpm.TagCookie,
+	rpm.TagPayloadCompressor,
+	rpm.TagPayloadFlags,
+	rpm.TagPayloadDigest,
+	rpm.TagPayloadDigestAlgo,
+	rpm.TagPayloadSize,
+	rpm.TagPayloadSHA512,
+	rpm.TagPayloadSHA3_256,
+}
+
+// Reproducible compares rebuilds of package bit by bit apart from
+// NormalizedTags and IgnoreTags of opts. Every other tag of header is
+// compared as raw data, payloads are compared decompressed entry by
+// entry. Payloads are read, so packages can't be read again.
+func Reproducible(old, new *rpmutil.Package, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	skip := map[rpm.HeaderTag]bool{}
+	for _, t := range NormalizedTags {
+		skip[t] = true
+	}
+	for _, t := range opts.IgnoreTags {
+		skip[t] = true
+	}
+	res := &Result{Old: nevra(old.Header), New: nevra(new.Header)}
+	res.Tags = compareTags(old.Header, new.Header, skip)
+
+	oe, err := payloadEntries(old)
+	if err != nil {
+		return nil, err
+	}
+	ne, err := payloadEntries(new)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range oe.order {
+		if _, ok := ne.entries[name]; !ok {
+			res.Files = append(res.Files, FileChange{Path: name, Change: Removed})
+		}
+	}
+	for _, name := range ne.order {
+		n := ne.entries[name]
+		o, ok := oe.entries[name]
+		if !ok {
+			res.Files = append(res.Files, FileChange{Path: name, Change: Added})
+			continue
+		}
+		if attrs := entryAttrs(o, n); attrs != rpm.VerifyNone {
+			res.Files = append(res.Files, FileChange{Path: name, Change: Changed, Attrs: attrs})
+		}
+	}
+	return res, nil
+}
+
+// payloadEntry is cpio entry with digest of its data
+type payloadEntry struct {
+	hdr    cpio.Header
+	digest [sha256.Size]byte
+}
+
+type payloadList struct {
+	order   []string
+	entries map[string]*payloadEntry
+}
+
+func payloadEntries(pkg *rpmutil.Package) (*payloadList, error) {
+	pl, err := pkg.Payload()
+	if err != nil {
+		return nil, err
+	}
+	res := &payloadList{entries: map[string]*payloadEntry{}}
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := &payloadEntry{hdr: *h}
+		sum := sha256.New()
+		if _, err := io.Copy(sum, r); err != nil {
+			return nil, err
+		}
+		copy(e.digest[:], sum.Sum(nil))
+		name := h.Name
+		if len(name) > 0 && name[0] == '.' {
+			name = name[1:]
+		}
+		if _, ok := res.entries[name]; !ok {
+			res.order = append(res.order, name)
+		}
+		res.entries[name] = e
+	}
+	return res, nil
+}
+
+// entryAttrs returns attributes of payload entry which differ, inode
+// numbers and devices are up to the build and aren't compared
+func entryAttrs(o, n *payloadEntry) rpm.VerifyFlags {
+	var attrs rpm.VerifyFlags
+	if o.hdr.Size != n.hdr.Size {
+		attrs |= rpm.VerifySize
+	}
+	if o.hdr.Mode != n.hdr.Mode {
+		attrs |= rpm.VerifyMode
+	}
+	if o.digest != n.digest {
+		attrs |= rpm.VerifyDigest
+	}
+	if o.hdr.Linkname != n.hdr.Linkname {
+		attrs |= rpm.VerifyLinkTo
+	}
+	if o.hdr.UID != n.hdr.UID {
+		attrs |= rpm.VerifyUser
+	}
+	if o.hdr.GID != n.hdr.GID {
+		attrs |= rpm.VerifyGroup
+	}
+	if !o.hdr.Mtime.Equal(n.hdr.Mtime) {
+		attrs |= rpm.VerifyMTime
+	}
+	return attrs
+}
@@ -0,0 +1,95 @@
+package rpmdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+)
+
+func TestReproducible(t *testing.T) {
+	files := []rpmtest.File{
+		{Name: "/usr/bin/hello", Mode: 0100755, Data: "hello", MTime: 1000},
+		{Name: "/usr/share/hello", Mode: 040755, MTime: 1000},
+	}
+	build := func(host string, time int32, files []rpmtest.File) *rpm.Header {
+		h := rpmtest.Header("hello", files...)
+		h.SetString(rpm.TagBuildHost, host)
+		h.SetInt32s(rpm.TagBuildTime, []int32{time})
+		h.SetString(rpm.TagCookie, host+" 1234")
+		h.SetStrings(rpm.TagPayloadDigest, []string{host})
+		return h
+	}
+
+	tests := []struct {
+		name  string
+		new   func() (*rpm.Header, []rpmtest.File)
+		opts  *Options
+		tags  []rpm.HeaderTag
+		files []FileChange
+	}{
+		{
+			name: "normalized tags",
+			new:  func() (*rpm.Header, []rpmtest.File) { return build("other", 2000, files), files },
+		},
+		{
+			name: "summary",
+			new: func() (*rpm.Header, []rpmtest.File) {
+				h := build("other", 2000, files)
+				h.SetString(rpm.TagSummary, "changed")
+				return h, files
+			},
+			tags: []rpm.HeaderTag{rpm.TagSummary},
+		},
+		{
+			name: "ignored summary",
+			new: func() (*rpm.Header, []rpmtest.File) {
+				h := build("other", 2000, files)
+				h.SetString(rpm.TagSummary, "changed")
+				return h, files
+			},
+			opts: &Options{IgnoreTags: []rpm.HeaderTag{rpm.TagSummary}},
+		},
+		{
+			// mtimes of files are in the header too
+			name: "mtime",
+			new: func() (*rpm.Header, []rpmtest.File) {
+				changed := append([]rpmtest.File(nil), files...)
+				changed[1].MTime = 2000
+				return build("other", 2000, changed), changed
+			},
+			tags:  []rpm.HeaderTag{rpm.TagFileMTimes},
+			files: []FileChange{{Path: "/usr/share/hello", Change: Changed, Attrs: rpm.VerifyMTime}},
+		},
+		{
+			name: "contents",
+			new: func() (*rpm.Header, []rpmtest.File) {
+				changed := append([]rpmtest.File(nil), files...)
+				changed[0].Data = "HELLO"
+				return build("other", 2000, changed), changed
+			},
+			tags:  []rpm.HeaderTag{rpm.TagFileMD5S},
+			files: []FileChange{{Path: "/usr/bin/hello", Change: Changed, Attrs: rpm.VerifyDigest}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nh, nf := tt.new()
+			res, err := Reproducible(newPackage(t, build("host", 1000, files), files), newPackage(t, nh, nf), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tags []rpm.HeaderTag
+			for _, c := range res.Tags {
+				tags = append(tags, c.Tag)
+			}
+			if !reflect.DeepEqual(tags, tt.tags) {
+				t.Errorf("got tags %v, expected %v", tags, tt.tags)
+			}
+			if !reflect.DeepEqual(res.Files, tt.files) {
+				t.Errorf("got files %+v, expected %+v", res.Files, tt.files)
+			}
+		})
+	}
+}
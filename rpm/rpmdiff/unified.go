@@ -0,0 +1,225 @@
+package rpmdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is number of unchanged lines around hunks
+const contextLines = 3
+
+// maxEditDistance bounds effort spent on finding minimal diff, files
+// differing more are shown as replaced as a whole
+const maxEditDistance = 2000
+
+type editOp int8
+
+const (
+	opEqual editOp = iota
+	opDelete
+	opInsert
+)
+
+type edit struct {
+	op editOp
+	// line indexes in old and new text, only the relevant one is
+	// meaningful for deletes and inserts
+	a, b int
+}
+
+// Unified returns unified diff of old and new text, empty string when
+// they are the same
+func Unified(oldName, newName, old, new string) string {
+	if old == new {
+		return ""
+	}
+	a, b := splitLines(old), splitLines(new)
+	edits := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(edits); {
+		// find first change and extend hunk over changes separated
+		// by less than two contexts
+		for start < len(edits) && edits[start].op == opEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].op != opEqual {
+				end = i + 1
+			} else if i-end >= 2*contextLines {
+				break
+			}
+		}
+		from, to := start-contextLines, end+contextLines
+		if from < 0 {
+			from = 0
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		writeHunk(&sb, a, b, edits[from:to])
+		start = to
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, a, b []string, edits []edit) {
+	// position of hunk is taken from its first line, empty ranges
+	// are numbered by the line before them
+	aStart, bStart := -1, -1
+	var aLen, bLen int
+	for _, e := range edits {
+		if e.op != opInsert {
+			if aStart < 0 {
+				aStart = e.a
+			}
+			aLen++
+		}
+		if e.op != opDelete {
+			if bStart < 0 {
+				bStart = e.b
+			}
+			bLen++
+		}
+	}
+	first := edits[0]
+	if aStart < 0 {
+		aStart = first.a
+	}
+	if bStart < 0 {
+		bStart = first.b
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, e := range edits {
+		switch e.op {
+		case opEqual:
+			writeLine(sb, ' ', a[e.a])
+		case opDelete:
+			writeLine(sb, '-', a[e.a])
+		case opInsert:
+			writeLine(sb, '+', b[e.b])
+		}
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits text into lines keeping line endings
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		lines = append(lines, s[:i])
+		s = s[i:]
+	}
+	return lines
+}
+
+// diffLines finds shortest edit script turning a into b using Myers'
+// algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > 0 && max > maxEditDistance {
+		max = maxEditDistance
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+	// trace keeps v of every round for k in [-d-1, d+1]
+	var trace [][]int
+	found := -1
+	for d := 0; d <= max && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return replaceAll(n, m)
+	}
+
+	var edits []edit
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		tv := trace[d]
+		at := func(k int) int { return tv[k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{opEqual, x, y})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{opInsert, x, y})
+		} else {
+			x--
+			edits = append(edits, edit{opDelete, x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{opEqual, x, y})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(n, m int) []edit {
+	edits := make([]edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, edit{opDelete, i, 0})
+	}
+	for j := 0; j < m; j++ {
+		edits = append(edits, edit{opInsert, n, j})
+	}
+	return edits
+}
@@ -0,0 +1,340 @@
+package rpmlint
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/license"
+)
+
+const (
+	modeTypeMask = 0xf000
+	modeReg      = 0x8000
+	modeDir      = 0x4000
+	modeLink     = 0xa000
+)
+
+func init() {
+	for _, c := range []*Check{
+		{
+			Name:        "no-license",
+			Severity:    Warning,
+			Description: "Package ships files but none of them is marked as %license.",
+			Run:         checkLicense,
+		},
+		{
+			Name:        "invalid-license",
+			Severity:    Error,
+			Description: "License is not valid SPDX expression or uses identifiers missing in SPDX license list.",
+			Run:         checkInvalidLicense,
+		},
+		{
+			Name:        "legacy-license",
+			Severity:    Warning,
+			Description: "License uses legacy short names, use SPDX identifiers instead.",
+			Run:         checkLegacyLicense,
+		},
+		{
+			Name:        "summary-ended-with-dot",
+			Severity:    Warning,
+			Description: "Summary is not a sentence and shouldn't end with a dot.",
+			Run:         checkSummaryDot,
+		},
+		{
+			Name:        "summary-not-capitalized",
+			Severity:    Warning,
+			Description: "Summary should start with a capital letter.",
+			Run:         checkSummaryCapital,
+		},
+		{
+			Name:        "changelog-not-utf8",
+			Severity:    Error,
+			Description: "Changelog contains text which is not valid UTF-8.",
+			Run:         checkChangelogUTF8,
+		},
+		{
+			Name:        "dir-or-file-in-usr-local",
+			Severity:    Error,
+			Description: "/usr/local belongs to local administrator, packages must not ship anything there.",
+			Run:         checkUsrLocal,
+		},
+		{
+			Name:        "world-writable",
+			Severity:    Error,
+			Description: "File or directory is writable by everyone.",
+			Run:         checkWorldWritable,
+		},
+		{
+			Name:        "setuid-binary",
+			Severity:    Error,
+			Description: "File is setuid or setgid, make sure it is intended and safe.",
+			Run:         checkSetuid,
+		},
+		{
+			Name:        "mismatched-self-provides",
+			Severity:    Error,
+			Description: "Package provides its own name with version other than its own.",
+			Run:         checkSelfProvides,
+		},
+		{
+			Name:        "unversioned-explicit-obsoletes",
+			Severity:    Warning,
+			Description: "Obsoletes without version obsolete all future versions of package too.",
+			Run:         checkObsoletes,
+		},
+		{
+			Name:        "empty-package",
+			Severity:    Warning,
+			Description: "Package contains no files.",
+			Run:         checkEmpty,
+		},
+		{
+			Name:        "no-binary",
+			Severity:    Warning,
+			Description: "Architecture dependent package contains only data files, it could be noarch.",
+			Run:         checkNoBinary,
+		},
+	} {
+		Register(c)
+	}
+}
+
+func checkLicense(pkg *Package, r *Reporter) error {
+	if pkg.IsSource() || len(pkg.Files) == 0 {
+		return nil
+	}
+	for _, f := range pkg.Files {
+		if f.Flags&rpm.FileLicense != 0 {
+			return nil
+		}
+	}
+	r.Report("", "")
+	return nil
+}
+
+// parseLicense returns License of package as it is and normalized,
+// nil if package has none
+func parseLicense(pkg *Package) (*license.Expr, *license.Expr, error) {
+	s, err := pkg.Header.GetString(rpm.TagLicense)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := license.Parse(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, license.Normalize(e), nil
+}
+
+func checkInvalidLicense(pkg *Package, r *Reporter) error {
+	_, n, err := parseLicense(pkg)
+	if errors.Is(err, license.ErrMalformed) {
+		r.Report("", "%s", err)
+		return nil
+	}
+	if err != nil || n == nil {
+		return err
+	}
+	if unknown := n.Unknown(); len(unknown) > 0 {
+		r.Report("", "%q: unknown %s", n, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func checkLegacyLicense(pkg *Package, r *Reporter) error {
+	e, n, err := parseLicense(pkg)
+	if errors.Is(err, license.ErrMalformed) {
+		return nil
+	}
+	if err != nil || e == nil {
+		return err
+	}
+	if e.String() == n.String() || len(n.Unknown()) > 0 {
+		return nil
+	}
+	s, _ := pkg.Header.GetString(rpm.TagLicense)
+	if ambiguous := n.Ambiguous(); len(ambiguous) > 0 {
+		r.Report("", "%q, %s stands for several SPDX licenses", s, strings.Join(ambiguous, ", "))
+	} else {
+		r.Report("", "%q, SPDX is %q", s, n)
+	}
+	return nil
+}
+
+func summary(pkg *Package) (string, error) {
+	s, err := pkg.Summary("")
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return "", nil
+	}
+	return s, err
+}
+
+func checkSummaryDot(pkg *Package, r *Reporter) error {
+	s, err := summary(pkg)
+	// ellipsis is fine
+	if err == nil && strings.HasSuffix(s, ".") && !strings.HasSuffix(s, "...") {
+		r.Report("", "%q", s)
+	}
+	return err
+}
+
+func checkSummaryCapital(pkg *Package, r *Reporter) error {
+	s, err := summary(pkg)
+	if err != nil || len(s) == 0 {
+		return err
+	}
+	if c, _ := utf8.DecodeRuneInString(s); unicode.IsLower(c) {
+		r.Report("", "%q", s)
+	}
+	return nil
+}
+
+func checkChangelogUTF8(pkg *Package, r *Reporter) error {
+	for _, t := range []rpm.HeaderTag{rpm.TagChangelogName, rpm.TagChangelogText} {
+		values, err := pkg.Header.GetStrings(t)
+		if errors.Is(err, rpm.ErrTagNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if !utf8.ValidString(v) {
+				r.Report("", "%q", firstLine(v))
+			}
+		}
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func checkUsrLocal(pkg *Package, r *Reporter) error {
+	for _, f := range pkg.Files {
+		if f.Name == "/usr/local" || strings.HasPrefix(f.Name, "/usr/local/") {
+			r.Report(f.Name, "")
+		}
+	}
+	return nil
+}
+
+func checkWorldWritable(pkg *Package, r *Reporter) error {
+	for _, f := range pkg.Files {
+		if f.Mode&modeTypeMask == modeLink || f.Mode&0002 == 0 {
+			continue
+		}
+		// sticky directories like /tmp are meant to be shared
+		if f.Mode&modeTypeMask == modeDir && f.Mode&01000 != 0 {
+			continue
+		}
+		r.Report(f.Name, "%04o", f.Mode&07777)
+	}
+	return nil
+}
+
+func checkSetuid(pkg *Package, r *Reporter) error {
+	for _, f := range pkg.Files {
+		if f.Mode&modeTypeMask != modeReg {
+			continue
+		}
+		switch {
+		case f.Mode&04000 != 0:
+			r.Report(f.Name, "setuid %s %04o", f.User, f.Mode&07777)
+		case f.Mode&02000 != 0:
+			r.Report(f.Name, "setgid %s %04o", f.Group, f.Mode&07777)
+		}
+	}
+	return nil
+}
+
+func checkSelfProvides(pkg *Package, r *Reporter) error {
+	if pkg.IsSource() {
+		return nil
+	}
+	provides, err := pkg.Header.Provides()
+	if err != nil {
+		return err
+	}
+	var evr rpm.EVR
+	evr.Version, _ = pkg.Header.GetString(rpm.TagVersion)
+	evr.Release, _ = pkg.Header.GetString(rpm.TagRelease)
+	if e, err := pkg.Header.GetInt(rpm.TagEpoch); err == nil {
+		evr.Epoch = int(e)
+	}
+	for _, p := range provides {
+		if p.Name != pkg.Name || p.Flags&rpm.SenseCompareMask != rpm.SenseEqual {
+			continue
+		}
+		pevr := rpm.ParseEVR(p.Version)
+		// provide without release matches any release
+		own := evr
+		if len(pevr.Release) == 0 {
+			own.Release = ""
+		}
+		if rpm.CompareEVR(pevr, own) != 0 {
+			r.Report("", "%s, package is %s", p, evr)
+		}
+	}
+	return nil
+}
+
+func checkObsoletes(pkg *Package, r *Reporter) error {
+	obsoletes, err := pkg.Header.Obsoletes()
+	if err != nil {
+		return err
+	}
+	for _, o := range obsoletes {
+		if o.Flags&rpm.SenseCompareMask == 0 {
+			r.Report("", "%s", o.Name)
+		}
+	}
+	return nil
+}
+
+func checkEmpty(pkg *Package, r *Reporter) error {
+	if !pkg.IsSource() && len(pkg.Files) == 0 {
+		r.Report("", "")
+	}
+	return nil
+}
+
+// binaryDirs hold architecture dependent files when packages lack
+// file colors telling ELF files apart
+var binaryDirs = []string{"/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/", "/lib64/", "/usr/lib64/", "/usr/libexec/"}
+
+func checkNoBinary(pkg *Package, r *Reporter) error {
+	arch, _ := pkg.Header.GetString(rpm.TagArch)
+	if pkg.IsSource() || arch == "noarch" || len(pkg.Files) == 0 {
+		return nil
+	}
+	_, _, err := pkg.Header.GetTag(rpm.TagFileColors)
+	colored := err == nil
+	for _, f := range pkg.Files {
+		if f.Mode&modeTypeMask != modeReg {
+			continue
+		}
+		if colored && f.Color != 0 {
+			return nil
+		}
+		if !colored {
+			for _, d := range binaryDirs {
+				if strings.HasPrefix(f.Name, d) {
+					return nil
+				}
+			}
+		}
+	}
+	r.Report("", "")
+	return nil
+}
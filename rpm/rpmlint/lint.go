@@ -0,0 +1,227 @@
+// Package rpmlint runs checks over packages the way rpmlint does.
+// Checks are plain functions registered under unique names, findings
+// of them can be suppressed per check and path.
+package rpmlint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+// Severity of finding
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(d []byte) error {
+	for i, name := range severityNames {
+		if string(d) == name {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", d)
+}
+
+// Finding is single problem check found in package, Path is set for
+// problems of particular file
+type Finding struct {
+	Package  string   `json:"package"`
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// String formats finding the way rpmlint prints it
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s: %c: %s", f.Package, strings.ToUpper(f.Severity.String())[0], f.Check)
+	if len(f.Path) > 0 {
+		s += " " + f.Path
+	}
+	if len(f.Message) > 0 {
+		s += " " + f.Message
+	}
+	return s
+}
+
+// Check inspects package and reports problems it finds
+type Check struct {
+	Name     string
+	Severity Severity
+	// Description explains what check looks for
+	Description string
+	Run         func(pkg *Package, r *Reporter) error
+}
+
+// Package is package being checked along with data most checks need
+type Package struct {
+	*rpmutil.Package
+	Name  string
+	NVRA  string
+	Files []rpmutil.FileInfo
+}
+
+// IsSource reports whether package is source one
+func (p *Package) IsSource() bool {
+	if p.Lead != nil {
+		return p.Lead.IsSource()
+	}
+	_, err := p.Header.GetString(rpm.TagSourceRPM)
+	return err != nil
+}
+
+// Reporter collects findings of check
+type Reporter struct {
+	check    *Check
+	pkg      *Package
+	findings []Finding
+}
+
+// Report reports problem of package, path is empty unless problem is
+// with particular file
+func (r *Reporter) Report(path, format string, args ...interface{}) {
+	r.findings = append(r.findings, Finding{
+		Package:  r.pkg.NVRA,
+		Check:    r.check.Name,
+		Severity: r.check.Severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+var checks = map[string]*Check{}
+
+// Register adds check to ones run by default, it panics when name is
+// already taken
+func Register(c *Check) {
+	if _, ok := checks[c.Name]; ok {
+		panic("rpmlint: check " + c.Name + " registered twice")
+	}
+	checks[c.Name] = c
+}
+
+// Checks returns registered checks sorted by name
+func Checks() []*Check {
+	res := make([]*Check, 0, len(checks))
+	for _, c := range checks {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Suppression silences findings of check, Path is glob of paths the
+// way path.Match takes them, empty for all findings of check
+type Suppression struct {
+	Check string
+	Path  string
+}
+
+// ParseSuppression parses "check" or "check:path-glob"
+func ParseSuppression(s string) Suppression {
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		return Suppression{Check: s[:i], Path: s[i+1:]}
+	}
+	return Suppression{Check: s}
+}
+
+func (s Suppression) matches(f *Finding) bool {
+	if s.Check != f.Check {
+		return false
+	}
+	if len(s.Path) == 0 {
+		return true
+	}
+	ok, _ := path.Match(s.Path, f.Path)
+	return ok
+}
+
+// Linter runs checks over packages
+type Linter struct {
+	// Checks to run, nil for all registered ones
+	Checks   []*Check
+	Suppress []Suppression
+}
+
+// Lint runs checks over package and returns what they found
+func (l *Linter) Lint(pkg *rpmutil.Package) ([]Finding, error) {
+	p := &Package{Package: pkg, NVRA: rpmutil.HeaderNVRA(pkg.Header)}
+	p.Name, _ = pkg.Header.GetString(rpm.TagName)
+	files, err := pkg.Files()
+	if err != nil {
+		return nil, err
+	}
+	p.Files = files
+
+	cs := l.Checks
+	if cs == nil {
+		cs = Checks()
+	}
+	var res []Finding
+	for _, c := range cs {
+		r := &Reporter{check: c, pkg: p}
+		if err := c.Run(p, r); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		for _, f := range r.findings {
+			if !l.suppressed(&f) {
+				res = append(res, f)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (l *Linter) suppressed(f *Finding) bool {
+	for _, s := range l.Suppress {
+		if s.matches(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes findings one per line
+func WriteText(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		if _, err := fmt.Fprintln(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes findings as JSON array
+func WriteJSON(w io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
@@ -0,0 +1,250 @@
+package rpmlint
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+var baseFiles = []rpmtest.File{
+	{Name: "/usr/bin/hello", Mode: 0100755, Data: "hello"},
+	{Name: "/usr/share/licenses/hello/LICENSE", Mode: 0100644, Data: "MIT", Flags: rpm.FileLicense},
+}
+
+// newPackage returns package no check finds anything wrong with
+// unless set changes its header
+func newPackage(t *testing.T, files []rpmtest.File, set func(h *rpm.Header)) *rpmutil.Package {
+	t.Helper()
+	h := rpmtest.Header("hello", files...)
+	h.SetString(rpm.TagArch, "x86_64")
+	h.SetString(rpm.TagLicense, "MIT")
+	h.SetString(rpm.TagSummary, "Greeting program")
+	h.SetString(rpm.TagSourceRPM, "hello-1.0-1.src.rpm")
+	setDeps(h, rpm.DepProvides, "hello", rpm.SenseEqual, "1.0-1")
+	if set != nil {
+		set(h)
+	}
+	var b bytes.Buffer
+	lead := rpm.NewLead("hello-1.0-1", "x86_64", 0)
+	if err := rpmutil.WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := rpmutil.ReadPackage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func setDeps(h *rpm.Header, kind rpm.DependencyKind, name string, flags rpm.SenseFlags, version string) {
+	nameTag, flagsTag, versionTag := rpm.DependencyTags(kind)
+	h.SetStrings(nameTag, []string{name})
+	h.SetInt32s(flagsTag, []int32{int32(flags)})
+	h.SetStrings(versionTag, []string{version})
+}
+
+func TestChecks(t *testing.T) {
+	withFile := func(f rpmtest.File) []rpmtest.File {
+		return append(append([]rpmtest.File(nil), baseFiles...), f)
+	}
+	setString := func(tag rpm.HeaderTag, s string) func(h *rpm.Header) {
+		return func(h *rpm.Header) { h.SetString(tag, s) }
+	}
+
+	tests := []struct {
+		name  string
+		check string
+		files []rpmtest.File
+		set   func(h *rpm.Header)
+		found string
+	}{
+		{name: "license file", check: "no-license"},
+		{
+			name: "no license file", check: "no-license",
+			files: baseFiles[:1], found: "hello-1.0-1.x86_64: W: no-license",
+		},
+		{name: "valid license", check: "invalid-license"},
+		{
+			name: "unknown license", check: "invalid-license",
+			set:   setString(rpm.TagLicense, "MIT AND Foo-Bar"),
+			found: `hello-1.0-1.x86_64: E: invalid-license "MIT AND Foo-Bar": unknown Foo-Bar`,
+		},
+		{
+			name: "malformed license", check: "invalid-license",
+			set:   setString(rpm.TagLicense, "MIT AND"),
+			found: "hello-1.0-1.x86_64: E: invalid-license",
+		},
+		{name: "SPDX license", check: "legacy-license"},
+		{
+			name: "legacy license", check: "legacy-license",
+			set:   setString(rpm.TagLicense, "ASL 2.0"),
+			found: `hello-1.0-1.x86_64: W: legacy-license "ASL 2.0", SPDX is "Apache-2.0"`,
+		},
+		{
+			name: "ambiguous legacy license", check: "legacy-license",
+			set:   setString(rpm.TagLicense, "BSD"),
+			found: `hello-1.0-1.x86_64: W: legacy-license "BSD", BSD stands for several SPDX licenses`,
+		},
+		{name: "summary", check: "summary-ended-with-dot"},
+		{name: "summary with ellipsis", check: "summary-ended-with-dot", set: setString(rpm.TagSummary, "Wait for it...")},
+		{
+			name: "summary with dot", check: "summary-ended-with-dot",
+			set:   setString(rpm.TagSummary, "Greeting program."),
+			found: `hello-1.0-1.x86_64: W: summary-ended-with-dot "Greeting program."`,
+		},
+		{name: "capitalized summary", check: "summary-not-capitalized"},
+		{name: "summary starting with digit", check: "summary-not-capitalized", set: setString(rpm.TagSummary, "3D viewer")},
+		{
+			name: "lower case summary", check: "summary-not-capitalized",
+			set:   setString(rpm.TagSummary, "greeting program"),
+			found: `hello-1.0-1.x86_64: W: summary-not-capitalized "greeting program"`,
+		},
+		{
+			name: "utf-8 changelog", check: "changelog-not-utf8",
+			set: func(h *rpm.Header) { h.SetStrings(rpm.TagChangelogText, []string{"- Grüße"}) },
+		},
+		{
+			name: "latin-1 changelog", check: "changelog-not-utf8",
+			set:   func(h *rpm.Header) { h.SetStrings(rpm.TagChangelogText, []string{"- Gr\xfc\xdfe\n- more"}) },
+			found: `hello-1.0-1.x86_64: E: changelog-not-utf8 "- Gr\xfc\xdfe"`,
+		},
+		{name: "no /usr/local", check: "dir-or-file-in-usr-local", files: withFile(rpmtest.File{Name: "/usr/localized", Mode: 0100644})},
+		{
+			name: "/usr/local", check: "dir-or-file-in-usr-local",
+			files: withFile(rpmtest.File{Name: "/usr/local/bin/hello", Mode: 0100755}),
+			found: "hello-1.0-1.x86_64: E: dir-or-file-in-usr-local /usr/local/bin/hello",
+		},
+		{name: "sticky directory", check: "world-writable", files: withFile(rpmtest.File{Name: "/var/tmp/hello", Mode: 041777})},
+		{name: "symlink", check: "world-writable", files: withFile(rpmtest.File{Name: "/usr/bin/hi", Mode: 0120777, Data: "hello"})},
+		{
+			name: "world writable file", check: "world-writable",
+			files: withFile(rpmtest.File{Name: "/var/lib/hello", Mode: 0100666}),
+			found: "hello-1.0-1.x86_64: E: world-writable /var/lib/hello 0666",
+		},
+		{
+			name: "setuid", check: "setuid-binary",
+			files: withFile(rpmtest.File{Name: "/usr/bin/su", Mode: 0104755}),
+			found: "hello-1.0-1.x86_64: E: setuid-binary /usr/bin/su setuid root 4755",
+		},
+		{
+			name: "setgid", check: "setuid-binary",
+			files: withFile(rpmtest.File{Name: "/usr/bin/wall", Mode: 0102755}),
+			found: "hello-1.0-1.x86_64: E: setuid-binary /usr/bin/wall setgid root 2755",
+		},
+		{name: "setgid directory", check: "setuid-binary", files: withFile(rpmtest.File{Name: "/srv/hello", Mode: 042775})},
+		{name: "self provides", check: "mismatched-self-provides"},
+		{
+			name: "self provides without release", check: "mismatched-self-provides",
+			set: func(h *rpm.Header) { setDeps(h, rpm.DepProvides, "hello", rpm.SenseEqual, "1.0") },
+		},
+		{
+			name: "self provides of other version", check: "mismatched-self-provides",
+			set:   func(h *rpm.Header) { setDeps(h, rpm.DepProvides, "hello", rpm.SenseEqual, "2.0-1") },
+			found: "hello-1.0-1.x86_64: E: mismatched-self-provides hello = 2.0-1, package is 1.0-1",
+		},
+		{
+			name: "versioned obsoletes", check: "unversioned-explicit-obsoletes",
+			set: func(h *rpm.Header) { setDeps(h, rpm.DepObsoletes, "hello-old", rpm.SenseLess, "1.0") },
+		},
+		{
+			name: "unversioned obsoletes", check: "unversioned-explicit-obsoletes",
+			set:   func(h *rpm.Header) { setDeps(h, rpm.DepObsoletes, "hello-old", rpm.SenseAny, "") },
+			found: "hello-1.0-1.x86_64: W: unversioned-explicit-obsoletes hello-old",
+		},
+		{name: "package with files", check: "empty-package"},
+		{
+			name: "empty package", check: "empty-package",
+			files: []rpmtest.File{}, found: "hello-1.0-1.x86_64: W: empty-package",
+		},
+		{name: "binary", check: "no-binary"},
+		{name: "noarch data", check: "no-binary", files: baseFiles[1:], set: setString(rpm.TagArch, "noarch")},
+		{
+			name: "colored data", check: "no-binary", files: baseFiles[1:],
+			set: func(h *rpm.Header) { h.SetInt32s(rpm.TagFileColors, []int32{2}) },
+		},
+		{
+			name: "arch dependent data", check: "no-binary",
+			files: baseFiles[1:], found: "hello-1.0-1.x86_64: W: no-binary",
+		},
+		{
+			name: "uncolored binary", check: "no-binary",
+			set:   func(h *rpm.Header) { h.SetInt32s(rpm.TagFileColors, []int32{0, 0}) },
+			found: "hello-1.0-1.x86_64: W: no-binary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := checks[tt.check]
+			if !ok {
+				t.Fatalf("no check %s", tt.check)
+			}
+			files := tt.files
+			if files == nil {
+				files = baseFiles
+			}
+			l := &Linter{Checks: []*Check{c}}
+			findings, err := l.Lint(newPackage(t, files, tt.set))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.found == "" {
+				if len(findings) > 0 {
+					t.Errorf("got %v, expected nothing", findings)
+				}
+				return
+			}
+			if len(findings) != 1 || !strings.HasPrefix(findings[0].String(), tt.found) {
+				t.Errorf("got %v, expected %q", findings, tt.found)
+			}
+		})
+	}
+}
+
+// TestClean runs all checks over package none of them should
+// complain about
+func TestClean(t *testing.T) {
+	findings, err := (&Linter{}).Lint(newPackage(t, baseFiles, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) > 0 {
+		t.Errorf("got %v", findings)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	files := append(append([]rpmtest.File(nil), baseFiles...),
+		rpmtest.File{Name: "/usr/bin/su", Mode: 0104755},
+		rpmtest.File{Name: "/usr/libexec/hello/helper", Mode: 0104755},
+	)
+	tests := []struct {
+		suppress []string
+		paths    []string
+	}{
+		{nil, []string{"/usr/bin/su", "/usr/libexec/hello/helper"}},
+		{[]string{"setuid-binary"}, nil},
+		{[]string{"setuid-binary:/usr/libexec/*/*"}, []string{"/usr/bin/su"}},
+		{[]string{"world-writable"}, []string{"/usr/bin/su", "/usr/libexec/hello/helper"}},
+	}
+	for _, tt := range tests {
+		l := &Linter{Checks: []*Check{checks["setuid-binary"]}}
+		for _, s := range tt.suppress {
+			l.Suppress = append(l.Suppress, ParseSuppression(s))
+		}
+		findings, err := l.Lint(newPackage(t, files, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, f := range findings {
+			paths = append(paths, f.Path)
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.paths, " ") {
+			t.Errorf("suppressed %v: got %v, expected %v", tt.suppress, paths, tt.paths)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package rpmutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// ChangelogEntry is single %changelog record
+type ChangelogEntry struct {
+	Time   time.Time
+	Author string
+	Email  string
+	// EVR claimed by the entry header, nil if entry doesn't
+	// follow "Name <email> - EVR" convention
+	EVR  *rpm.EVR
+	Text []string
+}
+
+// Changelog returns changelog entries in the order they are stored
+// in header, which is newest first
+func (pkg *Package) Changelog() ([]ChangelogEntry, error) {
+	times, err := pkg.Header.GetInt32s(rpm.TagChangelogTime)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pkg.Header.GetStrings(rpm.TagChangelogName)
+	if err != nil {
+		return nil, err
+	}
+	texts, err := pkg.Header.GetStrings(rpm.TagChangelogText)
+	if err != nil {
+		return nil, err
+	}
+	if len(times) != len(names) || len(times) != len(texts) {
+		return nil, fmt.Errorf("changelog: %w", rpm.ErrCountMismatch)
+	}
+
+	entries := make([]ChangelogEntry, len(times))
+	for i := range entries {
+		e := &entries[i]
+		e.Time = time.Unix(int64(uint32(times[i])), 0).UTC()
+		e.Author, e.Email, e.EVR = parseChangelogName(names[i])
+		e.Text = strings.Split(texts[i], "\n")
+	}
+	return entries, nil
+}
+
+// parseChangelogName splits "Name <email> - EVR" header of changelog entry
+func parseChangelogName(s string) (author, email string, evr *rpm.EVR) {
+	rest := s
+	if lt := strings.IndexByte(s, '<'); lt >= 0 {
+		if gt := strings.IndexByte(s[lt:], '>'); gt >= 0 {
+			author = strings.TrimSpace(s[:lt])
+			email = s[lt+1 : lt+gt]
+			rest = s[lt+gt+1:]
+		}
+	}
+	if len(email) == 0 {
+		// no email, version still might follow " - "
+		if i := strings.LastIndex(s, " - "); i >= 0 {
+			author = strings.TrimSpace(s[:i])
+			rest = s[i+3:]
+		} else {
+			return strings.TrimSpace(s), "", nil
+		}
+	}
+
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "-"))
+	if len(rest) == 0 || strings.ContainsAny(rest, " \t") {
+		return author, email, nil
+	}
+	v := rpm.ParseEVR(rest)
+	return author, email, &v
+}
+
+// ChangelogNewerThan returns leading entries of changelog which were
+// added after evr. Changelog is expected to be ordered newest first,
+// entries without EVR are kept as long as they precede an older entry.
+func ChangelogNewerThan(entries []ChangelogEntry, evr rpm.EVR) []ChangelogEntry {
+	for i, e := range entries {
+		if e.EVR != nil && e.EVR.Compare(evr) <= 0 {
+			return entries[:i]
+		}
+	}
+	return entries
+}
+
+// ChangelogSince returns leading entries of changelog newer than t
+func ChangelogSince(entries []ChangelogEntry, t time.Time) []ChangelogEntry {
+	for i, e := range entries {
+		if !e.Time.After(t) {
+			return entries[:i]
+		}
+	}
+	return entries
+}
@@ -0,0 +1,121 @@
+package rpmutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+func TestParseChangelogName(t *testing.T) {
+	tests := []struct {
+		s             string
+		author, email string
+		evr           string
+	}{
+		{"John Doe <john@example.com> - 1.0-1", "John Doe", "john@example.com", "1.0-1"},
+		{"John Doe <john@example.com> 2:1.0-1", "John Doe", "john@example.com", "2:1.0-1"},
+		{"John Doe <john@example.com>-1.0-1", "John Doe", "john@example.com", "1.0-1"},
+		{"John Doe <john@example.com>", "John Doe", "john@example.com", ""},
+		{"John Doe <john@example.com> - rebuilt for 1.0", "John Doe", "john@example.com", ""},
+		{"John Doe - 1.0-1", "John Doe", "", "1.0-1"},
+		{"John Doe", "John Doe", "", ""},
+		{"John Doe <john@example.com", "John Doe <john@example.com", "", ""},
+	}
+	for _, tt := range tests {
+		author, email, evr := parseChangelogName(tt.s)
+		if author != tt.author || email != tt.email {
+			t.Errorf("%q: got %q <%s>, expected %q <%s>", tt.s, author, email, tt.author, tt.email)
+		}
+		switch {
+		case evr == nil && tt.evr != "":
+			t.Errorf("%q: no EVR, expected %s", tt.s, tt.evr)
+		case evr != nil && evr.String() != tt.evr:
+			t.Errorf("%q: got EVR %s, expected %q", tt.s, evr, tt.evr)
+		}
+	}
+}
+
+func changelogEntries(evrs ...string) []ChangelogEntry {
+	var entries []ChangelogEntry
+	for i, s := range evrs {
+		e := ChangelogEntry{Time: time.Unix(int64(1000*(len(evrs)-i)), 0).UTC()}
+		if s != "" {
+			evr := rpm.ParseEVR(s)
+			e.EVR = &evr
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestChangelogNewerThan(t *testing.T) {
+	entries := changelogEntries("1.2-1", "", "1.1-1", "1.0-1")
+	tests := []struct {
+		evr string
+		n   int
+	}{
+		{"1.2-1", 0},
+		{"1.1-1", 2},
+		{"1.0-1", 3},
+		{"1.1-0", 3},
+		{"0.9-1", 4},
+		{"1:0.1-1", 0},
+	}
+	for _, tt := range tests {
+		if got := ChangelogNewerThan(entries, rpm.ParseEVR(tt.evr)); len(got) != tt.n {
+			t.Errorf("newer than %s: got %d entries, expected %d", tt.evr, len(got), tt.n)
+		}
+	}
+}
+
+func TestChangelogSince(t *testing.T) {
+	entries := changelogEntries("1.2-1", "1.1-1", "1.0-1")
+	tests := []struct {
+		t int64
+		n int
+	}{
+		{3000, 0},
+		{2999, 1},
+		{2000, 1},
+		{1000, 2},
+		{0, 3},
+	}
+	for _, tt := range tests {
+		if got := ChangelogSince(entries, time.Unix(tt.t, 0)); len(got) != tt.n {
+			t.Errorf("since %d: got %d entries, expected %d", tt.t, len(got), tt.n)
+		}
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	h := newFilesHeader()
+	h.SetInt32s(rpm.TagChangelogTime, []int32{2000, 1000})
+	h.SetStrings(rpm.TagChangelogName, []string{"John Doe <john@example.com> - 1.1-1", "Jane Doe"})
+	h.SetStrings(rpm.TagChangelogText, []string{"- update\n- fix", "- initial"})
+	entries, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Changelog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evr := rpm.ParseEVR("1.1-1")
+	expected := []ChangelogEntry{
+		{Time: time.Unix(2000, 0).UTC(), Author: "John Doe", Email: "john@example.com", EVR: &evr, Text: []string{"- update", "- fix"}},
+		{Time: time.Unix(1000, 0).UTC(), Author: "Jane Doe", Text: []string{"- initial"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("got %+v, expected %+v", entries, expected)
+	}
+
+	h.SetStrings(rpm.TagChangelogText, []string{"- update"})
+	_, err = newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Changelog()
+	if !errors.Is(err, rpm.ErrCountMismatch) {
+		t.Errorf("got %v, expected count mismatch", err)
+	}
+
+	_, err = newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), newFilesHeader(), nil).Changelog()
+	if !errors.Is(err, rpm.ErrTagNotFound) {
+		t.Errorf("got %v, expected missing tag", err)
+	}
+}
@@ -3,34 +3,49 @@ package rpmutil
 import (
 	"compress/bzip2"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 
 	"code.pikelabs.net/go/compress/xz"
+	"code.pikelabs.net/go/compress/zstd"
 	"code.pikelabs.net/go/rpm"
 )
 
+// ErrUnsupportedCompression is returned for payloads compressed by
+// a compressor rpmutil can't decompress
+var ErrUnsupportedCompression = errors.New("unsupported payload compression")
+
 const (
 	plUncompressed = "uncompressed"
 	plGzip         = "gzip"
 	plBzip2        = "bzip2"
 	plXZ           = "xz"
+	plZstd         = "zstd"
 )
 
 func decompressPkgPayload(p *Package) (io.Reader, error) {
-	compressor, err := p.Header.GetString(rpm.TagPayloadCompressor)
+	return decompressPayload(p.Header, p.r)
+}
+
+func decompressPayload(h *rpm.Header, r io.Reader) (io.Reader, error) {
+	compressor, err := h.GetString(rpm.TagPayloadCompressor)
 
 	if err != nil {
 		return nil, err
 	}
 
 	switch compressor {
+	case plUncompressed:
+		return r, nil
 	case plGzip:
-		return gzip.NewReader(p.r)
+		return gzip.NewReader(r)
 	case plBzip2:
-		return bzip2.NewReader(p.r), nil
+		return bzip2.NewReader(r), nil
 	case plXZ:
-		return xz.NewReader(p.r), nil
+		return xz.NewReader(r), nil
+	case plZstd:
+		return zstd.NewReader(r), nil
 	}
-	return nil, fmt.Errorf("unsuppored compression format: %s", compressor)
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCompression, compressor)
 }
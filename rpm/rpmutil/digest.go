@@ -0,0 +1,282 @@
+package rpmutil
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+
+	"code.pikelabs.net/go/crypto/sha3"
+	"code.pikelabs.net/go/rpm"
+)
+
+var (
+	ErrSizeMismatch      = errors.New("size mismatch")
+	ErrDigestMismatch    = errors.New("digest mismatch")
+	ErrMissingDigest     = errors.New("missing mandatory digest")
+	ErrUnsupportedDigest = errors.New("unsupported digest algorithm")
+)
+
+// DigestError reports digest stored in Tag which doesn't match
+// the package contents
+type DigestError struct {
+	Tag      rpm.HeaderTag
+	Expected string
+	Actual   string
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("%s: tag %d expected %s, got %s", ErrDigestMismatch, e.Tag, e.Expected, e.Actual)
+}
+
+func (e *DigestError) Unwrap() error {
+	return ErrDigestMismatch
+}
+
+// SizeError reports size stored in Tag which doesn't match
+// the package contents
+type SizeError struct {
+	Tag      rpm.HeaderTag
+	Expected int64
+	Actual   int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s: tag %d expected %d, got %d", ErrSizeMismatch, e.Tag, e.Expected, e.Actual)
+}
+
+func (e *SizeError) Unwrap() error {
+	return ErrSizeMismatch
+}
+
+// FormatVersion returns package format version, 4 or 6. It's
+// decided by the header, v6 packages record their format and carry
+// SHA3-256 payload digest, the lead is the same for both.
+func (pkg *Package) FormatVersion() int {
+	if v, err := pkg.Header.GetInt(rpm.TagRPMFormat); err == nil {
+		return int(v)
+	}
+	if hasTag(pkg.Header, rpm.TagPayloadSHA3_256) {
+		return 6
+	}
+	return 4
+}
+
+// NewHash returns hash for algorithm, nil if it's not supported
+func NewHash(algo rpm.HashAlgo) hash.Hash {
+	switch algo {
+	case rpm.HashMD5:
+		return md5.New()
+	case rpm.HashSHA1:
+		return sha1.New()
+	case rpm.HashSHA224:
+		return sha256.New224()
+	case rpm.HashSHA256:
+		return sha256.New()
+	case rpm.HashSHA384:
+		return sha512.New384()
+	case rpm.HashSHA512:
+		return sha512.New()
+	case rpm.HashSHA3_256:
+		return sha3.New256()
+	case rpm.HashSHA3_512:
+		return sha3.New512()
+	}
+	return nil
+}
+
+type headerDigest struct {
+	tag  rpm.HeaderTag
+	algo rpm.HashAlgo
+	v6   bool
+}
+
+// header digests stored in signature header as hex strings, v6
+// packages are required to carry the ones marked
+var headerDigests = []headerDigest{
+	{rpm.SigTagSHA1, rpm.HashSHA1, false},
+	{rpm.SigTagSHA256, rpm.HashSHA256, true},
+	{rpm.SigTagSHA3_256, rpm.HashSHA3_256, true},
+}
+
+// VerifyHeader checks header digests stored in signature header,
+// it doesn't consume payload of the package
+func (pkg *Package) VerifyHeader() error {
+	d, err := pkg.Header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	v6 := pkg.FormatVersion() >= 6
+	for _, hd := range headerDigests {
+		expected, err := pkg.SigHeader.GetString(hd.tag)
+		if errors.Is(err, rpm.ErrTagNotFound) {
+			if v6 && hd.v6 {
+				return fmt.Errorf("%w: signature tag %d", ErrMissingDigest, hd.tag)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h := NewHash(hd.algo)
+		h.Write(d)
+		if err := compareDigest(hd.tag, expected, h.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type payloadDigest struct {
+	tag rpm.HeaderTag
+	h   hash.Hash
+}
+
+// Verify checks header digests along with digests of payload, both
+// compressed and uncompressed, legacy MD5 of v4 packages and sizes
+// recorded in signature header. Archive size is checked only when
+// payload is decompressed for its digests. It consumes the rest of
+// the package, so payload can't be read afterwards.
+func (pkg *Package) Verify() error {
+	if err := pkg.VerifyHeader(); err != nil {
+		return err
+	}
+	v6 := pkg.FormatVersion() >= 6
+
+	var compressed, uncompressed []payloadDigest
+	algo := rpm.HashSHA256
+	if v, err := pkg.Header.GetInt(rpm.TagPayloadDigestAlgo); err == nil {
+		algo = rpm.HashAlgo(v)
+	}
+	for _, pd := range []struct {
+		tag, alt rpm.HeaderTag
+		algo     rpm.HashAlgo
+	}{
+		{rpm.TagPayloadDigest, rpm.TagPayloadDigestAlt, algo},
+		{rpm.TagPayloadSHA512, rpm.TagPayloadSHA512Alt, rpm.HashSHA512},
+		{rpm.TagPayloadSHA3_256, rpm.TagPayloadSHA3_256Alt, rpm.HashSHA3_256},
+	} {
+		if !hasTag(pkg.Header, pd.tag) {
+			if v6 && pd.tag != rpm.TagPayloadSHA512 {
+				return fmt.Errorf("%w: tag %d", ErrMissingDigest, pd.tag)
+			}
+			continue
+		}
+		h := NewHash(pd.algo)
+		if h == nil {
+			return fmt.Errorf("%w: hash algorithm %d", ErrUnsupportedDigest, pd.algo)
+		}
+		compressed = append(compressed, payloadDigest{pd.tag, h})
+		if hasTag(pkg.Header, pd.alt) {
+			uncompressed = append(uncompressed, payloadDigest{pd.alt, NewHash(pd.algo)})
+		}
+	}
+
+	// legacy MD5 covers header and payload
+	var md5h hash.Hash
+	_, md5sum, err := pkg.SigHeader.GetTag(rpm.SigTagMD5)
+	if err == nil && !v6 {
+		hdr, err := pkg.Header.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		md5h = md5.New()
+		md5h.Write(hdr)
+	}
+
+	hdrSize, err := headerSize(pkg.Header)
+	if err != nil {
+		return err
+	}
+	var plSize, archiveSize countWriter
+	writers := make([]io.Writer, 0, len(compressed)+2)
+	writers = append(writers, &plSize)
+	for _, pd := range compressed {
+		writers = append(writers, pd.h)
+	}
+	if md5h != nil {
+		writers = append(writers, md5h)
+	}
+	r := io.TeeReader(pkg.r, io.MultiWriter(writers...))
+	// payload failing to decompress is reported only when the
+	// compressed digests match, mismatch says more about the cause
+	var plErr error
+	if len(uncompressed) > 0 {
+		plRdr, err := decompressPayload(pkg.Header, r)
+		if err != nil {
+			return err
+		}
+		writers := []io.Writer{&archiveSize}
+		for _, pd := range uncompressed {
+			writers = append(writers, pd.h)
+		}
+		_, plErr = io.Copy(io.MultiWriter(writers...), plRdr)
+	}
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return err
+	}
+
+	if plErr == nil {
+		compressed = append(compressed, uncompressed...)
+	}
+	for _, pd := range compressed {
+		expected, err := getStringList(pkg.Header, pd.tag)
+		if err != nil {
+			return err
+		}
+		if err := compareDigest(pd.tag, expected[0], pd.h.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	if plErr != nil {
+		return plErr
+	}
+
+	if n, tag, err := getSize(pkg.SigHeader, rpm.SigTagLongSize, rpm.SigTagSize); err == nil {
+		if actual := hdrSize + int64(plSize); n != actual {
+			return &SizeError{Tag: tag, Expected: n, Actual: actual}
+		}
+	}
+	if n, tag, err := pkg.archiveSize(); err == nil && len(uncompressed) > 0 {
+		if n != int64(archiveSize) {
+			return &SizeError{Tag: tag, Expected: n, Actual: int64(archiveSize)}
+		}
+	}
+	if md5h != nil {
+		if sum := md5h.Sum(nil); !bytes.Equal(sum, md5sum) {
+			return &DigestError{Tag: rpm.SigTagMD5, Expected: hex.EncodeToString(md5sum), Actual: hex.EncodeToString(sum)}
+		}
+	}
+	return nil
+}
+
+func compareDigest(tag rpm.HeaderTag, expected string, sum []byte) error {
+	if actual := hex.EncodeToString(sum); actual != expected {
+		return &DigestError{Tag: tag, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+func headerSize(h *rpm.Header) (int64, error) {
+	d, err := h.MarshalBinary()
+	return int64(len(d)), err
+}
+
+type countWriter int64
+
+func (c *countWriter) Write(d []byte) (int, error) {
+	*c += countWriter(len(d))
+	return len(d), nil
+}
+
+func hasTag(h *rpm.Header, t rpm.HeaderTag) bool {
+	_, _, err := h.GetTag(t)
+	return err == nil
+}
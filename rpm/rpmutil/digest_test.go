@@ -0,0 +1,211 @@
+package rpmutil
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/crypto/sha3"
+	"code.pikelabs.net/go/rpm"
+)
+
+func TestFormatVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    uint8
+		set      func(h *rpm.Header)
+		expected int
+	}{
+		{"v4", 3, nil, 4},
+		{"v4 with 4.0 lead", 4, nil, 4},
+		{"rpmformat", 3, func(h *rpm.Header) { h.SetInt32s(rpm.TagRPMFormat, []int32{6}) }, 6},
+		{"sha3 payload digest", 3, func(h *rpm.Header) {
+			h.SetStrings(rpm.TagPayloadSHA3_256, []string{"00"})
+		}, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFilesHeader()
+			if tt.set != nil {
+				tt.set(h)
+			}
+			lead := rpm.NewLead("test-1.0-1", "x86_64", 0)
+			lead.Major = tt.major
+			var b bytes.Buffer
+			if err := WritePackage(&b, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil); err != nil {
+				t.Fatal(err)
+			}
+			pkg, err := ReadPackage(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := pkg.FormatVersion(); v != tt.expected {
+				t.Errorf("got %d, expected %d", v, tt.expected)
+			}
+		})
+	}
+}
+
+// signedPackage returns signature header with digests of h and
+// payload, the ones v6 packages are required to carry when v6 is set
+func signedPackage(t *testing.T, h *rpm.Header, payload []byte, v6 bool) *rpm.Header {
+	t.Helper()
+	h.SetString(rpm.TagPayloadCompressor, "uncompressed")
+	plSHA256 := sha256.Sum256(payload)
+	h.SetStrings(rpm.TagPayloadDigest, []string{hex.EncodeToString(plSHA256[:])})
+	h.SetStrings(rpm.TagPayloadDigestAlt, []string{hex.EncodeToString(plSHA256[:])})
+	if v6 {
+		plSHA3 := sha3.Sum256(payload)
+		h.SetInt32s(rpm.TagRPMFormat, []int32{6})
+		h.SetStrings(rpm.TagPayloadSHA3_256, []string{hex.EncodeToString(plSHA3[:])})
+		h.SetStrings(rpm.TagPayloadSHA3_256Alt, []string{hex.EncodeToString(plSHA3[:])})
+	}
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := rpm.NewHeader(rpm.TagHeaderSignatures)
+	hdrSHA1 := sha1.Sum(d)
+	hdrSHA256 := sha256.Sum256(d)
+	sig.SetString(rpm.SigTagSHA1, hex.EncodeToString(hdrSHA1[:]))
+	sig.SetString(rpm.SigTagSHA256, hex.EncodeToString(hdrSHA256[:]))
+	if v6 {
+		hdrSHA3 := sha3.Sum256(d)
+		sig.SetString(rpm.SigTagSHA3_256, hex.EncodeToString(hdrSHA3[:]))
+	} else {
+		sum := md5.Sum(append(d, payload...))
+		sig.SetBin(rpm.SigTagMD5, sum[:])
+	}
+	sig.SetInt32s(rpm.SigTagSize, []int32{int32(len(d) + len(payload))})
+	sig.SetInt32s(rpm.SigTagPayloadSize, []int32{int32(len(payload))})
+	return sig
+}
+
+func TestVerify(t *testing.T) {
+	payload := []byte("payload")
+	tests := []struct {
+		name   string
+		v6     bool
+		tamper func(sig, h *rpm.Header, payload []byte) []byte
+		tag    rpm.HeaderTag
+		err    error
+	}{
+		{name: "v4"},
+		{name: "v6", v6: true},
+		{
+			name: "v4 header",
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				h.SetString(rpm.TagName, "evil")
+				return payload
+			},
+			tag: rpm.SigTagSHA1,
+		},
+		{
+			name: "v6 header", v6: true,
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				h.SetString(rpm.TagName, "evil")
+				return payload
+			},
+			tag: rpm.SigTagSHA1,
+		},
+		{
+			name: "v4 payload",
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				return []byte("PAYLOAD")
+			},
+			tag: rpm.TagPayloadDigest,
+		},
+		{
+			name: "v6 payload", v6: true,
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				return []byte("PAYLOAD")
+			},
+			tag: rpm.TagPayloadDigest,
+		},
+		{
+			name: "legacy md5",
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				sig.SetBin(rpm.SigTagMD5, make([]byte, md5.Size))
+				return payload
+			},
+			tag: rpm.SigTagMD5,
+		},
+		{
+			name: "v6 without sha3 header digest", v6: true,
+			tamper: func(sig, h *rpm.Header, payload []byte) []byte {
+				sig.DeleteTag(rpm.SigTagSHA3_256)
+				return payload
+			},
+			err: ErrMissingDigest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFilesHeader()
+			sig := signedPackage(t, h, payload, tt.v6)
+			pl := payload
+			if tt.tamper != nil {
+				pl = tt.tamper(sig, h, payload)
+			}
+			err := newPackage(t, sig, h, pl).Verify()
+			switch {
+			case tt.tag == 0 && tt.err == nil:
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, expected %v", err, tt.err)
+				}
+			default:
+				var de *DigestError
+				if !errors.As(err, &de) || de.Tag != tt.tag {
+					t.Errorf("got %v, expected digest mismatch of tag %d", err, tt.tag)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyMissingPayloadDigest(t *testing.T) {
+	payload := []byte("payload")
+	h := newFilesHeader()
+	h.SetInt32s(rpm.TagRPMFormat, []int32{6})
+	sig := signedPackage(t, h, payload, false)
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha3.Sum256(d)
+	sig.SetString(rpm.SigTagSHA3_256, hex.EncodeToString(sum[:]))
+
+	pkg := newPackage(t, sig, h, payload)
+	if err := pkg.VerifyHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.Verify(); !errors.Is(err, ErrMissingDigest) {
+		t.Errorf("got %v, expected %v", err, ErrMissingDigest)
+	}
+}
+
+func TestVerifyHeader(t *testing.T) {
+	payload := []byte("payload")
+	h := newFilesHeader()
+	sig := signedPackage(t, h, payload, false)
+	// VerifyHeader doesn't look at payload
+	if err := newPackage(t, sig, h, []byte("PAYLOAD")).VerifyHeader(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	sig.SetString(rpm.SigTagSHA256, strings.Repeat("0", 64))
+	var de *DigestError
+	if err := newPackage(t, sig, h, payload).VerifyHeader(); !errors.As(err, &de) || de.Tag != rpm.SigTagSHA256 {
+		t.Errorf("got %v, expected digest mismatch of tag %d", err, rpm.SigTagSHA256)
+	}
+}
@@ -0,0 +1,366 @@
+package rpmutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"code.pikelabs.net/go/archive/cpio"
+	"code.pikelabs.net/go/rpm"
+)
+
+var ErrUnsafePath = errors.New("path escapes root")
+
+const (
+	modeReg  = 0x8000
+	modeLink = 0xa000
+	// maxLinks bounds symlinks followed resolving single path
+	maxLinks = 40
+)
+
+// ExtractOptions control how payload is written to disk
+type ExtractOptions struct {
+	// NoOwner leaves files owned by the user extracting them, owners
+	// are set only when running as root anyway
+	NoOwner bool
+}
+
+// Extract writes files of payload under root with attributes recorded
+// in header and returns paths written, relative to root. Symlinks of
+// directories are resolved within root, the way they would be after
+// chroot. Existing %config(noreplace) files which differ are kept and
+// new ones are written with .rpmnew suffix. Payload is consumed.
+func (pkg *Package) Extract(root string, opts *ExtractOptions) ([]string, error) {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	files, err := pkg.Files()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*FileInfo{}
+	for i := range files {
+		byName[files[i].Name] = &files[i]
+	}
+	x := &extractor{root: root, chown: !opts.NoOwner && os.Geteuid() == 0, links: map[int64]string{}, pending: map[int64][]string{}}
+	if x.chown {
+		x.users = nameIDs(readIDs(root, "/etc/passwd"))
+		x.groups = nameIDs(readIDs(root, "/etc/group"))
+	}
+
+	pl, err := pkg.Payload()
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		name := path.Clean("/" + strings.TrimPrefix(h.Name, "."))
+		f := byName[name]
+		if f == nil {
+			f = &FileInfo{Name: name, Mode: uint16(h.Mode), MTime: h.Mtime}
+		}
+		if f.Flags&rpm.FileGhost != 0 {
+			continue
+		}
+		dest, err := x.extract(name, h, r, f)
+		if err != nil {
+			return written, &os.PathError{Op: "extract", Path: name, Err: err}
+		}
+		if len(dest) > 0 {
+			written = append(written, dest)
+		}
+	}
+	return written, nil
+}
+
+type extractor struct {
+	root          string
+	chown         bool
+	users, groups map[string]int
+	// links are paths hardlinked inodes were written to, pending
+	// are links seen before data of their inode
+	links   map[int64]string
+	pending map[int64][]string
+}
+
+func nameIDs(ids map[uint32]string) map[string]int {
+	res := map[string]int{}
+	for id, name := range ids {
+		res[name] = int(id)
+	}
+	return res
+}
+
+// extract writes single payload entry and returns its path relative
+// to root, empty if it wasn't written
+func (x *extractor) extract(name string, h *cpio.Header, r io.Reader, f *FileInfo) (string, error) {
+	dest, err := SecurePath(x.root, name)
+	if err != nil {
+		return "", err
+	}
+	if name == "/" {
+		return "", nil
+	}
+	if err := x.mkdirs(path.Dir(name)); err != nil {
+		return "", err
+	}
+	mode := os.FileMode(h.Mode & 0777)
+	for _, m := range []struct {
+		bit  cpio.FileMode
+		mode os.FileMode
+	}{{04000, os.ModeSetuid}, {02000, os.ModeSetgid}, {01000, os.ModeSticky}} {
+		if h.Mode&m.bit != 0 {
+			mode |= m.mode
+		}
+	}
+	rel := name
+	switch h.Mode & modeTypeMask {
+	case modeDir:
+		fi, err := os.Lstat(dest)
+		if err == nil && fi.IsDir() {
+			break
+		}
+		// symlinks to directories, like /bin of usrmerge, are kept
+		// as they are, the way rpm does
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 && x.isDir(name) {
+			return rel, nil
+		}
+		if err := os.Mkdir(dest, 0700); err != nil {
+			return "", err
+		}
+	case modeReg:
+		// data of hardlinked files comes with the last link, empty
+		// entries before it are linked once it's written
+		if h.Links > 1 {
+			if first, ok := x.links[h.Inode]; ok {
+				os.Remove(dest)
+				if err := os.Link(first, dest); err != nil {
+					return "", err
+				}
+				return rel, nil
+			}
+			if h.Size == 0 && f.Size > 0 {
+				x.pending[h.Inode] = append(x.pending[h.Inode], dest)
+				return name, nil
+			}
+		}
+		if rel, dest, err = x.configTarget(name, dest, f); err != nil {
+			return "", err
+		}
+		if err := writeFile(dest, r); err != nil {
+			return "", err
+		}
+		if h.Links > 1 {
+			if err := x.linkPending(h.Inode, dest); err != nil {
+				return "", err
+			}
+			x.links[h.Inode] = dest
+		}
+	case modeLink:
+		target, err := ioutil.ReadAll(io.LimitReader(r, 4096))
+		if err != nil {
+			return "", err
+		}
+		if len(target) == 0 {
+			target = []byte(f.LinkTo)
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return "", err
+		}
+		if err := os.Symlink(string(target), dest); err != nil {
+			return "", err
+		}
+		return rel, x.setOwner(dest, f, true)
+	default:
+		os.Remove(dest)
+		if err := mknod(dest, uint32(h.Mode), int(f.Rdev)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := x.setOwner(dest, f, false); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dest, mode); err != nil {
+		return "", err
+	}
+	return rel, os.Chtimes(dest, h.Mtime, h.Mtime)
+}
+
+func (x *extractor) linkPending(ino int64, dest string) error {
+	for _, p := range x.pending[ino] {
+		os.Remove(p)
+		if err := os.Link(dest, p); err != nil {
+			return err
+		}
+	}
+	delete(x.pending, ino)
+	return nil
+}
+
+// configTarget picks where config file goes, modified %config
+// (noreplace) files are kept and new one is saved next to them
+func (x *extractor) configTarget(name, dest string, f *FileInfo) (string, string, error) {
+	if f.Flags&rpm.FileConfig == 0 || f.Flags&rpm.FileNoReplace == 0 {
+		return name, dest, nil
+	}
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		return name, dest, nil
+	}
+	if len(f.Digest) > 0 {
+		sum, err := fileDigest(dest, algoOfDigest(f.Digest))
+		if err == nil && sum == f.Digest {
+			return name, dest, nil
+		}
+	}
+	return name + ".rpmnew", dest + ".rpmnew", nil
+}
+
+// algoOfDigest guesses hash algorithm by length of hex digest
+func algoOfDigest(d string) rpm.HashAlgo {
+	switch len(d) {
+	case 32:
+		return rpm.HashMD5
+	case 40:
+		return rpm.HashSHA1
+	case 128:
+		return rpm.HashSHA512
+	}
+	return rpm.HashSHA256
+}
+
+// mkdirs creates missing parent directories the payload doesn't
+// list, the way rpm does
+func (x *extractor) mkdirs(dir string) error {
+	if dir == "/" {
+		return nil
+	}
+	dest, err := SecurePath(x.root, dir)
+	if err != nil {
+		return err
+	}
+	if x.isDir(dir) {
+		return nil
+	}
+	if err := x.mkdirs(path.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dest, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// isDir reports whether name is directory or symlink to one, resolved
+// within root
+func (x *extractor) isDir(name string) bool {
+	p, err := ResolvePath(x.root, name)
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
+func (x *extractor) setOwner(dest string, f *FileInfo, link bool) error {
+	if !x.chown {
+		return nil
+	}
+	uid, gid := x.users[f.User], x.groups[f.Group]
+	if link {
+		return os.Lchown(dest, uid, gid)
+	}
+	return os.Chown(dest, uid, gid)
+}
+
+// writeFile writes data into temporary file next to dest which then
+// replaces it, running binaries aren't overwritten in place
+func writeFile(dest string, r io.Reader) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), ".ypkg-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if fi, lerr := os.Lstat(dest); lerr == nil && fi.IsDir() {
+			err = fmt.Errorf("directory in place of file")
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// SecurePath returns path of name on host with root as its root
+// directory. Symlinks of its directory part are followed within root,
+// absolute ones relative to root, and .. doesn't go above it.
+func SecurePath(root, name string) (string, error) {
+	return securePath(root, name, false)
+}
+
+// ResolvePath is SecurePath which follows symlink name refers to as
+// well, so the result is never a symlink to outside of root
+func ResolvePath(root, name string) (string, error) {
+	return securePath(root, name, true)
+}
+
+func securePath(root, name string, follow bool) (string, error) {
+	dir, base := path.Split(path.Clean("/" + name))
+	if follow {
+		dir, base = dir+base, ""
+	}
+	resolved := "/"
+	parts := strings.Split(dir, "/")
+	followed := 0
+	for len(parts) > 0 {
+		p := parts[0]
+		parts = parts[1:]
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, p)
+		fi, err := os.Lstat(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if followed++; followed > maxLinks {
+			return "", fmt.Errorf("%w: too many links in %s", ErrUnsafePath, name)
+		}
+		target, err := os.Readlink(filepath.Join(root, filepath.FromSlash(next)))
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	if base == ".." {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return filepath.Join(root, filepath.FromSlash(path.Join(resolved, base))), nil
+}
@@ -0,0 +1,104 @@
+package rpmutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+)
+
+// newRoot creates root with /usr/bin, /bin linking to it absolutely
+// and /conf linking to /etc, which exists on host only
+func newRoot(t *testing.T) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "rpmutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "usr/bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{"bin": "/usr/bin", "conf": "/etc", "up": "../../.."} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSecurePath(t *testing.T) {
+	root := newRoot(t)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		name     string
+		secure   string
+		resolved string
+	}{
+		{"/usr/bin/sh", "/usr/bin/sh", "/usr/bin/sh"},
+		{"/bin/sh", "/usr/bin/sh", "/usr/bin/sh"},
+		{"/bin", "/bin", "/usr/bin"},
+		{"/conf/passwd", "/etc/passwd", "/etc/passwd"},
+		{"/conf", "/conf", "/etc"},
+		{"/up/etc/passwd", "/etc/passwd", "/etc/passwd"},
+		{"/../../etc/passwd", "/etc/passwd", "/etc/passwd"},
+	}
+	for _, tt := range tests {
+		s, err := SecurePath(root, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := filepath.Join(root, tt.secure); s != expected {
+			t.Errorf("SecurePath %s: got %s, expected %s", tt.name, s, expected)
+		}
+		r, err := ResolvePath(root, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := filepath.Join(root, tt.resolved); r != expected {
+			t.Errorf("ResolvePath %s: got %s, expected %s", tt.name, r, expected)
+		}
+	}
+}
+
+func TestSecurePathLoop(t *testing.T) {
+	root, err := ioutil.TempDir("", "rpmutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Symlink("/loop", filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolvePath(root, "/loop"); err == nil {
+		t.Error("symlink loop resolved")
+	}
+}
+
+func TestExtractDirSymlink(t *testing.T) {
+	root := newRoot(t)
+	defer os.RemoveAll(root)
+
+	files := []rpmtest.File{
+		{Name: "/bin", Mode: 040755},
+		{Name: "/bin/sh", Mode: 0100755, Data: "#!/bin/sh\n"},
+	}
+	pkg := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), rpmtest.Header("test", files...), rpmtest.Payload(files...))
+
+	written, err := pkg.Extract(root, &ExtractOptions{NoOwner: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 {
+		t.Errorf("got %v written", written)
+	}
+	if fi, err := os.Lstat(filepath.Join(root, "bin")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("/bin symlink replaced: %v %v", fi.Mode(), err)
+	}
+	if d, err := ioutil.ReadFile(filepath.Join(root, "usr/bin/sh")); err != nil || string(d) != "#!/bin/sh\n" {
+		t.Errorf("/usr/bin/sh: got %q %v", d, err)
+	}
+}
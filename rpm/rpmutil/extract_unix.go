@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package rpmutil
+
+import (
+	"syscall"
+)
+
+func mknod(path string, mode uint32, rdev int) error {
+	return syscall.Mknod(path, mode, rdev)
+}
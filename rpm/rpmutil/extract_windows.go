@@ -0,0 +1,10 @@
+package rpmutil
+
+import (
+	"errors"
+)
+
+// mknod can't create devices and fifos on windows
+func mknod(path string, mode uint32, rdev int) error {
+	return errors.New("special files not supported")
+}
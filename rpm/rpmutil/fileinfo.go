@@ -1,5 +1,164 @@
 package rpmutil
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// FileInfo describes file of package as recorded in the header,
+// attributes missing from the header are left zero
 type FileInfo struct {
 	Name string
+	Size int64
+	// Mode is unix mode including file type bits
+	Mode        uint16
+	Rdev        uint16
+	MTime       time.Time
+	Digest      string
+	LinkTo      string
+	User        string
+	Group       string
+	Flags       rpm.FileFlags
+	VerifyFlags rpm.VerifyFlags
+	Color       uint32
+	// State is set for headers of installed packages only
+	State rpm.FileState
+}
+
+func (pkg *Package) Files() ([]FileInfo, error) {
+	return HeaderFiles(pkg.Header)
+}
+
+// HeaderFiles returns files listed in header, headers of installed
+// packages read from rpmdb included
+func HeaderFiles(h *rpm.Header) ([]FileInfo, error) {
+	paths, err := h.GetStrings(rpm.TagFilenames)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := len(paths)
+	checkCount := func(t rpm.HeaderTag, cnt int, err error) error {
+		if errors.Is(err, rpm.ErrTagNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if cnt != n {
+			return fmt.Errorf("tag %d: %w: %d values for %d files", t, rpm.ErrCountMismatch, cnt, n)
+		}
+		return nil
+	}
+	int16s := func(t rpm.HeaderTag) ([]int16, error) {
+		v, err := h.GetInt16s(t)
+		return v, checkCount(t, len(v), err)
+	}
+	int32s := func(t rpm.HeaderTag) ([]int32, error) {
+		v, err := h.GetInt32s(t)
+		return v, checkCount(t, len(v), err)
+	}
+	strs := func(t rpm.HeaderTag) ([]string, error) {
+		v, err := h.GetStrings(t)
+		return v, checkCount(t, len(v), err)
+	}
+
+	sizes, err := getSizes(h)
+	if err := checkCount(rpm.TagFileSizes, len(sizes), err); err != nil {
+		return nil, err
+	}
+	modes, err := int16s(rpm.TagFileModes)
+	if err != nil {
+		return nil, err
+	}
+	rdevs, err := int16s(rpm.TagFileRDevs)
+	if err != nil {
+		return nil, err
+	}
+	mtimes, err := int32s(rpm.TagFileMTimes)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := strs(rpm.TagFileMD5S)
+	if err != nil {
+		return nil, err
+	}
+	links, err := strs(rpm.TagFileLinkTos)
+	if err != nil {
+		return nil, err
+	}
+	users, err := strs(rpm.TagFileUsername)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := strs(rpm.TagFileGroupname)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := int32s(rpm.TagFileFlags)
+	if err != nil {
+		return nil, err
+	}
+	verifyFlags, err := int32s(rpm.TagFileVerifyFlags)
+	if err != nil {
+		return nil, err
+	}
+	colors, err := int32s(rpm.TagFileColors)
+	if err != nil {
+		return nil, err
+	}
+	_, states, err := h.GetTag(rpm.TagFileStates)
+	if err := checkCount(rpm.TagFileStates, len(states), err); err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, n)
+	for i := range files {
+		f := &files[i]
+		f.Name = paths[i]
+		// files without verify flags are verified fully
+		f.VerifyFlags = rpm.VerifyAll
+		if sizes != nil {
+			f.Size = sizes[i]
+		}
+		if modes != nil {
+			f.Mode = uint16(modes[i])
+		}
+		if rdevs != nil {
+			f.Rdev = uint16(rdevs[i])
+		}
+		if mtimes != nil {
+			f.MTime = time.Unix(int64(uint32(mtimes[i])), 0)
+		}
+		if digests != nil {
+			f.Digest = digests[i]
+		}
+		if links != nil {
+			f.LinkTo = links[i]
+		}
+		if users != nil {
+			f.User = users[i]
+		}
+		if groups != nil {
+			f.Group = groups[i]
+		}
+		if flags != nil {
+			f.Flags = rpm.FileFlags(flags[i])
+		}
+		if verifyFlags != nil {
+			f.VerifyFlags = rpm.VerifyFlags(verifyFlags[i])
+		}
+		if colors != nil {
+			f.Color = uint32(colors[i])
+		}
+		if states != nil {
+			f.State = rpm.FileState(states[i])
+		}
+	}
+	return files, nil
 }
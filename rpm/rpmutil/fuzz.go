@@ -0,0 +1,22 @@
+//go:build gofuzz
+// +build gofuzz
+
+package rpmutil
+
+import (
+	"bytes"
+)
+
+// Fuzz is go-fuzz entry point exercising ReadPackage and header
+// based package accessors
+func Fuzz(data []byte) int {
+	pkg, err := ReadPackage(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	pkg.Files()
+	pkg.Changelog()
+	pkg.Scriptlets()
+	pkg.Triggers()
+	return 1
+}
@@ -0,0 +1,21 @@
+package rpmutil
+
+import (
+	"code.pikelabs.net/go/rpm"
+)
+
+// Summary returns package summary for locale, falling back to C
+func (pkg *Package) Summary(locale string) (string, error) {
+	return pkg.Header.GetI18NString(rpm.TagSummary, locale)
+}
+
+// Description returns package description for locale, falling
+// back to C
+func (pkg *Package) Description(locale string) (string, error) {
+	return pkg.Header.GetI18NString(rpm.TagDescription, locale)
+}
+
+// Group returns package group for locale, falling back to C
+func (pkg *Package) Group(locale string) (string, error) {
+	return pkg.Header.GetI18NString(rpm.TagGroup, locale)
+}
@@ -2,7 +2,6 @@ package rpmutil
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -13,9 +12,12 @@ import (
 )
 
 type Package struct {
+	Lead      *rpm.Lead
 	SigHeader *rpm.Header
 	Header    *rpm.Header
 	r         *readCounter
+	// offsets of header and payload in package
+	headerStart, payloadStart int64
 }
 
 func OpenFile(fname string) (*Package, error) {
@@ -28,26 +30,25 @@ func OpenFile(fname string) (*Package, error) {
 }
 
 func ReadPackage(r io.Reader) (*Package, error) {
+	return ReadPackageWithLimits(r, rpm.DefaultHeaderLimits)
+}
+
+// ReadPackageWithLimits reads package refusing headers which
+// exceed limits
+func ReadPackageWithLimits(r io.Reader, limits rpm.HeaderLimits) (*Package, error) {
 	rc := &readCounter{r: r}
-	// first lets get rid of Lead, for sanity check
-	// will still use first LeadMagic to make sure
-	// file is considered to be RPM, but rpm itself
-	// no longe uses lead structure. it's there for
-	// `file` command
-
-	lead := make([]byte, rpm.LeadSize)
-	if _, err := io.ReadFull(rc, lead); err != nil {
-		return nil, err
-	}
+	// rpm itself no longer uses lead structure apart
+	// from LeadMagic, it's there for `file` command,
+	// still decode it for tools which look at it
 
-	magic := binary.BigEndian.Uint32(lead[0:4])
-	if magic&0xFFFFFFFF != rpm.LeadMagic {
-		return nil, errors.New("bad lead magic")
+	lead, err := rpm.ReadLead(rc)
+	if err != nil {
+		return nil, err
 	}
 
-	sigHeader, err := rpm.ReadHeader(rc)
+	sigHeader, err := rpm.ReadHeaderWithLimits(rc, limits)
 	if err != nil {
-		return nil, err
+		return nil, packageError(err, "signature", rpm.LeadSize)
 	}
 
 	// signature header padded to align to 8 bytes
@@ -55,22 +56,33 @@ func ReadPackage(r io.Reader) (*Package, error) {
 	skip := int64(psize - rc.n)
 
 	if _, err := io.CopyN(ioutil.Discard, rc, skip); err != nil {
-		return nil, err
+		return nil, packageError(err, "signature", int64(rc.n))
 	}
 
-	header, err := rpm.ReadHeader(rc)
+	hstart := int64(rc.n)
+	header, err := rpm.ReadHeaderWithLimits(rc, limits)
 
 	if err != nil {
-		return nil, err
+		return nil, packageError(err, "header", hstart)
 	}
 	pkg := &Package{
+		Lead:      lead,
 		SigHeader: sigHeader,
 		Header:    header,
 		r:         rc,
+
+		headerStart:  hstart,
+		payloadStart: int64(rc.n),
 	}
 	return pkg, nil
 }
 
+// HeaderRange returns offset of header in package and offset of
+// payload which follows it
+func (pkg *Package) HeaderRange() (start, end int64) {
+	return pkg.headerStart, pkg.payloadStart
+}
+
 func (pkg *Package) Payload() (cpio.Reader, error) {
 	plRdr, err := decompressPkgPayload(pkg)
 	if err != nil {
@@ -79,23 +91,9 @@ func (pkg *Package) Payload() (cpio.Reader, error) {
 	return cpio.NewReader(plRdr)
 }
 
-func (pkg *Package) Files() ([]FileInfo, error) {
-	var (
-		paths     []string
-		err, err1 error
-	)
-	paths, err = pkg.Header.GetStrings(rpm.TagFilenames)
-	if err != nil {
-		paths, err1 = pkg.Header.GetStrings(rpm.TagOldFilenames)
-		if err1 != nil {
-			return nil, err1
-		}
-	}
-	files := make([]FileInfo, len(paths))
-	for i := 0; i < len(files); i++ {
-		files[i].Name = paths[i]
-	}
-	return files, nil
+// PayloadReader returns uncompressed payload of package
+func (pkg *Package) PayloadReader() (io.Reader, error) {
+	return decompressPkgPayload(pkg)
 }
 
 func (pkg *Package) Dump(w io.Writer) error {
@@ -115,13 +113,28 @@ func (pkg *Package) Dump(w io.Writer) error {
 		switch ttype {
 		case rpm.DataTypeString:
 			fmt.Fprintf(w, "\t %s\n", tdata)
-		case rpm.DataTypeStringArray:
+		case rpm.DataTypeStringArray, rpm.DataTypeI18NString:
 			fmt.Fprintf(w, "\t %v\n", rpm.CStringArrayToSlice(tdata))
 		}
 	}
 	return nil
 }
 
+// packageError makes format errors of package section relative
+// to the start of the package
+func packageError(err error, section string, start int64) error {
+	var fe *rpm.FormatError
+	if errors.As(err, &fe) {
+		fe.Section = section
+		fe.Offset += start
+		return fe
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &rpm.FormatError{Section: section, Offset: start, Err: rpm.ErrTruncated}
+	}
+	return err
+}
+
 type readCounter struct {
 	n int
 	r io.Reader
@@ -132,3 +145,29 @@ func (rc *readCounter) Read(b []byte) (n int, err error) {
 	rc.n += n
 	return
 }
+
+// WritePackage writes package built from lead, signature header and
+// header followed by the (already compressed) payload
+func WritePackage(w io.Writer, lead *rpm.Lead, sigHeader, header *rpm.Header, payload io.Reader) error {
+	if err := rpm.WriteLead(w, lead); err != nil {
+		return err
+	}
+	sig, err := sigHeader.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// signature header padded to align to 8 bytes
+	pad := (len(sig)+7)/8*8 - len(sig)
+	sig = append(sig, make([]byte, pad)...)
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+	if err := rpm.WriteHeader(w, header); err != nil {
+		return err
+	}
+	if payload == nil {
+		return nil
+	}
+	_, err = io.Copy(w, payload)
+	return err
+}
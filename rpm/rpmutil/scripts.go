@@ -0,0 +1,265 @@
+package rpmutil
+
+import (
+	"errors"
+	"fmt"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// ScriptPhase identifies when scriptlet runs
+type ScriptPhase string
+
+const (
+	PhasePretrans  ScriptPhase = "pretrans"
+	PhasePrein     ScriptPhase = "prein"
+	PhasePostin    ScriptPhase = "postin"
+	PhasePreun     ScriptPhase = "preun"
+	PhasePostun    ScriptPhase = "postun"
+	PhasePosttrans ScriptPhase = "posttrans"
+	PhaseVerify    ScriptPhase = "verify"
+)
+
+// ScriptFlags are rpmscriptFlags stored in *Flags tags of scriptlets
+type ScriptFlags uint32
+
+const (
+	ScriptExpand   ScriptFlags = 1 << 0
+	ScriptQFormat  ScriptFlags = 1 << 1
+	ScriptCritical ScriptFlags = 1 << 2
+)
+
+// Script is body of scriptlet along with interpreter it runs with
+type Script struct {
+	Interpreter string
+	Args        []string
+	Body        string
+	Flags       ScriptFlags
+}
+
+// Scriptlet is package script run at given phase of transaction
+type Scriptlet struct {
+	Phase ScriptPhase
+	Script
+}
+
+type scriptTags struct {
+	phase ScriptPhase
+	body  rpm.HeaderTag
+	prog  rpm.HeaderTag
+	flags rpm.HeaderTag
+}
+
+var scriptletTags = []scriptTags{
+	{PhasePretrans, rpm.TagPretrans, rpm.TagPretransProg, rpm.TagPretransFlags},
+	{PhasePrein, rpm.TagPrein, rpm.TagPreinProg, rpm.TagPreinFlags},
+	{PhasePostin, rpm.TagPostin, rpm.TagPostinProg, rpm.TagPostinFlags},
+	{PhasePreun, rpm.TagPreun, rpm.TagPreunProg, rpm.TagPreunFlags},
+	{PhasePostun, rpm.TagPostun, rpm.TagPostunProg, rpm.TagPostunFlags},
+	{PhasePosttrans, rpm.TagPosttrans, rpm.TagPosttransProg, rpm.TagPosttransFlags},
+	{PhaseVerify, rpm.TagVerifyScript, rpm.TagVerifyScriptProg, rpm.TagVerifyScriptFlags},
+}
+
+// TriggerType is kind of event trigger reacts on
+type TriggerType string
+
+const (
+	TriggerPrein  TriggerType = "triggerprein"
+	TriggerIn     TriggerType = "triggerin"
+	TriggerUn     TriggerType = "triggerun"
+	TriggerPostun TriggerType = "triggerpostun"
+)
+
+// TriggerCondition is package name (or path prefix for file triggers)
+// with optional version constraint which fires trigger
+type TriggerCondition struct {
+	Name    string
+	Flags   rpm.SenseFlags
+	Version string
+}
+
+// Trigger is script run when other packages (or files) are
+// installed or removed
+type Trigger struct {
+	Type TriggerType
+	// File is set for file triggers, Transaction for
+	// transaction file triggers
+	File        bool
+	Transaction bool
+	Priority    int32
+	Conditions  []TriggerCondition
+	Script
+}
+
+type triggerTags struct {
+	file, trans bool
+
+	scripts, prog, scriptFlags rpm.HeaderTag
+	name, index, version       rpm.HeaderTag
+	flags, priorities          rpm.HeaderTag
+}
+
+var triggerTagSets = []triggerTags{
+	{
+		scripts: rpm.TagTriggerScripts, prog: rpm.TagTriggerScriptProg, scriptFlags: rpm.TagTriggerScriptFlags,
+		name: rpm.TagTriggerName, index: rpm.TagTriggerIndex, version: rpm.TagTriggerVersion,
+		flags: rpm.TagTriggerFlags,
+	},
+	{
+		file:    true,
+		scripts: rpm.TagFileTriggerScripts, prog: rpm.TagFileTriggerScriptProg, scriptFlags: rpm.TagFileTriggerScriptFlags,
+		name: rpm.TagFileTriggerName, index: rpm.TagFileTriggerIndex, version: rpm.TagFileTriggerVersion,
+		flags: rpm.TagFileTriggerFlags, priorities: rpm.TagFileTriggerPriorities,
+	},
+	{
+		file: true, trans: true,
+		scripts: rpm.TagTransFileTriggerScripts, prog: rpm.TagTransFileTriggerScriptProg, scriptFlags: rpm.TagTransFileTriggerScriptFlags,
+		name: rpm.TagTransFileTriggerName, index: rpm.TagTransFileTriggerIndex, version: rpm.TagTransFileTriggerVersion,
+		flags: rpm.TagTransFileTriggerFlags, priorities: rpm.TagTransFileTriggerPriorities,
+	},
+}
+
+// defaultInterpreter is used by rpm when *Prog tag is missing
+const defaultInterpreter = "/bin/sh"
+
+// Scriptlets returns all scriptlets of package in order they run
+func (pkg *Package) Scriptlets() ([]Scriptlet, error) {
+	var res []Scriptlet
+	h := pkg.Header
+	for _, st := range scriptletTags {
+		body, bodyErr := h.GetString(st.body)
+		prog, progErr := getStringList(h, st.prog)
+		if errors.Is(bodyErr, rpm.ErrTagNotFound) && errors.Is(progErr, rpm.ErrTagNotFound) {
+			continue
+		}
+		if bodyErr != nil && !errors.Is(bodyErr, rpm.ErrTagNotFound) {
+			return nil, bodyErr
+		}
+		if progErr != nil && !errors.Is(progErr, rpm.ErrTagNotFound) {
+			return nil, progErr
+		}
+		s := Scriptlet{Phase: st.phase}
+		s.Body = body
+		s.Interpreter = defaultInterpreter
+		if len(prog) > 0 {
+			s.Interpreter, s.Args = prog[0], prog[1:]
+		}
+		if flags, err := h.GetInt(st.flags); err == nil {
+			s.Flags = ScriptFlags(flags)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Triggers returns package triggers, classic ones first followed by
+// file and transaction file triggers
+func (pkg *Package) Triggers() ([]Trigger, error) {
+	var res []Trigger
+	for _, tt := range triggerTagSets {
+		t, err := readTriggers(pkg.Header, tt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t...)
+	}
+	return res, nil
+}
+
+func readTriggers(h *rpm.Header, tt triggerTags) ([]Trigger, error) {
+	scripts, err := h.GetStrings(tt.scripts)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		// no triggers of this kind
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names, err := h.GetStrings(tt.name)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := h.GetInt32s(tt.index)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := h.GetInt32s(tt.flags)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := h.GetStrings(tt.version)
+	if err != nil {
+		versions = make([]string, len(names))
+	}
+	if len(indexes) != len(names) || len(flags) != len(names) || len(versions) != len(names) {
+		return nil, fmt.Errorf("triggers: %w", rpm.ErrCountMismatch)
+	}
+	progs, _ := h.GetStrings(tt.prog)
+	scriptFlags, _ := h.GetInt32s(tt.scriptFlags)
+	var priorities []int32
+	if tt.priorities != 0 {
+		priorities, _ = h.GetInt32s(tt.priorities)
+	}
+
+	triggers := make([]Trigger, len(scripts))
+	for i := range triggers {
+		t := &triggers[i]
+		t.File, t.Transaction = tt.file, tt.trans
+		t.Body = scripts[i]
+		t.Interpreter = defaultInterpreter
+		if i < len(progs) {
+			t.Interpreter = progs[i]
+		}
+		if i < len(scriptFlags) {
+			t.Flags = ScriptFlags(scriptFlags[i])
+		}
+		if i < len(priorities) {
+			t.Priority = priorities[i]
+		}
+	}
+
+	for i, name := range names {
+		idx := int(indexes[i])
+		if idx < 0 || idx >= len(triggers) {
+			return nil, fmt.Errorf("%w: trigger index %d", rpm.ErrOutOfRange, idx)
+		}
+		f := rpm.SenseFlags(flags[i])
+		t := &triggers[idx]
+		t.Type = triggerType(f)
+		t.Conditions = append(t.Conditions, TriggerCondition{
+			Name:    name,
+			Flags:   f & rpm.SenseCompareMask,
+			Version: versions[i],
+		})
+	}
+	return triggers, nil
+}
+
+func triggerType(f rpm.SenseFlags) TriggerType {
+	switch {
+	case f&rpm.SenseTriggerPrein != 0:
+		return TriggerPrein
+	case f&rpm.SenseTriggerUn != 0:
+		return TriggerUn
+	case f&rpm.SenseTriggerPostun != 0:
+		return TriggerPostun
+	}
+	return TriggerIn
+}
+
+// getStringList reads tag which can be stored either as STRING
+// or STRING_ARRAY, such as scriptlet interpreters
+func getStringList(h *rpm.Header, t rpm.HeaderTag) ([]string, error) {
+	dt, _, err := h.GetTag(t)
+	if err != nil {
+		return nil, err
+	}
+	if dt == rpm.DataTypeString {
+		s, err := h.GetString(t)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	return h.GetStrings(t)
+}
@@ -0,0 +1,117 @@
+package rpmutil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+func TestScriptlets(t *testing.T) {
+	h := newFilesHeader()
+	// set out of order, scriptlets are returned in order they run
+	h.SetString(rpm.TagPostun, "rm -f /var/lib/test")
+	h.SetString(rpm.TagPrein, "useradd test")
+	h.SetStrings(rpm.TagPreinProg, []string{"/bin/sh", "-e"})
+	h.SetInt32s(rpm.TagPreinFlags, []int32{int32(ScriptCritical)})
+	h.SetString(rpm.TagPretrans, "print(1)")
+	h.SetString(rpm.TagPretransProg, "<lua>")
+	// interpreter without body runs with no script at all
+	h.SetString(rpm.TagPostinProg, "/sbin/ldconfig")
+
+	scripts, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Scriptlets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Scriptlet{
+		{PhasePretrans, Script{Interpreter: "<lua>", Args: []string{}, Body: "print(1)"}},
+		{PhasePrein, Script{Interpreter: "/bin/sh", Args: []string{"-e"}, Body: "useradd test", Flags: ScriptCritical}},
+		{PhasePostin, Script{Interpreter: "/sbin/ldconfig", Args: []string{}}},
+		{PhasePostun, Script{Interpreter: "/bin/sh", Body: "rm -f /var/lib/test"}},
+	}
+	if !reflect.DeepEqual(scripts, expected) {
+		t.Errorf("got %+v, expected %+v", scripts, expected)
+	}
+}
+
+func TestScriptletsNone(t *testing.T) {
+	scripts, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), newFilesHeader(), nil).Scriptlets()
+	if err != nil || len(scripts) != 0 {
+		t.Errorf("got %+v, %v, expected no scriptlets", scripts, err)
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	h := newFilesHeader()
+	h.SetStrings(rpm.TagTriggerScripts, []string{"echo in", "echo un"})
+	h.SetStrings(rpm.TagTriggerScriptProg, []string{"/bin/sh", "/bin/bash"})
+	// first trigger fires on two packages
+	h.SetStrings(rpm.TagTriggerName, []string{"foo", "bar", "baz"})
+	h.SetInt32s(rpm.TagTriggerIndex, []int32{0, 0, 1})
+	h.SetStrings(rpm.TagTriggerVersion, []string{"1.0", "", ""})
+	h.SetInt32s(rpm.TagTriggerFlags, []int32{
+		int32(rpm.SenseTriggerIn | rpm.SenseGreater | rpm.SenseEqual),
+		int32(rpm.SenseTriggerIn),
+		int32(rpm.SenseTriggerUn),
+	})
+	h.SetStrings(rpm.TagTransFileTriggerScripts, []string{"ldconfig"})
+	h.SetStrings(rpm.TagTransFileTriggerName, []string{"/usr/lib64/"})
+	h.SetInt32s(rpm.TagTransFileTriggerIndex, []int32{0})
+	h.SetInt32s(rpm.TagTransFileTriggerFlags, []int32{int32(rpm.SenseTriggerPostun)})
+	h.SetInt32s(rpm.TagTransFileTriggerPriorities, []int32{10000})
+
+	triggers, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Triggers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Trigger{
+		{
+			Type: TriggerIn,
+			Conditions: []TriggerCondition{
+				{Name: "foo", Flags: rpm.SenseGreater | rpm.SenseEqual, Version: "1.0"},
+				{Name: "bar"},
+			},
+			Script: Script{Interpreter: "/bin/sh", Body: "echo in"},
+		},
+		{
+			Type:       TriggerUn,
+			Conditions: []TriggerCondition{{Name: "baz"}},
+			Script:     Script{Interpreter: "/bin/bash", Body: "echo un"},
+		},
+		{
+			Type: TriggerPostun, File: true, Transaction: true, Priority: 10000,
+			Conditions: []TriggerCondition{{Name: "/usr/lib64/"}},
+			Script:     Script{Interpreter: "/bin/sh", Body: "ldconfig"},
+		},
+	}
+	if !reflect.DeepEqual(triggers, expected) {
+		t.Errorf("got %+v, expected %+v", triggers, expected)
+	}
+}
+
+func TestTriggersBad(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int32
+		flags   []int32
+		err     error
+	}{
+		{"index out of range", []int32{1}, []int32{0}, rpm.ErrOutOfRange},
+		{"negative index", []int32{-1}, []int32{0}, rpm.ErrOutOfRange},
+		{"count mismatch", []int32{0}, []int32{0, 0}, rpm.ErrCountMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFilesHeader()
+			h.SetStrings(rpm.TagTriggerScripts, []string{"echo"})
+			h.SetStrings(rpm.TagTriggerName, []string{"foo"})
+			h.SetInt32s(rpm.TagTriggerIndex, tt.indexes)
+			h.SetInt32s(rpm.TagTriggerFlags, tt.flags)
+			_, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Triggers()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, expected %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package rpmutil
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// ErrBadSignature is returned for OpenPGP signatures which can't
+// be parsed
+var ErrBadSignature = errors.New("malformed OpenPGP signature")
+
+// Signature describes OpenPGP signature stored in signature header,
+// signatures aren't verified, only decoded
+type Signature struct {
+	// Tag signature was found in
+	Tag        rpm.HeaderTag
+	Version    int
+	Type       uint8
+	PubKeyAlgo uint8
+	HashAlgo   rpm.HashAlgo
+	Created    time.Time
+	// KeyID of the issuer, derived from fingerprint when signature
+	// carries only issuer fingerprint
+	KeyID       string
+	Fingerprint string
+}
+
+// Signatures returns OpenPGP signatures of the package, both v4
+// header (RSA, DSA) and header+payload (PGP, GPG) signatures and
+// v6 OpenPGP signatures which may come in multiple copies
+func (pkg *Package) Signatures() ([]Signature, error) {
+	var res []Signature
+	for _, tag := range []rpm.HeaderTag{rpm.SigTagRSA, rpm.SigTagDSA, rpm.SigTagPGP, rpm.SigTagGPG} {
+		dt, d, err := pkg.SigHeader.GetTag(tag)
+		if errors.Is(err, rpm.ErrTagNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if dt != rpm.DataTypeBin {
+			return nil, &rpm.TypeMismatchError{Tag: tag, Expected: rpm.DataTypeBin, Actual: dt}
+		}
+		sig, err := ParseSignature(d)
+		if err != nil {
+			return nil, err
+		}
+		sig.Tag = tag
+		res = append(res, *sig)
+	}
+
+	sigs, err := pkg.SigHeader.GetStrings(rpm.SigTagOpenPGP)
+	if errors.Is(err, rpm.ErrTagNotFound) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sigs {
+		// signatures are base64 encoded to fit into string array
+		d, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrBadSignature, err)
+		}
+		sig, err := ParseSignature(d)
+		if err != nil {
+			return nil, err
+		}
+		sig.Tag = rpm.SigTagOpenPGP
+		res = append(res, *sig)
+	}
+	return res, nil
+}
+
+const (
+	pgpTagSignature = 2
+
+	pgpSubCreated     = 2
+	pgpSubIssuer      = 16
+	pgpSubFingerprint = 33
+)
+
+// ParseSignature decodes OpenPGP signature packet (v3, v4 or v6)
+func ParseSignature(d []byte) (*Signature, error) {
+	tag, body, err := readPGPPacket(d)
+	if err != nil {
+		return nil, err
+	}
+	if tag != pgpTagSignature {
+		return nil, fmt.Errorf("%w: packet tag %d", ErrBadSignature, tag)
+	}
+	if len(body) < 1 {
+		return nil, fmt.Errorf("%w: empty packet", ErrBadSignature)
+	}
+
+	sig := &Signature{Version: int(body[0])}
+	switch sig.Version {
+	case 3:
+		if len(body) < 19 || body[1] != 5 {
+			return nil, fmt.Errorf("%w: short v3 signature", ErrBadSignature)
+		}
+		sig.Type = body[2]
+		sig.Created = time.Unix(int64(binary.BigEndian.Uint32(body[3:])), 0).UTC()
+		sig.KeyID = hex.EncodeToString(body[7:15])
+		sig.PubKeyAlgo = body[15]
+		sig.HashAlgo = rpm.HashAlgo(body[16])
+		return sig, nil
+	case 4, 6:
+	default:
+		return nil, fmt.Errorf("%w: version %d", ErrBadSignature, sig.Version)
+	}
+
+	if len(body) < 4 {
+		return nil, fmt.Errorf("%w: short signature", ErrBadSignature)
+	}
+	sig.Type, sig.PubKeyAlgo, sig.HashAlgo = body[1], body[2], rpm.HashAlgo(body[3])
+	rest := body[4:]
+	// hashed and unhashed subpacket areas, lengths are 2 bytes in v4
+	// and 4 bytes in v6
+	for area := 0; area < 2; area++ {
+		var n int
+		if sig.Version == 4 {
+			if len(rest) < 2 {
+				return nil, fmt.Errorf("%w: truncated subpackets", ErrBadSignature)
+			}
+			n, rest = int(binary.BigEndian.Uint16(rest)), rest[2:]
+		} else {
+			if len(rest) < 4 {
+				return nil, fmt.Errorf("%w: truncated subpackets", ErrBadSignature)
+			}
+			n, rest = int(binary.BigEndian.Uint32(rest)), rest[4:]
+		}
+		if n < 0 || n > len(rest) {
+			return nil, fmt.Errorf("%w: truncated subpackets", ErrBadSignature)
+		}
+		if err := sig.parseSubpackets(rest[:n]); err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+	}
+
+	if len(sig.KeyID) == 0 && len(sig.Fingerprint) > 0 {
+		// v4 key ID is the tail of fingerprint, v6 one the head
+		if sig.Version == 4 {
+			sig.KeyID = sig.Fingerprint[len(sig.Fingerprint)-16:]
+		} else {
+			sig.KeyID = sig.Fingerprint[:16]
+		}
+	}
+	return sig, nil
+}
+
+func (sig *Signature) parseSubpackets(d []byte) error {
+	for len(d) > 0 {
+		n, hl, err := pgpLength(d, false)
+		if err != nil {
+			return err
+		}
+		d = d[hl:]
+		if n < 1 || n > len(d) {
+			return fmt.Errorf("%w: truncated subpacket", ErrBadSignature)
+		}
+		typ, data := d[0]&0x7f, d[1:n]
+		d = d[n:]
+		switch typ {
+		case pgpSubCreated:
+			if len(data) == 4 {
+				sig.Created = time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC()
+			}
+		case pgpSubIssuer:
+			if len(data) == 8 {
+				sig.KeyID = hex.EncodeToString(data)
+			}
+		case pgpSubFingerprint:
+			if len(data) > 1 {
+				sig.Fingerprint = hex.EncodeToString(data[1:])
+			}
+		}
+	}
+	return nil
+}
+
+// readPGPPacket returns tag and body of the first OpenPGP packet in d
+func readPGPPacket(d []byte) (int, []byte, error) {
+	if len(d) < 2 || d[0]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("%w: bad packet header", ErrBadSignature)
+	}
+	var tag, n, hl int
+	if d[0]&0x40 != 0 {
+		// new format packet
+		tag = int(d[0] & 0x3f)
+		var err error
+		if n, hl, err = pgpLength(d[1:], true); err != nil {
+			return 0, nil, err
+		}
+		hl++
+	} else {
+		tag = int(d[0]>>2) & 0xf
+		switch d[0] & 3 {
+		case 0:
+			n, hl = int(d[1]), 2
+		case 1:
+			if len(d) < 3 {
+				return 0, nil, fmt.Errorf("%w: bad packet header", ErrBadSignature)
+			}
+			n, hl = int(binary.BigEndian.Uint16(d[1:])), 3
+		case 2:
+			if len(d) < 5 {
+				return 0, nil, fmt.Errorf("%w: bad packet header", ErrBadSignature)
+			}
+			n, hl = int(binary.BigEndian.Uint32(d[1:])), 5
+		default:
+			n, hl = len(d)-1, 1
+		}
+	}
+	if n < 0 || n > len(d)-hl {
+		return 0, nil, fmt.Errorf("%w: truncated packet", ErrBadSignature)
+	}
+	return tag, d[hl : hl+n], nil
+}
+
+// pgpLength decodes new format packet or subpacket length, returning
+// length and number of bytes it was encoded with
+func pgpLength(d []byte, packet bool) (int, int, error) {
+	if len(d) < 1 {
+		return 0, 0, fmt.Errorf("%w: truncated length", ErrBadSignature)
+	}
+	switch o := int(d[0]); {
+	case o < 192:
+		return o, 1, nil
+	case o < 224 || (!packet && o < 255):
+		if len(d) < 2 {
+			return 0, 0, fmt.Errorf("%w: truncated length", ErrBadSignature)
+		}
+		return (o-192)<<8 + int(d[1]) + 192, 2, nil
+	case o == 255:
+		if len(d) < 5 {
+			return 0, 0, fmt.Errorf("%w: truncated length", ErrBadSignature)
+		}
+		return int(binary.BigEndian.Uint32(d[1:])), 5, nil
+	}
+	return 0, 0, fmt.Errorf("%w: partial body length", ErrBadSignature)
+}
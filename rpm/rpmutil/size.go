@@ -0,0 +1,63 @@
+package rpmutil
+
+import (
+	"errors"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// Size returns installed size of the package, 64-bit LONGSIZE
+// is preferred when present
+func (pkg *Package) Size() (int64, error) {
+	n, _, err := getSize(pkg.Header, rpm.TagLongSize, rpm.TagSize)
+	return n, err
+}
+
+// ArchiveSize returns size of uncompressed payload. It's stored in
+// signature header by v4 packages, in main header by older and
+// v6 ones.
+func (pkg *Package) ArchiveSize() (int64, error) {
+	n, _, err := pkg.archiveSize()
+	return n, err
+}
+
+// archiveSize is ArchiveSize along with the tag size was found in
+func (pkg *Package) archiveSize() (int64, rpm.HeaderTag, error) {
+	if pkg.SigHeader != nil {
+		n, tag, err := getSize(pkg.SigHeader, rpm.SigTagLongArchiveSize, rpm.SigTagPayloadSize)
+		if !errors.Is(err, rpm.ErrTagNotFound) {
+			return n, tag, err
+		}
+	}
+	return getSize(pkg.Header, rpm.TagPayloadSizeAlt, rpm.TagArchiveSize)
+}
+
+// getSize returns value of the first size tag found in header
+// along with the tag
+func getSize(h *rpm.Header, tags ...rpm.HeaderTag) (int64, rpm.HeaderTag, error) {
+	var err error
+	for _, t := range tags {
+		var n int64
+		if n, err = h.GetInt(t); !errors.Is(err, rpm.ErrTagNotFound) {
+			return n, t, err
+		}
+	}
+	return 0, 0, err
+}
+
+// getSizes returns file sizes, LONGFILESIZES are preferred
+func getSizes(h *rpm.Header) ([]int64, error) {
+	sizes, err := h.GetInt64s(rpm.TagLongFileSizes)
+	if !errors.Is(err, rpm.ErrTagNotFound) {
+		return sizes, err
+	}
+	sizes32, err := h.GetInt32s(rpm.TagFileSizes)
+	if err != nil {
+		return nil, err
+	}
+	sizes = make([]int64, len(sizes32))
+	for i, n := range sizes32 {
+		sizes[i] = int64(uint32(n))
+	}
+	return sizes, nil
+}
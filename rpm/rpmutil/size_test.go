@@ -0,0 +1,195 @@
+package rpmutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+)
+
+const large = 5 << 30
+
+// newPackage writes package of headers and payload and reads it back
+func newPackage(t *testing.T, sig, h *rpm.Header, payload []byte) *Package {
+	t.Helper()
+	var b bytes.Buffer
+	lead := rpm.NewLead("test-1.0-1", "x86_64", 0)
+	if err := WritePackage(&b, lead, sig, h, bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := ReadPackage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func newFilesHeader() *rpm.Header {
+	return rpmtest.Header("test",
+		rpmtest.File{Name: "/usr/share/test/small", Mode: 0100644},
+		rpmtest.File{Name: "/usr/share/test/large", Mode: 0100644},
+	)
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      func(h *rpm.Header)
+		expected int64
+	}{
+		{"size", func(h *rpm.Header) { h.SetInt32s(rpm.TagSize, []int32{1024}) }, 1024},
+		{"size over 2GiB", func(h *rpm.Header) { h.SetInt32s(rpm.TagSize, []int32{-1}) }, 1<<32 - 1},
+		{"long size", func(h *rpm.Header) { h.SetInt64s(rpm.TagLongSize, []int64{large}) }, large},
+		{"long size preferred", func(h *rpm.Header) {
+			h.SetInt32s(rpm.TagSize, []int32{1024})
+			h.SetInt64s(rpm.TagLongSize, []int64{large})
+		}, large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newFilesHeader()
+			tt.set(h)
+			pkg := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil)
+			n, err := pkg.Size()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.expected {
+				t.Errorf("got %d, expected %d", n, tt.expected)
+			}
+		})
+	}
+}
+
+func TestArchiveSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		sig      func(h *rpm.Header)
+		hdr      func(h *rpm.Header)
+		expected int64
+	}{
+		{"payload size", func(h *rpm.Header) { h.SetInt32s(rpm.SigTagPayloadSize, []int32{4096}) }, nil, 4096},
+		{"long archive size", func(h *rpm.Header) { h.SetInt64s(rpm.SigTagLongArchiveSize, []int64{large}) }, nil, large},
+		{"main header", nil, func(h *rpm.Header) { h.SetInt32s(rpm.TagArchiveSize, []int32{4096}) }, 4096},
+		{"v6", nil, func(h *rpm.Header) { h.SetInt64s(rpm.TagPayloadSizeAlt, []int64{large}) }, large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := rpm.NewHeader(rpm.TagHeaderSignatures)
+			h := newFilesHeader()
+			if tt.sig != nil {
+				tt.sig(sig)
+			}
+			if tt.hdr != nil {
+				tt.hdr(h)
+			}
+			pkg := newPackage(t, sig, h, nil)
+			n, err := pkg.ArchiveSize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.expected {
+				t.Errorf("got %d, expected %d", n, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileSizes(t *testing.T) {
+	h := newFilesHeader()
+	h.SetInt64s(rpm.TagLongFileSizes, []int64{10, large})
+	files, err := newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Size != 10 || files[1].Size != large {
+		t.Errorf("got %+v", files)
+	}
+
+	h.SetInt64s(rpm.TagLongFileSizes, []int64{10})
+	_, err = newPackage(t, rpm.NewHeader(rpm.TagHeaderSignatures), h, nil).Files()
+	if !errors.Is(err, rpm.ErrCountMismatch) {
+		t.Errorf("got %v, expected count mismatch", err)
+	}
+}
+
+func TestVerifySize(t *testing.T) {
+	payload := []byte("payload")
+	h := newFilesHeader()
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(d) + len(payload))
+
+	tests := []struct {
+		name string
+		set  func(sig *rpm.Header)
+		tag  rpm.HeaderTag
+	}{
+		{"size", func(sig *rpm.Header) { sig.SetInt32s(rpm.SigTagSize, []int32{int32(size)}) }, 0},
+		{"long size", func(sig *rpm.Header) { sig.SetInt64s(rpm.SigTagLongSize, []int64{size}) }, 0},
+		{"size mismatch", func(sig *rpm.Header) { sig.SetInt32s(rpm.SigTagSize, []int32{int32(size + 1)}) }, rpm.SigTagSize},
+		{"long size mismatch", func(sig *rpm.Header) {
+			sig.SetInt32s(rpm.SigTagSize, []int32{int32(size)})
+			sig.SetInt64s(rpm.SigTagLongSize, []int64{large})
+		}, rpm.SigTagLongSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := rpm.NewHeader(rpm.TagHeaderSignatures)
+			tt.set(sig)
+			err := newPackage(t, sig, h, payload).Verify()
+			if tt.tag == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var se *SizeError
+			if !errors.As(err, &se) || se.Tag != tt.tag {
+				t.Errorf("got %v, expected size mismatch of tag %d", err, tt.tag)
+			}
+		})
+	}
+}
+
+func TestVerifyArchiveSize(t *testing.T) {
+	payload := []byte("payload")
+	sum := sha256.Sum256(payload)
+	h := newFilesHeader()
+	h.SetString(rpm.TagPayloadCompressor, "uncompressed")
+	h.SetStrings(rpm.TagPayloadDigest, []string{hex.EncodeToString(sum[:])})
+	h.SetStrings(rpm.TagPayloadDigestAlt, []string{hex.EncodeToString(sum[:])})
+
+	tests := []struct {
+		name string
+		set  func(sig *rpm.Header)
+		tag  rpm.HeaderTag
+	}{
+		{"payload size", func(sig *rpm.Header) { sig.SetInt32s(rpm.SigTagPayloadSize, []int32{int32(len(payload))}) }, 0},
+		{"payload size mismatch", func(sig *rpm.Header) { sig.SetInt32s(rpm.SigTagPayloadSize, []int32{1}) }, rpm.SigTagPayloadSize},
+		{"long archive size mismatch", func(sig *rpm.Header) { sig.SetInt64s(rpm.SigTagLongArchiveSize, []int64{large}) }, rpm.SigTagLongArchiveSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := rpm.NewHeader(rpm.TagHeaderSignatures)
+			tt.set(sig)
+			err := newPackage(t, sig, h, payload).Verify()
+			if tt.tag == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var se *SizeError
+			if !errors.As(err, &se) || se.Tag != tt.tag {
+				t.Errorf("got %v, expected size mismatch of tag %d", err, tt.tag)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package rpmutil
+
+import (
+	"os"
+	"syscall"
+)
+
+type statInfo struct {
+	mode     uint32
+	uid, gid uint32
+	rdev     uint64
+}
+
+func fileStat(fi os.FileInfo) (statInfo, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return statInfo{}, false
+	}
+	return statInfo{mode: uint32(st.Mode), uid: st.Uid, gid: st.Gid, rdev: uint64(st.Rdev)}, true
+}
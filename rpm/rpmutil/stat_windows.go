@@ -0,0 +1,17 @@
+package rpmutil
+
+import (
+	"os"
+)
+
+type statInfo struct {
+	mode     uint32
+	uid, gid uint32
+	rdev     uint64
+}
+
+// fileStat has nothing to offer on windows, owner, mode and device
+// checks are skipped there
+func fileStat(fi os.FileInfo) (statInfo, bool) {
+	return statInfo{}, false
+}
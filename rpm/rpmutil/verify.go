@@ -0,0 +1,278 @@
+package rpmutil
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+)
+
+// Verifier checks files of packages against system installed in Root
+// the way rpm -V does. File capabilities aren't verified.
+type Verifier struct {
+	Root string
+	// Omit are checks left out, like --nomtime or --nodigest do
+	Omit rpm.VerifyFlags
+	// Skip are kinds of files left out, like --noconfig, --nodoc or
+	// --noghost do
+	Skip rpm.FileFlags
+
+	// user and group names of Root
+	users, groups map[uint32]string
+}
+
+func NewVerifier(root string) *Verifier {
+	return &Verifier{Root: root}
+}
+
+// VerifyResult is outcome of verification of single file
+type VerifyResult struct {
+	Package string
+	Path    string
+	Flags   rpm.FileFlags
+	// Failed are checks which failed, VerifyLstat for missing file
+	Failed rpm.VerifyFlags
+}
+
+// Missing reports whether file isn't present at all
+func (r VerifyResult) Missing() bool {
+	return r.Failed&rpm.VerifyLstat != 0
+}
+
+// Passed reports whether file verified fine, %ghost and missingok
+// files are fine to be missing
+func (r VerifyResult) Passed() bool {
+	if r.Missing() {
+		return r.Flags&(rpm.FileGhost|rpm.FileMissingOK) != 0
+	}
+	return r.Failed == rpm.VerifyNone
+}
+
+// String formats result the way rpm -V prints it
+func (r VerifyResult) String() string {
+	attr := byte(' ')
+	if s := r.Flags.String(); len(s) > 0 {
+		attr = s[0]
+	}
+	if r.Missing() {
+		return fmt.Sprintf("missing   %c %s", attr, r.Path)
+	}
+	return fmt.Sprintf("%s  %c %s", r.Failed, attr, r.Path)
+}
+
+func (r VerifyResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Package string   `json:"package"`
+		Path    string   `json:"path"`
+		Attrs   string   `json:"attrs"`
+		Result  string   `json:"result"`
+		Missing bool     `json:"missing"`
+		Failed  []string `json:"failed"`
+	}{r.Package, r.Path, r.Flags.String(), r.Failed.String(), r.Missing(), r.Failed.Names()})
+}
+
+// VerifyHeader verifies files of package described by header, either
+// installed one from rpmdb or header of package file. Result is
+// returned for every file verified, failed or not.
+func (v *Verifier) VerifyHeader(h *rpm.Header) ([]VerifyResult, error) {
+	files, err := HeaderFiles(h)
+	if err != nil {
+		return nil, err
+	}
+	algo := rpm.HashMD5
+	if n, err := h.GetInt(rpm.TagFileDigestAlgo); err == nil {
+		algo = rpm.HashAlgo(n)
+	}
+	if v.users == nil {
+		v.users = readIDs(v.Root, "/etc/passwd")
+		v.groups = readIDs(v.Root, "/etc/group")
+	}
+
+	nvra := HeaderNVRA(h)
+	var res []VerifyResult
+	for _, f := range files {
+		if f.Flags&v.Skip != 0 {
+			continue
+		}
+		res = append(res, VerifyResult{
+			Package: nvra,
+			Path:    f.Name,
+			Flags:   f.Flags,
+			Failed:  v.verifyFile(&f, algo),
+		})
+	}
+	return res, nil
+}
+
+const (
+	modeTypeMask = 0xf000
+	modeDir      = 0x4000
+	modeChr      = 0x2000
+	modeBlk      = 0x6000
+)
+
+func (v *Verifier) verifyFile(f *FileInfo, algo rpm.HashAlgo) rpm.VerifyFlags {
+	flags := f.VerifyFlags &^ (v.Omit | rpm.VerifyCaps)
+	switch f.State {
+	case rpm.FileStateNotInstalled, rpm.FileStateNetShared:
+		return rpm.VerifyNone
+	case rpm.FileStateReplaced:
+		// only presence of replaced files can be verified
+		flags = rpm.VerifyNone
+	case rpm.FileStateWrongColor:
+		// file of other color shares just some attributes
+		flags &^= rpm.VerifyDigest | rpm.VerifySize | rpm.VerifyMTime | rpm.VerifyRdev
+	}
+	// contents of %ghost files are up to whoever creates them
+	if f.Flags&rpm.FileGhost != 0 {
+		flags &^= rpm.VerifyDigest | rpm.VerifySize | rpm.VerifyMTime | rpm.VerifyLinkTo
+	}
+
+	// links are followed within root, absolute ones relative to it
+	path, err := SecurePath(v.Root, f.Name)
+	if err != nil {
+		return rpm.VerifyLstat
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return rpm.VerifyLstat
+	}
+	// directory replaced by symlink to directory is fine
+	if f.Mode&modeTypeMask == modeDir && fi.Mode()&os.ModeSymlink != 0 {
+		dir, err := ResolvePath(v.Root, f.Name)
+		if err != nil {
+			return rpm.VerifyLstat
+		}
+		if fi, err = os.Lstat(dir); err != nil || !fi.IsDir() {
+			return rpm.VerifyLstat
+		}
+	}
+	st, ok := fileStat(fi)
+	if !ok {
+		flags &^= rpm.VerifyMode | rpm.VerifyRdev | rpm.VerifyUser | rpm.VerifyGroup
+	}
+
+	// links have no mode, other files have no link target
+	if fi.Mode()&os.ModeSymlink != 0 {
+		flags &^= rpm.VerifyMode
+	} else {
+		flags &^= rpm.VerifyLinkTo
+	}
+	if !fi.Mode().IsRegular() {
+		flags &^= rpm.VerifyDigest | rpm.VerifySize | rpm.VerifyMTime
+	}
+
+	var res rpm.VerifyFlags
+	if flags&rpm.VerifyDigest != 0 && len(f.Digest) > 0 {
+		if sum, err := fileDigest(path, algo); err != nil {
+			res |= rpm.VerifyRead | rpm.VerifyDigest
+		} else if sum != f.Digest {
+			res |= rpm.VerifyDigest
+		}
+	}
+	if flags&rpm.VerifyLinkTo != 0 {
+		if link, err := os.Readlink(path); err != nil {
+			res |= rpm.VerifyReadLink | rpm.VerifyLinkTo
+		} else if link != f.LinkTo {
+			res |= rpm.VerifyLinkTo
+		}
+	}
+	if flags&rpm.VerifySize != 0 && fi.Size() != f.Size {
+		res |= rpm.VerifySize
+	}
+	if flags&rpm.VerifyMode != 0 {
+		meta, mode := uint32(f.Mode), st.mode
+		// type of %ghost files is meaningless, permissions are not
+		if f.Flags&rpm.FileGhost != 0 {
+			meta &^= modeTypeMask
+			mode &^= modeTypeMask
+		}
+		if meta != mode {
+			res |= rpm.VerifyMode
+		}
+	}
+	if flags&rpm.VerifyRdev != 0 {
+		metaType, fileType := uint32(f.Mode)&modeTypeMask, st.mode&modeTypeMask
+		isDev := func(t uint32) bool { return t == modeChr || t == modeBlk }
+		if (isDev(metaType) || isDev(fileType)) && metaType != fileType {
+			res |= rpm.VerifyRdev
+		} else if isDev(metaType) && st.rdev != uint64(f.Rdev) {
+			res |= rpm.VerifyRdev
+		}
+	}
+	if flags&rpm.VerifyMTime != 0 && fi.ModTime().Unix() != f.MTime.Unix() {
+		res |= rpm.VerifyMTime
+	}
+	if flags&rpm.VerifyUser != 0 && v.users[st.uid] != f.User {
+		res |= rpm.VerifyUser
+	}
+	if flags&rpm.VerifyGroup != 0 && v.groups[st.gid] != f.Group {
+		res |= rpm.VerifyGroup
+	}
+	return res
+}
+
+func fileDigest(path string, algo rpm.HashAlgo) (string, error) {
+	h := NewHash(algo)
+	if h == nil {
+		return "", fmt.Errorf("%w: hash algorithm %d", ErrUnsupportedDigest, algo)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readIDs reads names from passwd or group file of root, id 0 is
+// root whatever the file says the same way it's in rpm
+func readIDs(root, name string) map[uint32]string {
+	ids := map[uint32]string{0: "root"}
+	path, err := ResolvePath(root, name)
+	if err != nil {
+		return ids
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ids
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		if _, ok := ids[uint32(id)]; !ok {
+			ids[uint32(id)] = fields[0]
+		}
+	}
+	return ids
+}
+
+// HeaderNVRA returns name-version-release.arch of package
+func HeaderNVRA(h *rpm.Header) string {
+	var s [4]string
+	for i, t := range []rpm.HeaderTag{rpm.TagName, rpm.TagVersion, rpm.TagRelease, rpm.TagArch} {
+		s[i], _ = h.GetString(t)
+	}
+	nvr := strings.Join(s[:3], "-")
+	if len(s[3]) > 0 {
+		return nvr + "." + s[3]
+	}
+	return nvr
+}
@@ -0,0 +1,205 @@
+package rpmutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+)
+
+func TestVerifyWithinRoot(t *testing.T) {
+	root := newRoot(t)
+	defer os.RemoveAll(root)
+
+	h := rpmtest.Header("test",
+		rpmtest.File{Name: "/bin", Mode: 040755},
+		rpmtest.File{Name: "/conf", Mode: 040755},
+		rpmtest.File{Name: "/conf/passwd", Mode: 0100644},
+	)
+
+	res, err := NewVerifier(root).VerifyHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := map[string]bool{
+		// symlink to directory in root
+		"/bin": false,
+		// symlinks to host /etc
+		"/conf":        true,
+		"/conf/passwd": true,
+	}
+	if len(res) != len(missing) {
+		t.Fatalf("got %d results, expected %d", len(res), len(missing))
+	}
+	for _, r := range res {
+		if r.Missing() != missing[r.Path] {
+			t.Errorf("%s: missing %v, expected %v", r.Path, r.Missing(), missing[r.Path])
+		}
+	}
+}
+
+// verifyFiles are files of package, the ones on disk are created by
+// newVerifyRoot
+var verifyFiles = []rpmtest.File{
+	{Name: "/usr/bin/ok", Mode: 0100755, Data: "ok", MTime: 1000},
+	{Name: "/usr/bin/changed", Mode: 0100755, Data: "old", MTime: 1000},
+	{Name: "/usr/bin/link", Mode: 0120777, Data: "ok"},
+	{Name: "/etc/test.conf", Mode: 0100644, Data: "conf", MTime: 1000, Flags: rpm.FileConfig},
+	{Name: "/etc/opt.conf", Mode: 0100644, Data: "conf", Flags: rpm.FileConfig | rpm.FileMissingOK},
+	{Name: "/usr/share/doc/README", Mode: 0100644, Data: "doc", Flags: rpm.FileDoc},
+	{Name: "/var/log/test.log", Mode: 0100644, Flags: rpm.FileGhost},
+	{Name: "/var/log/test.link", Mode: 0120777, Data: "test.log", Flags: rpm.FileGhost},
+	{Name: "/var/cache/test", Mode: 0100644, Flags: rpm.FileGhost},
+}
+
+func newVerifyRoot(t *testing.T) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "rpmutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		name, data string
+		mode       os.FileMode
+		mtime      int64
+	}{
+		{"/usr/bin/ok", "ok", 0755, 1000},
+		{"/usr/bin/changed", "changed", 0755, 2000},
+		{"/etc/test.conf", "edited", 0644, 1000},
+		{"/var/log/test.log", "log line", 0644, 3000},
+	} {
+		path := filepath.Join(root, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f.data), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, time.Unix(f.mtime, 0), time.Unix(f.mtime, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"/usr/bin/link": "ok", "/var/log/test.link": "elsewhere"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func verifyResults(t *testing.T, v *Verifier) map[string]VerifyResult {
+	t.Helper()
+	// owners depend on who runs the tests
+	v.Omit |= rpm.VerifyUser | rpm.VerifyGroup
+	res, err := v.VerifyHeader(rpmtest.Header("test", verifyFiles...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]VerifyResult{}
+	for _, r := range res {
+		byPath[r.Path] = r
+	}
+	return byPath
+}
+
+func TestVerifyFiles(t *testing.T) {
+	root := newVerifyRoot(t)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		path   string
+		out    string
+		passed bool
+	}{
+		{"/usr/bin/ok", ".........    /usr/bin/ok", true},
+		{"/usr/bin/changed", "S.5....T.    /usr/bin/changed", false},
+		{"/usr/bin/link", ".........    /usr/bin/link", true},
+		{"/etc/test.conf", "S.5......  c /etc/test.conf", false},
+		{"/etc/opt.conf", "missing   c /etc/opt.conf", true},
+		{"/usr/share/doc/README", "missing   d /usr/share/doc/README", false},
+		// modified and missing %ghost files are fine
+		{"/var/log/test.log", ".........  g /var/log/test.log", true},
+		{"/var/log/test.link", ".........  g /var/log/test.link", true},
+		{"/var/cache/test", "missing   g /var/cache/test", true},
+	}
+	res := verifyResults(t, NewVerifier(root))
+	if len(res) != len(tests) {
+		t.Errorf("got %d results, expected %d", len(res), len(tests))
+	}
+	for _, tt := range tests {
+		r, ok := res[tt.path]
+		if !ok {
+			t.Errorf("%s: not verified", tt.path)
+			continue
+		}
+		if s := r.String(); s != tt.out {
+			t.Errorf("%s: got %q, expected %q", tt.path, s, tt.out)
+		}
+		if r.Passed() != tt.passed {
+			t.Errorf("%s: passed %v, expected %v", tt.path, r.Passed(), tt.passed)
+		}
+	}
+}
+
+func TestVerifyOmit(t *testing.T) {
+	root := newVerifyRoot(t)
+	defer os.RemoveAll(root)
+
+	v := NewVerifier(root)
+	v.Omit = rpm.VerifyDigest | rpm.VerifySize
+	if s := verifyResults(t, v)["/usr/bin/changed"].Failed.String(); s != ".......T." {
+		t.Errorf("got %s, expected only mtime to fail", s)
+	}
+}
+
+func TestVerifySkip(t *testing.T) {
+	root := newVerifyRoot(t)
+	defer os.RemoveAll(root)
+
+	for _, skip := range []rpm.FileFlags{rpm.FileConfig, rpm.FileDoc, rpm.FileGhost} {
+		v := NewVerifier(root)
+		v.Skip = skip
+		for path, r := range verifyResults(t, v) {
+			if r.Flags&skip != 0 {
+				t.Errorf("%s (%s) verified with %s skipped", path, r.Flags, skip)
+			}
+		}
+	}
+}
+
+func TestVerifyResultJSON(t *testing.T) {
+	tests := []struct {
+		r        VerifyResult
+		expected string
+	}{
+		{
+			VerifyResult{Package: "test-1.0-1.noarch", Path: "/usr/bin/changed", Failed: rpm.VerifySize | rpm.VerifyDigest | rpm.VerifyMTime},
+			`{"package":"test-1.0-1.noarch","path":"/usr/bin/changed","attrs":"","result":"S.5....T.","missing":false,"failed":["size","digest","mtime"]}`,
+		},
+		{
+			VerifyResult{Package: "test-1.0-1.noarch", Path: "/etc/test.conf", Flags: rpm.FileConfig | rpm.FileNoReplace, Failed: rpm.VerifyLstat},
+			`{"package":"test-1.0-1.noarch","path":"/etc/test.conf","attrs":"cn","result":".........","missing":true,"failed":[]}`,
+		},
+		{
+			VerifyResult{Package: "test-1.0-1.noarch", Path: "/usr/bin/ok"},
+			`{"package":"test-1.0-1.noarch","path":"/usr/bin/ok","attrs":"","result":".........","missing":false,"failed":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		d, err := json.Marshal(tt.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != tt.expected {
+			t.Errorf("got %s, expected %s", d, tt.expected)
+		}
+	}
+}
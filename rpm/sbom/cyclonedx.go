@@ -0,0 +1,141 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// cdxLicense is either SPDX expression or just name of license
+type cdxLicense struct {
+	License *struct {
+		Name string `json:"name"`
+	} `json:"license,omitempty"`
+	Expression string `json:"expression,omitempty"`
+}
+
+type cdxReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	Type        string         `json:"type"`
+	Ref         string         `json:"bom-ref,omitempty"`
+	Name        string         `json:"name"`
+	Version     string         `json:"version,omitempty"`
+	Publisher   string         `json:"publisher,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Hashes      []cdxHash      `json:"hashes,omitempty"`
+	Licenses    []cdxLicense   `json:"licenses,omitempty"`
+	PURL        string         `json:"purl,omitempty"`
+	References  []cdxReference `json:"externalReferences,omitempty"`
+	Properties  []cdxProperty  `json:"properties,omitempty"`
+	Components  []cdxComponent `json:"components,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxDocument struct {
+	Format   string `json:"bomFormat"`
+	Spec     string `json:"specVersion"`
+	Serial   string `json:"serialNumber"`
+	Version  int    `json:"version"`
+	Metadata struct {
+		Timestamp string `json:"timestamp"`
+		Tools     struct {
+			Components []cdxComponent `json:"components"`
+		} `json:"tools"`
+		Component *cdxComponent `json:"component,omitempty"`
+	} `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+// WriteCycloneDX writes document as CycloneDX 1.5 JSON
+func (d *Document) WriteCycloneDX(w io.Writer) error {
+	doc := &cdxDocument{
+		Format:  "CycloneDX",
+		Spec:    "1.5",
+		Serial:  "urn:uuid:" + d.uuid(),
+		Version: 1,
+	}
+	doc.Metadata.Timestamp = d.Created.UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: "ypkg"}}
+	if len(d.Name) > 0 {
+		doc.Metadata.Component = &cdxComponent{Type: "operating-system", Name: d.Name}
+	}
+	doc.Components = []cdxComponent{}
+	doc.Dependencies = []cdxDependency{}
+
+	refs := make([]string, len(d.Components))
+	for i, c := range d.Components {
+		p := c.Package
+		refs[i] = d.PURL(c)
+		cc := cdxComponent{
+			Type:        "library",
+			Ref:         refs[i],
+			Name:        p.Name,
+			Version:     p.EVR.String(),
+			Publisher:   p.Vendor,
+			Description: p.Summary,
+			PURL:        refs[i],
+			Properties:  []cdxProperty{{"rpm:arch", p.Arch}},
+		}
+		if len(p.SourceRPM) > 0 {
+			cc.Properties = append(cc.Properties, cdxProperty{"rpm:sourcerpm", p.SourceRPM})
+		}
+		if e := declaredLicense(p.License); e != nil {
+			cc.Licenses = []cdxLicense{{Expression: e.String()}}
+		} else if len(p.License) > 0 {
+			var l cdxLicense
+			l.License = &struct {
+				Name string `json:"name"`
+			}{p.License}
+			cc.Licenses = []cdxLicense{l}
+		}
+		if len(p.URL) > 0 {
+			cc.References = []cdxReference{{"website", p.URL}}
+		}
+		if algo, ok := repo.ChecksumAlgo(p.Checksum.Type); ok && len(hashNames[algo][1]) > 0 {
+			cc.Hashes = []cdxHash{{hashNames[algo][1], p.Checksum.Value}}
+		}
+		if d.Files {
+			for _, name := range c.files() {
+				f := cdxComponent{Type: "file", Name: name}
+				if sum, ok := c.Digests[name]; ok && len(hashNames[c.DigestAlgo][1]) > 0 {
+					f.Hashes = []cdxHash{{hashNames[c.DigestAlgo][1], sum}}
+				}
+				cc.Components = append(cc.Components, f)
+			}
+		}
+		doc.Components = append(doc.Components, cc)
+	}
+	for i, deps := range d.dependencies() {
+		dep := cdxDependency{Ref: refs[i], DependsOn: []string{}}
+		for _, j := range deps {
+			dep.DependsOn = append(dep.DependsOn, refs[j])
+		}
+		doc.Dependencies = append(doc.Dependencies, dep)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
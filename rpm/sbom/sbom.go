@@ -0,0 +1,262 @@
+// Package sbom describes sets of rpm packages as software bills of
+// materials in SPDX and CycloneDX JSON formats
+package sbom
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/license"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+	"code.pikelabs.net/go/rpm/solve"
+)
+
+const (
+	modeTypeMask = 0xf000
+	modeReg      = 0x8000
+)
+
+// Component is package described by SBOM
+type Component struct {
+	Package *repo.Package
+	// Digests are digests of regular files keyed by path, known only
+	// for packages read from headers
+	Digests    map[string]string
+	DigestAlgo rpm.HashAlgo
+	// SHA1 are SHA1 digests of regular files keyed by path, SPDX
+	// requires them, known only for package files whose payload is
+	// read
+	SHA1 map[string]string
+}
+
+// FromHeader returns component of package read from header of package
+// file or rpmdb
+func FromHeader(pkg *rpmutil.Package) (*Component, error) {
+	p, err := repo.PackageFromHeader(pkg, 0)
+	if err != nil {
+		return nil, err
+	}
+	files, err := pkg.Files()
+	if err != nil {
+		return nil, err
+	}
+	c := &Component{Package: p, Digests: map[string]string{}, DigestAlgo: rpm.HashMD5}
+	if n, err := pkg.Header.GetInt(rpm.TagFileDigestAlgo); err == nil {
+		c.DigestAlgo = rpm.HashAlgo(n)
+	}
+	for _, f := range files {
+		if len(f.Digest) > 0 {
+			c.Digests[f.Name] = f.Digest
+		}
+	}
+	return c, nil
+}
+
+// ReadFile returns component of package file, package checksum is
+// sha256 of the file and SHA1 of files are computed from payload
+func ReadFile(fname string) (*Component, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	tr := io.TeeReader(f, h)
+	pkg, err := rpmutil.ReadPackage(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	c, err := FromHeader(pkg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if c.SHA1, err = payloadSHA1(pkg); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	c.Package.PkgID = sum
+	c.Package.Checksum = repo.Checksum{Type: "sha256", Value: sum}
+	return c, nil
+}
+
+// payloadSHA1 returns SHA1 digests of regular files of payload keyed
+// by path, data of hardlinked files comes with the last link
+func payloadSHA1(pkg *rpmutil.Package) (map[string]string, error) {
+	pl, err := pkg.Payload()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{}
+	links := map[int64][]string{}
+	for {
+		h, r, err := pl.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Mode&modeTypeMask != modeReg {
+			continue
+		}
+		name := path.Clean("/" + strings.TrimPrefix(h.Name, "."))
+		if h.Links > 1 {
+			links[h.Inode] = append(links[h.Inode], name)
+			if h.Size == 0 {
+				continue
+			}
+		}
+		sum := sha1.New()
+		if _, err := io.Copy(sum, r); err != nil {
+			return nil, err
+		}
+		res[name] = hex.EncodeToString(sum.Sum(nil))
+		for _, l := range links[h.Inode] {
+			res[l] = res[name]
+		}
+	}
+}
+
+// Document is SBOM of set of packages
+type Document struct {
+	Name string
+	// Namespace is vendor or distribution part of purls, like fedora
+	Namespace string
+	Created   time.Time
+	// Files lists files of packages along with packages
+	Files      bool
+	Components []*Component
+}
+
+// PURL returns package URL of component
+func (d *Document) PURL(c *Component) string {
+	p := c.Package
+	var sb strings.Builder
+	sb.WriteString("pkg:rpm/")
+	if len(d.Namespace) > 0 {
+		sb.WriteString(url.PathEscape(strings.ToLower(d.Namespace)) + "/")
+	}
+	sb.WriteString(url.PathEscape(p.Name) + "@" + url.PathEscape(p.EVR.Version+"-"+p.EVR.Release))
+	// qualifiers are sorted by key
+	q := []string{"arch=" + url.QueryEscape(p.Arch)}
+	if p.EVR.Epoch > 0 {
+		q = append(q, "epoch="+strconv.Itoa(p.EVR.Epoch))
+	}
+	if len(p.SourceRPM) > 0 {
+		q = append(q, "upstream="+url.QueryEscape(p.SourceRPM))
+	}
+	sb.WriteString("?" + strings.Join(q, "&"))
+	return sb.String()
+}
+
+// files returns paths of files of component to list, directories
+// and ghosts are left out
+func (c *Component) files() []string {
+	var res []string
+	for _, f := range c.Package.Files {
+		if f.Type != repo.FileDir && f.Type != repo.FileGhost {
+			res = append(res, f.Path)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// dependencies returns indexes of components each component requires,
+// requirements are resolved to the best provider within document
+func (d *Document) dependencies() [][]int {
+	pool := solve.NewPool("noarch")
+	index := map[*repo.Package]int{}
+	for i, c := range d.Components {
+		pool.AddInstalled(c.Package)
+		index[c.Package] = i
+	}
+	res := make([][]int, len(d.Components))
+	for i, c := range d.Components {
+		seen := map[int]bool{i: true}
+		for _, dep := range c.Package.Deps[rpm.DepRequires] {
+			deps := []rpm.Dependency{dep}
+			if rpm.IsRich(dep.Name) {
+				rd, err := rpm.ParseRich(dep.Name)
+				if err != nil {
+					continue
+				}
+				deps = richLeaves(rd, nil)
+			}
+			for _, dep := range deps {
+				if prov := pool.WhatProvides(dep); len(prov) > 0 {
+					if j := index[prov[0]]; !seen[j] {
+						seen[j] = true
+						res[i] = append(res[i], j)
+					}
+				}
+			}
+		}
+		sort.Ints(res[i])
+	}
+	return res
+}
+
+// richLeaves appends dependencies rich dependency may pull in,
+// conditions of if and unless are left out
+func richLeaves(d *rpm.RichDep, res []rpm.Dependency) []rpm.Dependency {
+	args := d.Args
+	switch d.Op {
+	case "":
+		return append(res, d.Dep)
+	case rpm.RichIf, rpm.RichUnless:
+		args = append([]*rpm.RichDep{args[0]}, args[2:]...)
+	case rpm.RichWithout:
+		args = args[:1]
+	}
+	for _, a := range args {
+		res = append(res, richLeaves(a, nil)...)
+	}
+	return res
+}
+
+// declaredLicense returns License of package as normalized SPDX
+// expression, nil if it's not valid one or has legacy names SPDX
+// license of which isn't known
+func declaredLicense(s string) *license.Expr {
+	e, err := license.Parse(s)
+	if err != nil {
+		return nil
+	}
+	if e = license.Normalize(e); len(e.Unknown()) > 0 || len(e.Ambiguous()) > 0 {
+		return nil
+	}
+	return e
+}
+
+// uuid returns version 5 style UUID derived from packages of document
+// and its creation time, so that the same input gives the same
+// document
+func (d *Document) uuid() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n", d.Name, d.Created.Unix())
+	for _, c := range d.Components {
+		fmt.Fprintf(h, "%s %s\n", d.PURL(c), c.Package.Checksum.Value)
+	}
+	s := h.Sum(nil)[:16]
+	s[6] = s[6]&0x0f | 0x50
+	s[8] = s[8]&0x3f | 0x80
+	x := hex.EncodeToString(s)
+	return x[:8] + "-" + x[8:12] + "-" + x[12:16] + "-" + x[16:20] + "-" + x[20:]
+}
@@ -0,0 +1,228 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+const (
+	// spdxNamespace prefixes documentNamespace of documents
+	spdxNamespace = "https://code.pikelabs.net/spdxdocs/"
+	noAssertion   = "NOASSERTION"
+)
+
+// hash algorithms as SPDX and CycloneDX name them
+var hashNames = map[rpm.HashAlgo][2]string{
+	rpm.HashMD5:      {"MD5", "MD5"},
+	rpm.HashSHA1:     {"SHA1", "SHA-1"},
+	rpm.HashSHA224:   {"SHA224", ""},
+	rpm.HashSHA256:   {"SHA256", "SHA-256"},
+	rpm.HashSHA384:   {"SHA384", "SHA-384"},
+	rpm.HashSHA512:   {"SHA512", "SHA-512"},
+	rpm.HashSHA3_256: {"SHA3-256", "SHA3-256"},
+	rpm.HashSHA3_512: {"SHA3-512", "SHA3-512"},
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	ID               string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	Version          string            `json:"versionInfo"`
+	Supplier         string            `json:"supplier"`
+	Download         string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	Copyright        string            `json:"copyrightText"`
+	Summary          string            `json:"summary,omitempty"`
+	Homepage         string            `json:"homepage,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxFile struct {
+	ID               string         `json:"SPDXID"`
+	Name             string         `json:"fileName"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	Copyright        string         `json:"copyrightText"`
+}
+
+type spdxExtractedLicense struct {
+	ID   string `json:"licenseId"`
+	Name string `json:"name"`
+	Text string `json:"extractedText"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	Version      string `json:"spdxVersion"`
+	DataLicense  string `json:"dataLicense"`
+	ID           string `json:"SPDXID"`
+	Name         string `json:"name"`
+	Namespace    string `json:"documentNamespace"`
+	CreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages      []spdxPackage          `json:"packages"`
+	Files         []spdxFile             `json:"files,omitempty"`
+	Licenses      []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+	Relationships []spdxRelationship     `json:"relationships"`
+}
+
+// WriteSPDX writes document as SPDX 2.3 JSON
+func (d *Document) WriteSPDX(w io.Writer) error {
+	doc := &spdxDocument{
+		Version:     "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		ID:          "SPDXRef-DOCUMENT",
+		Name:        d.Name,
+		Namespace:   spdxNamespace + spdxIDString(d.Name) + "-" + d.uuid(),
+	}
+	doc.CreationInfo.Created = d.Created.UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: ypkg"}
+	doc.Packages = []spdxPackage{}
+	doc.Relationships = []spdxRelationship{}
+
+	ids := make([]string, len(d.Components))
+	for i, c := range d.Components {
+		p := c.Package
+		ids[i] = fmt.Sprintf("SPDXRef-Package-%d-%s", i, spdxIDString(p.Name))
+		sp := spdxPackage{
+			ID:               ids[i],
+			Name:             p.Name,
+			Version:          p.EVR.String(),
+			Supplier:         noAssertion,
+			Download:         noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			Copyright:        noAssertion,
+			Summary:          p.Summary,
+			Homepage:         p.URL,
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", d.PURL(c)}},
+		}
+		if len(p.Vendor) > 0 {
+			sp.Supplier = "Organization: " + p.Vendor
+		}
+		if len(p.License) > 0 {
+			sp.LicenseDeclared = doc.license(p.License)
+		}
+		if len(p.SourceRPM) > 0 {
+			sp.SourceInfo = "built package from: " + p.SourceRPM
+		}
+		if algo, ok := repo.ChecksumAlgo(p.Checksum.Type); ok && len(hashNames[algo][0]) > 0 {
+			sp.Checksums = []spdxChecksum{{hashNames[algo][0], p.Checksum.Value}}
+		}
+		doc.Packages = append(doc.Packages, sp)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{doc.ID, "DESCRIBES", ids[i]})
+
+		if !d.Files {
+			continue
+		}
+		for _, name := range c.files() {
+			// SPDX requires SHA1 of every file, files it isn't known
+			// for are left out
+			sha1, ok := c.sha1(name)
+			if !ok {
+				continue
+			}
+			f := spdxFile{
+				ID:               fmt.Sprintf("SPDXRef-File-%d-%d", i, len(doc.Files)),
+				Name:             "." + name,
+				Checksums:        []spdxChecksum{{hashNames[rpm.HashSHA1][0], sha1}},
+				LicenseConcluded: noAssertion,
+				Copyright:        noAssertion,
+			}
+			if sum, ok := c.Digests[name]; ok && c.DigestAlgo != rpm.HashSHA1 && len(hashNames[c.DigestAlgo][0]) > 0 {
+				f.Checksums = append(f.Checksums, spdxChecksum{hashNames[c.DigestAlgo][0], sum})
+			}
+			doc.Files = append(doc.Files, f)
+			doc.Relationships = append(doc.Relationships, spdxRelationship{ids[i], "CONTAINS", f.ID})
+		}
+	}
+	for i, deps := range d.dependencies() {
+		for _, j := range deps {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{ids[i], "DEPENDS_ON", ids[j]})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// sha1 returns SHA1 digest of file of component, computed from payload
+// or recorded in header
+func (c *Component) sha1(name string) (string, bool) {
+	if sum, ok := c.SHA1[name]; ok {
+		return sum, true
+	}
+	if c.DigestAlgo != rpm.HashSHA1 {
+		return "", false
+	}
+	sum, ok := c.Digests[name]
+	return sum, ok
+}
+
+// license returns declared license of package, license which isn't
+// valid SPDX expression is referenced as extracted one along with
+// references legacy names are mapped to
+func (doc *spdxDocument) license(s string) string {
+	var expr string
+	var refs []string
+	if e := declaredLicense(s); e != nil {
+		expr = e.String()
+		for _, l := range e.Leaves() {
+			if strings.HasPrefix(l.License, "LicenseRef-") {
+				refs = append(refs, l.License)
+			}
+		}
+	} else {
+		expr = "LicenseRef-" + spdxIDString(s)
+		refs = []string{expr}
+	}
+	for _, id := range refs {
+		found := false
+		for _, l := range doc.Licenses {
+			found = found || l.ID == id
+		}
+		if !found {
+			doc.Licenses = append(doc.Licenses, spdxExtractedLicense{id, strings.TrimPrefix(id, "LicenseRef-"), s})
+		}
+	}
+	return expr
+}
+
+// spdxIDString replaces characters SPDX identifiers can't have
+func spdxIDString(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, s)
+}
@@ -0,0 +1,135 @@
+package sbom
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/internal/rpmtest"
+	"code.pikelabs.net/go/rpm/repo"
+	"code.pikelabs.net/go/rpm/rpmutil"
+)
+
+func sum(h []byte) string {
+	return hex.EncodeToString(h)
+}
+
+// writePackage writes package with /usr/bin/hello and /usr/bin/hi
+// hardlinked to it into dir
+func writePackage(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	const data = "#!/bin/sh\necho hello\n"
+	files := []rpmtest.File{
+		{Name: "/usr/bin/hello", Mode: 0100755, Ino: 1, Data: data},
+		{Name: "/usr/bin/hi", Mode: 0100755, Ino: 1, Data: data},
+	}
+	h := rpmtest.Header("hello", files...)
+	h.SetString(rpm.TagLicense, "MIT")
+
+	fname := filepath.Join(dir, "hello-1.0-1.noarch.rpm")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lead := rpm.NewLead("hello-1.0-1", "noarch", 0)
+	if err := rpmutil.WritePackage(f, lead, rpm.NewHeader(rpm.TagHeaderSignatures), h, bytes.NewReader(rpmtest.Payload(files...))); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha1.Sum([]byte(data))
+	return fname, sum(digest[:])
+}
+
+func TestSPDXFileChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sbom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname, digest := writePackage(t, dir)
+
+	c, err := ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same package without payload has no SHA1 of files
+	noPayload := &Component{Package: c.Package, Digests: c.Digests, DigestAlgo: c.DigestAlgo}
+	d := &Document{Name: "test", Created: time.Unix(0, 0), Files: true, Components: []*Component{c, noPayload}}
+	var b bytes.Buffer
+	if err := d.WriteSPDX(&b); err != nil {
+		t.Fatal(err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Files) != 2 {
+		t.Fatalf("got %d files, expected 2", len(doc.Files))
+	}
+	for i, name := range []string{"./usr/bin/hello", "./usr/bin/hi"} {
+		f := doc.Files[i]
+		if f.Name != name {
+			t.Errorf("got %s, expected %s", f.Name, name)
+		}
+		if len(f.Checksums) == 0 || f.Checksums[0] != (spdxChecksum{"SHA1", digest}) {
+			t.Errorf("%s: got checksums %v, expected SHA1 %s", f.Name, f.Checksums, digest)
+		}
+		if len(f.Checksums) != 2 || f.Checksums[1].Algorithm != "SHA256" {
+			t.Errorf("%s: got checksums %v, expected SHA256 of header", f.Name, f.Checksums)
+		}
+	}
+	for _, r := range doc.Relationships {
+		if r.Type == "CONTAINS" && r.Element != doc.Packages[0].ID {
+			t.Errorf("%s of package without payload listed", r.Related)
+		}
+	}
+}
+
+func TestSPDXLicense(t *testing.T) {
+	tests := []struct {
+		license   string
+		declared  string
+		extracted []string
+	}{
+		{"MIT", "MIT", nil},
+		{"ASL 2.0 and MIT", "Apache-2.0 AND MIT", nil},
+		{"Public Domain", "LicenseRef-Fedora-Public-Domain", []string{"LicenseRef-Fedora-Public-Domain"}},
+		// no BSD variant is asserted for legacy BSD
+		{"BSD", "LicenseRef-BSD", []string{"LicenseRef-BSD"}},
+		{"GPLv2+ and BSD", "LicenseRef-GPLv2--and-BSD", []string{"LicenseRef-GPLv2--and-BSD"}},
+	}
+	for _, tt := range tests {
+		c := &Component{Package: &repo.Package{Name: "test", Arch: "noarch", License: tt.license}}
+		d := &Document{Name: "test", Created: time.Unix(0, 0), Components: []*Component{c}}
+		var b bytes.Buffer
+		if err := d.WriteSPDX(&b); err != nil {
+			t.Fatal(err)
+		}
+		var doc spdxDocument
+		if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if p := doc.Packages[0]; p.LicenseDeclared != tt.declared || p.LicenseConcluded != noAssertion {
+			t.Errorf("%s: got declared %q, concluded %q", tt.license, p.LicenseDeclared, p.LicenseConcluded)
+		}
+		var extracted []string
+		for _, l := range doc.Licenses {
+			extracted = append(extracted, l.ID)
+			if l.Text != tt.license {
+				t.Errorf("%s: %s has text %q", tt.license, l.ID, l.Text)
+			}
+		}
+		if !reflect.DeepEqual(extracted, tt.extracted) {
+			t.Errorf("%s: got extracted %v, expected %v", tt.license, extracted, tt.extracted)
+		}
+	}
+}
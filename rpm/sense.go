@@ -0,0 +1,71 @@
+package rpm
+
+// SenseFlags are dependency and trigger flags as stored in
+// *Flags tags of the header (rpmsenseFlags)
+type SenseFlags uint32
+
+const (
+	SenseAny     SenseFlags = 0
+	SenseLess    SenseFlags = 1 << 1
+	SenseGreater SenseFlags = 1 << 2
+	SenseEqual   SenseFlags = 1 << 3
+
+	SensePostTrans    SenseFlags = 1 << 5
+	SensePrereq       SenseFlags = 1 << 6
+	SensePreTrans     SenseFlags = 1 << 7
+	SenseInterp       SenseFlags = 1 << 8
+	SenseScriptPre    SenseFlags = 1 << 9
+	SenseScriptPost   SenseFlags = 1 << 10
+	SenseScriptPreun  SenseFlags = 1 << 11
+	SenseScriptPostun SenseFlags = 1 << 12
+	SenseScriptVerify SenseFlags = 1 << 13
+	SenseFindRequires SenseFlags = 1 << 14
+	SenseFindProvides SenseFlags = 1 << 15
+
+	SenseTriggerIn     SenseFlags = 1 << 16
+	SenseTriggerUn     SenseFlags = 1 << 17
+	SenseTriggerPostun SenseFlags = 1 << 18
+	SenseMissingOK     SenseFlags = 1 << 19
+	SenseRpmlib        SenseFlags = 1 << 24
+	SenseTriggerPrein  SenseFlags = 1 << 25
+	SenseKeyring       SenseFlags = 1 << 26
+	SenseConfig        SenseFlags = 1 << 28
+
+	SenseCompareMask = SenseLess | SenseGreater | SenseEqual
+	SenseTriggerMask = SenseTriggerPrein | SenseTriggerIn | SenseTriggerUn | SenseTriggerPostun
+)
+
+// Operator returns comparison operator of flags, empty if none
+func (f SenseFlags) Operator() string {
+	switch f & SenseCompareMask {
+	case SenseLess:
+		return "<"
+	case SenseLess | SenseEqual:
+		return "<="
+	case SenseEqual:
+		return "="
+	case SenseGreater | SenseEqual:
+		return ">="
+	case SenseGreater:
+		return ">"
+	}
+	return ""
+}
+
+// ParseOperator returns flags of comparison operator, false if op
+// isn't one
+func ParseOperator(op string) (SenseFlags, bool) {
+	switch op {
+	case "<":
+		return SenseLess, true
+	case "<=", "=<":
+		return SenseLess | SenseEqual, true
+	case "=", "==":
+		return SenseEqual, true
+	case ">=", "=>":
+		return SenseGreater | SenseEqual, true
+	case ">":
+		return SenseGreater, true
+	}
+	return 0, false
+}
@@ -1,7 +1,55 @@
 package rpm
 
+// Signature header tags, the ones below 1000 share numbering
+// with main header tags
 const (
-	SigTagSize HeaderTag = 1000
-	SigTagMD5            = 1001
-	SigTagPGP            = 1002
+	SigTagDSA             HeaderTag = 267
+	SigTagRSA             HeaderTag = 268
+	SigTagSHA1            HeaderTag = 269
+	SigTagLongSize        HeaderTag = 270
+	SigTagLongArchiveSize HeaderTag = 271
+	SigTagSHA256          HeaderTag = 273
+	SigTagOpenPGP         HeaderTag = 278
+	SigTagSHA3_256        HeaderTag = 279
+)
+
+const (
+	SigTagSize HeaderTag = 1000 + iota
+	SigTagLEMD5_1
+	SigTagPGP
+	SigTagLEMD5_2
+	SigTagMD5
+	SigTagGPG
+	SigTagPGP5
+	SigTagPayloadSize
+	SigTagReservedSpace
+)
+
+// Payload digest and package format tags of main header
+const (
+	TagPayloadDigest      HeaderTag = 5092
+	TagPayloadDigestAlgo  HeaderTag = 5093
+	TagPayloadDigestAlt   HeaderTag = 5097
+	TagPayloadSize        HeaderTag = 5112
+	TagPayloadSizeAlt     HeaderTag = 5113
+	TagRPMFormat          HeaderTag = 5114
+	TagPayloadSHA512      HeaderTag = 5121
+	TagPayloadSHA512Alt   HeaderTag = 5122
+	TagPayloadSHA3_256    HeaderTag = 5123
+	TagPayloadSHA3_256Alt HeaderTag = 5124
+)
+
+// HashAlgo is OpenPGP hash algorithm identifier, rpm uses the
+// same numbers in *Algo tags
+type HashAlgo int32
+
+const (
+	HashMD5      HashAlgo = 1
+	HashSHA1     HashAlgo = 2
+	HashSHA256   HashAlgo = 8
+	HashSHA384   HashAlgo = 9
+	HashSHA512   HashAlgo = 10
+	HashSHA224   HashAlgo = 11
+	HashSHA3_256 HashAlgo = 12
+	HashSHA3_512 HashAlgo = 14
 )
@@ -0,0 +1,138 @@
+package solve
+
+import (
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+// Broken is package requirements of which can't be satisfied
+type Broken struct {
+	Package    *repo.Package
+	Unresolved []rpm.Dependency
+}
+
+// Compatible reports whether packages of arch install on architecture
+// of pool
+func (p *Pool) Compatible(arch string) bool {
+	return arch != "src" && arch != "nosrc" && archScore(p.Arch, arch) >= 0
+}
+
+// Closure checks requirements of pkgs against packages of pool, which
+// should include pkgs themselves. Packages of architectures pool
+// doesn't install are skipped, with newest only the latest version of
+// every name and architecture is checked.
+func (p *Pool) Closure(pkgs []*repo.Package, newest bool) []Broken {
+	if newest {
+		pkgs = latest(pkgs)
+	}
+	var res []Broken
+	for _, pkg := range pkgs {
+		if !p.Compatible(pkg.Arch) {
+			continue
+		}
+		if deps := p.Unresolved(pkg); len(deps) > 0 {
+			res = append(res, Broken{Package: pkg, Unresolved: deps})
+		}
+	}
+	return res
+}
+
+func latest(pkgs []*repo.Package) []*repo.Package {
+	best := map[[2]string]*repo.Package{}
+	for _, pkg := range pkgs {
+		k := [2]string{pkg.Name, pkg.Arch}
+		if b, ok := best[k]; !ok || rpm.CompareEVR(pkg.EVR, b.EVR) > 0 {
+			best[k] = pkg
+		}
+	}
+	var res []*repo.Package
+	for _, pkg := range pkgs {
+		if best[[2]string{pkg.Name, pkg.Arch}] == pkg {
+			res = append(res, pkg)
+		}
+	}
+	return res
+}
+
+// Unresolved returns requirements of package no package of pool
+// satisfies. Rich dependency is unresolved when branch of it which
+// may be needed can't be satisfied, "(A if B)" with B available needs
+// A for example, "(A unless B)" needs A always.
+func (p *Pool) Unresolved(pkg *repo.Package) []rpm.Dependency {
+	var res []rpm.Dependency
+	for _, d := range hardDeps(pkg) {
+		ok := true
+		if rpm.IsRich(d.Name) {
+			rd, err := rpm.ParseRich(d.Name)
+			ok = err == nil && p.richResolved(rd)
+		} else {
+			ok = len(p.whatProvides(d)) > 0
+		}
+		if !ok {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+func (p *Pool) richResolved(d *rpm.RichDep) bool {
+	switch d.Op {
+	case "":
+		return len(p.whatProvides(d.Dep)) > 0
+	case rpm.RichAnd:
+		for _, arg := range d.Args {
+			if !p.richResolved(arg) {
+				return false
+			}
+		}
+		return true
+	case rpm.RichOr:
+		for _, arg := range d.Args {
+			if p.richResolved(arg) {
+				return true
+			}
+		}
+		return false
+	case rpm.RichIf, rpm.RichUnless:
+		// unless is if with condition negated. Branch is needed when
+		// its condition may hold, the condition package may always
+		// be missing and may be installed if pool provides it.
+		cond := p.richResolved(d.Args[1])
+		then, otherwise := cond, true
+		if d.Op == rpm.RichUnless {
+			then, otherwise = true, cond
+		}
+		if then && !p.richResolved(d.Args[0]) {
+			return false
+		}
+		return len(d.Args) < 3 || !otherwise || p.richResolved(d.Args[2])
+	case rpm.RichWith, rpm.RichWithout:
+		return len(p.withProviders(d)) > 0
+	}
+	return false
+}
+
+// withProviders returns packages providing first operand of with or
+// without which do or don't provide the others
+func (p *Pool) withProviders(d *rpm.RichDep) []int {
+	for _, arg := range d.Args {
+		if len(arg.Op) > 0 {
+			return nil
+		}
+	}
+	ids := p.whatProvides(d.Args[0].Dep)
+	for _, arg := range d.Args[1:] {
+		other := map[int]bool{}
+		for _, id := range p.whatProvides(arg.Dep) {
+			other[id] = true
+		}
+		var kept []int
+		for _, id := range ids {
+			if other[id] == (d.Op == rpm.RichWith) {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+	}
+	return ids
+}
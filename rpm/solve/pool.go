@@ -0,0 +1,241 @@
+// Package solve resolves requests to install packages against
+// metadata of repositories. Requirements, conflicts, obsoletes and
+// rich dependencies are turned into boolean clauses which CDCL solver
+// satisfies preferring best architecture and newest versions, weak
+// dependencies are installed on top when they fit.
+package solve
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+// compatArches lists architectures packages of which install on the
+// key architecture, best first
+var compatArches = map[string][]string{
+	"x86_64":  {"x86_64", "amd64", "ia32e", "athlon", "i686", "i586", "i486", "i386"},
+	"i686":    {"i686", "i586", "i486", "i386"},
+	"aarch64": {"aarch64"},
+	"armv7hl": {"armv7hl", "armv6hl"},
+	"ppc64le": {"ppc64le"},
+	"ppc64":   {"ppc64", "ppc"},
+	"s390x":   {"s390x", "s390"},
+	"riscv64": {"riscv64"},
+}
+
+// goArches maps GOARCH to rpm architecture
+var goArches = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "i686",
+	"arm64":   "aarch64",
+	"arm":     "armv7hl",
+	"ppc64le": "ppc64le",
+	"ppc64":   "ppc64",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// DefaultArch returns rpm architecture of running system
+func DefaultArch() string {
+	if arch, ok := goArches[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+// archScore returns rank of package architecture on arch, lower is
+// better, -1 for architecture which doesn't install there
+func archScore(arch, pkgArch string) int {
+	if pkgArch == "noarch" {
+		return 0
+	}
+	compat, ok := compatArches[arch]
+	if !ok {
+		compat = []string{arch}
+	}
+	for i, a := range compat {
+		if a == pkgArch {
+			return i
+		}
+	}
+	return -1
+}
+
+type entry struct {
+	pkg       *repo.Package
+	installed bool
+	arch      int
+}
+
+type provider struct {
+	id  int
+	dep rpm.Dependency
+}
+
+// Pool is set of packages requests are resolved against
+type Pool struct {
+	Arch string
+
+	pkgs     []entry
+	byName   map[string][]int
+	provides map[string][]provider
+	files    map[string][]int
+}
+
+// NewPool returns empty pool for architecture arch
+func NewPool(arch string) *Pool {
+	return &Pool{
+		Arch:     arch,
+		byName:   map[string][]int{},
+		provides: map[string][]provider{},
+		files:    map[string][]int{},
+	}
+}
+
+// Add adds available packages, source packages and the ones of
+// architecture not compatible with the pool are left out
+func (p *Pool) Add(pkgs ...*repo.Package) {
+	for _, pkg := range pkgs {
+		p.add(pkg, false)
+	}
+}
+
+// AddInstalled adds packages already installed, they are kept as
+// they are and satisfy dependencies of the others
+func (p *Pool) AddInstalled(pkgs ...*repo.Package) {
+	for _, pkg := range pkgs {
+		p.add(pkg, true)
+	}
+}
+
+func (p *Pool) add(pkg *repo.Package, installed bool) {
+	score := archScore(p.Arch, pkg.Arch)
+	if !installed && (pkg.Arch == "src" || pkg.Arch == "nosrc" || score < 0) {
+		return
+	}
+	id := len(p.pkgs)
+	p.pkgs = append(p.pkgs, entry{pkg: pkg, installed: installed, arch: score})
+	p.byName[pkg.Name] = append(p.byName[pkg.Name], id)
+	// packages provide themselves even if metadata omits it
+	self := rpm.Dependency{Name: pkg.Name, Flags: rpm.SenseEqual, Version: pkg.EVR.String()}
+	p.provides[pkg.Name] = append(p.provides[pkg.Name], provider{id, self})
+	for _, d := range pkg.Deps[rpm.DepProvides] {
+		p.provides[d.Name] = append(p.provides[d.Name], provider{id, d})
+	}
+	for _, f := range pkg.Files {
+		p.files[f.Path] = append(p.files[f.Path], id)
+	}
+}
+
+// Len returns number of packages in pool
+func (p *Pool) Len() int {
+	return len(p.pkgs)
+}
+
+// WhatProvides returns packages providing dependency, best first
+func (p *Pool) WhatProvides(dep rpm.Dependency) []*repo.Package {
+	var res []*repo.Package
+	for _, id := range p.whatProvides(dep) {
+		res = append(res, p.pkgs[id].pkg)
+	}
+	return res
+}
+
+func (p *Pool) whatProvides(dep rpm.Dependency) []int {
+	seen := map[int]bool{}
+	var ids []int
+	for _, prov := range p.provides[dep.Name] {
+		if !seen[prov.id] && prov.dep.Overlaps(dep) {
+			seen[prov.id] = true
+			ids = append(ids, prov.id)
+		}
+	}
+	if strings.HasPrefix(dep.Name, "/") {
+		for _, id := range p.files[dep.Name] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	p.sortBest(ids, dep.Name)
+	return ids
+}
+
+// sortBest orders packages by preference: installed ones, the ones
+// named after dependency, better architecture, then newer versions
+func (p *Pool) sortBest(ids []int, name string) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := &p.pkgs[ids[i]], &p.pkgs[ids[j]]
+		if a.installed != b.installed {
+			return a.installed
+		}
+		if an, bn := a.pkg.Name == name, b.pkg.Name == name; an != bn {
+			return an
+		}
+		if a.arch != b.arch {
+			return a.arch < b.arch
+		}
+		if a.pkg.Name != b.pkg.Name {
+			return a.pkg.Name < b.pkg.Name
+		}
+		if c := rpm.CompareEVR(a.pkg.EVR, b.pkg.EVR); c != 0 {
+			return c > 0
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// match returns packages spec selects, best first. Spec is package
+// name optionally followed by version, release and architecture, or
+// capability the packages provide, "name >= version" or file path.
+func (p *Pool) match(spec string) []int {
+	if strings.ContainsAny(spec, " <>=") {
+		dep, err := rpm.ParseDependency(spec)
+		if err != nil {
+			return nil
+		}
+		return p.whatProvides(dep)
+	}
+	var ids []int
+	for id := range p.pkgs {
+		if matchNEVRA(p.pkgs[id].pkg, spec) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return p.whatProvides(rpm.Dependency{Name: spec})
+	}
+	p.sortBest(ids, spec)
+	return ids
+}
+
+// matchNEVRA reports whether spec is one of name, name.arch,
+// name-version, name-version-release and name-version-release.arch
+// forms of package, version optionally with epoch
+func matchNEVRA(pkg *repo.Package, spec string) bool {
+	if !strings.HasPrefix(spec, pkg.Name) {
+		return false
+	}
+	rest := spec[len(pkg.Name):]
+	if len(rest) == 0 || rest == "."+pkg.Arch {
+		return true
+	}
+	if rest[0] != '-' {
+		return false
+	}
+	rest = strings.TrimSuffix(rest[1:], "."+pkg.Arch)
+	evr := pkg.EVR
+	epoch := strconv.Itoa(evr.Epoch) + ":"
+	for _, v := range []string{evr.Version, evr.Version + "-" + evr.Release} {
+		if rest == v || rest == epoch+v {
+			return true
+		}
+	}
+	return false
+}
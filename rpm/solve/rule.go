@@ -0,0 +1,82 @@
+package solve
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+// RuleKind tells where rule comes from
+type RuleKind int
+
+const (
+	RuleJob RuleKind = iota
+	RuleInstalled
+	RuleRequires
+	RuleConflicts
+	RuleObsoletes
+	RuleSameName
+)
+
+var ruleKindNames = []string{"job", "installed", "requires", "conflicts", "obsoletes", "same-name"}
+
+func (k RuleKind) String() string {
+	if int(k) < len(ruleKindNames) {
+		return ruleKindNames[k]
+	}
+	return fmt.Sprintf("RuleKind(%d)", int(k))
+}
+
+// Rule is constraint clauses of solver are generated from, unsolvable
+// requests are explained by rules which contradict each other
+type Rule struct {
+	Kind RuleKind
+	// Package rule is about, nil for jobs
+	Package *repo.Package
+	// Dep is requested spec for jobs, dependency otherwise
+	Dep string
+	// Others are packages matching job or requirement, conflicting
+	// or obsoleted package or other package of the same name
+	Others []*repo.Package
+}
+
+func nevras(pkgs []*repo.Package) string {
+	s := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		s[i] = p.NEVRA()
+	}
+	return strings.Join(s, ", ")
+}
+
+func (r *Rule) String() string {
+	switch r.Kind {
+	case RuleJob:
+		return fmt.Sprintf("%s is requested", r.Dep)
+	case RuleInstalled:
+		return fmt.Sprintf("%s is installed", r.Package.NEVRA())
+	case RuleRequires:
+		if len(r.Others) == 0 {
+			return fmt.Sprintf("nothing provides %s needed by %s", r.Dep, r.Package.NEVRA())
+		}
+		return fmt.Sprintf("%s requires %s, provided by %s", r.Package.NEVRA(), r.Dep, nevras(r.Others))
+	case RuleConflicts:
+		if len(r.Others) == 0 {
+			return fmt.Sprintf("%s conflicts with %s", r.Package.NEVRA(), r.Dep)
+		}
+		return fmt.Sprintf("%s conflicts with %s provided by %s", r.Package.NEVRA(), r.Dep, nevras(r.Others))
+	case RuleObsoletes:
+		return fmt.Sprintf("%s obsoletes %s provided by %s", r.Package.NEVRA(), r.Dep, nevras(r.Others))
+	case RuleSameName:
+		return fmt.Sprintf("only one of %s and %s can be installed", r.Package.NEVRA(), nevras(r.Others))
+	}
+	return r.Kind.String()
+}
+
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind string `json:"kind"`
+		Text string `json:"text"`
+	}{r.Kind.String(), r.String()})
+}
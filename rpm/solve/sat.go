@@ -0,0 +1,365 @@
+package solve
+
+import "sort"
+
+// lit is literal of boolean variable, negative one stands for
+// negation, variables are numbered from 1
+type lit int
+
+func (l lit) v() int {
+	if l < 0 {
+		return int(-l)
+	}
+	return int(l)
+}
+
+// index of literal into watch lists
+func (l lit) index() int {
+	if l < 0 {
+		return 2*int(-l) + 1
+	}
+	return 2 * int(l)
+}
+
+type clause struct {
+	// lits are reordered as watches move, pref keeps the original
+	// order which is order of preference of positive literals
+	lits []lit
+	pref []lit
+	// rule clause comes from, nil for learned clauses
+	rule *Rule
+	// from are clauses learned clause was derived from
+	from []int
+}
+
+// sat is CDCL solver. Decisions are made by caller which assigns
+// chosen literals true, variables left unassigned are false.
+type sat struct {
+	clauses []*clause
+	watches [][]int
+	// clauses which may be left unsatisfied by variables defaulting
+	// to false: the ones without negative literal, the ones with
+	// single negative literal by its variable and the rest which mix
+	// positive and negative ones. Clauses of negative literals only
+	// are satisfied by propagation, learned ones are implied.
+	positive []int
+	owned    [][]int
+	mixed    []int
+	// scan is position in trail before which owned clauses of true
+	// variables are satisfied
+	scan int
+
+	value  []int8
+	level  []int
+	reason []int
+	trail  []lit
+	// lim are starts of decision levels in trail
+	lim   []int
+	qhead int
+	seen  []bool
+}
+
+func newSat() *sat {
+	s := &sat{}
+	s.newVar()
+	return s
+}
+
+// newVar returns positive literal of new variable
+func (s *sat) newVar() lit {
+	s.value = append(s.value, 0)
+	s.level = append(s.level, 0)
+	s.reason = append(s.reason, -1)
+	s.seen = append(s.seen, false)
+	s.owned = append(s.owned, nil)
+	s.watches = append(s.watches, nil, nil)
+	return lit(len(s.value) - 1)
+}
+
+func (s *sat) val(l lit) int8 {
+	if l < 0 {
+		return -s.value[-l]
+	}
+	return s.value[l]
+}
+
+// add adds clause, duplicate literals are dropped and tautologies are
+// left out, -1 is returned for those
+func (s *sat) add(lits []lit, rule *Rule, from []int) int {
+	seen := map[lit]bool{}
+	var uniq []lit
+	for _, l := range lits {
+		if seen[-l] {
+			return -1
+		}
+		if !seen[l] {
+			seen[l] = true
+			uniq = append(uniq, l)
+		}
+	}
+	c := &clause{lits: uniq, pref: append([]lit(nil), uniq...), rule: rule, from: from}
+	ci := len(s.clauses)
+	s.clauses = append(s.clauses, c)
+	if len(uniq) >= 2 {
+		s.watches[uniq[0].index()] = append(s.watches[uniq[0].index()], ci)
+		s.watches[uniq[1].index()] = append(s.watches[uniq[1].index()], ci)
+	}
+	if rule == nil {
+		return ci
+	}
+	var neg []lit
+	for _, l := range uniq {
+		if l < 0 {
+			neg = append(neg, l)
+		}
+	}
+	switch {
+	case len(neg) == 0:
+		s.positive = append(s.positive, ci)
+	case len(neg) == 1:
+		s.owned[neg[0].v()] = append(s.owned[neg[0].v()], ci)
+	case len(neg) < len(uniq):
+		s.mixed = append(s.mixed, ci)
+	}
+	return ci
+}
+
+func (s *sat) enqueue(l lit, reason int) {
+	v := l.v()
+	s.value[v] = 1
+	if l < 0 {
+		s.value[v] = -1
+	}
+	s.level[v] = len(s.lim)
+	s.reason[v] = reason
+	s.trail = append(s.trail, l)
+}
+
+func (s *sat) decide(l lit) {
+	s.lim = append(s.lim, len(s.trail))
+	s.enqueue(l, -1)
+}
+
+func (s *sat) backtrack(level int) {
+	if level >= len(s.lim) {
+		return
+	}
+	for _, l := range s.trail[s.lim[level]:] {
+		s.value[l.v()] = 0
+		s.reason[l.v()] = -1
+	}
+	s.trail = s.trail[:s.lim[level]]
+	s.lim = s.lim[:level]
+	s.qhead = len(s.trail)
+	// literals which satisfied owned clauses may be gone
+	s.scan = 0
+}
+
+// propagate assigns literals implied by clauses, index of clause
+// found false is returned on conflict, -1 otherwise
+func (s *sat) propagate() int {
+	for s.qhead < len(s.trail) {
+		f := -s.trail[s.qhead]
+		s.qhead++
+		ws := s.watches[f.index()]
+		kept := ws[:0]
+		for i, ci := range ws {
+			c := s.clauses[ci]
+			if c.lits[0] == f {
+				c.lits[0], c.lits[1] = c.lits[1], c.lits[0]
+			}
+			if s.val(c.lits[0]) > 0 {
+				kept = append(kept, ci)
+				continue
+			}
+			moved := false
+			for k := 2; k < len(c.lits); k++ {
+				if s.val(c.lits[k]) >= 0 {
+					c.lits[1], c.lits[k] = c.lits[k], c.lits[1]
+					s.watches[c.lits[1].index()] = append(s.watches[c.lits[1].index()], ci)
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+			kept = append(kept, ci)
+			if s.val(c.lits[0]) < 0 {
+				kept = append(kept, ws[i+1:]...)
+				s.watches[f.index()] = kept
+				return ci
+			}
+			s.enqueue(c.lits[0], ci)
+		}
+		s.watches[f.index()] = kept
+	}
+	return -1
+}
+
+// analyze derives clause asserting at lower level from conflict,
+// first unique implication point is used
+func (s *sat) analyze(confl int) (learnt []lit, level int, from []int) {
+	cur := len(s.lim)
+	learnt = []lit{0}
+	var marked []int
+	var p lit
+	pending := 0
+	idx := len(s.trail) - 1
+	for ci := confl; ; {
+		from = append(from, ci)
+		for _, q := range s.clauses[ci].lits {
+			v := q.v()
+			if q == p || s.seen[v] {
+				continue
+			}
+			s.seen[v] = true
+			marked = append(marked, v)
+			switch {
+			case s.level[v] == 0:
+				// false at top level, its reason is part of proof
+				from = append(from, s.reason[v])
+			case s.level[v] == cur:
+				pending++
+			default:
+				learnt = append(learnt, q)
+			}
+		}
+		for !s.seen[s.trail[idx].v()] {
+			idx--
+		}
+		p = s.trail[idx]
+		idx--
+		ci = s.reason[p.v()]
+		pending--
+		if pending == 0 {
+			break
+		}
+	}
+	learnt[0] = -p
+	for _, v := range marked {
+		s.seen[v] = false
+	}
+
+	for i := 2; i < len(learnt); i++ {
+		if s.level[learnt[i].v()] > s.level[learnt[1].v()] {
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+	if len(learnt) > 1 {
+		level = s.level[learnt[1].v()]
+	}
+	return learnt, level, from
+}
+
+// core returns rules which together can't be satisfied, the ones
+// conflict at top level was derived from
+func (s *sat) core(confl int) []*Rule {
+	visited := map[int]bool{}
+	var rules []int
+	queue := []int{confl}
+	for len(queue) > 0 {
+		ci := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if ci < 0 || visited[ci] {
+			continue
+		}
+		visited[ci] = true
+		c := s.clauses[ci]
+		if c.rule != nil {
+			rules = append(rules, ci)
+		}
+		queue = append(queue, c.from...)
+		for _, l := range c.lits {
+			if s.value[l.v()] != 0 && s.level[l.v()] == 0 {
+				queue = append(queue, s.reason[l.v()])
+			}
+		}
+	}
+	sort.Ints(rules)
+	var res []*Rule
+	seen := map[*Rule]bool{}
+	for _, ci := range rules {
+		if r := s.clauses[ci].rule; !seen[r] {
+			seen[r] = true
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// unsatisfied returns clause which is left unsatisfied when variables
+// not assigned yet are false, -1 if there's none
+func (s *sat) unsatisfied() int {
+	for _, list := range [][]int{s.positive, s.mixed} {
+		for _, ci := range list {
+			if s.open(ci) {
+				return ci
+			}
+		}
+	}
+	// clause with single negative literal stays satisfied once its
+	// variable is true until backtracking
+	for ; s.scan < len(s.trail); s.scan++ {
+		if l := s.trail[s.scan]; l > 0 {
+			for _, ci := range s.owned[l] {
+				if s.open(ci) {
+					return ci
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func (s *sat) open(ci int) bool {
+	for _, l := range s.clauses[ci].lits {
+		if v := s.val(l); v > 0 || (v == 0 && l < 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// choice returns preferred literal which satisfies open clause
+func (s *sat) choice(ci int) lit {
+	for _, l := range s.clauses[ci].pref {
+		if l > 0 && s.val(l) == 0 {
+			return l
+		}
+	}
+	return 0
+}
+
+// solve searches for assignment satisfying clauses, pick returns next
+// decision or 0 when it's complete. Rules of proof are returned when
+// clauses can't be satisfied.
+func (s *sat) solve(pick func() lit) (bool, []*Rule) {
+	for ci, c := range s.clauses {
+		switch {
+		case len(c.lits) == 0:
+			return false, s.core(ci)
+		case len(c.lits) == 1 && s.val(c.lits[0]) < 0:
+			return false, s.core(ci)
+		case len(c.lits) == 1 && s.val(c.lits[0]) == 0:
+			s.enqueue(c.lits[0], ci)
+		}
+	}
+	for {
+		if confl := s.propagate(); confl >= 0 {
+			if len(s.lim) == 0 {
+				return false, s.core(confl)
+			}
+			learnt, level, from := s.analyze(confl)
+			s.backtrack(level)
+			ci := s.add(learnt, nil, from)
+			s.enqueue(learnt[0], ci)
+			continue
+		}
+		l := pick()
+		if l == 0 {
+			return true, nil
+		}
+		s.decide(l)
+	}
+}
@@ -0,0 +1,628 @@
+package solve
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+var (
+	ErrNoMatch        = errors.New("no package matches")
+	ErrUnsatisfiable  = errors.New("unsatisfiable request")
+	errTooComplexRich = errors.New("rich dependency too complex")
+)
+
+// UnsatError explains why request can't be satisfied, Rules are
+// constraints which contradict each other
+type UnsatError struct {
+	Rules []*Rule
+}
+
+func (e *UnsatError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(ErrUnsatisfiable.Error())
+	for _, r := range e.Rules {
+		sb.WriteString("\n  - ")
+		sb.WriteString(r.String())
+	}
+	return sb.String()
+}
+
+func (e *UnsatError) Unwrap() error {
+	return ErrUnsatisfiable
+}
+
+// Options control resolution
+type Options struct {
+	// IgnoreWeak doesn't install Recommends and Supplements
+	IgnoreWeak bool
+}
+
+// Reason tells why package is part of transaction
+type Reason int
+
+const (
+	ReasonRequested Reason = iota
+	ReasonDependency
+	ReasonWeak
+)
+
+var reasonNames = []string{"requested", "dependency", "weak"}
+
+func (r Reason) String() string {
+	if int(r) < len(reasonNames) {
+		return reasonNames[r]
+	}
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+func (r Reason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Step is package to be installed
+type Step struct {
+	Package *repo.Package
+	Reason  Reason
+}
+
+// Transaction is outcome of resolution
+type Transaction struct {
+	// Install are packages to install, dependencies come before
+	// packages requiring them unless they require each other
+	Install []Step
+}
+
+// resolver holds state of single resolution
+type resolver struct {
+	*Pool
+	opts *Options
+	s    *sat
+	// packages rules were generated for, order is sorted closure
+	closure   map[int]bool
+	order     []int
+	requested map[int]bool
+	weak      map[int]bool
+	tried     map[weakKey]bool
+}
+
+// weakKey is recommends of package by index, -1 for its supplements
+type weakKey struct {
+	id, dep int
+}
+
+// maxConjunctions bounds size of rich dependency expanded into
+// disjunctive normal form
+const maxConjunctions = 4096
+
+// Resolve finds packages to install so that packages specs select
+// are installed along with their dependencies
+func (p *Pool) Resolve(specs []string, opts *Options) (*Transaction, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	r := &resolver{
+		Pool:      p,
+		opts:      opts,
+		s:         newSat(),
+		closure:   map[int]bool{},
+		requested: map[int]bool{},
+		weak:      map[int]bool{},
+		tried:     map[weakKey]bool{},
+	}
+	for range p.pkgs {
+		r.s.newVar()
+	}
+
+	var seeds []int
+	for _, spec := range specs {
+		ids, err := r.job(spec)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, ids...)
+	}
+	for id, e := range p.pkgs {
+		if e.installed {
+			r.s.add([]lit{pkgLit(id)}, &Rule{Kind: RuleInstalled, Package: e.pkg}, nil)
+			seeds = append(seeds, id)
+		} else if !opts.IgnoreWeak && len(e.pkg.Deps[rpm.DepSupplements]) > 0 {
+			seeds = append(seeds, id)
+		}
+	}
+	r.expand(seeds)
+	if err := r.rules(); err != nil {
+		return nil, err
+	}
+
+	ok, proof := r.s.solve(r.pick)
+	if !ok {
+		return nil, &UnsatError{Rules: proof}
+	}
+	return r.transaction(), nil
+}
+
+func pkgLit(id int) lit {
+	return lit(id + 1)
+}
+
+// pkgID returns package of literal, -1 for auxiliary variables
+func (r *resolver) pkgID(l lit) int {
+	if l > 0 && int(l) <= len(r.pkgs) {
+		return int(l) - 1
+	}
+	return -1
+}
+
+// job adds clause of requested spec and returns packages it matches
+func (r *resolver) job(spec string) ([]int, error) {
+	rule := &Rule{Kind: RuleJob, Dep: spec}
+	if rpm.IsRich(spec) {
+		d, err := rpm.ParseRich(spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.encodeRich(0, d, false, rule); err != nil {
+			return nil, err
+		}
+		ids := r.richProviders(d)
+		for _, id := range ids {
+			r.requested[id] = true
+		}
+		return ids, nil
+	}
+	ids := r.match(spec)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoMatch, spec)
+	}
+	lits := make([]lit, len(ids))
+	for i, id := range ids {
+		lits[i] = pkgLit(id)
+		rule.Others = append(rule.Others, r.pkgs[id].pkg)
+		r.requested[id] = true
+	}
+	r.s.add(lits, rule, nil)
+	return ids, nil
+}
+
+// hardDeps returns requirements considered by solver, the ones on
+// rpmlib features are satisfied by rpm itself
+func hardDeps(pkg *repo.Package) []rpm.Dependency {
+	var deps []rpm.Dependency
+	for _, d := range pkg.Deps[rpm.DepRequires] {
+		if !strings.HasPrefix(d.Name, "rpmlib(") {
+			deps = append(deps, d)
+		}
+	}
+	return deps
+}
+
+// expand collects packages which may become part of transaction,
+// the ones reachable over requirements from seeds
+func (r *resolver) expand(seeds []int) {
+	queue := append([]int(nil), seeds...)
+	for len(queue) > 0 {
+		id := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if r.closure[id] {
+			continue
+		}
+		r.closure[id] = true
+		pkg := r.pkgs[id].pkg
+		deps := hardDeps(pkg)
+		if !r.opts.IgnoreWeak {
+			deps = append(deps, pkg.Deps[rpm.DepRecommends]...)
+		}
+		for _, d := range deps {
+			if rpm.IsRich(d.Name) {
+				if rd, err := rpm.ParseRich(d.Name); err == nil {
+					queue = append(queue, r.richProviders(rd)...)
+				}
+				continue
+			}
+			queue = append(queue, r.whatProvides(d)...)
+		}
+	}
+}
+
+// richProviders returns packages providing any of simple
+// dependencies of rich one
+func (r *resolver) richProviders(d *rpm.RichDep) []int {
+	if len(d.Op) == 0 {
+		return r.whatProvides(d.Dep)
+	}
+	var ids []int
+	for _, arg := range d.Args {
+		ids = append(ids, r.richProviders(arg)...)
+	}
+	return ids
+}
+
+// rules generates clauses for packages of closure
+func (r *resolver) rules() error {
+	ids := make([]int, 0, len(r.closure))
+	for id := range r.closure {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	r.order = ids
+	names := map[string]bool{}
+	for _, id := range ids {
+		if err := r.packageRules(id); err != nil {
+			return err
+		}
+		names[r.pkgs[id].pkg.Name] = true
+	}
+
+	// packages of the same name replace each other unless they are
+	// multilib variants of different architectures
+	for _, id := range ids {
+		pkg := r.pkgs[id].pkg
+		if !names[pkg.Name] {
+			continue
+		}
+		names[pkg.Name] = false
+		same := r.byName[pkg.Name]
+		for i, a := range same {
+			for _, b := range same[i+1:] {
+				pa, pb := r.pkgs[a].pkg, r.pkgs[b].pkg
+				if pa.Arch == pb.Arch || pa.Arch == "noarch" || pb.Arch == "noarch" {
+					rule := &Rule{Kind: RuleSameName, Package: pa, Others: []*repo.Package{pb}}
+					r.s.add([]lit{-pkgLit(a), -pkgLit(b)}, rule, nil)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *resolver) packageRules(id int) error {
+	pkg := r.pkgs[id].pkg
+	p := pkgLit(id)
+	for _, d := range hardDeps(pkg) {
+		rule := &Rule{Kind: RuleRequires, Package: pkg, Dep: d.String()}
+		if rpm.IsRich(d.Name) {
+			rd, err := rpm.ParseRich(d.Name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+			}
+			rule.Others = r.packages(r.richProviders(rd))
+			if err := r.encodeRich(p, rd, false, rule); err != nil {
+				return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+			}
+			continue
+		}
+		ids := r.whatProvides(d)
+		lits := []lit{-p}
+		for _, pid := range ids {
+			lits = append(lits, pkgLit(pid))
+		}
+		rule.Others = r.packages(ids)
+		r.s.add(lits, rule, nil)
+	}
+
+	for _, d := range pkg.Deps[rpm.DepConflicts] {
+		if rpm.IsRich(d.Name) {
+			rd, err := rpm.ParseRich(d.Name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+			}
+			rule := &Rule{Kind: RuleConflicts, Package: pkg, Dep: d.String()}
+			if err := r.encodeRich(p, rd, true, rule); err != nil {
+				return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+			}
+			continue
+		}
+		// packages never conflict with themselves
+		for _, q := range r.whatProvides(d) {
+			if q != id {
+				rule := &Rule{Kind: RuleConflicts, Package: pkg, Dep: d.String(), Others: r.packages([]int{q})}
+				r.s.add([]lit{-p, -pkgLit(q)}, rule, nil)
+			}
+		}
+	}
+
+	// obsoletes match names of packages, not their provides
+	for _, d := range pkg.Deps[rpm.DepObsoletes] {
+		for _, q := range r.byName[d.Name] {
+			other := r.pkgs[q].pkg
+			self := rpm.Dependency{Name: other.Name, Flags: rpm.SenseEqual, Version: other.EVR.String()}
+			if other.Name != pkg.Name && self.Overlaps(d) {
+				rule := &Rule{Kind: RuleObsoletes, Package: pkg, Dep: d.String(), Others: []*repo.Package{other}}
+				r.s.add([]lit{-p, -pkgLit(q)}, rule, nil)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *resolver) packages(ids []int) []*repo.Package {
+	seen := map[int]bool{}
+	var pkgs []*repo.Package
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			pkgs = append(pkgs, r.pkgs[id].pkg)
+		}
+	}
+	return pkgs
+}
+
+// encodeRich adds clauses making owner imply rich dependency, or its
+// negation for conflicts. Without owner dependency has to hold.
+func (r *resolver) encodeRich(owner lit, d *rpm.RichDep, negate bool, rule *Rule) error {
+	dnf, err := r.dnf(d, !negate)
+	if err != nil {
+		return err
+	}
+	var head []lit
+	if owner != 0 {
+		head = []lit{-owner}
+	}
+	for _, conj := range dnf {
+		if len(conj) == 0 {
+			return nil
+		}
+	}
+	if len(dnf) == 1 {
+		for _, l := range dnf[0] {
+			r.s.add(append(head[:len(head):len(head)], l), rule, nil)
+		}
+		return nil
+	}
+	// alternatives which install less are preferred, "A if B" is
+	// satisfied by not installing B before installing A
+	sort.SliceStable(dnf, func(i, j int) bool {
+		return positives(dnf[i]) < positives(dnf[j])
+	})
+	lits := append([]lit(nil), head...)
+	for _, conj := range dnf {
+		if len(conj) == 1 && conj[0] > 0 {
+			lits = append(lits, conj[0])
+			continue
+		}
+		aux := r.s.newVar()
+		for _, l := range conj {
+			r.s.add([]lit{-aux, l}, rule, nil)
+		}
+		lits = append(lits, aux)
+	}
+	r.s.add(lits, rule, nil)
+	return nil
+}
+
+// dnf returns rich dependency or its negation as disjunction of
+// conjunctions of package literals. If and unless are evaluated the
+// way rpm does, "A if B" as "A or not B" and "A unless B" as
+// "A and not B".
+func (r *resolver) dnf(d *rpm.RichDep, pos bool) ([][]lit, error) {
+	if len(d.Op) == 0 {
+		return r.leaf(r.whatProvides(d.Dep), pos), nil
+	}
+	args := make([][2][][]lit, len(d.Args))
+	for i, arg := range d.Args {
+		if d.Op == rpm.RichWith || d.Op == rpm.RichWithout {
+			break
+		}
+		var err error
+		if args[i][0], err = r.dnf(arg, false); err != nil {
+			return nil, err
+		}
+		if args[i][1], err = r.dnf(arg, true); err != nil {
+			return nil, err
+		}
+	}
+	// arg returns i-th operand, negated unless p
+	arg := func(i int, p bool) [][]lit {
+		if p {
+			return args[i][1]
+		}
+		return args[i][0]
+	}
+
+	var res [][]lit
+	switch d.Op {
+	case rpm.RichAnd, rpm.RichOr:
+		and := (d.Op == rpm.RichAnd) == pos
+		res = arg(0, pos)
+		for i := 1; i < len(d.Args); i++ {
+			if and {
+				res = cross(res, arg(i, pos))
+			} else {
+				res = append(res, arg(i, pos)...)
+			}
+		}
+	case rpm.RichIf, rpm.RichUnless:
+		// unless is if with condition negated
+		c := d.Op == rpm.RichIf
+		if len(d.Args) == 2 {
+			if pos == c {
+				// A or not B, negated for unless
+				res = append(append(res, arg(0, pos)...), arg(1, !c)...)
+			} else {
+				res = cross(arg(0, pos), arg(1, c))
+			}
+			break
+		}
+		// (A and B) or (C and not B), with B negated for unless,
+		// negation is (not A and B) or (not C and not B)
+		res = append(cross(arg(0, pos), arg(1, c)), cross(arg(2, pos), arg(1, !c))...)
+	case rpm.RichWith, rpm.RichWithout:
+		for _, arg := range d.Args {
+			if len(arg.Op) > 0 {
+				return nil, fmt.Errorf("%w: %s", rpm.ErrMalformedRich, d)
+			}
+		}
+		ids := r.withProviders(d)
+		res = r.leaf(ids, pos)
+	}
+	if len(res) > maxConjunctions {
+		return nil, fmt.Errorf("%w: %s", errTooComplexRich, d)
+	}
+	return res, nil
+}
+
+// leaf returns providers as disjunction of packages, or conjunction
+// of their negations
+func (r *resolver) leaf(ids []int, pos bool) [][]lit {
+	if !pos {
+		conj := []lit{}
+		for _, id := range ids {
+			conj = append(conj, -pkgLit(id))
+		}
+		return [][]lit{conj}
+	}
+	var res [][]lit
+	for _, id := range ids {
+		res = append(res, []lit{pkgLit(id)})
+	}
+	return res
+}
+
+func positives(conj []lit) int {
+	n := 0
+	for _, l := range conj {
+		if l > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func cross(a, b [][]lit) [][]lit {
+	var res [][]lit
+	for _, x := range a {
+		for _, y := range b {
+			res = append(res, append(append([]lit(nil), x...), y...))
+		}
+	}
+	return res
+}
+
+// pick chooses next package to install, requirements come first,
+// weak dependencies are tried once they are all satisfied
+func (r *resolver) pick() lit {
+	if ci := r.s.unsatisfied(); ci >= 0 {
+		return r.s.choice(ci)
+	}
+	if r.opts.IgnoreWeak {
+		return 0
+	}
+	for _, l := range r.s.trail {
+		id := r.pkgID(l)
+		if id < 0 {
+			continue
+		}
+		for i, d := range r.pkgs[id].pkg.Deps[rpm.DepRecommends] {
+			key := weakKey{id, i}
+			if rpm.IsRich(d.Name) || r.tried[key] {
+				continue
+			}
+			r.tried[key] = true
+			if l := r.weakChoice(r.whatProvides(d)); l != 0 {
+				return l
+			}
+		}
+	}
+	for _, id := range r.order {
+		pkg := r.pkgs[id].pkg
+		key := weakKey{id, -1}
+		if len(pkg.Deps[rpm.DepSupplements]) == 0 || r.tried[key] || r.s.val(pkgLit(id)) != 0 {
+			continue
+		}
+		for _, d := range pkg.Deps[rpm.DepSupplements] {
+			if rpm.IsRich(d.Name) || !r.anyTrue(r.whatProvides(d)) {
+				continue
+			}
+			r.tried[key] = true
+			// only the best candidate of its name is pulled in
+			same := append([]int(nil), r.byName[pkg.Name]...)
+			r.sortBest(same, pkg.Name)
+			if l := r.weakChoice(same); l == pkgLit(id) {
+				return l
+			}
+			break
+		}
+	}
+	return 0
+}
+
+func (r *resolver) anyTrue(ids []int) bool {
+	for _, id := range ids {
+		if r.s.val(pkgLit(id)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// weakChoice returns the best of providers which can be still
+// installed, 0 if one of them is installed already
+func (r *resolver) weakChoice(ids []int) lit {
+	if r.anyTrue(ids) {
+		return 0
+	}
+	for _, id := range ids {
+		if r.s.val(pkgLit(id)) == 0 {
+			r.weak[id] = true
+			return pkgLit(id)
+		}
+	}
+	return 0
+}
+
+// transaction collects packages to install ordered by requirements
+func (r *resolver) transaction() *Transaction {
+	var ids []int
+	install := map[int]bool{}
+	for id, e := range r.pkgs {
+		if !e.installed && r.s.val(pkgLit(id)) > 0 {
+			ids = append(ids, id)
+			install[id] = true
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return r.pkgs[ids[i]].pkg.NEVRA() < r.pkgs[ids[j]].pkg.NEVRA()
+	})
+
+	t := &Transaction{}
+	done := map[int]bool{}
+	var visit func(id int)
+	visit = func(id int) {
+		if done[id] {
+			return
+		}
+		done[id] = true
+		for _, d := range hardDeps(r.pkgs[id].pkg) {
+			var provs []int
+			if rpm.IsRich(d.Name) {
+				if rd, err := rpm.ParseRich(d.Name); err == nil {
+					provs = r.richProviders(rd)
+				}
+			} else {
+				provs = r.whatProvides(d)
+			}
+			for _, q := range provs {
+				if install[q] {
+					visit(q)
+				}
+			}
+		}
+		reason := ReasonDependency
+		switch {
+		case r.requested[id]:
+			reason = ReasonRequested
+		case r.weak[id]:
+			reason = ReasonWeak
+		}
+		t.Install = append(t.Install, Step{Package: r.pkgs[id].pkg, Reason: reason})
+	}
+	for _, id := range ids {
+		visit(id)
+	}
+	return t
+}
@@ -0,0 +1,539 @@
+package solve
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"code.pikelabs.net/go/rpm"
+	"code.pikelabs.net/go/rpm/repo"
+)
+
+// newPackage returns package name-evr.arch with dependencies given as
+// "kind:dependency", like "R:foo >= 1" or "C:(bar or baz)". Kinds are
+// R, P, C, O, Rec and Sup.
+func newPackage(t *testing.T, nevra string, deps ...string) *repo.Package {
+	t.Helper()
+	dot := strings.LastIndexByte(nevra, '.')
+	nevr := nevra[:dot]
+	rel := strings.LastIndexByte(nevr, '-')
+	ver := strings.LastIndexByte(nevr[:rel], '-')
+	p := &repo.Package{
+		Name: nevr[:ver],
+		Arch: nevra[dot+1:],
+		EVR:  rpm.ParseEVR(nevr[ver+1:]),
+		Deps: map[rpm.DependencyKind][]rpm.Dependency{},
+	}
+	kinds := map[string]rpm.DependencyKind{
+		"R":   rpm.DepRequires,
+		"P":   rpm.DepProvides,
+		"C":   rpm.DepConflicts,
+		"O":   rpm.DepObsoletes,
+		"Rec": rpm.DepRecommends,
+		"Sup": rpm.DepSupplements,
+	}
+	for _, s := range deps {
+		i := strings.IndexByte(s, ':')
+		kind, ok := kinds[s[:i]]
+		if !ok {
+			t.Fatalf("unknown dependency kind %s", s)
+		}
+		d := rpm.Dependency{Name: s[i+1:]}
+		if !rpm.IsRich(d.Name) {
+			var err error
+			if d, err = rpm.ParseDependency(s[i+1:]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p.Deps[kind] = append(p.Deps[kind], d)
+	}
+	return p
+}
+
+type pkgs []string
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		available []pkgs
+		installed []pkgs
+		specs     []string
+		opts      Options
+		// install are NEVRAs of transaction in order, reasons are
+		// given as suffix: "!" requested, "~" weak
+		install []string
+		// err is error expected, rules are kinds of rules proof of
+		// unsatisfiable request has to mention
+		err   error
+		rules []RuleKind
+	}{
+		{
+			name: "dependencies first",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:libfoo.so.1()(64bit)"},
+				{"libfoo-1-1.x86_64", "P:libfoo.so.1()(64bit)", "R:/bin/sh"},
+				{"bash-5-1.x86_64", "P:/bin/sh"},
+			},
+			specs:   []string{"app"},
+			install: []string{"bash-5-1.x86_64", "libfoo-1-1.x86_64", "app-1-1.x86_64!"},
+		},
+		{
+			name: "newest version",
+			available: []pkgs{
+				{"foo-1-1.x86_64"},
+				{"foo-2-1.x86_64"},
+				{"foo-1.5-1.x86_64"},
+			},
+			specs:   []string{"foo"},
+			install: []string{"foo-2-1.x86_64!"},
+		},
+		{
+			name: "epoch wins over version",
+			available: []pkgs{
+				{"foo-1:1-1.x86_64"},
+				{"foo-2-1.x86_64"},
+			},
+			specs:   []string{"foo"},
+			install: []string{"foo-1:1-1.x86_64!"},
+		},
+		{
+			name: "requested version",
+			available: []pkgs{
+				{"foo-1-1.x86_64"},
+				{"foo-2-1.x86_64"},
+			},
+			specs:   []string{"foo-1-1.x86_64"},
+			install: []string{"foo-1-1.x86_64!"},
+		},
+		{
+			name: "versioned requirement",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:foo < 2"},
+				{"foo-1-1.x86_64"},
+				{"foo-2-1.x86_64"},
+			},
+			specs:   []string{"app"},
+			install: []string{"foo-1-1.x86_64", "app-1-1.x86_64!"},
+		},
+		{
+			name: "requested capability",
+			available: []pkgs{
+				{"foo-1-1.x86_64", "P:webserver = 1"},
+				{"bar-1-1.x86_64", "P:webserver = 2"},
+			},
+			specs:   []string{"webserver >= 2"},
+			install: []string{"bar-1-1.x86_64!"},
+		},
+		{
+			name: "installed satisfies requirement",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:libfoo"},
+				{"libfoo-2-1.x86_64"},
+			},
+			installed: []pkgs{{"libfoo-1-1.x86_64"}},
+			specs:     []string{"app"},
+			install:   []string{"app-1-1.x86_64!"},
+		},
+		{
+			name: "upgrade requires newer than installed",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:libfoo >= 2"},
+				{"libfoo-2-1.x86_64"},
+			},
+			installed: []pkgs{{"libfoo-1-1.x86_64"}},
+			specs:     []string{"app"},
+			// installed packages are kept, the newer one can't
+			// replace them
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleInstalled, RuleSameName, RuleRequires},
+		},
+		{
+			name: "upgrade of installed package",
+			available: []pkgs{
+				{"foo-2-1.x86_64"},
+			},
+			installed: []pkgs{{"foo-1-1.x86_64"}},
+			specs:     []string{"foo-2"},
+			err:       ErrUnsatisfiable,
+			rules:     []RuleKind{RuleJob, RuleInstalled, RuleSameName},
+		},
+		{
+			name: "conflict",
+			available: []pkgs{
+				{"foo-1-1.x86_64", "C:bar"},
+				{"bar-1-1.x86_64"},
+			},
+			specs: []string{"foo", "bar"},
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleJob, RuleConflicts},
+		},
+		{
+			name: "conflict with installed",
+			available: []pkgs{
+				{"foo-1-1.x86_64", "C:bar < 2"},
+			},
+			installed: []pkgs{{"bar-1-1.x86_64"}},
+			specs:     []string{"foo"},
+			err:       ErrUnsatisfiable,
+			rules:     []RuleKind{RuleJob, RuleInstalled, RuleConflicts},
+		},
+		{
+			name: "versioned conflict",
+			available: []pkgs{
+				{"foo-1-1.x86_64", "C:bar < 2"},
+				{"bar-2-1.x86_64"},
+			},
+			specs:   []string{"foo", "bar"},
+			install: []string{"bar-2-1.x86_64!", "foo-1-1.x86_64!"},
+		},
+		{
+			name: "conflict avoided by other provider",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:mta", "C:postfix"},
+				{"postfix-1-1.x86_64", "P:mta"},
+				{"sendmail-1-1.x86_64", "P:mta"},
+			},
+			specs:   []string{"app"},
+			install: []string{"sendmail-1-1.x86_64", "app-1-1.x86_64!"},
+		},
+		{
+			name: "obsoletes",
+			available: []pkgs{
+				{"new-2-1.x86_64", "O:old < 2"},
+				{"old-1-1.x86_64"},
+			},
+			specs: []string{"new", "old"},
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleJob, RuleObsoletes},
+		},
+		{
+			name: "obsoletes installed",
+			available: []pkgs{
+				{"new-2-1.x86_64", "O:old < 2"},
+			},
+			installed: []pkgs{{"old-1-1.x86_64"}},
+			specs:     []string{"new"},
+			err:       ErrUnsatisfiable,
+			rules:     []RuleKind{RuleInstalled, RuleObsoletes},
+		},
+		{
+			name: "obsoletes out of range",
+			available: []pkgs{
+				{"new-2-1.x86_64", "O:old < 2"},
+				{"old-2-1.x86_64"},
+			},
+			specs:   []string{"new", "old"},
+			install: []string{"new-2-1.x86_64!", "old-2-1.x86_64!"},
+		},
+		{
+			name: "obsoletes match names only",
+			available: []pkgs{
+				{"new-2-1.x86_64", "O:old"},
+				{"compat-1-1.x86_64", "P:old"},
+			},
+			specs:   []string{"new", "compat"},
+			install: []string{"compat-1-1.x86_64!", "new-2-1.x86_64!"},
+		},
+		{
+			name: "rich or",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:(foo or bar)"},
+				{"bar-1-1.x86_64"},
+			},
+			specs:   []string{"app"},
+			install: []string{"bar-1-1.x86_64", "app-1-1.x86_64!"},
+		},
+		{
+			name: "rich and",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:(foo and bar >= 2)"},
+				{"foo-1-1.x86_64"},
+				{"bar-1-1.x86_64"},
+				{"bar-2-1.x86_64"},
+			},
+			specs:   []string{"app"},
+			install: []string{"foo-1-1.x86_64", "bar-2-1.x86_64", "app-1-1.x86_64!"},
+		},
+		{
+			name: "rich if not triggered",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:(app-doc if man)"},
+				{"app-doc-1-1.noarch"},
+				{"man-1-1.x86_64"},
+			},
+			specs:   []string{"app"},
+			install: []string{"app-1-1.x86_64!"},
+		},
+		{
+			name: "rich if triggered",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:(app-doc if man)"},
+				{"app-doc-1-1.noarch"},
+				{"man-1-1.x86_64"},
+			},
+			specs:   []string{"app", "man"},
+			install: []string{"app-doc-1-1.noarch", "man-1-1.x86_64!", "app-1-1.x86_64!"},
+		},
+		{
+			name: "rich unsatisfiable",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:(foo and bar)"},
+				{"foo-1-1.x86_64"},
+			},
+			specs: []string{"app"},
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleJob, RuleRequires},
+		},
+		{
+			name: "rich conflict",
+			available: []pkgs{
+				{"app-1-1.x86_64", "C:(foo or bar)"},
+				{"bar-1-1.x86_64"},
+			},
+			specs: []string{"app", "bar"},
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleJob, RuleConflicts},
+		},
+		{
+			name: "rich request",
+			available: []pkgs{
+				{"foo-1-1.x86_64"},
+				{"bar-1-1.x86_64"},
+			},
+			specs:   []string{"(foo and bar)"},
+			install: []string{"bar-1-1.x86_64!", "foo-1-1.x86_64!"},
+		},
+		{
+			name: "weak dependencies",
+			available: []pkgs{
+				{"app-1-1.x86_64", "Rec:app-plugins"},
+				{"app-plugins-1-1.x86_64"},
+				{"app-langpack-1-1.noarch", "Sup:app"},
+			},
+			specs:   []string{"app"},
+			install: []string{"app-1-1.x86_64!", "app-langpack-1-1.noarch~", "app-plugins-1-1.x86_64~"},
+		},
+		{
+			name: "weak dependencies ignored",
+			available: []pkgs{
+				{"app-1-1.x86_64", "Rec:app-plugins"},
+				{"app-plugins-1-1.x86_64"},
+				{"app-langpack-1-1.noarch", "Sup:app"},
+			},
+			specs:   []string{"app"},
+			opts:    Options{IgnoreWeak: true},
+			install: []string{"app-1-1.x86_64!"},
+		},
+		{
+			name: "weak dependency left out on conflict",
+			available: []pkgs{
+				{"app-1-1.x86_64", "Rec:app-plugins", "C:app-plugins"},
+				{"app-plugins-1-1.x86_64"},
+			},
+			specs:   []string{"app"},
+			install: []string{"app-1-1.x86_64!"},
+		},
+		{
+			name: "best architecture",
+			available: []pkgs{
+				{"foo-1-1.i686"},
+				{"foo-1-1.x86_64"},
+				{"foo-1-1.aarch64"},
+			},
+			specs:   []string{"foo"},
+			install: []string{"foo-1-1.x86_64!"},
+		},
+		{
+			name: "multilib",
+			available: []pkgs{
+				{"foo-1-1.i686"},
+				{"foo-1-1.x86_64"},
+			},
+			specs:   []string{"foo.i686", "foo.x86_64"},
+			install: []string{"foo-1-1.i686!", "foo-1-1.x86_64!"},
+		},
+		{
+			name:      "incompatible architecture",
+			available: []pkgs{{"foo-1-1.aarch64"}},
+			specs:     []string{"foo"},
+			err:       ErrNoMatch,
+		},
+		{
+			name:      "no match",
+			available: []pkgs{{"foo-1-1.x86_64"}},
+			specs:     []string{"bar"},
+			err:       ErrNoMatch,
+		},
+		{
+			name: "missing requirement",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:libfoo"},
+			},
+			specs: []string{"app"},
+			err:   ErrUnsatisfiable,
+			rules: []RuleKind{RuleJob, RuleRequires},
+		},
+		{
+			name: "rpmlib requirements",
+			available: []pkgs{
+				{"app-1-1.x86_64", "R:rpmlib(PayloadIsZstd) <= 5.4.18-1"},
+			},
+			specs:   []string{"app"},
+			install: []string{"app-1-1.x86_64!"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool("x86_64")
+			for _, p := range tt.available {
+				pool.Add(newPackage(t, p[0], p[1:]...))
+			}
+			for _, p := range tt.installed {
+				pool.AddInstalled(newPackage(t, p[0], p[1:]...))
+			}
+			tr, err := pool.Resolve(tt.specs, &tt.opts)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, expected %v", err, tt.err)
+				}
+				checkProof(t, err, tt.rules)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range tr.Install {
+				nevra := s.Package.NEVRA()
+				switch s.Reason {
+				case ReasonRequested:
+					nevra += "!"
+				case ReasonWeak:
+					nevra += "~"
+				}
+				got = append(got, nevra)
+			}
+			if !reflect.DeepEqual(got, tt.install) {
+				t.Errorf("got %v, expected %v", got, tt.install)
+			}
+		})
+	}
+}
+
+// checkProof checks that unsatisfiable request is explained by rules
+// of kinds
+func checkProof(t *testing.T, err error, kinds []RuleKind) {
+	t.Helper()
+	var ue *UnsatError
+	if !errors.As(err, &ue) {
+		if len(kinds) > 0 {
+			t.Errorf("got %v, expected proof", err)
+		}
+		return
+	}
+	got := map[RuleKind]bool{}
+	for _, r := range ue.Rules {
+		got[r.Kind] = true
+		if len(r.String()) == 0 {
+			t.Errorf("rule %d formats empty", r.Kind)
+		}
+	}
+	for _, k := range kinds {
+		if !got[k] {
+			var s []string
+			for _, r := range ue.Rules {
+				s = append(s, r.String())
+			}
+			sort.Strings(s)
+			t.Errorf("proof lacks %s rule: %s", k, strings.Join(s, "; "))
+		}
+	}
+}
+
+func TestClosure(t *testing.T) {
+	pool := NewPool("x86_64")
+	for _, p := range []pkgs{
+		{"app-1-1.x86_64", "R:libfoo", "R:libbar"},
+		{"app-2-1.x86_64", "R:libfoo >= 2"},
+		{"libfoo-1-1.x86_64"},
+		{"tool-1-1.x86_64", "R:(libfoo or libbaz)", "R:(libqux and libfoo)"},
+	} {
+		pool.Add(newPackage(t, p[0], p[1:]...))
+	}
+	var all []*repo.Package
+	for _, e := range pool.pkgs {
+		all = append(all, e.pkg)
+	}
+
+	broken := pool.Closure(all, false)
+	got := map[string][]string{}
+	for _, b := range broken {
+		for _, d := range b.Unresolved {
+			got[b.Package.NEVRA()] = append(got[b.Package.NEVRA()], d.String())
+		}
+	}
+	expected := map[string][]string{
+		"app-1-1.x86_64":  {"libbar"},
+		"app-2-1.x86_64":  {"libfoo >= 2"},
+		"tool-1-1.x86_64": {"(libqux and libfoo)"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+
+	// only newest version of package is checked
+	broken = pool.Closure(all, true)
+	if len(broken) != 2 || broken[0].Package.NEVRA() != "app-2-1.x86_64" {
+		t.Errorf("newest: got %+v", broken)
+	}
+}
+
+func TestUnresolvedRich(t *testing.T) {
+	pool := NewPool("x86_64")
+	pool.Add(newPackage(t, "a-1-1.x86_64"))
+	pool.Add(newPackage(t, "b-1-1.x86_64", "P:feature"))
+	sh := newPackage(t, "bash-5-1.x86_64")
+	sh.Files = []repo.File{{Path: "/usr/bin/bash"}}
+	pool.Add(sh)
+
+	tests := []struct {
+		dep      string
+		resolved bool
+	}{
+		{"/usr/bin/bash", true},
+		{"/usr/bin/zsh", false},
+		{"(/usr/bin/bash or /usr/bin/zsh)", true},
+		{"(b with feature)", true},
+		{"(a with feature)", false},
+		{"(a without feature)", true},
+		{"(b without feature)", false},
+		// then branch is needed only when condition is available
+		{"(a if b)", true},
+		{"(missing if b)", false},
+		{"(missing if none)", true},
+		// else branch may always be needed
+		{"(a if b else c)", false},
+		{"(a if none else b)", true},
+		{"(missing if none else b)", true},
+		{"(missing if b else a)", false},
+		// unless is if with negated condition
+		{"(a unless b)", true},
+		{"(missing unless b)", false},
+		{"(missing unless none)", false},
+		{"(a unless b else c)", false},
+		{"(a unless none else missing)", true},
+		{"(a unless b else missing)", false},
+		{"(missing unless b else a)", false},
+		{"(a unless b else b)", true},
+	}
+	for _, tt := range tests {
+		pkg := newPackage(t, "app-1-1.x86_64", "R:"+tt.dep)
+		deps := pool.Unresolved(pkg)
+		if resolved := len(deps) == 0; resolved != tt.resolved {
+			t.Errorf("%s: got resolved %v, expected %v", tt.dep, resolved, tt.resolved)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package rpm
+
+// HeaderNames maps main header tags to names rpm knows them by
+var HeaderNames = map[HeaderTag]string{
+	TagName:                        "RPMTAG_NAME",
+	TagVersion:                     "RPMTAG_VERSION",
+	TagRelease:                     "RPMTAG_RELEASE",
+	TagEpoch:                       "RPMTAG_EPOCH",
+	TagSummary:                     "RPMTAG_SUMMARY",
+	TagDescription:                 "RPMTAG_DESCRIPTION",
+	TagBuildTime:                   "RPMTAG_BUILDTIME",
+	TagBuildHost:                   "RPMTAG_BUILDHOST",
+	TagInstallTime:                 "RPMTAG_INSTALLTIME",
+	TagSize:                        "RPMTAG_SIZE",
+	TagDistribution:                "RPMTAG_DISTRIBUTION",
+	TagVendor:                      "RPMTAG_VENDOR",
+	TagGif:                         "RPMTAG_GIF",
+	TagXmp:                         "RPMTAG_XMP",
+	TagLicense:                     "RPMTAG_LICENSE",
+	TagPackager:                    "RPMTAG_PACKAGER",
+	TagGroup:                       "RPMTAG_GROUP",
+	TagChangelog:                   "RPMTAG_CHANGELOG",
+	TagSource:                      "RPMTAG_SOURCE",
+	TagPatch:                       "RPMTAG_PATCH",
+	TagURL:                         "RPMTAG_URL",
+	TagOS:                          "RPMTAG_OS",
+	TagArch:                        "RPMTAG_ARCH",
+	TagPrein:                       "RPMTAG_PREIN",
+	TagPostin:                      "RPMTAG_POSTIN",
+	TagPreun:                       "RPMTAG_PREUN",
+	TagPostun:                      "RPMTAG_POSTUN",
+	TagOldFilenames:                "RPMTAG_OLDFILENAMES",
+	TagFileSizes:                   "RPMTAG_FILESIZES",
+	TagFileStates:                  "RPMTAG_FILESTATES",
+	TagFileModes:                   "RPMTAG_FILEMODES",
+	TagFileUIDs:                    "RPMTAG_FILEUIDS",
+	TagFileGIDs:                    "RPMTAG_FILEGIDS",
+	TagFileRDevs:                   "RPMTAG_FILERDEVS",
+	TagFileMTimes:                  "RPMTAG_FILEMTIMES",
+	TagFileMD5S:                    "RPMTAG_FILEDIGESTS",
+	TagFileLinkTos:                 "RPMTAG_FILELINKTOS",
+	TagFileFlags:                   "RPMTAG_FILEFLAGS",
+	TagRoot:                        "RPMTAG_ROOT",
+	TagFileUsername:                "RPMTAG_FILEUSERNAME",
+	TagFileGroupname:               "RPMTAG_FILEGROUPNAME",
+	TagExclude:                     "RPMTAG_EXCLUDE",
+	TagExclusive:                   "RPMTAG_EXCLUSIVE",
+	TagIcon:                        "RPMTAG_ICON",
+	TagSourceRPM:                   "RPMTAG_SOURCERPM",
+	TagFileVerifyFlags:             "RPMTAG_FILEVERIFYFLAGS",
+	TagArchiveSize:                 "RPMTAG_ARCHIVESIZE",
+	TagProvideName:                 "RPMTAG_PROVIDENAME",
+	TagRequireFlags:                "RPMTAG_REQUIREFLAGS",
+	TagRequireName:                 "RPMTAG_REQUIRENAME",
+	TagRequireVersion:              "RPMTAG_REQUIREVERSION",
+	TagNoSource:                    "RPMTAG_NOSOURCE",
+	TagNoPatch:                     "RPMTAG_NOPATCH",
+	TagConflictFlags:               "RPMTAG_CONFLICTFLAGS",
+	TagConflictName:                "RPMTAG_CONFLICTNAME",
+	TagConflictVersion:             "RPMTAG_CONFLICTVERSION",
+	TagDefaultPrefix:               "RPMTAG_DEFAULTPREFIX",
+	TagBuildroot:                   "RPMTAG_BUILDROOT",
+	TagInstallPrefix:               "RPMTAG_INSTALLPREFIX",
+	TagExclusiveArch:               "RPMTAG_EXCLUDEARCH",
+	TagExclusiveOS:                 "RPMTAG_EXCLUDEOS",
+	TagEnclusiveArch:               "RPMTAG_EXCLUSIVEARCH",
+	TagEnclusiveOS:                 "RPMTAG_EXCLUSIVEOS",
+	TagAutoReqProv:                 "RPMTAG_AUTOREQPROV",
+	TagRPMVersion:                  "RPMTAG_RPMVERSION",
+	TagTriggerScripts:              "RPMTAG_TRIGGERSCRIPTS",
+	TagTriggerName:                 "RPMTAG_TRIGGERNAME",
+	TagTriggerVersion:              "RPMTAG_TRIGGERVERSION",
+	TagTriggerFlags:                "RPMTAG_TRIGGERFLAGS",
+	TagTriggerIndex:                "RPMTAG_TRIGGERINDEX",
+	TagVerifyScript:                "RPMTAG_VERIFYSCRIPT",
+	TagChangelogTime:               "RPMTAG_CHANGELOGTIME",
+	TagChangelogName:               "RPMTAG_CHANGELOGNAME",
+	TagChangelogText:               "RPMTAG_CHANGELOGTEXT",
+	TagBrokenMD5:                   "RPMTAG_BROKENMD5",
+	TagPrereq:                      "RPMTAG_PREREQ",
+	TagPreinProg:                   "RPMTAG_PREINPROG",
+	TagPostinProg:                  "RPMTAG_POSTINPROG",
+	TagPreunProg:                   "RPMTAG_PREUNPROG",
+	TagPostunProg:                  "RPMTAG_POSTUNPROG",
+	TagBuildArchs:                  "RPMTAG_BUILDARCHS",
+	TagObsoleteName:                "RPMTAG_OBSOLETENAME",
+	TagVerifyScriptProg:            "RPMTAG_VERIFYSCRIPTPROG",
+	TagTriggerScriptProg:           "RPMTAG_TRIGGERSCRIPTPROG",
+	TagDocdir:                      "RPMTAG_DOCDIR",
+	TagCookie:                      "RPMTAG_COOKIE",
+	TagFileDevices:                 "RPMTAG_FILEDEVICES",
+	TagFileInodes:                  "RPMTAG_FILEINODES",
+	TagFileLangs:                   "RPMTAG_FILELANGS",
+	TagPrefixes:                    "RPMTAG_PREFIXES",
+	TagInstPrefixes:                "RPMTAG_INSTPREFIXES",
+	TagTriggerin:                   "RPMTAG_TRIGGERIN",
+	TagTriggerun:                   "RPMTAG_TRIGGERUN",
+	TagTriggerpostun:               "RPMTAG_TRIGGERPOSTUN",
+	TagAutoreq:                     "RPMTAG_AUTOREQ",
+	TagAutoprov:                    "RPMTAG_AUTOPROV",
+	TagCapability:                  "RPMTAG_CAPABILITY",
+	TagSourcePackage:               "RPMTAG_SOURCEPACKAGE",
+	TagOldOrigFilenames:            "RPMTAG_OLDORIGFILENAMES",
+	TagBuildPrereq:                 "RPMTAG_BUILDPREREQ",
+	TagBuildRequires:               "RPMTAG_BUILDREQUIRES",
+	TagBuildConflicts:              "RPMTAG_BUILDCONFLICTS",
+	TagBuildMacros:                 "RPMTAG_BUILDMACROS",
+	TagProvideFlags:                "RPMTAG_PROVIDEFLAGS",
+	TagProvideVersion:              "RPMTAG_PROVIDEVERSION",
+	TagObsoleteFlags:               "RPMTAG_OBSOLETEFLAGS",
+	TagObsoleteVersion:             "RPMTAG_OBSOLETEVERSION",
+	TagDirIndexes:                  "RPMTAG_DIRINDEXES",
+	TagBaseNames:                   "RPMTAG_BASENAMES",
+	TagDirNames:                    "RPMTAG_DIRNAMES",
+	TagOrigDirIndexes:              "RPMTAG_ORIGDIRINDEXES",
+	TagOrigBaseNames:               "RPMTAG_ORIGBASENAMES",
+	TagOrigdirNames:                "RPMTAG_ORIGDIRNAMES",
+	TagOptFlags:                    "RPMTAG_OPTFLAGS",
+	TagDistURL:                     "RPMTAG_DISTURL",
+	TagPayloadFormat:               "RPMTAG_PAYLOADFORMAT",
+	TagPayloadCompressor:           "RPMTAG_PAYLOADCOMPRESSOR",
+	TagPayloadFlags:                "RPMTAG_PAYLOADFLAGS",
+	TagInstallColor:                "RPMTAG_INSTALLCOLOR",
+	TagInstallTID:                  "RPMTAG_INSTALLTID",
+	TagRemoveTID:                   "RPMTAG_REMOVETID",
+	TagSha1RHN:                     "RPMTAG_SHA1RHN",
+	TagRHNPlatform:                 "RPMTAG_RHNPLATFORM",
+	TagPlatform:                    "RPMTAG_PLATFORM",
+	TagPatchesName:                 "RPMTAG_PATCHESNAME",
+	TagPatchesFlags:                "RPMTAG_PATCHESFLAGS",
+	TagPatchesVersion:              "RPMTAG_PATCHESVERSION",
+	TagCacheCtime:                  "RPMTAG_CACHECTIME",
+	TagCachePkgPath:                "RPMTAG_CACHEPKGPATH",
+	TagCachePkgSize:                "RPMTAG_CACHEPKGSIZE",
+	TagCachePkgMtime:               "RPMTAG_CACHEPKGMTIME",
+	TagFileColors:                  "RPMTAG_FILECOLORS",
+	TagFileClass:                   "RPMTAG_FILECLASS",
+	TagClassDict:                   "RPMTAG_CLASSDICT",
+	TagFileDependsX:                "RPMTAG_FILEDEPENDSX",
+	TagFileDependsN:                "RPMTAG_FILEDEPENDSN",
+	TagDependsDict:                 "RPMTAG_DEPENDSDICT",
+	TagSourcePkgID:                 "RPMTAG_SOURCEPKGID",
+	TagFileContexts:                "RPMTAG_FILECONTEXTS",
+	TagFsContexts:                  "RPMTAG_FSCONTEXTS",
+	TagReContexts:                  "RPMTAG_RECONTEXTS",
+	TagPolicies:                    "RPMTAG_POLICIES",
+	TagPretrans:                    "RPMTAG_PRETRANS",
+	TagPosttrans:                   "RPMTAG_POSTTRANS",
+	TagPretransProg:                "RPMTAG_PRETRANSPROG",
+	TagPosttransProg:               "RPMTAG_POSTTRANSPROG",
+	TagLongFileSizes:               "RPMTAG_LONGFILESIZES",
+	TagLongSize:                    "RPMTAG_LONGSIZE",
+	TagPreinFlags:                  "RPMTAG_PREINFLAGS",
+	TagPostinFlags:                 "RPMTAG_POSTINFLAGS",
+	TagPreunFlags:                  "RPMTAG_PREUNFLAGS",
+	TagPostunFlags:                 "RPMTAG_POSTUNFLAGS",
+	TagPretransFlags:               "RPMTAG_PRETRANSFLAGS",
+	TagPosttransFlags:              "RPMTAG_POSTTRANSFLAGS",
+	TagVerifyScriptFlags:           "RPMTAG_VERIFYSCRIPTFLAGS",
+	TagTriggerScriptFlags:          "RPMTAG_TRIGGERSCRIPTFLAGS",
+	TagFileTriggerScripts:          "RPMTAG_FILETRIGGERSCRIPTS",
+	TagFileTriggerScriptProg:       "RPMTAG_FILETRIGGERSCRIPTPROG",
+	TagFileTriggerScriptFlags:      "RPMTAG_FILETRIGGERSCRIPTFLAGS",
+	TagFileTriggerName:             "RPMTAG_FILETRIGGERNAME",
+	TagFileTriggerIndex:            "RPMTAG_FILETRIGGERINDEX",
+	TagFileTriggerVersion:          "RPMTAG_FILETRIGGERVERSION",
+	TagFileTriggerFlags:            "RPMTAG_FILETRIGGERFLAGS",
+	TagTransFileTriggerScripts:     "RPMTAG_TRANSFILETRIGGERSCRIPTS",
+	TagTransFileTriggerScriptProg:  "RPMTAG_TRANSFILETRIGGERSCRIPTPROG",
+	TagTransFileTriggerScriptFlags: "RPMTAG_TRANSFILETRIGGERSCRIPTFLAGS",
+	TagTransFileTriggerName:        "RPMTAG_TRANSFILETRIGGERNAME",
+	TagTransFileTriggerIndex:       "RPMTAG_TRANSFILETRIGGERINDEX",
+	TagTransFileTriggerVersion:     "RPMTAG_TRANSFILETRIGGERVERSION",
+	TagTransFileTriggerFlags:       "RPMTAG_TRANSFILETRIGGERFLAGS",
+	TagRemovePathPostfixes:         "RPMTAG_REMOVEPATHPOSTFIXES",
+	TagFileTriggerPriorities:       "RPMTAG_FILETRIGGERPRIORITIES",
+	TagTransFileTriggerPriorities:  "RPMTAG_TRANSFILETRIGGERPRIORITIES",
+	TagPayloadDigest:               "RPMTAG_PAYLOADDIGEST",
+	TagPayloadDigestAlgo:           "RPMTAG_PAYLOADDIGESTALGO",
+	TagPayloadDigestAlt:            "RPMTAG_PAYLOADDIGESTALT",
+	TagPayloadSize:                 "RPMTAG_PAYLOADSIZE",
+	TagPayloadSizeAlt:              "RPMTAG_PAYLOADSIZEALT",
+	TagRPMFormat:                   "RPMTAG_RPMFORMAT",
+	TagPayloadSHA512:               "RPMTAG_PAYLOADSHA512",
+	TagPayloadSHA512Alt:            "RPMTAG_PAYLOADSHA512ALT",
+	TagPayloadSHA3_256:             "RPMTAG_PAYLOADSHA3_256",
+	TagPayloadSHA3_256Alt:          "RPMTAG_PAYLOADSHA3_256ALT",
+	TagHeaderImage:                 "RPMTAG_HEADERIMAGE",
+	TagHeaderSignatures:            "RPMTAG_HEADERSIGNATURES",
+	TagHeaderImmutable:             "RPMTAG_HEADERIMMUTABLE",
+	TagHeaderRegions:               "RPMTAG_HEADERREGIONS",
+	TagHeaderI18NTable:             "RPMTAG_HEADERI18NTABLE",
+	TagFileCaps:                    "RPMTAG_FILECAPS",
+	TagFileDigestAlgo:              "RPMTAG_FILEDIGESTALGO",
+	TagFilenames:                   "RPMTAG_FILENAMES",
+	TagRecommendName:               "RPMTAG_RECOMMENDNAME",
+	TagRecommendVersion:            "RPMTAG_RECOMMENDVERSION",
+	TagRecommendFlags:              "RPMTAG_RECOMMENDFLAGS",
+	TagSuggestName:                 "RPMTAG_SUGGESTNAME",
+	TagSuggestVersion:              "RPMTAG_SUGGESTVERSION",
+	TagSuggestFlags:                "RPMTAG_SUGGESTFLAGS",
+	TagSupplementName:              "RPMTAG_SUPPLEMENTNAME",
+	TagSupplementVersion:           "RPMTAG_SUPPLEMENTVERSION",
+	TagSupplementFlags:             "RPMTAG_SUPPLEMENTFLAGS",
+	TagEnhanceName:                 "RPMTAG_ENHANCENAME",
+	TagEnhanceVersion:              "RPMTAG_ENHANCEVERSION",
+	TagEnhanceFlags:                "RPMTAG_ENHANCEFLAGS",
+}
@@ -0,0 +1,209 @@
+package rpm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// HeaderLimits bounds what ReadHeader accepts from the input, so
+// malformed header can't make it allocate arbitrary amount of memory
+type HeaderLimits struct {
+	MaxIndexEntries int
+	MaxDataSize     int
+}
+
+// DefaultHeaderLimits are the limits rpm itself enforces
+var DefaultHeaderLimits = HeaderLimits{
+	MaxIndexEntries: 0xffff,
+	MaxDataSize:     0x0fffffff,
+}
+
+// Region tags, region entry is the first one in the index and its data
+// is trailer entry with negative offset covering the region
+const (
+	TagHeaderImage      HeaderTag = 61
+	TagHeaderSignatures HeaderTag = 62
+	TagHeaderImmutable  HeaderTag = 63
+	TagHeaderRegions    HeaderTag = 64
+	TagHeaderI18NTable  HeaderTag = 100
+)
+
+const (
+	regionTagType  = DataTypeBin
+	regionTagCount = HeaderIndexEntrySize
+
+	dataTypeMax = DataTypeI18NString
+)
+
+// LimitError is returned when header exceeds HeaderLimits
+type LimitError struct {
+	What  string
+	Value int64
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("header %s %d exceeds limit %d", e.What, e.Value, e.Limit)
+}
+
+// ValidationError describes index entry which doesn't describe
+// data of the header correctly
+type ValidationError struct {
+	Entry int
+	Tag   HeaderTag
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid header entry %d (tag %d): %v", e.Entry, e.Tag, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks index of the header against its data the same way
+// rpm does on import: types, counts, alignment, bounds and ordering
+// of every entry along with region tag and its trailer
+func (h Header) Validate() error {
+	if len(h.indexes) < 1 {
+		return &ValidationError{Entry: -1, Err: errors.New("no tags")}
+	}
+
+	start, rdl := 0, int64(-1)
+	if isRegionTag(h.indexes[0].Tag) {
+		var err error
+		if rdl, err = h.validateRegion(); err != nil {
+			return err
+		}
+		start = 1
+	}
+
+	dl := int64(len(h.data))
+	var end int64
+	for i := start; i < len(h.indexes); i++ {
+		e := &h.indexes[i]
+		fail := func(format string, a ...interface{}) error {
+			return &ValidationError{Entry: i, Tag: e.Tag, Err: fmt.Errorf(format, a...)}
+		}
+		off := int64(e.Offset)
+		if off < 0 || off > dl {
+			return fail("%w: offset %d", ErrOutOfRange, off)
+		}
+		if end > off {
+			return fail("data overlaps previous entry")
+		}
+		if e.Tag < TagHeaderI18NTable {
+			return fail("invalid tag")
+		}
+		if e.DataType < DataTypeNull || e.DataType > dataTypeMax {
+			return fail("invalid type %d", e.DataType)
+		}
+		if e.Count < 1 || int64(e.Count) > dl {
+			return fail("invalid count %d", e.Count)
+		}
+		if a := typeAlign(e.DataType); off%a != 0 {
+			return fail("offset %d not aligned to %d", off, a)
+		}
+		n, err := dataLength(e, h.data)
+		if err != nil {
+			return &ValidationError{Entry: i, Tag: e.Tag, Err: err}
+		}
+		end = off + n
+		if rdl >= 0 && end > rdl-regionTagCount && off < rdl {
+			return fail("data overlaps region trailer")
+		}
+	}
+	return nil
+}
+
+func isRegionTag(t HeaderTag) bool {
+	return t == TagHeaderImage || t == TagHeaderSignatures || t == TagHeaderImmutable
+}
+
+// validateRegion verifies region entry with its trailer and returns
+// length of data covered by region
+func (h Header) validateRegion() (int64, error) {
+	e := h.indexes[0]
+	fail := func(reason string) (int64, error) {
+		return 0, &ValidationError{Entry: 0, Tag: e.Tag, Err: errors.New(reason)}
+	}
+	if e.DataType != regionTagType || e.Count != regionTagCount {
+		return fail("invalid region tag")
+	}
+	rdl := int64(e.Offset) + regionTagCount
+	if e.Offset < 0 || rdl > int64(len(h.data)) {
+		return fail("invalid region offset")
+	}
+
+	var trailer HeaderIndexEntry
+	trailer.UnmarshalBinary(h.data[e.Offset:rdl])
+	// some old packages have HEADERIMAGE in signature region trailer
+	if e.Tag == TagHeaderSignatures && trailer.Tag == TagHeaderImage {
+		trailer.Tag = TagHeaderSignatures
+	}
+	if trailer.Tag != e.Tag || trailer.DataType != regionTagType || trailer.Count != regionTagCount {
+		return fail("invalid region trailer")
+	}
+	// trailer offset is negative size of region index
+	ril := -int64(trailer.Offset)
+	if ril%HeaderIndexEntrySize != 0 || ril/HeaderIndexEntrySize < 1 || ril/HeaderIndexEntrySize > int64(len(h.indexes)) {
+		return fail("invalid region size")
+	}
+	return rdl, nil
+}
+
+func typeAlign(dt HeaderDataType) int64 {
+	switch dt {
+	case DataTypeInt16:
+		return 2
+	case DataTypeInt32:
+		return 4
+	case DataTypeInt64:
+		return 8
+	}
+	return 1
+}
+
+// dataLength returns length of entry data, making sure it fits
+// into the data of the header
+func dataLength(idx *HeaderIndexEntry, d []byte) (int64, error) {
+	off, cnt, dl := int64(idx.Offset), int64(idx.Count), int64(len(d))
+	if off < 0 || off > dl || cnt < 0 {
+		return 0, fmt.Errorf("%w: offset %d", ErrOutOfRange, off)
+	}
+
+	var n int64
+	switch idx.DataType {
+	case DataTypeNull:
+		return 0, nil
+	case DataTypeChar, DataTypeInt8, DataTypeBin:
+		n = cnt
+	case DataTypeInt16:
+		n = 2 * cnt
+	case DataTypeInt32:
+		n = 4 * cnt
+	case DataTypeInt64:
+		n = 8 * cnt
+	case DataTypeString, DataTypeStringArray, DataTypeI18NString:
+		if idx.DataType == DataTypeString && cnt != 1 {
+			return 0, fmt.Errorf("%w: string with count %d", ErrCountMismatch, cnt)
+		}
+		end := off
+		for ; cnt > 0; cnt-- {
+			next := bytes.IndexByte(d[end:], 0)
+			if next < 0 {
+				return 0, fmt.Errorf("%w: unterminated string", ErrTruncated)
+			}
+			end += int64(next) + 1
+		}
+		return end - off, nil
+	default:
+		return 0, fmt.Errorf("%w: data type %d", ErrOutOfRange, idx.DataType)
+	}
+	if n > dl-off {
+		return 0, fmt.Errorf("%w: %d bytes at offset %d", ErrTruncated, n, off)
+	}
+	return n, nil
+}
@@ -0,0 +1,214 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// validHeader is region, NAME string at 0, SIZE int32 at 8 and
+// region trailer at 12
+func validHeader(t *testing.T) []byte {
+	h := NewHeader(TagHeaderImmutable)
+	h.SetString(TagName, "hello")
+	h.SetInt32s(TagSize, []int32{42})
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// entry returns index entry i of marshaled header
+func entry(d []byte, i int) []byte {
+	return d[headerInfoSize+i*HeaderIndexEntrySize:]
+}
+
+// data returns data of marshaled header with 3 index entries
+func data(d []byte) []byte {
+	return d[headerInfoSize+3*HeaderIndexEntrySize:]
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mangle func(d []byte)
+		entry  int
+		err    error
+		reason string
+	}{
+		{
+			name:   "offset past data",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 1)[8:], 100) },
+			entry:  1, err: ErrOutOfRange,
+		},
+		{
+			name:   "negative offset",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[8:], 0xfffffff8) },
+			entry:  2, err: ErrOutOfRange,
+		},
+		{
+			name:   "count overflow",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[12:], 0x40000000) },
+			entry:  2, reason: "invalid count",
+		},
+		{
+			name:   "data past end",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[12:], 6) },
+			entry:  2, err: ErrTruncated,
+		},
+		{
+			name:   "zero count",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[12:], 0) },
+			entry:  2, reason: "invalid count",
+		},
+		{
+			name:   "misaligned int32",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[8:], 9) },
+			entry:  2, reason: "not aligned",
+		},
+		{
+			name:   "invalid type",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 1)[4:], 10) },
+			entry:  1, reason: "invalid type",
+		},
+		{
+			name:   "string array as string",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 1)[12:], 2) },
+			entry:  1, err: ErrCountMismatch,
+		},
+		{
+			name:   "overlapping entries",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[8:], 4) },
+			entry:  2, reason: "overlaps previous entry",
+		},
+		{
+			name:   "data in region trailer",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 2)[8:], 12) },
+			entry:  2, reason: "region trailer",
+		},
+		{
+			name:   "tag below i18n table",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 1)[0:], 99) },
+			entry:  1, reason: "invalid tag",
+		},
+		{
+			name:   "bad region type",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 0)[4:], uint32(DataTypeInt32)) },
+			entry:  0, reason: "invalid region tag",
+		},
+		{
+			name:   "region offset past data",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(entry(d, 0)[8:], 16) },
+			entry:  0, reason: "invalid region offset",
+		},
+		{
+			name:   "bad region trailer tag",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(data(d)[12:], uint32(TagHeaderImage)) },
+			entry:  0, reason: "invalid region trailer",
+		},
+		{
+			// trailer offset -64 claims 4 entries
+			name:   "region larger than index",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(data(d)[20:], 0xffffffc0) },
+			entry:  0, reason: "invalid region size",
+		},
+		{
+			// trailer offset -47
+			name:   "region size not multiple of entry",
+			mangle: func(d []byte) { binary.BigEndian.PutUint32(data(d)[20:], 0xffffffd1) },
+			entry:  0, reason: "invalid region size",
+		},
+	}
+
+	for _, tt := range tests {
+		d := validHeader(t)
+		tt.mangle(d)
+		_, err := ReadHeader(bytes.NewReader(d))
+		var fe *FormatError
+		var ve *ValidationError
+		if !errors.As(err, &fe) || !errors.As(err, &ve) {
+			t.Errorf("%s: got %v, expected validation error", tt.name, err)
+			continue
+		}
+		if ve.Entry != tt.entry {
+			t.Errorf("%s: error in entry %d, expected %d", tt.name, ve.Entry, tt.entry)
+		}
+		if offset := int64(headerInfoSize + tt.entry*HeaderIndexEntrySize); fe.Offset != offset {
+			t.Errorf("%s: error at offset %d, expected %d", tt.name, fe.Offset, offset)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, expected %v", tt.name, err, tt.err)
+		}
+		if !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s: got %v, expected %q", tt.name, err, tt.reason)
+		}
+	}
+}
+
+func TestValidateUnterminated(t *testing.T) {
+	h := NewHeader(0)
+	h.SetString(TagName, "hello")
+	h.data[len(h.data)-1] = 'x'
+	if err := h.Validate(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, expected %v", err, ErrTruncated)
+	}
+}
+
+func TestValidateEmpty(t *testing.T) {
+	var ve *ValidationError
+	if err := (Header{}).Validate(); !errors.As(err, &ve) || ve.Entry != -1 {
+		t.Errorf("got %v, expected error without entry", err)
+	}
+}
+
+func TestHeaderLimits(t *testing.T) {
+	d := validHeader(t)
+	il, dl := 3, len(data(d))
+
+	tests := []struct {
+		name   string
+		limits HeaderLimits
+		what   string
+		value  int64
+	}{
+		{"at limits", HeaderLimits{MaxIndexEntries: il, MaxDataSize: dl}, "", 0},
+		{"index entries", HeaderLimits{MaxIndexEntries: il - 1, MaxDataSize: dl}, "index entries", int64(il)},
+		{"data size", HeaderLimits{MaxIndexEntries: il, MaxDataSize: dl - 1}, "data size", int64(dl)},
+	}
+	for _, tt := range tests {
+		_, err := ReadHeaderWithLimits(bytes.NewReader(d), tt.limits)
+		if tt.what == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || le.What != tt.what || le.Value != tt.value {
+			t.Errorf("%s: got %v, expected %s %d over limit", tt.name, err, tt.what, tt.value)
+		}
+	}
+}
+
+// TestHeaderLimitsBeforeRead makes sure huge claimed sizes are
+// refused without reading or allocating anything
+func TestHeaderLimitsBeforeRead(t *testing.T) {
+	for _, info := range [][2]uint32{{0x10000, 0}, {1, 0x10000000}, {0xffffffff, 0xffffffff}} {
+		var b bytes.Buffer
+		binary.Write(&b, binary.BigEndian, headerInfo{Magic: HeaderMagic, IndexCnt: info[0], Size: info[1]})
+		var le *LimitError
+		if _, err := ReadHeader(&b); !errors.As(err, &le) {
+			t.Errorf("il %d dl %d: got %v, expected limit error", info[0], info[1], err)
+		}
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, [2]uint32{0xffffffff, 0})
+	var le *LimitError
+	if _, err := ReadHeaderBlob(&b, DefaultHeaderLimits); !errors.As(err, &le) {
+		t.Errorf("blob: got %v, expected limit error", err)
+	}
+}
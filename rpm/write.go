@@ -0,0 +1,176 @@
+package rpm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// NewHeader creates empty header for building packages. region is
+// the region tag header data is sealed with, TagHeaderImmutable for
+// main package header and TagHeaderSignatures for signature header,
+// or 0 for header without region.
+func NewHeader(region HeaderTag) *Header {
+	h := &Header{}
+	if region != 0 {
+		h.indexes = []HeaderIndexEntry{{Tag: region, DataType: regionTagType, Count: regionTagCount}}
+	}
+	h.rebuild(nil)
+	return h
+}
+
+func (h *Header) SetString(t HeaderTag, s string) {
+	h.set(t, DataTypeString, 1, []byte(s+"\x00"))
+}
+
+func (h *Header) SetStrings(t HeaderTag, s []string) {
+	h.set(t, DataTypeStringArray, len(s), []byte(strings.Join(s, "\x00")+"\x00"))
+}
+
+// SetI18NStrings sets localized string, s holds translations in the
+// order of locales in HEADERI18NTABLE
+func (h *Header) SetI18NStrings(t HeaderTag, s []string) {
+	h.set(t, DataTypeI18NString, len(s), []byte(strings.Join(s, "\x00")+"\x00"))
+}
+
+func (h *Header) SetBin(t HeaderTag, d []byte) {
+	h.set(t, DataTypeBin, len(d), append([]byte(nil), d...))
+}
+
+func (h *Header) SetInt16s(t HeaderTag, v []int16) {
+	d := make([]byte, 2*len(v))
+	for i, n := range v {
+		binary.BigEndian.PutUint16(d[2*i:], uint16(n))
+	}
+	h.set(t, DataTypeInt16, len(v), d)
+}
+
+func (h *Header) SetInt32s(t HeaderTag, v []int32) {
+	d := make([]byte, 4*len(v))
+	for i, n := range v {
+		binary.BigEndian.PutUint32(d[4*i:], uint32(n))
+	}
+	h.set(t, DataTypeInt32, len(v), d)
+}
+
+func (h *Header) SetInt64s(t HeaderTag, v []int64) {
+	d := make([]byte, 8*len(v))
+	for i, n := range v {
+		binary.BigEndian.PutUint64(d[8*i:], uint64(n))
+	}
+	h.set(t, DataTypeInt64, len(v), d)
+}
+
+// DeleteTag removes tag from header, it's no-op for missing tags
+func (h *Header) DeleteTag(t HeaderTag) {
+	entries := h.entries()
+	for i, e := range entries {
+		if e.idx.Tag == t {
+			h.rebuild(append(entries[:i], entries[i+1:]...))
+			return
+		}
+	}
+}
+
+type headerEntry struct {
+	idx  HeaderIndexEntry
+	data []byte
+}
+
+// entries returns tags of header along with their data, region
+// tag is left out
+func (h *Header) entries() []headerEntry {
+	var res []headerEntry
+	for _, idx := range h.indexes {
+		if isRegionTag(idx.Tag) {
+			continue
+		}
+		d, _ := readData(&idx, h.data)
+		res = append(res, headerEntry{idx: idx, data: d})
+	}
+	return res
+}
+
+func (h *Header) set(t HeaderTag, dt HeaderDataType, count int, d []byte) {
+	entries := h.entries()
+	e := headerEntry{idx: HeaderIndexEntry{Tag: t, DataType: dt, Count: int32(count)}, data: d}
+	for i := range entries {
+		if entries[i].idx.Tag == t {
+			entries[i] = e
+			h.rebuild(entries)
+			return
+		}
+	}
+	h.rebuild(append(entries, e))
+}
+
+// rebuild lays out data of entries the way rpm does, index sorted by
+// tag after region entry, as rpm looks tags up with binary search, and
+// each entry aligned to its type with region trailer at the very end
+func (h *Header) rebuild(entries []headerEntry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].idx.Tag < entries[j].idx.Tag })
+
+	var region *HeaderIndexEntry
+	if len(h.indexes) > 0 && isRegionTag(h.indexes[0].Tag) {
+		region = &HeaderIndexEntry{}
+		*region = h.indexes[0]
+	}
+
+	indexes := make([]HeaderIndexEntry, 0, len(entries)+1)
+	if region != nil {
+		indexes = append(indexes, *region)
+	}
+	var data []byte
+	for _, e := range entries {
+		for a := typeAlign(e.idx.DataType); int64(len(data))%a != 0; {
+			data = append(data, 0)
+		}
+		e.idx.Offset = int32(len(data))
+		data = append(data, e.data...)
+		indexes = append(indexes, e.idx)
+	}
+	if region != nil {
+		indexes[0].Offset = int32(len(data))
+		trailer := make([]byte, HeaderIndexEntrySize)
+		binary.BigEndian.PutUint32(trailer[0:], uint32(region.Tag))
+		binary.BigEndian.PutUint32(trailer[4:], uint32(regionTagType))
+		binary.BigEndian.PutUint32(trailer[8:], uint32(-int32(len(indexes)*HeaderIndexEntrySize)))
+		binary.BigEndian.PutUint32(trailer[12:], regionTagCount)
+		data = append(data, trailer...)
+	}
+	h.indexes = indexes
+	h.data = data
+}
+
+// MarshalBinary encodes header the way it's stored in package,
+// headers read from package are encoded byte for byte the same
+func (h Header) MarshalBinary() ([]byte, error) {
+	if len(h.indexes) > 0xffffffff/HeaderIndexEntrySize {
+		return nil, fmt.Errorf("%w: %d index entries", ErrOutOfRange, len(h.indexes))
+	}
+	d := make([]byte, headerInfoSize, headerInfoSize+len(h.indexes)*HeaderIndexEntrySize+len(h.data))
+	binary.BigEndian.PutUint32(d[0:], HeaderMagic)
+	binary.BigEndian.PutUint32(d[8:], uint32(len(h.indexes)))
+	binary.BigEndian.PutUint32(d[12:], uint32(len(h.data)))
+	for _, idx := range h.indexes {
+		var e [HeaderIndexEntrySize]byte
+		binary.BigEndian.PutUint32(e[0:], uint32(idx.Tag))
+		binary.BigEndian.PutUint32(e[4:], uint32(idx.DataType))
+		binary.BigEndian.PutUint32(e[8:], uint32(idx.Offset))
+		binary.BigEndian.PutUint32(e[12:], uint32(idx.Count))
+		d = append(d, e[:]...)
+	}
+	return append(d, h.data...), nil
+}
+
+// WriteHeader writes encoded header to w
+func WriteHeader(w io.Writer, h *Header) error {
+	d, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
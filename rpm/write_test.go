@@ -0,0 +1,80 @@
+package rpm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	h := NewHeader(TagHeaderImmutable)
+	h.SetString(TagName, "hello")
+	h.SetString(TagVersion, "1.0")
+	h.SetInt32s(TagSize, []int32{42})
+	// tag lower than the ones already set goes at the end of index
+	// unless index is sorted
+	h.SetStrings(TagHeaderI18NTable, []string{"C", "de"})
+	h.SetI18NStrings(TagSummary, []string{"greeting", "Gruß"})
+
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadHeader(bytes.NewReader(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.indexes[0].Tag != TagHeaderImmutable {
+		t.Errorf("first index entry is %d, expected region", got.indexes[0].Tag)
+	}
+	for i := 2; i < len(got.indexes); i++ {
+		if got.indexes[i-1].Tag >= got.indexes[i].Tag {
+			t.Errorf("index not sorted: %d before %d", got.indexes[i-1].Tag, got.indexes[i].Tag)
+		}
+	}
+
+	for tag, expected := range map[HeaderTag]string{TagName: "hello", TagVersion: "1.0"} {
+		if s, err := got.GetString(tag); err != nil || s != expected {
+			t.Errorf("tag %d: got %q, %v, expected %q", tag, s, err, expected)
+		}
+	}
+	if s, err := got.GetI18NString(TagSummary, "de_DE.UTF-8"); err != nil || s != "Gruß" {
+		t.Errorf("localized summary: got %q, %v", s, err)
+	}
+	if locales, err := got.GetStrings(TagHeaderI18NTable); err != nil || len(locales) != 2 {
+		t.Errorf("i18n table: got %v, %v", locales, err)
+	}
+	if n, err := got.GetInt(TagSize); err != nil || n != 42 {
+		t.Errorf("size: got %d, %v", n, err)
+	}
+
+	again, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, again) {
+		t.Error("header read back encodes differently")
+	}
+}
+
+func TestHeaderDeleteTag(t *testing.T) {
+	h := NewHeader(TagHeaderImmutable)
+	h.SetString(TagName, "hello")
+	h.SetString(TagVersion, "1.0")
+	h.DeleteTag(TagName)
+
+	d, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadHeader(bytes.NewReader(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.GetString(TagName); err == nil {
+		t.Error("deleted tag still present")
+	}
+	if s, _ := got.GetString(TagVersion); s != "1.0" {
+		t.Errorf("version: got %q", s)
+	}
+}